@@ -2,6 +2,11 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"database/sql"
+	"encoding/hex"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,91 +15,270 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/redis/go-redis/v9"
+
 	"xiaowo/backend/internal/api/v1"
+	"xiaowo/backend/internal/broker"
+	"xiaowo/backend/internal/config"
+	"xiaowo/backend/internal/media"
+	"xiaowo/backend/internal/metrics"
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/proxy"
 	"xiaowo/backend/internal/repository"
 	"xiaowo/backend/internal/service"
+	"xiaowo/backend/internal/syncplay"
+	"xiaowo/backend/internal/token"
+	"xiaowo/backend/internal/webrtc"
+	"xiaowo/backend/internal/websocket"
 	"xiaowo/backend/pkg/database"
 )
 
 func main() {
+	configPath := flag.String("config", os.Getenv("XIAOWO_CONFIG"), "配置文件路径（YAML），为空时只使用默认值和环境变量")
+	flag.Parse()
+
 	fmt.Println("=== Xiaowo Backend Starting ===")
 	
 	// 1. 初始化配置
-	config, err := loadConfig()
+	conf, err := loadConfig(*configPath)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
-	fmt.Printf("✓ Config loaded: %+v\n", config)
+	fmt.Printf("✓ Config loaded: server=%+v database=%+v\n", conf.Server, conf.Database)
 	
 	// 2. 初始化数据库
-	err = database.Init(config.Database.Path)
+	err = database.Init(database.Options{
+		Path:            conf.Database.Path,
+		MaxOpenConns:    conf.Database.MaxOpenConns,
+		MaxIdleConns:    conf.Database.MaxIdleConns,
+		ConnMaxLifetime: conf.Database.ConnMaxLifetime,
+	})
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	fmt.Println("✓ Database initialized")
 	
+	if err := repository.MigrateDatabase(database.DB); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+	
+	if err := metrics.RegisterDBStats(func() (*sql.DBStats, error) {
+		return repository.GetConnectionStats(database.DB)
+	}); err != nil {
+		log.Fatalf("Failed to register database metrics: %v", err)
+	}
+	
 	// 3. 初始化Repository层
 	roomRepo := repository.NewRoomRepo(database.DB)
 	memberRepo := repository.NewRoomMemberRepo(database.DB)
 	sessionRepo := repository.NewSessionRepo(database.DB)
+	messageRepo := repository.NewMessageRepository(database.DB)
+	playlistRepo := repository.NewPlaylistRepo(database.DB)
+	subtitleRepo := repository.NewSubtitleRepo(database.DB)
+	danmakuRepo := repository.NewDanmakuRepo(database.DB)
+	inviteRepo := repository.NewInviteRepo(database.DB)
+	tokenRepo := repository.NewTokenRepo(database.DB)
 	fmt.Println("✓ Repository layer initialized")
 	
 	// 4. 初始化Service层
 	roomService := service.NewRoomService(roomRepo, memberRepo)
+	roomService.SetLimits(service.RoomLimits{
+		DefaultMaxUsers: conf.Room.DefaultMaxUsers,
+		MaxUsers:        conf.Room.MaxUsers,
+	})
 	memberService := service.NewMemberService(memberRepo, roomRepo)
 	sessionService := service.NewSessionService(sessionRepo)
+	messageService := service.NewMessageService(messageRepo, memberRepo)
+	playlistService := service.NewPlaylistService(playlistRepo, roomRepo)
+	if conf.Media.ProbeEnabled {
+		// 探测地址由用户提交，与媒体代理一样默认拒绝内网和回环地址
+		prober := media.NewProber(media.Options{
+			Client:   proxy.NewClient(conf.Media.ProbeTimeout, conf.Media.ProxyAllowPrivate),
+			Timeout:  conf.Media.ProbeTimeout,
+			CacheTTL: conf.Media.ProbeCacheTTL,
+		})
+		roomService.ProbeWith(prober)
+		playlistService.ProbeWith(prober)
+	}
+	subtitleClient := proxy.NewClient(conf.Media.SubtitleTimeout, conf.Media.ProxyAllowPrivate)
+	subtitleClient.Timeout = conf.Media.SubtitleTimeout
+	subtitleService := service.NewSubtitleService(subtitleRepo, roomRepo, service.SubtitleOptions{
+		Client:  subtitleClient,
+		MaxSize: conf.Media.SubtitleMaxSize,
+	})
+	danmakuService := service.NewDanmakuService(danmakuRepo, roomRepo, memberRepo, service.DanmakuOptions{
+		RateLimit:  conf.Room.DanmakuRateLimit,
+		RateWindow: conf.Room.DanmakuRateWindow,
+	})
+	inviteService := service.NewInviteService(inviteRepo, roomRepo)
+	janitor := service.NewJanitor(roomRepo, sessionRepo, messageRepo, service.JanitorOptions{
+		Interval:         conf.Janitor.Interval,
+		RoomGracePeriod:  conf.Janitor.RoomGracePeriod,
+		RoomRetention:    conf.Janitor.RoomRetention,
+		MessageRetention: conf.Janitor.MessageRetention,
+	})
+	presenceService := service.NewPresenceService(sessionRepo, memberRepo, roomRepo, service.PresenceOptions{
+		FlushInterval:  conf.Room.PresenceFlush,
+		OfflineTimeout: conf.Room.OfflineTimeout,
+	})
+	tokenManager, err := token.NewManager(conf.JWT.Secret, conf.JWT.TTL)
+	if err != nil {
+		log.Fatalf("Failed to initialize token manager: %v", err)
+	}
+	// 吊销记录保存在数据库中，重启后仍然有效，共用数据库的实例之间互相可见
+	tokenManager.PersistWith(tokenRepo)
 	fmt.Println("✓ Service layer initialized")
 	
 	// 5. 初始化API Handler
-	roomHandler := v1.NewRoomHandler(roomService, memberService)
+	roomHandler := v1.NewRoomHandler(roomService, memberService, sessionService, presenceService, tokenManager, conf.Frontend)
 	sessionHandler := v1.NewSessionHandler(sessionService)
+	tokenHandler := v1.NewTokenHandler(tokenManager, memberService)
+	messageHandler := v1.NewMessageHandler(messageService)
+	memberHandler := v1.NewMemberHandler(roomService, memberService, tokenManager)
+	playlistHandler := v1.NewPlaylistHandler(roomService, playlistService)
+	var mediaHandler *v1.MediaHandler
+	if conf.Media.ProxyEnabled {
+		mediaProxy, err := proxy.New(proxy.Options{
+			Secret:       conf.JWT.Secret,
+			TargetTTL:    conf.Media.ProxyTargetTTL,
+			Timeout:      conf.Media.ProxyTimeout,
+			AllowPrivate: conf.Media.ProxyAllowPrivate,
+			CacheDir:     conf.Media.ProxyCacheDir,
+			SliceSize:    conf.Media.ProxySliceSize,
+			CacheMaxSize: conf.Media.ProxyCacheMaxSize,
+		})
+		if err != nil {
+			log.Fatalf("Failed to initialize media proxy: %v", err)
+		}
+		mediaHandler = v1.NewMediaHandler(roomService, playlistService, tokenManager, mediaProxy)
+	}
+	subtitleHandler := v1.NewSubtitleHandler(roomService, subtitleService)
+	danmakuHandler := v1.NewDanmakuHandler(danmakuService)
+	inviteHandler := v1.NewInviteHandler(roomService, memberService, sessionService, inviteService, tokenManager, conf.Frontend)
+	adminHandler := v1.NewAdminHandler(janitor, conf.Admin.Token)
 	healthHandler := v1.NewHealthHandler()
 	versionHandler := v1.NewVersionHandler()
 	fmt.Println("✓ API Handlers initialized")
 	
 	// 6. 初始化WebSocket Hub
-	// TODO: 需要实现WebSocketHub结构
-	// wsHub := websocket.NewWebSocketHub()
+	roomBroker, closeBroker, err := newBroker(conf.Broker)
+	if err != nil {
+		log.Fatalf("Failed to initialize broker: %v", err)
+	}
+	fmt.Printf("✓ Broker initialized (%s)\n", conf.Broker.Driver)
+	
+	wsAuth := v1.NewRoomAuthenticator(tokenManager, sessionService, memberService)
+	iceServers := webrtc.NewICE(webrtc.Options{
+		STUNURLs:   conf.WebRTC.STUNURLs,
+		TURNURLs:   conf.WebRTC.TURNURLs,
+		TURNSecret: conf.WebRTC.TURNSecret,
+		TURNTTL:    conf.WebRTC.TURNTTL,
+	})
+	wsHub := websocket.NewWebSocketHub(websocket.HubOptions{
+		Authenticator: wsAuth,
+		Playback:      roomService,
+		Chat:          messageService,
+		Permissions:   memberService,
+		Broker:        roomBroker,
+		BufferingTimeout: conf.Room.BufferingTimeout,
+		Playlist:      playlistService,
+		Subtitles:     subtitleService,
+		Danmaku:       danmakuService,
+		ICEServers:    iceServers.Servers,
+		ResumeWindow:  conf.Room.ResumeWindow,
+		Presence:      presenceService,
+	})
+	roomService.OnPlaybackChange(wsHub.PublishPlayback)
+	memberService.OnMemberChange(wsHub.PublishMemberEvent)
+	roomService.OnSettingsChange(wsHub.PublishSettings)
+	roomService.OnRoomClosed(wsHub.EvictRoom)
+	roomService.ScheduleWith(wsHub.ScheduleLead)
+	playlistService.OnPlaybackChange(wsHub.PublishPlayback)
+	playlistService.OnPlaylistChange(wsHub.PublishPlaylist)
+	playlistService.ScheduleWith(wsHub.ScheduleLead)
+	subtitleService.OnSubtitleChange(wsHub.PublishSubtitles)
+	presenceService.OnMemberChange(func(event string, member *model.RoomMember) {
+		// 离线超时被移出房间的会话，其访问令牌随之失效
+		if err := tokenManager.RevokeSession(member.RoomID, member.SessionID); err != nil {
+			log.Printf("Failed to revoke tokens of %s: %v", member.SessionID, err)
+		}
+		wsHub.PublishMemberEvent(event, member)
+	})
+	presenceService.Start()
+	wsHub.StartPeriodicTasks()
 	fmt.Println("✓ WebSocket Hub initialized")
 	
+	// 后台清理任务（空闲房间、过期会话、历史聊天记录）
+	janitor.TrackLiveRooms(wsHub.ActiveRoomIDs)
+	janitor.OnRoomClosed(wsHub.EvictRoom)
+	janitor.PruneWith("password_attempts", roomService.PrunePasswordAttempts)
+	janitor.PruneWith("token_revocations", tokenManager.Prune)
+	if conf.Janitor.Enabled {
+		janitor.Start()
+		fmt.Printf("✓ Janitor started (interval %s)\n", conf.Janitor.Interval)
+	}
+	
 	// 7. 设置路由
-	router := v1.SetupRouter(roomHandler, sessionHandler, healthHandler, versionHandler)
-	wsRouter := v1.SetupWebSocketRouter(nil) // 暂时传入nil
+	router := v1.SetupRouter(roomHandler, sessionHandler, tokenHandler, messageHandler, memberHandler, playlistHandler, mediaHandler, subtitleHandler, danmakuHandler, inviteHandler, adminHandler, healthHandler, versionHandler, tokenManager, conf.CORS.AllowOrigins, conf.Server.TrustedProxies)
+	wsRouter := v1.SetupWebSocketRouter(wsHub, wsAuth, conf.CORS.AllowOrigins)
 	
 	// 8. 创建HTTP服务器
 	server := &http.Server{
-		Addr:         config.Server.Port,
+		Addr:         conf.Server.Addr(),
 		Handler:      router,
-		ReadTimeout:  config.Server.ReadTimeout,
-		WriteTimeout: config.Server.WriteTimeout,
-		IdleTimeout:  config.Server.IdleTimeout,
+		ReadTimeout:  conf.Server.ReadTimeout,
+		WriteTimeout: conf.Server.WriteTimeout,
+		IdleTimeout:  conf.Server.IdleTimeout,
 	}
 	
 	wsServer := &http.Server{
-		Addr:         config.Server.WSPort,
+		Addr:         conf.Server.WSAddr(),
 		Handler:      wsRouter,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  120 * time.Second,
+		ReadTimeout:  conf.Server.WSReadTimeout,
+		WriteTimeout: conf.Server.WSWriteTimeout,
+		IdleTimeout:  conf.Server.WSIdleTimeout,
 	}
 	
 	// 9. 启动服务器（在goroutine中）
 	go func() {
-		log.Printf("HTTP server starting on %s", config.Server.Port)
+		log.Printf("HTTP server starting on %s", server.Addr)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("HTTP server failed to start: %v", err)
 		}
 	}()
 	
 	go func() {
-		log.Printf("WebSocket server starting on %s", config.Server.WSPort)
+		log.Printf("WebSocket server starting on %s", wsServer.Addr)
 		if err := wsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("WebSocket server failed to start: %v", err)
 		}
 	}()
 	
-	fmt.Printf("✓ HTTP server running on %s\n", config.Server.Port)
-	fmt.Printf("✓ WebSocket server running on %s\n", config.Server.WSPort)
+	// Syncplay 协议服务（桌面播放器经 Syncplay 客户端加入房间）
+	var syncplayServer *syncplay.Server
+	if conf.Syncplay.Enabled {
+		syncplayServer, err = newSyncplayServer(conf.Syncplay, syncplay.Options{
+			Hub:           wsHub,
+			Members:       memberService,
+			Authenticator: v1.NewSyncplayAuthenticator(roomService, memberService, sessionService, tokenManager),
+			MOTD:          conf.Syncplay.MOTD,
+		})
+		if err != nil {
+			log.Fatalf("Failed to initialize syncplay server: %v", err)
+		}
+		go func() {
+			log.Printf("Syncplay server starting on %s", conf.Syncplay.Addr)
+			if err := syncplayServer.ListenAndServe(conf.Syncplay.Addr); err != nil && err != syncplay.ErrServerClosed {
+				log.Fatalf("Syncplay server failed to start: %v", err)
+			}
+		}()
+	}
+	
+	fmt.Printf("✓ HTTP server running on %s\n", server.Addr)
+	fmt.Printf("✓ WebSocket server running on %s\n", wsServer.Addr)
+	if syncplayServer != nil {
+		fmt.Printf("✓ Syncplay server running on %s\n", conf.Syncplay.Addr)
+	}
 	fmt.Println("=== Xiaowo Backend Started Successfully ===")
 	
 	// 10. 优雅关闭
@@ -112,6 +296,18 @@ func main() {
 		log.Printf("WebSocket server forced to shutdown: %v", err)
 	}
 	
+	// Syncplay 连接同样接入 Hub，需在 Hub 之前关闭
+	if syncplayServer != nil {
+		syncplayServer.Close()
+	}
+	
+	// 关闭WebSocket Hub（已升级的连接不受 Server.Shutdown 管理）
+	wsHub.Shutdown()
+	// 写入 Hub 关闭时各连接的下线状态
+	presenceService.Stop()
+	closeBroker()
+	janitor.Stop()
+	
 	// 关闭HTTP服务器
 	if err := server.Shutdown(ctx); err != nil {
 		log.Printf("HTTP server forced to shutdown: %v", err)
@@ -120,45 +316,64 @@ func main() {
 	log.Println("Servers shutdown complete")
 }
 
-// Config 配置结构
-type Config struct {
-	Server struct {
-		Port        string
-		WSPort      string
-		ReadTimeout time.Duration
-		WriteTimeout time.Duration
-		IdleTimeout time.Duration
-	} `mapstructure:"server"`
-	Database struct {
-		Path string `mapstructure:"path"`
-	} `mapstructure:"database"`
+// newBroker 创建跨实例房间事件 Broker，返回的函数用于关闭 Broker 及其 Redis 连接
+func newBroker(conf config.BrokerConfig) (broker.Broker, func(), error) {
+	if conf.Driver != "redis" {
+		b := broker.NewMemory()
+		return b, func() { b.Close() }, nil
+	}
+	
+	opts, err := redis.ParseURL(conf.RedisURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse redis url: %w", err)
+	}
+	client := redis.NewClient(opts)
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, nil, fmt.Errorf("connect redis: %w", err)
+	}
+	
+	b := broker.NewRedis(client, conf.ChannelPrefix)
+	return b, func() {
+		b.Close()
+		client.Close()
+	}, nil
 }
 
-// loadConfig 加载配置
-func loadConfig() (*Config, error) {
-	config := &Config{
-		Server: struct {
-			Port        string
-			WSPort      string
-			ReadTimeout time.Duration
-			WriteTimeout time.Duration
-			IdleTimeout time.Duration
-		}{
-			Port:        ":8080",
-			WSPort:      ":8081",
-			ReadTimeout: 10 * time.Second,
-			WriteTimeout: 10 * time.Second,
-			IdleTimeout: 60 * time.Second,
-		},
-		Database: struct {
-			Path string `mapstructure:"path"`
-		}{
-			Path: "xiaowo.db",
-		},
-	}
-	
-	// TODO: 从配置文件加载实际配置
-	// 暂时使用默认值
-	
-	return config, nil
+// newSyncplayServer 创建 Syncplay 服务，配置了证书时允许客户端协商 TLS
+func newSyncplayServer(conf config.SyncplayConfig, opts syncplay.Options) (*syncplay.Server, error) {
+	if conf.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(conf.TLSCert, conf.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("load syncplay certificate: %w", err)
+		}
+		opts.TLS = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+	return syncplay.New(opts), nil
+}
+
+// loadConfig 加载配置文件和环境变量
+func loadConfig(path string) (*config.Config, error) {
+	conf, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	
+	// 令牌签名密钥，未配置时使用随机密钥（重启后已签发令牌全部失效）
+	if conf.JWT.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate token secret: %w", err)
+		}
+		conf.JWT.Secret = hex.EncodeToString(secret)
+		log.Println("JWT secret not configured, using a random token secret")
+	}
+	
+	return conf, nil
 }
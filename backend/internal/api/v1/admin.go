@@ -0,0 +1,38 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"xiaowo/backend/internal/service"
+)
+
+// AdminHandler 运维管理相关API处理器
+type AdminHandler struct {
+	janitor *service.Janitor
+	token   string
+}
+
+// NewAdminHandler 创建管理处理器，token 为空时管理接口不开放
+func NewAdminHandler(janitor *service.Janitor, token string) *AdminHandler {
+	return &AdminHandler{
+		janitor: janitor,
+		token:   token,
+	}
+}
+
+// GetJanitorStats 获取后台清理任务状态
+// @Summary 后台清理任务状态
+// @Description 获取最近一次清理任务的执行结果
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer 管理令牌"
+// @Success 200 {object} JanitorStatsResponse
+// @Router /api/v1/admin/janitor [get]
+func (h *AdminHandler) GetJanitorStats(c *gin.Context) {
+	lastRun, runs := h.janitor.LastRun()
+	c.JSON(http.StatusOK, JanitorStatsResponse{
+		Runs:    runs,
+		LastRun: lastRun,
+	})
+}
@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"xiaowo/backend/internal/model"
+)
+
+// 测试后台清理任务：停用空闲房间、保留在线房间、删除长期停用房间并通知 Hub
+func TestJanitor_RoomLifecycle(t *testing.T) {
+	ts := newTestServer(t)
+	idle := ts.createRoom(t)
+	live := ts.createRoom(t)
+	stale := ts.createRoom(t)
+
+	liveConn, _, err := ts.dialRoom(live.Room.ID, live.Token)
+	if err != nil {
+		t.Fatalf("连接房间失败: %v", err)
+	}
+	defer liveConn.Close()
+	readUntil(t, liveConn, "room_state")
+
+	longAgo := time.Now().Add(-time.Hour)
+	ts.db.Model(&model.Room{}).Where("id IN ?", []string{idle.Room.ID, live.Room.ID}).
+		Updates(map[string]interface{}{"last_active_at": longAgo})
+	ts.db.Model(&model.Room{}).Where("id = ?", stale.Room.ID).
+		Updates(map[string]interface{}{"status": model.RoomStatusInactive, "last_active_at": time.Now().Add(-8 * 24 * time.Hour)})
+	ts.db.Create(&model.Message{ID: "old-message", RoomID: live.Room.ID, SessionID: live.SessionID,
+		MessageType: model.MessageTypeChat, Content: "很久以前", CreatedAt: time.Now().Add(-31 * 24 * time.Hour)})
+
+	stats := ts.janitor.RunOnce()
+	if len(stats.Errors) > 0 {
+		t.Fatalf("清理任务出错: %v", stats.Errors)
+	}
+
+	t.Run("TestIdleRoomDeactivated", func(t *testing.T) {
+		room, err := ts.rooms.GetRoom(idle.Room.ID)
+		if err != nil || room.Status != model.RoomStatusInactive {
+			t.Errorf("无人在线的空闲房间应被停用: %+v %v", room, err)
+		}
+		if stats.RoomsDeactivated != 1 {
+			t.Errorf("期望停用房间数: 1, 实际: %d", stats.RoomsDeactivated)
+		}
+	})
+
+	t.Run("TestLiveRoomKept", func(t *testing.T) {
+		room, err := ts.rooms.GetRoom(live.Room.ID)
+		if err != nil || room.Status != model.RoomStatusActive || room.LastActiveAt.Before(longAgo.Add(time.Minute)) {
+			t.Errorf("有在线连接的房间应保持活跃并刷新活跃时间: %+v %v", room, err)
+		}
+	})
+
+	t.Run("TestStaleRoomPurged", func(t *testing.T) {
+		if _, err := ts.rooms.GetRoom(stale.Room.ID); err == nil {
+			t.Error("长期停用的房间应被删除")
+		}
+		if count, _ := ts.members.GetMemberCount(stale.Room.ID); count != 0 {
+			t.Errorf("被删除房间的成员记录应一并清理，剩余: %d", count)
+		}
+	})
+
+	t.Run("TestOldMessagesPruned", func(t *testing.T) {
+		if stats.MessagesPruned != 1 {
+			t.Errorf("期望清理聊天记录数: 1, 实际: %d", stats.MessagesPruned)
+		}
+	})
+
+	t.Run("TestStatsEndpoint", func(t *testing.T) {
+		var resp JanitorStatsResponse
+		if status := ts.doJSON(t, http.MethodGet, "/api/v1/admin/janitor", testAdminToken, nil, &resp); status != http.StatusOK {
+			t.Fatalf("获取清理状态失败，状态码: %d", status)
+		}
+		if resp.Runs != 1 || resp.LastRun == nil || resp.LastRun.RoomsPurged != 1 {
+			t.Errorf("清理状态不正确: %+v", resp)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/service"
+)
+
+// defaultDanmakuSpan 未指定窗口终点时查询的时长（秒）
+const defaultDanmakuSpan = 60
+
+// DanmakuHandler 弹幕相关API处理器
+type DanmakuHandler struct {
+	danmakuService *service.DanmakuService
+}
+
+// NewDanmakuHandler 创建弹幕处理器
+func NewDanmakuHandler(danmakuService *service.DanmakuService) *DanmakuHandler {
+	return &DanmakuHandler{
+		danmakuService: danmakuService,
+	}
+}
+
+// ListDanmaku 按时间窗口获取弹幕
+// @Summary 按时间窗口获取弹幕
+// @Description 获取媒体在 [from, to) 时间段内的弹幕和表情回应，用于回放；未指定媒体时取房间当前播放的媒体
+// @Tags danmaku
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Param media_url query string false "媒体地址"
+// @Param from query number false "窗口起点（秒）" default(0)
+// @Param to query number false "窗口终点（秒），默认为起点后 60 秒"
+// @Param limit query int false "最多返回条数" default(500)
+// @Success 200 {object} DanmakuListResponse
+// @Router /api/v1/rooms/{room_id}/danmaku [get]
+func (h *DanmakuHandler) ListDanmaku(c *gin.Context) {
+	from, to, err := parseDanmakuWindow(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "无效的请求参数",
+			"detail": err.Error(),
+		})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	danmaku, mediaURL, err := h.danmakuService.ListWindow(c.Param("room_id"), c.Query("media_url"), from, to, limit)
+	if err != nil {
+		c.JSON(danmakuErrorStatus(err), gin.H{
+			"error":  "获取弹幕失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, &DanmakuListResponse{
+		MediaURL: mediaURL,
+		From:     from,
+		To:       to,
+		Danmaku:  danmaku,
+	})
+}
+
+// ExportDanmaku 导出弹幕
+// @Summary 导出弹幕
+// @Description 以 Bilibili XML 弹幕格式导出媒体的全部弹幕（不含表情回应），可直接用于 synctv 等播放器；未指定媒体时取房间当前播放的媒体
+// @Tags danmaku
+// @Produce xml
+// @Param room_id path string true "房间ID"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Param media_url query string false "媒体地址"
+// @Success 200 {string} string "Bilibili XML 弹幕"
+// @Router /api/v1/rooms/{room_id}/danmaku/export [get]
+func (h *DanmakuHandler) ExportDanmaku(c *gin.Context) {
+	roomID := c.Param("room_id")
+	data, err := h.danmakuService.ExportXML(roomID, c.Query("media_url"))
+	if err != nil {
+		c.JSON(danmakuErrorStatus(err), gin.H{
+			"error":  "导出弹幕失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="danmaku-%s.xml"`, roomID))
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", data)
+}
+
+// parseDanmakuWindow 解析时间窗口参数
+func parseDanmakuWindow(c *gin.Context) (float64, float64, error) {
+	var from, to float64
+	var err error
+	if raw := c.Query("from"); raw != "" {
+		if from, err = strconv.ParseFloat(raw, 64); err != nil {
+			return 0, 0, fmt.Errorf("from: %w", err)
+		}
+	}
+	to = from + defaultDanmakuSpan
+	if raw := c.Query("to"); raw != "" {
+		if to, err = strconv.ParseFloat(raw, 64); err != nil {
+			return 0, 0, fmt.Errorf("to: %w", err)
+		}
+	}
+	return from, to, nil
+}
+
+// danmakuErrorStatus 弹幕错误对应的HTTP状态码
+func danmakuErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, model.ErrRoomNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, model.ErrInvalidDanmaku):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,135 @@
+package v1
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"xiaowo/backend/internal/model"
+)
+
+// 测试弹幕和表情回应的实时推送、限流、按时间窗口回放和导出
+func TestDanmaku_SendReplayAndExport(t *testing.T) {
+	ts := newTestServer(t)
+	created := ts.createRoom(t)
+	roomID := created.Room.ID
+	joined := ts.joinRoom(t, roomID, "弹幕观众")
+
+	host, _, err := ts.dialRoom(roomID, created.Token)
+	if err != nil {
+		t.Fatalf("房主连接失败: %v", err)
+	}
+	defer host.Close()
+	readUntil(t, host, "room_state")
+
+	viewer, _, err := ts.dialRoom(roomID, joined.Token)
+	if err != nil {
+		t.Fatalf("观众连接失败: %v", err)
+	}
+	defer viewer.Close()
+	readUntil(t, viewer, "room_state")
+
+	t.Run("TestBroadcast", func(t *testing.T) {
+		host.WriteJSON(map[string]interface{}{"type": "danmaku", "content": " 前方\n高能 ", "time": 12.5, "mode": 5, "color": 0xFF0000})
+		msg := readUntil(t, viewer, "danmaku")
+		if msg["content"] != "前方 高能" || msg["time"].(float64) != 12.5 || msg["mode"].(float64) != 5 || msg["color"].(float64) != 0xFF0000 {
+			t.Errorf("弹幕内容不正确: %v", msg)
+		}
+		if msg["media_url"] != "https://example.com/video.mp4" || msg["session_id"] != created.SessionID || msg["display_name"] == "" {
+			t.Errorf("弹幕应带上媒体地址和发送者: %v", msg)
+		}
+
+		viewer.WriteJSON(map[string]interface{}{"type": "reaction", "content": "🔥", "time": 13})
+		if msg := readUntil(t, host, "reaction"); msg["content"] != "🔥" || msg["display_name"] != "弹幕观众" {
+			t.Errorf("表情回应不正确: %v", msg)
+		}
+	})
+
+	t.Run("TestInvalid", func(t *testing.T) {
+		viewer.WriteJSON(map[string]interface{}{"type": "reaction", "content": "🍕"})
+		if msg := readUntil(t, viewer, "error"); msg["code"] != "danmaku_failed" {
+			t.Errorf("不支持的表情应被拒绝: %v", msg)
+		}
+		viewer.WriteJSON(map[string]interface{}{"type": "danmaku", "content": strings.Repeat("长", 101)})
+		if msg := readUntil(t, viewer, "error"); msg["code"] != "danmaku_failed" {
+			t.Errorf("超长弹幕应被拒绝: %v", msg)
+		}
+	})
+
+	t.Run("TestRateLimit", func(t *testing.T) {
+		// 测试服务限制每分钟 3 条，观众已发送 1 条表情（被拒绝的不计入）
+		viewer.WriteJSON(map[string]interface{}{"type": "danmaku", "content": "第二条", "time": 42})
+		readUntil(t, host, "danmaku")
+		viewer.WriteJSON(map[string]interface{}{"type": "danmaku", "content": "第三条", "time": 43})
+		readUntil(t, host, "danmaku")
+
+		viewer.WriteJSON(map[string]interface{}{"type": "danmaku", "content": "第四条", "time": 44})
+		if msg := readUntil(t, viewer, "error"); msg["code"] != "danmaku_rate_limited" {
+			t.Errorf("超过频率限制应被拒绝: %v", msg)
+		}
+
+		// 限流按会话计算，房主不受影响
+		host.WriteJSON(map[string]interface{}{"type": "danmaku", "content": "房主"})
+		if msg := readUntil(t, viewer, "danmaku"); msg["content"] != "房主" || msg["time"].(float64) != 0 {
+			t.Errorf("未指定时间点时应取当前播放位置: %v", msg)
+		}
+	})
+
+	t.Run("TestWindow", func(t *testing.T) {
+		path := "/api/v1/rooms/" + roomID + "/danmaku"
+		var list DanmakuListResponse
+		if status := ts.doJSON(t, http.MethodGet, path+"?from=10&to=40", joined.Token, nil, &list); status != http.StatusOK {
+			t.Fatalf("查询弹幕失败，状态码: %d", status)
+		}
+		if list.MediaURL != "https://example.com/video.mp4" || len(list.Danmaku) != 2 {
+			t.Fatalf("时间窗口内应有 2 条弹幕: %+v", list)
+		}
+		if list.Danmaku[0].Content != "前方 高能" || list.Danmaku[1].Kind != model.DanmakuKindReaction {
+			t.Errorf("弹幕应按时间点排序: %+v %+v", list.Danmaku[0], list.Danmaku[1])
+		}
+
+		tests := []struct {
+			name   string
+			query  string
+			status int
+			to     float64
+			count  int
+		}{
+			{"DefaultWindow", "?from=0", http.StatusOK, 60, 5},
+			{"OtherMedia", "?media_url=https://example.com/other.mp4", http.StatusOK, 60, 0},
+			{"ReversedWindow", "?from=30&to=10", http.StatusBadRequest, 0, 0},
+		}
+		for _, tt := range tests {
+			var list DanmakuListResponse
+			if status := ts.doJSON(t, http.MethodGet, path+tt.query, joined.Token, nil, &list); status != tt.status {
+				t.Errorf("%s: 期望状态码: %d, 实际: %d", tt.name, tt.status, status)
+				continue
+			}
+			if tt.status == http.StatusOK && (list.To != tt.to || len(list.Danmaku) != tt.count) {
+				t.Errorf("%s: 期望截止 %v 共 %d 条，实际: %+v", tt.name, tt.to, tt.count, list)
+			}
+		}
+	})
+
+	t.Run("TestExport", func(t *testing.T) {
+		resp, err := http.Get(ts.api.URL + "/api/v1/rooms/" + roomID + "/danmaku/export?token=" + joined.Token)
+		if err != nil {
+			t.Fatalf("导出弹幕失败: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+
+		if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/xml") {
+			t.Fatalf("导出响应不正确: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		xml := string(body)
+		if !strings.Contains(xml, "<chatserver>chat.bilibili.com</chatserver>") ||
+			!strings.Contains(xml, `<d p="12.50000,5,25,16711680,`) || !strings.Contains(xml, ">前方 高能</d>") {
+			t.Errorf("导出的 Bilibili XML 不正确:\n%s", xml)
+		}
+		if strings.Count(xml, "<d p=") != 4 || strings.Contains(xml, "🔥") {
+			t.Errorf("导出应只包含弹幕，不含表情回应:\n%s", xml)
+		}
+	})
+}
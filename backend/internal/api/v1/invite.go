@@ -0,0 +1,292 @@
+package v1
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"xiaowo/backend/internal/config"
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/service"
+	"xiaowo/backend/internal/token"
+)
+
+// InviteHandler 房间邀请相关API处理器
+type InviteHandler struct {
+	roomService    *service.RoomService
+	memberService  *service.MemberService
+	sessionService *service.SessionService
+	inviteService  *service.InviteService
+	tokens         *token.Manager
+	frontend       config.FrontendConfig
+}
+
+// NewInviteHandler 创建邀请处理器
+func NewInviteHandler(roomService *service.RoomService, memberService *service.MemberService, sessionService *service.SessionService, inviteService *service.InviteService, tokens *token.Manager, frontend config.FrontendConfig) *InviteHandler {
+	return &InviteHandler{
+		roomService:    roomService,
+		memberService:  memberService,
+		sessionService: sessionService,
+		inviteService:  inviteService,
+		tokens:         tokens,
+		frontend:       frontend,
+	}
+}
+
+// CreateInvite 创建邀请
+// @Summary 创建邀请
+// @Description 房主创建邀请码，可设置有效期、最多使用次数和加入后的角色；邀请链接只包含邀请码
+// @Tags invites
+// @Accept json
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Param request body CreateInviteRequest true "创建邀请请求"
+// @Success 201 {object} InviteResponse
+// @Router /api/v1/rooms/{room_id}/invites [post]
+func (h *InviteHandler) CreateInvite(c *gin.Context) {
+	roomID := c.Param("room_id")
+	sessionID := getClaims(c).SessionID
+	if !authorizeRoomAction(c, h.roomService, roomID, sessionID, service.ActionManageRoom) {
+		return
+	}
+
+	var req CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "无效的请求参数",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	invite, err := h.inviteService.CreateInvite(roomID, sessionID, &service.CreateInviteRequest{
+		Role:    req.Role,
+		MaxUses: req.MaxUses,
+		TTL:     time.Duration(req.ExpiresIn) * time.Second,
+	})
+	if err != nil {
+		c.JSON(inviteErrorStatus(err), gin.H{
+			"error":  "创建邀请失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, h.response(invite))
+}
+
+// ListInvites 获取邀请列表
+// @Summary 获取邀请列表
+// @Description 房主查看房间的全部邀请（包括已过期、已用完和已吊销的）及每次使用的记录
+// @Tags invites
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Success 200 {array} InviteResponse
+// @Router /api/v1/rooms/{room_id}/invites [get]
+func (h *InviteHandler) ListInvites(c *gin.Context) {
+	roomID := c.Param("room_id")
+	if !authorizeRoomAction(c, h.roomService, roomID, getClaims(c).SessionID, service.ActionManageRoom) {
+		return
+	}
+
+	invites, err := h.inviteService.ListInvites(roomID)
+	if err != nil {
+		c.JSON(inviteErrorStatus(err), gin.H{
+			"error":  "获取邀请列表失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	resp := make([]*InviteResponse, 0, len(invites))
+	for _, invite := range invites {
+		resp = append(resp, h.response(invite))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RevokeInvite 吊销邀请
+// @Summary 吊销邀请
+// @Description 房主吊销邀请码，之后无法再通过该邀请加入，已加入的成员不受影响
+// @Tags invites
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param code path string true "邀请码"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/rooms/{room_id}/invites/{code} [delete]
+func (h *InviteHandler) RevokeInvite(c *gin.Context) {
+	roomID := c.Param("room_id")
+	if !authorizeRoomAction(c, h.roomService, roomID, getClaims(c).SessionID, service.ActionManageRoom) {
+		return
+	}
+
+	if err := h.inviteService.RevokeInvite(roomID, c.Param("code")); err != nil {
+		c.JSON(inviteErrorStatus(err), gin.H{
+			"error":  "吊销邀请失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Message: "成功吊销邀请",
+	})
+}
+
+// GetInvite 查看邀请
+// @Summary 查看邀请
+// @Description 使用前查看邀请对应的房间和加入后的角色，不消耗使用次数
+// @Tags invites
+// @Produce json
+// @Param code path string true "邀请码"
+// @Success 200 {object} InvitePreviewResponse
+// @Failure 410 {object} map[string]interface{} "邀请已过期、已用完或已吊销"
+// @Router /api/v1/invites/{code} [get]
+func (h *InviteHandler) GetInvite(c *gin.Context) {
+	invite, room, err := h.inviteService.GetInvite(c.Param("code"))
+	if err != nil {
+		c.JSON(inviteErrorStatus(err), gin.H{
+			"error":  "邀请不可用",
+			"detail": err.Error(),
+		})
+		return
+	}
+	memberCount, _ := h.memberService.GetMemberCount(room.ID)
+
+	c.JSON(http.StatusOK, InvitePreviewResponse{
+		Code:        invite.Code,
+		Room:        room,
+		MemberCount: memberCount,
+		Role:        invite.Role,
+		ExpiresAt:   invite.ExpiresAt,
+	})
+}
+
+// RedeemInvite 通过邀请加入房间
+// @Summary 通过邀请加入房间
+// @Description 校验邀请码后以邀请指定的角色加入房间，不需要房间密码，也不受“关闭新成员加入”限制；每次使用都会记录
+// @Tags invites
+// @Accept json
+// @Produce json
+// @Param code path string true "邀请码"
+// @Param session_id query string false "已有会话ID（不提供或已过期时创建新会话）"
+// @Param request body RedeemInviteRequest false "加入请求"
+// @Success 200 {object} JoinRoomResponse
+// @Failure 410 {object} map[string]interface{} "邀请已过期、已用完或已吊销"
+// @Router /api/v1/invites/{code} [post]
+func (h *InviteHandler) RedeemInvite(c *gin.Context) {
+	code := c.Param("code")
+
+	var req RedeemInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "无效的请求参数",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	// 先校验邀请，避免为无效的邀请创建会话
+	if _, _, err := h.inviteService.GetInvite(code); err != nil {
+		c.JSON(inviteErrorStatus(err), gin.H{
+			"error":  "邀请不可用",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	session, err := resolveSession(h.sessionService, c.Query("session_id"), req.DisplayName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "创建会话失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	displayName := req.DisplayName
+	if displayName == "" {
+		displayName = session.Nickname
+	}
+	member := &model.RoomMember{
+		SessionID: session.ID,
+		Nickname:  displayName,
+		Avatar:    session.Avatar,
+	}
+	invite, err := h.inviteService.Redeem(code, member)
+	if err != nil {
+		c.JSON(inviteErrorStatus(err), gin.H{
+			"error":  "加入房间失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+	roomID := invite.RoomID
+
+	if err := h.sessionService.JoinRoom(session.ID, roomID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "更新会话房间失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	room, err := h.roomService.GetRoom(roomID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":  "房间不存在",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	signed, claims, err := h.tokens.Issue(roomID, session.ID, member.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "生成访问令牌失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, &JoinRoomResponse{
+		Room:      room,
+		Settings:  room.GetSettings(),
+		SessionID: session.ID,
+		Role:      member.Role,
+		Token:     signed,
+		ExpiresAt: claims.ExpiresAt.Time,
+		JoinURL:   h.frontend.JoinURL(roomID),
+	})
+}
+
+// response 为邀请附上邀请链接
+func (h *InviteHandler) response(invite *model.Invite) *InviteResponse {
+	return &InviteResponse{
+		Invite: invite,
+		URL:    h.frontend.InviteURL(invite.Code),
+	}
+}
+
+// inviteErrorStatus 邀请错误对应的HTTP状态码
+func inviteErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, model.ErrInviteNotFound), errors.Is(err, model.ErrRoomNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, model.ErrInviteExpired), errors.Is(err, model.ErrInviteExhausted), errors.Is(err, model.ErrInviteRevoked):
+		return http.StatusGone
+	case errors.Is(err, model.ErrInvalidInvite):
+		return http.StatusBadRequest
+	case errors.Is(err, model.ErrAlreadyMember):
+		return http.StatusConflict
+	case errors.Is(err, model.ErrRoomFull):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,134 @@
+package v1
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"xiaowo/backend/internal/model"
+)
+
+// 测试房主创建、列出和吊销邀请，凭邀请码免密码加入并记录使用
+func TestInvite_CreateRedeemAndRevoke(t *testing.T) {
+	ts := newTestServer(t)
+
+	var created RoomResponse
+	if status := ts.postJSON(t, "/api/v1/rooms", map[string]interface{}{
+		"name":       "私密放映",
+		"is_private": true,
+		"password":   "letmein",
+		"max_users":  5,
+		"media_url":  "https://example.com/video.mp4",
+	}, &created); status != http.StatusCreated {
+		t.Fatalf("创建房间失败，状态码: %d", status)
+	}
+	invitesPath := "/api/v1/rooms/" + created.Room.ID + "/invites"
+
+	var invite InviteResponse
+	if status := ts.doJSON(t, http.MethodPost, invitesPath, created.Token, map[string]interface{}{
+		"max_uses":   2,
+		"expires_in": 3600,
+	}, &invite); status != http.StatusCreated {
+		t.Fatalf("创建邀请失败，状态码: %d", status)
+	}
+	if invite.Invite == nil || invite.Role != model.RoleMember || invite.ExpiresAt == nil ||
+		invite.URL != "http://localhost:3000/invite/"+invite.Code {
+		t.Fatalf("邀请内容不正确: %+v", invite)
+	}
+	if status := ts.doJSON(t, http.MethodPost, invitesPath, created.Token, map[string]interface{}{"role": "admin"}, nil); status != http.StatusBadRequest {
+		t.Errorf("未知角色应返回 400，实际: %d", status)
+	}
+
+	var preview InvitePreviewResponse
+	if status := ts.doJSON(t, http.MethodGet, "/api/v1/invites/"+invite.Code, "", nil, &preview); status != http.StatusOK {
+		t.Fatalf("查看邀请失败，状态码: %d", status)
+	}
+	if preview.Room == nil || preview.Room.ID != created.Room.ID || preview.MemberCount != 1 {
+		t.Errorf("邀请预览不正确: %+v", preview)
+	}
+
+	// 不需要房间密码即可加入
+	var joined JoinRoomResponse
+	if status := ts.doJSON(t, http.MethodPost, "/api/v1/invites/"+invite.Code, "", map[string]interface{}{"display_name": "受邀观众"}, &joined); status != http.StatusOK {
+		t.Fatalf("通过邀请加入失败，状态码: %d", status)
+	}
+	if joined.Role != model.RoleMember || joined.Token == "" || strings.Contains(joined.JoinURL, "token") {
+		t.Errorf("加入结果不正确: %+v", joined)
+	}
+	conn, _, err := ts.dialRoom(created.Room.ID, joined.Token)
+	if err != nil {
+		t.Fatalf("受邀成员连接失败: %v", err)
+	}
+	readUntil(t, conn, "room_state")
+	conn.Close()
+
+	if status := ts.doJSON(t, http.MethodPost, "/api/v1/invites/"+invite.Code+"?session_id="+joined.SessionID, "", nil, nil); status != http.StatusConflict {
+		t.Errorf("已是成员的会话再次使用邀请应返回 409，实际: %d", status)
+	}
+	if status := ts.doJSON(t, http.MethodPost, invitesPath, joined.Token, map[string]interface{}{}, nil); status != http.StatusForbidden {
+		t.Errorf("普通成员不能创建邀请，实际: %d", status)
+	}
+
+	t.Run("TestUseLimit", func(t *testing.T) {
+		if status := ts.doJSON(t, http.MethodPost, "/api/v1/invites/"+invite.Code, "", map[string]interface{}{"display_name": "第二位"}, nil); status != http.StatusOK {
+			t.Fatalf("通过邀请加入失败，状态码: %d", status)
+		}
+		if status := ts.doJSON(t, http.MethodPost, "/api/v1/invites/"+invite.Code, "", nil, nil); status != http.StatusGone {
+			t.Errorf("次数用完后应返回 410，实际: %d", status)
+		}
+	})
+
+	t.Run("TestHostRole", func(t *testing.T) {
+		var cohost InviteResponse
+		ts.doJSON(t, http.MethodPost, invitesPath, created.Token, map[string]interface{}{"role": "host"}, &cohost)
+		var joined JoinRoomResponse
+		if status := ts.doJSON(t, http.MethodPost, "/api/v1/invites/"+cohost.Code, "", nil, &joined); status != http.StatusOK {
+			t.Fatalf("通过邀请加入失败，状态码: %d", status)
+		}
+		if joined.Role != model.RoleHost {
+			t.Errorf("应以邀请指定的角色加入，实际: %s", joined.Role)
+		}
+	})
+
+	t.Run("TestExpired", func(t *testing.T) {
+		var expiring InviteResponse
+		ts.doJSON(t, http.MethodPost, invitesPath, created.Token, map[string]interface{}{"expires_in": 60}, &expiring)
+		ts.db.Model(&model.Invite{}).Where("code = ?", expiring.Code).Update("expires_at", time.Now().Add(-time.Minute))
+		if status := ts.doJSON(t, http.MethodGet, "/api/v1/invites/"+expiring.Code, "", nil, nil); status != http.StatusGone {
+			t.Errorf("过期的邀请应返回 410，实际: %d", status)
+		}
+	})
+
+	t.Run("TestRevoke", func(t *testing.T) {
+		var revoked InviteResponse
+		ts.doJSON(t, http.MethodPost, invitesPath, created.Token, nil, &revoked)
+		if status := ts.doJSON(t, http.MethodDelete, invitesPath+"/"+revoked.Code, joined.Token, nil, nil); status != http.StatusForbidden {
+			t.Errorf("普通成员不能吊销邀请，实际: %d", status)
+		}
+		if status := ts.doJSON(t, http.MethodDelete, invitesPath+"/"+revoked.Code, created.Token, nil, nil); status != http.StatusOK {
+			t.Fatalf("吊销邀请失败，状态码: %d", status)
+		}
+		if status := ts.doJSON(t, http.MethodPost, "/api/v1/invites/"+revoked.Code, "", nil, nil); status != http.StatusGone {
+			t.Errorf("吊销的邀请应返回 410，实际: %d", status)
+		}
+		if status := ts.doJSON(t, http.MethodDelete, invitesPath+"/NOSUCHCODE", created.Token, nil, nil); status != http.StatusNotFound {
+			t.Errorf("不存在的邀请应返回 404，实际: %d", status)
+		}
+	})
+
+	var invites []InviteResponse
+	if status := ts.doJSON(t, http.MethodGet, invitesPath, created.Token, nil, &invites); status != http.StatusOK {
+		t.Fatalf("获取邀请列表失败，状态码: %d", status)
+	}
+	var found *InviteResponse
+	for i := range invites {
+		if invites[i].Code == invite.Code {
+			found = &invites[i]
+		}
+	}
+	if len(invites) != 4 || found == nil || found.Uses != 2 || len(found.Redemptions) != 2 ||
+		found.Redemptions[0].Nickname != "受邀观众" || found.Redemptions[0].SessionID != joined.SessionID {
+		t.Errorf("邀请列表应包含使用记录: %+v", found)
+	}
+}
@@ -0,0 +1,129 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/proxy"
+	"xiaowo/backend/internal/service"
+	"xiaowo/backend/internal/token"
+)
+
+// MediaHandler 媒体代理API处理器
+type MediaHandler struct {
+	roomService     *service.RoomService
+	playlistService *service.PlaylistService
+	tokens          *token.Manager
+	proxy           *proxy.Proxy
+}
+
+// NewMediaHandler 创建媒体代理处理器
+func NewMediaHandler(roomService *service.RoomService, playlistService *service.PlaylistService, tokens *token.Manager, mediaProxy *proxy.Proxy) *MediaHandler {
+	return &MediaHandler{
+		roomService:     roomService,
+		playlistService: playlistService,
+		tokens:          tokens,
+		proxy:           mediaProxy,
+	}
+}
+
+// Proxy 代理房间媒体
+// @Summary 代理房间媒体
+// @Description 透传房间当前媒体（或指定播放列表条目），支持 Range 请求；HLS 播放列表中的地址改写为签名的代理地址
+// @Tags media
+// @Produce octet-stream
+// @Param room_id path string true "房间ID"
+// @Param token query string false "访问令牌（不带 target 时必填，也可以用 Authorization 请求头）"
+// @Param item_id query string false "播放列表条目ID，默认为当前条目"
+// @Param target query string false "播放列表改写出的签名目标地址"
+// @Success 200 {file} binary
+// @Success 206 {file} binary
+// @Router /api/v1/rooms/{room_id}/media/proxy [get]
+func (h *MediaHandler) Proxy(c *gin.Context) {
+	roomID := c.Param("room_id")
+
+	// 签名地址只由播放列表改写产生，持有者已通过房间令牌校验
+	var target string
+	if signed := c.Query("target"); signed != "" {
+		var err error
+		if target, err = h.proxy.Verify(roomID, signed); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":  "无效的代理地址",
+				"detail": err.Error(),
+			})
+			return
+		}
+	} else if _, err := h.tokens.ParseForRoom(requestToken(c), roomID); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":  "无效的访问令牌",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	room, err := h.roomService.GetRoom(roomID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "房间不存在",
+		})
+		return
+	}
+
+	if target == "" {
+		target = room.MediaURL
+		if itemID := c.Query("item_id"); itemID != "" {
+			if target, err = h.itemURL(roomID, itemID); err != nil {
+				c.JSON(playlistErrorStatus(err), gin.H{
+					"error":  "获取播放列表条目失败",
+					"detail": err.Error(),
+				})
+				return
+			}
+		}
+	}
+	if target == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "房间未设置媒体",
+		})
+		return
+	}
+
+	err = h.proxy.Serve(c.Writer, c.Request, proxy.Target{
+		RoomID:  roomID,
+		URL:     target,
+		Headers: room.GetSettings().ProxyHeaders,
+	})
+	if err != nil {
+		c.JSON(proxyErrorStatus(err), gin.H{
+			"error":  "代理媒体失败",
+			"detail": err.Error(),
+		})
+	}
+}
+
+// itemURL 获取播放列表条目的媒体地址
+func (h *MediaHandler) itemURL(roomID, itemID string) (string, error) {
+	playlist, err := h.playlistService.GetPlaylist(roomID)
+	if err != nil {
+		return "", err
+	}
+	i := playlist.IndexOf(itemID)
+	if i < 0 {
+		return "", model.ErrPlaylistItemNotFound
+	}
+	return playlist.Items[i].MediaURL, nil
+}
+
+// proxyErrorStatus 媒体代理错误对应的HTTP状态码
+func proxyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, proxy.ErrInvalidTarget):
+		return http.StatusBadRequest
+	case errors.Is(err, proxy.ErrBlockedTarget):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadGateway
+	}
+}
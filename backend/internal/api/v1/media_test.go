@@ -0,0 +1,190 @@
+package v1
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"xiaowo/backend/internal/media"
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/proxy"
+)
+
+// 测试创建房间和添加条目时探测媒体地址，补全类型、时长和标题
+func TestMedia_ProbeOnCreateAndAdd(t *testing.T) {
+	ts := newTestServer(t)
+	// 测试源站在回环地址上，与 proxy_allow_private 开启时一致
+	prober := media.NewProber(media.Options{Client: proxy.NewClient(time.Second, true), Timeout: time.Second})
+	ts.rooms.ProbeWith(prober)
+	ts.playlist.ProbeWith(prober)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/show/master.m3u8":
+			w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+			w.Write([]byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1280000,RESOLUTION=1280x720\nhd/index.m3u8\n"))
+		case "/show/hd/index.m3u8":
+			w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+			w.Write([]byte("#EXTM3U\n#EXTINF:60.0,\na.ts\n#EXTINF:30.0,\nb.ts\n#EXT-X-ENDLIST\n"))
+		case "/live.m3u8":
+			w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+			w.Write([]byte("#EXTM3U\n#EXTINF:6.0,\na.ts\n"))
+		case "/index.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer origin.Close()
+
+	var created RoomResponse
+	status := ts.postJSON(t, "/api/v1/rooms", map[string]interface{}{
+		"name":           "探测房间",
+		"media_url":      origin.URL + "/show/master.m3u8",
+		"media_duration": 10,
+	}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("创建房间失败，状态码: %d", status)
+	}
+
+	t.Run("TestCreateRoomFillsMedia", func(t *testing.T) {
+		room := created.Room
+		if room.MediaDuration != 90 || room.MediaType != "video" || room.MediaTitle != "show" {
+			t.Errorf("探测结果未写入房间: %v %v %v", room.MediaDuration, room.MediaType, room.MediaTitle)
+		}
+		var info media.Info
+		if err := json.Unmarshal([]byte(room.MediaInfo), &info); err != nil || info.Format != media.FormatHLS || len(info.Variants) != 1 {
+			t.Errorf("房间的探测结果不正确: %s", room.MediaInfo)
+		}
+
+		var playlist model.Playlist
+		ts.doJSON(t, http.MethodGet, "/api/v1/rooms/"+room.ID+"/playlist", "", nil, &playlist)
+		if current := playlist.Current(); current == nil || current.MediaDuration != 90 || current.MediaInfo != room.MediaInfo {
+			t.Errorf("第一个条目应保存探测结果: %+v", current)
+		}
+	})
+
+	t.Run("TestAddLiveItem", func(t *testing.T) {
+		var playlist model.Playlist
+		body := map[string]interface{}{"media_url": origin.URL + "/live.m3u8", "media_title": "直播", "media_duration": 100}
+		if code := ts.doJSON(t, http.MethodPost, "/api/v1/rooms/"+created.Room.ID+"/playlist", created.Token, body, &playlist); code != http.StatusCreated {
+			t.Fatalf("添加条目失败，状态码: %d", code)
+		}
+		added := playlist.Items[len(playlist.Items)-1]
+		if added.MediaType != "stream" || added.MediaDuration != 0 || added.MediaTitle != "直播" {
+			t.Errorf("直播条目应为 stream 且时长为 0，并保留客户端标题: %+v", added)
+		}
+	})
+
+	t.Run("TestRejectNotMedia", func(t *testing.T) {
+		for _, path := range []string{"/index.html", "/missing.mp4"} {
+			body := map[string]interface{}{"name": "网页", "media_url": origin.URL + path}
+			if code := ts.postJSON(t, "/api/v1/rooms", body, nil); code != http.StatusBadRequest {
+				t.Errorf("%s 期望状态码: %d, 实际: %d", path, http.StatusBadRequest, code)
+			}
+			if code := ts.doJSON(t, http.MethodPost, "/api/v1/rooms/"+created.Room.ID+"/playlist", created.Token, body, nil); code != http.StatusBadRequest {
+				t.Errorf("%s 期望状态码: %d, 实际: %d", path, http.StatusBadRequest, code)
+			}
+		}
+	})
+}
+
+// 测试媒体代理：令牌校验、播放列表改写、签名分片地址和房间请求头注入
+func TestMedia_Proxy(t *testing.T) {
+	ts := newTestServer(t)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/show/index.m3u8":
+			w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+			w.Write([]byte("#EXTM3U\n#EXTINF:6.0,\nseg0.ts\n#EXT-X-ENDLIST\n"))
+		case "/show/seg0.ts":
+			if r.Header.Get("Referer") != "https://example.com/" {
+				http.Error(w, "hotlink", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Content-Type", "video/mp2t")
+			http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer origin.Close()
+
+	var created RoomResponse
+	status := ts.postJSON(t, "/api/v1/rooms", map[string]interface{}{
+		"name":      "代理房间",
+		"media_url": origin.URL + "/show/index.m3u8",
+	}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("创建房间失败，状态码: %d", status)
+	}
+	proxyPath := "/api/v1/rooms/" + created.Room.ID + "/media/proxy"
+
+	get := func(t *testing.T, path, byteRange string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.api.URL+path, nil)
+		if byteRange != "" {
+			req.Header.Set("Range", byteRange)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("请求 %s 失败: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	t.Run("TestRequiresToken", func(t *testing.T) {
+		if code, _ := get(t, proxyPath, ""); code != http.StatusUnauthorized {
+			t.Errorf("期望状态码: %d, 实际: %d", http.StatusUnauthorized, code)
+		}
+		if code, _ := get(t, proxyPath+"?target=forged", ""); code != http.StatusForbidden {
+			t.Errorf("期望状态码: %d, 实际: %d", http.StatusForbidden, code)
+		}
+	})
+
+	var segment string
+	t.Run("TestRewritePlaylist", func(t *testing.T) {
+		code, body := get(t, proxyPath+"?token="+created.Token, "")
+		if code != http.StatusOK {
+			t.Fatalf("期望状态码: %d, 实际: %d", http.StatusOK, code)
+		}
+		for _, line := range strings.Split(body, "\n") {
+			if strings.HasPrefix(line, proxyPath+"?target=") {
+				segment = line
+			}
+		}
+		if segment == "" {
+			t.Fatalf("分片地址未改写为代理地址:\n%s", body)
+		}
+	})
+
+	t.Run("TestSegmentWithRoomHeaders", func(t *testing.T) {
+		if code, _ := get(t, segment, ""); code != http.StatusForbidden {
+			t.Errorf("未设置 Referer 时上游应拒绝，实际状态码: %d", code)
+		}
+
+		settings := map[string]interface{}{"proxy_headers": map[string]string{"referer": "https://example.com/"}}
+		if code := ts.doJSON(t, http.MethodPut, "/api/v1/rooms/"+created.Room.ID+"/settings", created.Token, settings, nil); code != http.StatusOK {
+			t.Fatalf("更新代理请求头失败，状态码: %d", code)
+		}
+		if code, body := get(t, segment, "bytes=2-5"); code != http.StatusPartialContent || body != "2345" {
+			t.Errorf("期望 206 和 2345，实际: %d %q", code, body)
+		}
+
+		// 分片签名绑定房间，不能在其他房间使用
+		other := ts.createRoom(t)
+		otherSegment := strings.Replace(segment, created.Room.ID, other.Room.ID, 1)
+		if code, _ := get(t, otherSegment, ""); code != http.StatusForbidden {
+			t.Errorf("期望状态码: %d, 实际: %d", http.StatusForbidden, code)
+		}
+	})
+
+}
@@ -0,0 +1,152 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/service"
+	"xiaowo/backend/internal/token"
+)
+
+// MemberHandler 房主管理成员相关API处理器
+type MemberHandler struct {
+	roomService   *service.RoomService
+	memberService *service.MemberService
+	tokens        *token.Manager
+}
+
+// NewMemberHandler 创建成员管理处理器
+func NewMemberHandler(roomService *service.RoomService, memberService *service.MemberService, tokens *token.Manager) *MemberHandler {
+	return &MemberHandler{
+		roomService:   roomService,
+		memberService: memberService,
+		tokens:        tokens,
+	}
+}
+
+// MuteMember 禁言成员
+// @Summary 禁言成员
+// @Description 房主禁止指定成员发送聊天消息
+// @Tags members
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param session_id path string true "成员会话ID"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Success 200 {object} model.RoomMember
+// @Router /api/v1/rooms/{room_id}/members/{session_id}/mute [post]
+func (h *MemberHandler) MuteMember(c *gin.Context) {
+	h.setMuted(c, true)
+}
+
+// UnmuteMember 解除禁言
+// @Summary 解除禁言
+// @Description 房主恢复指定成员的发言权限
+// @Tags members
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param session_id path string true "成员会话ID"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Success 200 {object} model.RoomMember
+// @Router /api/v1/rooms/{room_id}/members/{session_id}/unmute [post]
+func (h *MemberHandler) UnmuteMember(c *gin.Context) {
+	h.setMuted(c, false)
+}
+
+// KickMember 移出成员
+// @Summary 移出成员
+// @Description 房主将指定成员移出房间，其访问令牌和实时连接随即失效
+// @Tags members
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param session_id path string true "成员会话ID"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/rooms/{room_id}/members/{session_id}/kick [post]
+func (h *MemberHandler) KickMember(c *gin.Context) {
+	roomID := c.Param("room_id")
+	targetID := c.Param("session_id")
+	if !authorizeRoomAction(c, h.roomService, roomID, getClaims(c).SessionID, service.ActionManageRoom) {
+		return
+	}
+
+	if _, err := h.memberService.Kick(roomID, targetID); err != nil {
+		c.JSON(memberErrorStatus(err), gin.H{
+			"error":  "移出成员失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+	if err := h.tokens.RevokeSession(roomID, targetID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "吊销成员访问令牌失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Message: "已将成员移出房间",
+	})
+}
+
+// TransferHost 转让房主
+// @Summary 转让房主
+// @Description 房主将房主身份转让给指定成员
+// @Tags members
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param session_id path string true "新房主会话ID"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Success 200 {object} model.RoomMember
+// @Router /api/v1/rooms/{room_id}/members/{session_id}/transfer-host [post]
+func (h *MemberHandler) TransferHost(c *gin.Context) {
+	roomID := c.Param("room_id")
+	sessionID := getClaims(c).SessionID
+	if !authorizeRoomAction(c, h.roomService, roomID, sessionID, service.ActionManageRoom) {
+		return
+	}
+
+	member, err := h.memberService.TransferHost(roomID, sessionID, c.Param("session_id"))
+	if err != nil {
+		c.JSON(memberErrorStatus(err), gin.H{
+			"error":  "转让房主失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+// setMuted 禁言或解除禁言
+func (h *MemberHandler) setMuted(c *gin.Context, muted bool) {
+	roomID := c.Param("room_id")
+	if !authorizeRoomAction(c, h.roomService, roomID, getClaims(c).SessionID, service.ActionManageRoom) {
+		return
+	}
+
+	member, err := h.memberService.SetMuted(roomID, c.Param("session_id"), muted)
+	if err != nil {
+		c.JSON(memberErrorStatus(err), gin.H{
+			"error":  "更新禁言状态失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+// memberErrorStatus 成员管理错误对应的HTTP状态码
+func memberErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, model.ErrNotRoomMember):
+		return http.StatusNotFound
+	case errors.Is(err, model.ErrPermissionDenied):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
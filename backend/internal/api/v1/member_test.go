@@ -0,0 +1,189 @@
+package v1
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"xiaowo/backend/internal/model"
+)
+
+// 测试房主权限设置、禁言、移出和转让房主
+func TestPermissions_HostControls(t *testing.T) {
+	ts := newTestServer(t)
+	created := ts.createRoom(t)
+	roomPath := "/api/v1/rooms/" + created.Room.ID
+	guest := ts.joinRoom(t, created.Room.ID, "观众")
+
+	guestConn, _, err := ts.dialRoom(created.Room.ID, guest.Token)
+	if err != nil {
+		t.Fatalf("成员连接失败: %v", err)
+	}
+	defer guestConn.Close()
+	readUntil(t, guestConn, "room_state")
+
+	t.Run("TestHostOnlyControl", func(t *testing.T) {
+		if status := ts.doJSON(t, http.MethodPut, roomPath+"/settings", guest.Token, map[string]interface{}{"host_only_control": true}, nil); status != http.StatusForbidden {
+			t.Errorf("成员修改权限期望状态码: %d, 实际: %d", http.StatusForbidden, status)
+		}
+
+		var settings model.RoomSettings
+		if status := ts.doJSON(t, http.MethodPut, roomPath+"/settings", created.Token, map[string]interface{}{"host_only_control": true}, &settings); status != http.StatusOK {
+			t.Fatalf("房主修改权限失败，状态码: %d", status)
+		}
+		if !settings.HostOnlyControl || !settings.ChatEnabled {
+			t.Errorf("权限合并结果不正确: %+v", settings)
+		}
+		readUntil(t, guestConn, "room_settings")
+
+		if status := ts.doJSON(t, http.MethodPost, roomPath+"/play", guest.Token, nil, nil); status != http.StatusForbidden {
+			t.Errorf("成员 REST 播放期望状态码: %d, 实际: %d", http.StatusForbidden, status)
+		}
+		guestConn.WriteJSON(map[string]interface{}{"type": "seek", "target_time": 30})
+		if msg := readUntil(t, guestConn, "error"); msg["code"] != "permission_denied" {
+			t.Errorf("成员 WebSocket 跳转应被拒绝，实际: %v", msg)
+		}
+		if status := ts.doJSON(t, http.MethodPost, roomPath+"/play", created.Token, nil, nil); status != http.StatusOK {
+			t.Errorf("房主播放期望状态码: %d, 实际: %d", http.StatusOK, status)
+		}
+	})
+
+	t.Run("TestMuteMember", func(t *testing.T) {
+		if status := ts.doJSON(t, http.MethodPost, roomPath+"/members/"+guest.SessionID+"/mute", created.Token, nil, nil); status != http.StatusOK {
+			t.Fatalf("禁言失败，状态码: %d", status)
+		}
+		readUntil(t, guestConn, "member_muted")
+
+		guestConn.WriteJSON(map[string]interface{}{"type": "chat", "message": "还能说话吗"})
+		if msg := readUntil(t, guestConn, "error"); msg["code"] != "muted" {
+			t.Errorf("被禁言成员发送消息应被拒绝，实际: %v", msg)
+		}
+
+		if status := ts.doJSON(t, http.MethodPost, roomPath+"/members/"+guest.SessionID+"/unmute", created.Token, nil, nil); status != http.StatusOK {
+			t.Fatalf("解除禁言失败，状态码: %d", status)
+		}
+		guestConn.WriteJSON(map[string]interface{}{"type": "chat", "message": "又能说话了"})
+		readUntil(t, guestConn, "chat")
+	})
+
+	t.Run("TestTransferHost", func(t *testing.T) {
+		var member model.RoomMember
+		if status := ts.doJSON(t, http.MethodPost, roomPath+"/members/"+guest.SessionID+"/transfer-host", created.Token, nil, &member); status != http.StatusOK {
+			t.Fatalf("转让房主失败，状态码: %d", status)
+		}
+		if member.Role != model.RoleHost {
+			t.Errorf("期望新房主角色: %s, 实际: %s", model.RoleHost, member.Role)
+		}
+
+		// 原房主失去管理权限，新房主可以控制播放
+		if status := ts.doJSON(t, http.MethodPost, roomPath+"/pause", created.Token, nil, nil); status != http.StatusForbidden {
+			t.Errorf("原房主播放控制期望状态码: %d, 实际: %d", http.StatusForbidden, status)
+		}
+		if status := ts.doJSON(t, http.MethodPost, roomPath+"/pause", guest.Token, nil, nil); status != http.StatusOK {
+			t.Errorf("新房主播放控制期望状态码: %d, 实际: %d", http.StatusOK, status)
+		}
+	})
+
+	t.Run("TestKickMember", func(t *testing.T) {
+		if status := ts.doJSON(t, http.MethodPost, roomPath+"/members/"+guest.SessionID+"/kick", guest.Token, nil, nil); status != http.StatusForbidden {
+			t.Errorf("房主不能移出自己，期望状态码: %d, 实际: %d", http.StatusForbidden, status)
+		}
+		if status := ts.doJSON(t, http.MethodPost, roomPath+"/members/"+created.SessionID+"/kick", guest.Token, nil, nil); status != http.StatusOK {
+			t.Fatalf("移出成员失败，状态码: %d", status)
+		}
+		if ts.members.IsMember(created.Room.ID, created.SessionID) {
+			t.Error("被移出的会话不应该仍是成员")
+		}
+		if _, _, err := ts.dialRoom(created.Room.ID, created.Token); err == nil {
+			t.Error("被移出成员的令牌不应该建立连接")
+		}
+	})
+}
+
+// membersPresence 获取房间成员的在线状态
+func (ts *testServer) membersPresence(t *testing.T, roomID string) map[string]string {
+	t.Helper()
+
+	var members []struct {
+		SessionID string `json:"session_id"`
+		Presence  string `json:"presence"`
+	}
+	if status := ts.doJSON(t, http.MethodGet, "/api/v1/rooms/"+roomID+"/members", "", nil, &members); status != http.StatusOK {
+		t.Fatalf("获取成员列表失败，状态码: %d", status)
+	}
+	presence := make(map[string]string, len(members))
+	for _, member := range members {
+		presence[member.SessionID] = member.Presence
+	}
+	return presence
+}
+
+// 测试成员列表反映实时连接的在线状态，离线超时的成员被移出房间
+func TestPresence_LiveStatusAndOfflineTimeout(t *testing.T) {
+	ts := newTestServer(t)
+	created := ts.createRoom(t)
+	joined := ts.joinRoom(t, created.Room.ID, "后台观众")
+
+	host, _, err := ts.dialRoom(created.Room.ID, created.Token)
+	if err != nil {
+		t.Fatalf("房主连接失败: %v", err)
+	}
+	defer host.Close()
+	readUntil(t, host, "room_state")
+
+	guest, _, err := ts.dialRoom(created.Room.ID, joined.Token)
+	if err != nil {
+		t.Fatalf("成员连接失败: %v", err)
+	}
+	readUntil(t, guest, "room_state")
+	readUntil(t, host, "member_join")
+
+	if err := ts.presence.Flush(); err != nil {
+		t.Fatalf("写入在线状态失败: %v", err)
+	}
+	var session model.UserSession
+	ts.db.First(&session, "id = ?", joined.SessionID)
+	if session.Status != model.StatusOnline {
+		t.Errorf("连接后会话应为在线，实际: %s", session.Status)
+	}
+	if presence := ts.membersPresence(t, created.Room.ID); presence[created.SessionID] != "online" || presence[joined.SessionID] != "online" {
+		t.Errorf("连接的成员应为 online: %v", presence)
+	}
+
+	guest.WriteJSON(map[string]interface{}{"type": "presence", "status": "idle"})
+	if msg := readUntil(t, host, "member_presence"); msg["session_id"] != joined.SessionID || msg["status"] != "idle" {
+		t.Errorf("应广播成员空闲: %v", msg)
+	}
+	if presence := ts.membersPresence(t, created.Room.ID); presence[joined.SessionID] != "idle" {
+		t.Errorf("报告空闲的成员应为 idle: %v", presence)
+	}
+
+	// 成员长时间没有心跳（如所在实例异常退出）
+	guest.Close()
+	if err := ts.presence.Flush(); err != nil {
+		t.Fatalf("写入在线状态失败: %v", err)
+	}
+	ts.db.Model(&model.RoomMember{}).Where("room_id = ?", created.Room.ID).
+		Update("last_seen", time.Now().Add(-time.Hour))
+	if presence := ts.membersPresence(t, created.Room.ID); presence[joined.SessionID] != "away" {
+		t.Errorf("没有心跳的成员应为 away: %v", presence)
+	}
+
+	expired, err := ts.presence.ExpireOffline()
+	if err != nil {
+		t.Fatalf("移出离线成员失败: %v", err)
+	}
+	if len(expired) != 1 || expired[0].SessionID != joined.SessionID {
+		t.Fatalf("应只移出离线的成员，房主保留: %v", expired)
+	}
+	if msg := readUntil(t, host, "member_left"); msg["session_id"] != joined.SessionID {
+		t.Errorf("应通知成员离线超时被移出: %v", msg)
+	}
+	presence := ts.membersPresence(t, created.Room.ID)
+	if _, ok := presence[joined.SessionID]; ok || presence[created.SessionID] == "" {
+		t.Errorf("成员列表应只剩房主: %v", presence)
+	}
+	if _, resp, err := ts.dialRoom(created.Room.ID, joined.Token); err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Error("被移出的成员的令牌应失效")
+	}
+}
@@ -0,0 +1,122 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/service"
+)
+
+// MessageHandler 聊天记录相关API处理器
+type MessageHandler struct {
+	messageService *service.MessageService
+}
+
+// NewMessageHandler 创建聊天记录处理器
+func NewMessageHandler(messageService *service.MessageService) *MessageHandler {
+	return &MessageHandler{
+		messageService: messageService,
+	}
+}
+
+// ListMessages 获取聊天记录
+// @Summary 获取聊天记录
+// @Description 分页获取房间聊天记录，按时间正序返回
+// @Tags messages
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(50)
+// @Success 200 {object} MessageListResponse
+// @Router /api/v1/rooms/{room_id}/messages [get]
+func (h *MessageHandler) ListMessages(c *gin.Context) {
+	roomID := c.Param("room_id")
+	page, size := parsePage(c)
+
+	messages, total, err := h.messageService.GetHistory(roomID, page, size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "获取聊天记录失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, newMessageListResponse(messages, total, page, size))
+}
+
+// SearchMessages 搜索聊天记录
+// @Summary 搜索聊天记录
+// @Description 按关键词搜索房间聊天记录
+// @Tags messages
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Param q query string true "关键词"
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(50)
+// @Success 200 {object} MessageListResponse
+// @Router /api/v1/rooms/{room_id}/messages/search [get]
+func (h *MessageHandler) SearchMessages(c *gin.Context) {
+	roomID := c.Param("room_id")
+	keyword := strings.TrimSpace(c.Query("q"))
+	if keyword == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "搜索关键词不能为空",
+		})
+		return
+	}
+	page, size := parsePage(c)
+
+	messages, total, err := h.messageService.SearchMessages(roomID, keyword, page, size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "搜索聊天记录失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, newMessageListResponse(messages, total, page, size))
+}
+
+// parsePage 解析分页参数
+func parsePage(c *gin.Context) (int, int) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", strconv.Itoa(service.DefaultHistoryLimit)))
+
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > service.MaxHistoryPageSize {
+		size = service.DefaultHistoryLimit
+	}
+	return page, size
+}
+
+// newMessageListResponse 构建聊天记录列表响应
+func newMessageListResponse(messages []*model.Message, total int64, page, size int) *MessageListResponse {
+	resp := &MessageListResponse{
+		Messages: make([]*MessageResponse, 0, len(messages)),
+		Total:    total,
+		Page:     page,
+		Size:     size,
+		HasMore:  int64(page*size) < total,
+	}
+	for _, message := range messages {
+		resp.Messages = append(resp.Messages, &MessageResponse{
+			ID:          message.ID,
+			RoomID:      message.RoomID,
+			SessionID:   message.SessionID,
+			DisplayName: message.GetDisplayName(),
+			MessageType: message.MessageType,
+			Content:     message.Content,
+			CreatedAt:   message.CreatedAt,
+		})
+	}
+	return resp
+}
@@ -0,0 +1,68 @@
+package v1
+
+import (
+	"net/http"
+	"testing"
+)
+
+// 测试聊天消息持久化、连接时补发历史和 REST 分页搜索
+func TestChat_PersistedHistoryAndSearch(t *testing.T) {
+	ts := newTestServer(t)
+	created := ts.createRoom(t)
+	messagesPath := "/api/v1/rooms/" + created.Room.ID + "/messages"
+
+	conn, _, err := ts.dialRoom(created.Room.ID, created.Token)
+	if err != nil {
+		t.Fatalf("房主连接失败: %v", err)
+	}
+	readUntil(t, conn, "room_state")
+
+	t.Run("TestSendAndRejectEmpty", func(t *testing.T) {
+		conn.WriteJSON(map[string]interface{}{"type": "chat", "message": "大家好，开始看电影"})
+		readUntil(t, conn, "chat")
+
+		conn.WriteJSON(map[string]interface{}{"type": "chat", "message": "   "})
+		if msg := readUntil(t, conn, "error"); msg["code"] != "chat_failed" {
+			t.Errorf("空消息应该被拒绝，实际: %v", msg)
+		}
+
+		conn.WriteJSON(map[string]interface{}{"type": "chat", "message": "第二条消息"})
+		readUntil(t, conn, "chat")
+	})
+	conn.Close()
+
+	t.Run("TestBackfillOnConnect", func(t *testing.T) {
+		again, _, err := ts.dialRoom(created.Room.ID, created.Token)
+		if err != nil {
+			t.Fatalf("重新连接失败: %v", err)
+		}
+		defer again.Close()
+
+		state := readUntil(t, again, "room_state")
+		history, _ := state["messages"].([]interface{})
+		if len(history) != 2 {
+			t.Fatalf("期望补发 2 条历史消息，实际: %d", len(history))
+		}
+		if first := history[0].(map[string]interface{}); first["message"] != "大家好，开始看电影" {
+			t.Errorf("历史消息应按时间正序，实际第一条: %v", first)
+		}
+	})
+
+	t.Run("TestRESTHistoryAndSearch", func(t *testing.T) {
+		var list MessageListResponse
+		if status := ts.doJSON(t, http.MethodGet, messagesPath+"?page=1&size=1", created.Token, nil, &list); status != http.StatusOK {
+			t.Fatalf("获取聊天记录失败，状态码: %d", status)
+		}
+		if list.Total != 2 || len(list.Messages) != 1 || !list.HasMore {
+			t.Errorf("分页结果不正确: %+v", list)
+		}
+
+		var found MessageListResponse
+		if status := ts.doJSON(t, http.MethodGet, messagesPath+"/search?q=电影", created.Token, nil, &found); status != http.StatusOK {
+			t.Fatalf("搜索聊天记录失败，状态码: %d", status)
+		}
+		if found.Total != 1 || found.Messages[0].SessionID != created.SessionID {
+			t.Errorf("搜索结果不正确: %+v", found)
+		}
+	})
+}
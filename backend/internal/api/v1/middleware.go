@@ -1,23 +1,27 @@
 package v1
 
 import (
+	"crypto/subtle"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"xiaowo/backend/internal/metrics"
+	"xiaowo/backend/internal/token"
 )
 
 // ==================== 中间件 ====================
 
-// AuthMiddleware 认证中间件
-func AuthMiddleware() gin.HandlerFunc {
+// claimsContextKey 令牌载荷在 gin.Context 中的键
+const claimsContextKey = "claims"
+
+// AuthMiddleware 认证中间件，校验房间访问令牌并写入令牌载荷
+func AuthMiddleware(tokens *token.Manager) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token := c.GetHeader("Authorization")
-		if token == "" {
-			token = c.Query("token")
-		}
-		
-		if token == "" {
+		raw := requestToken(c)
+		if raw == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "缺少访问令牌",
 			})
@@ -25,32 +29,106 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// TODO: 验证JWT令牌
-		// 暂时简化实现，检查令牌格式
-		if len(token) < 10 {
+		claims, err := tokens.Parse(raw)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "无效的访问令牌",
+				"detail": err.Error(),
 			})
 			c.Abort()
 			return
 		}
 
-		c.Set("token", token)
+		// 路径中带房间ID时，令牌必须属于该房间
+		if roomID := c.Param("room_id"); roomID != "" && roomID != claims.RoomID {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "访问令牌不属于该房间",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("token", raw)
+		c.Set(claimsContextKey, claims)
 		c.Next()
 	}
 }
 
-// CORSMiddleware CORS中间件
-func CORSMiddleware() gin.HandlerFunc {
+// requestToken 读取 Authorization 请求头中的访问令牌，没有时读取 token 查询参数
+func requestToken(c *gin.Context) string {
+	raw := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	if raw == "" {
+		raw = c.Query("token")
+	}
+	return raw
+}
+
+// getClaims 获取认证中间件写入的令牌载荷
+func getClaims(c *gin.Context) *token.Claims {
+	if v, ok := c.Get(claimsContextKey); ok {
+		if claims, ok := v.(*token.Claims); ok {
+			return claims
+		}
+	}
+	return nil
+}
+
+// AdminMiddleware 管理接口认证中间件，未配置管理令牌时管理接口不可用
+func AdminMiddleware(adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if adminToken == "" {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "管理接口未开放",
+			})
+			c.Abort()
+			return
+		}
+
+		raw := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+		if subtle.ConstantTimeCompare([]byte(raw), []byte(adminToken)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "无效的管理令牌",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// CORSMiddleware CORS中间件，只对允许的来源返回跨域响应头
+//
+// 允许所有来源（"*"）时不返回 Access-Control-Allow-Credentials，避免任意站点携带凭据访问。
+func CORSMiddleware(allowOrigins []string) gin.HandlerFunc {
+	allowed := NewOriginChecker(allowOrigins)
+	credentials := true
+	for _, origin := range allowOrigins {
+		if origin == "*" {
+			credentials = false
+		}
+	}
 	return func(c *gin.Context) {
 		method := c.Request.Method
 		origin := c.Request.Header.Get("Origin")
 		
+		if origin == "" || !allowed(origin) {
+			if method == "OPTIONS" {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		c.Header("Vary", "Origin")
 		c.Header("Access-Control-Allow-Origin", origin)
 		c.Header("Access-Control-Allow-Headers", "Content-Type, AccessToken, X-CSRF-Token, Authorization, Token, x-token")
 		c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers")
-		c.Header("Access-Control-Allow-Credentials", "true")
+		c.Header("Access-Control-Expose-Headers", "Content-Length, Content-Range, Accept-Ranges, Access-Control-Allow-Origin, Access-Control-Allow-Headers")
+		if credentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
 
 		if method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusOK)
@@ -61,6 +139,37 @@ func CORSMiddleware() gin.HandlerFunc {
 	}
 }
 
+// NewOriginChecker 创建来源校验函数，列表包含 "*" 时允许所有来源
+func NewOriginChecker(allowOrigins []string) func(origin string) bool {
+	set := make(map[string]bool, len(allowOrigins))
+	for _, origin := range allowOrigins {
+		if origin == "*" {
+			return func(string) bool { return true }
+		}
+		set[strings.TrimRight(origin, "/")] = true
+	}
+	return func(origin string) bool {
+		return set[origin]
+	}
+}
+
+// ==================== 指标 ====================
+
+// MetricsMiddleware 按路由模板统计请求数和耗时，未匹配的路由统一记为 unmatched 以限制标签基数
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
 // ==================== 错误处理 ====================
 
 // HandleError 全局错误处理
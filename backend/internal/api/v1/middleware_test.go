@@ -0,0 +1,53 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 测试跨域响应头：只对允许的来源返回，允许所有来源时不携带凭据
+func TestCORSMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		allow       []string
+		origin      string
+		method      string
+		status      int
+		allowOrigin string
+		credentials string
+	}{
+		{"TestAllowedPreflight", []string{testOrigin}, testOrigin, http.MethodOptions, http.StatusOK, testOrigin, "true"},
+		{"TestTrailingSlash", []string{testOrigin + "/"}, testOrigin, http.MethodGet, http.StatusNoContent, testOrigin, "true"},
+		{"TestRejectedPreflight", []string{testOrigin}, "https://evil.example.com", http.MethodOptions, http.StatusForbidden, "", ""},
+		{"TestRejectedRequest", []string{testOrigin}, "https://evil.example.com", http.MethodGet, http.StatusNoContent, "", ""},
+		{"TestWildcardWithoutCredentials", []string{"*"}, "https://any.example.com", http.MethodGet, http.StatusNoContent, "https://any.example.com", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(CORSMiddleware(tt.allow))
+			router.GET("/", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+			router.OPTIONS("/", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+			req := httptest.NewRequest(tt.method, "/", nil)
+			req.Header.Set("Origin", tt.origin)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("状态码期望: %d, 实际: %d", tt.status, rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+				t.Errorf("Access-Control-Allow-Origin 期望: %q, 实际: %q", tt.allowOrigin, got)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != tt.credentials {
+				t.Errorf("Access-Control-Allow-Credentials 期望: %q, 实际: %q", tt.credentials, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/service"
+)
+
+// PlaylistHandler 房间播放列表相关API处理器
+type PlaylistHandler struct {
+	roomService     *service.RoomService
+	playlistService *service.PlaylistService
+}
+
+// NewPlaylistHandler 创建播放列表处理器
+func NewPlaylistHandler(roomService *service.RoomService, playlistService *service.PlaylistService) *PlaylistHandler {
+	return &PlaylistHandler{
+		roomService:     roomService,
+		playlistService: playlistService,
+	}
+}
+
+// GetPlaylist 获取播放列表
+// @Summary 获取播放列表
+// @Description 获取房间的播放列表、当前条目和列表版本号
+// @Tags playlist
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Success 200 {object} model.Playlist
+// @Router /api/v1/rooms/{room_id}/playlist [get]
+func (h *PlaylistHandler) GetPlaylist(c *gin.Context) {
+	playlist, err := h.playlistService.GetPlaylist(c.Param("room_id"))
+	if err != nil {
+		c.JSON(playlistErrorStatus(err), gin.H{
+			"error":  "获取播放列表失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, playlist)
+}
+
+// AddItem 添加播放列表条目
+// @Summary 添加播放列表条目
+// @Description 在播放列表末尾添加媒体，变更会推送给在线成员
+// @Tags playlist
+// @Accept json
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Param request body AddPlaylistItemRequest true "添加条目请求"
+// @Success 201 {object} model.Playlist
+// @Failure 409 {object} map[string]interface{} "列表版本冲突，附带最新的播放列表"
+// @Router /api/v1/rooms/{room_id}/playlist [post]
+func (h *PlaylistHandler) AddItem(c *gin.Context) {
+	roomID := c.Param("room_id")
+	sessionID := getClaims(c).SessionID
+	if !authorizeRoomAction(c, h.roomService, roomID, sessionID, service.ActionEditPlaylist) {
+		return
+	}
+
+	var req AddPlaylistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "无效的请求参数",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	playlist, err := h.playlistService.AddItem(roomID, sessionID, &model.PlaylistItem{
+		MediaURL:      req.MediaURL,
+		MediaType:     req.MediaType,
+		MediaTitle:    req.MediaTitle,
+		MediaDuration: req.MediaDuration,
+	}, req.BaseVersion)
+	if err != nil {
+		respondPlaylistError(c, "添加条目失败", playlist, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, playlist)
+}
+
+// RemoveItem 移除播放列表条目
+// @Summary 移除播放列表条目
+// @Description 移除指定条目，移除当前条目时正在播放的媒体保持不变
+// @Tags playlist
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param item_id path string true "条目ID"
+// @Param base_version query int false "基础列表版本号（乐观锁）"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Success 200 {object} model.Playlist
+// @Router /api/v1/rooms/{room_id}/playlist/{item_id} [delete]
+func (h *PlaylistHandler) RemoveItem(c *gin.Context) {
+	h.edit(c, "移除条目失败", func(roomID string, baseVersion *int) (*model.Playlist, error) {
+		return h.playlistService.RemoveItem(roomID, c.Param("item_id"), baseVersion)
+	})
+}
+
+// MoveItem 调整播放列表条目位置
+// @Summary 调整条目位置
+// @Description 将条目移动到指定位置，超出范围时移到两端
+// @Tags playlist
+// @Accept json
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param item_id path string true "条目ID"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Param request body MovePlaylistItemRequest true "调整位置请求"
+// @Success 200 {object} model.Playlist
+// @Router /api/v1/rooms/{room_id}/playlist/{item_id}/position [put]
+func (h *PlaylistHandler) MoveItem(c *gin.Context) {
+	roomID := c.Param("room_id")
+	if !authorizeRoomAction(c, h.roomService, roomID, getClaims(c).SessionID, service.ActionEditPlaylist) {
+		return
+	}
+
+	var req MovePlaylistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "无效的请求参数",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	playlist, err := h.playlistService.MoveItem(roomID, c.Param("item_id"), *req.Position, req.BaseVersion)
+	if err != nil {
+		respondPlaylistError(c, "调整条目位置失败", playlist, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, playlist)
+}
+
+// SetCurrent 切换到指定条目
+// @Summary 切换到指定条目
+// @Description 将指定条目设为当前播放的媒体，从头开始并保持原来的播放/暂停状态
+// @Tags playlist
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param item_id path string true "条目ID"
+// @Param base_version query int false "基础列表版本号（乐观锁）"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Success 200 {object} model.Playlist
+// @Router /api/v1/rooms/{room_id}/playlist/{item_id}/current [post]
+func (h *PlaylistHandler) SetCurrent(c *gin.Context) {
+	h.edit(c, "切换条目失败", func(roomID string, baseVersion *int) (*model.Playlist, error) {
+		return h.playlistService.SetCurrent(roomID, c.Param("item_id"), baseVersion)
+	})
+}
+
+// Next 切换到下一条目
+// @Summary 切换到下一条目
+// @Description 按房间的播放列表模式（顺序/循环/随机）切换到下一条目
+// @Tags playlist
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param base_version query int false "基础列表版本号（乐观锁）"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Success 200 {object} model.Playlist
+// @Router /api/v1/rooms/{room_id}/playlist/next [post]
+func (h *PlaylistHandler) Next(c *gin.Context) {
+	h.edit(c, "切换条目失败", h.playlistService.Next)
+}
+
+// Previous 切换到上一条目
+// @Summary 切换到上一条目
+// @Description 切换到上一条目，循环和随机模式下从第一条切到最后一条
+// @Tags playlist
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param base_version query int false "基础列表版本号（乐观锁）"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Success 200 {object} model.Playlist
+// @Router /api/v1/rooms/{room_id}/playlist/previous [post]
+func (h *PlaylistHandler) Previous(c *gin.Context) {
+	h.edit(c, "切换条目失败", h.playlistService.Previous)
+}
+
+// edit 校验权限并执行只需要 base_version 查询参数的播放列表操作
+func (h *PlaylistHandler) edit(c *gin.Context, failure string, apply func(roomID string, baseVersion *int) (*model.Playlist, error)) {
+	roomID := c.Param("room_id")
+	if !authorizeRoomAction(c, h.roomService, roomID, getClaims(c).SessionID, service.ActionEditPlaylist) {
+		return
+	}
+
+	var baseVersion *int
+	if raw := c.Query("base_version"); raw != "" {
+		version, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":  "无效的请求参数",
+				"detail": "base_version must be an integer",
+			})
+			return
+		}
+		baseVersion = &version
+	}
+
+	playlist, err := apply(roomID, baseVersion)
+	if err != nil {
+		respondPlaylistError(c, failure, playlist, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, playlist)
+}
+
+// respondPlaylistError 返回播放列表操作错误，版本冲突时附带最新的播放列表
+func respondPlaylistError(c *gin.Context, failure string, latest *model.Playlist, err error) {
+	body := gin.H{
+		"error":  failure,
+		"detail": err.Error(),
+	}
+	if errors.Is(err, model.ErrVersionConflict) && latest != nil {
+		body["playlist"] = latest
+	}
+	c.JSON(playlistErrorStatus(err), body)
+}
+
+// playlistErrorStatus 播放列表错误对应的HTTP状态码
+func playlistErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, model.ErrRoomNotFound), errors.Is(err, model.ErrPlaylistItemNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, model.ErrVersionConflict):
+		return http.StatusConflict
+	case errors.Is(err, model.ErrInvalidMediaURL), errors.Is(err, model.ErrInvalidPlaybackState),
+		errors.Is(err, model.ErrPlaylistEnd), errors.Is(err, model.ErrPlaylistFull):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
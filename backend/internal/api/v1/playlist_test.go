@@ -0,0 +1,148 @@
+package v1
+
+import (
+	"net/http"
+	"testing"
+)
+
+// 测试播放列表的增删、排序、切换、版本冲突和播完自动切换
+func TestPlaylist_QueueAndAutoAdvance(t *testing.T) {
+	ts := newTestServer(t)
+	created := ts.createRoom(t)
+	roomPath := "/api/v1/rooms/" + created.Room.ID
+	playlistPath := roomPath + "/playlist"
+
+	conn, _, err := ts.dialRoom(created.Room.ID, created.Token)
+	if err != nil {
+		t.Fatalf("房主连接失败: %v", err)
+	}
+	defer conn.Close()
+	state := readUntil(t, conn, "room_state")
+
+	// items 返回条目的媒体地址，按列表顺序
+	items := func(playlist map[string]interface{}) []string {
+		var urls []string
+		for _, item := range playlist["items"].([]interface{}) {
+			urls = append(urls, item.(map[string]interface{})["media_url"].(string))
+		}
+		return urls
+	}
+	itemID := func(playlist map[string]interface{}, url string) string {
+		for _, item := range playlist["items"].([]interface{}) {
+			if entry := item.(map[string]interface{}); entry["media_url"] == url {
+				return entry["id"].(string)
+			}
+		}
+		t.Fatalf("播放列表中没有 %s", url)
+		return ""
+	}
+
+	const first, second, third = "https://example.com/video.mp4", "https://example.com/ep2.mp4", "https://example.com/ep3.mp4"
+
+	t.Run("TestRoomMediaIsFirstItem", func(t *testing.T) {
+		playlist := state["playlist"].(map[string]interface{})
+		if got := items(playlist); len(got) != 1 || got[0] != first || playlist["current_id"] != itemID(playlist, first) {
+			t.Errorf("新房间的播放列表不正确: %v", playlist)
+		}
+	})
+
+	t.Run("TestAddAndConflict", func(t *testing.T) {
+		var playlist map[string]interface{}
+		body := map[string]interface{}{"media_url": second, "media_title": "第二集", "media_duration": 1, "base_version": 0}
+		if code := ts.doJSON(t, http.MethodPost, playlistPath, created.Token, body, &playlist); code != http.StatusCreated {
+			t.Fatalf("添加条目失败，状态码: %d", code)
+		}
+		if playlist["version"].(float64) != 1 || len(items(playlist)) != 2 {
+			t.Errorf("添加后的播放列表不正确: %v", playlist)
+		}
+		pushed := readUntil(t, conn, "playlist")["playlist"].(map[string]interface{})
+		if pushed["version"].(float64) != 1 {
+			t.Errorf("推送的列表版本不正确: %v", pushed["version"])
+		}
+
+		var conflict map[string]interface{}
+		if code := ts.doJSON(t, http.MethodPost, playlistPath, created.Token, body, &conflict); code != http.StatusConflict {
+			t.Fatalf("期望状态码: %d, 实际: %d", http.StatusConflict, code)
+		}
+		if latest, ok := conflict["playlist"].(map[string]interface{}); !ok || latest["version"].(float64) != 1 {
+			t.Errorf("版本冲突应返回最新的播放列表: %v", conflict)
+		}
+	})
+
+	t.Run("TestWebSocketAddAndMove", func(t *testing.T) {
+		conn.WriteJSON(map[string]interface{}{"type": "playlist_add", "media_url": third, "base_version": 1})
+		playlist := readUntil(t, conn, "playlist")["playlist"].(map[string]interface{})
+		if playlist["version"].(float64) != 2 || len(items(playlist)) != 3 {
+			t.Fatalf("WebSocket 添加后的播放列表不正确: %v", playlist)
+		}
+
+		var moved map[string]interface{}
+		path := playlistPath + "/" + itemID(playlist, third) + "/position"
+		if code := ts.doJSON(t, http.MethodPut, path, created.Token, map[string]interface{}{"position": 0}, &moved); code != http.StatusOK {
+			t.Fatalf("调整位置失败，状态码: %d", code)
+		}
+		if got := items(moved); got[0] != third || got[1] != first || got[2] != second {
+			t.Errorf("调整位置后的顺序不正确: %v", got)
+		}
+		readUntil(t, conn, "playlist")
+	})
+
+	var playlist map[string]interface{}
+	ts.doJSON(t, http.MethodGet, playlistPath, "", nil, &playlist)
+
+	t.Run("TestSetCurrentChangesMedia", func(t *testing.T) {
+		conn.WriteJSON(map[string]interface{}{"type": "playlist_set", "item_id": itemID(playlist, second)})
+		msg := readUntil(t, conn, "change_media")
+		if msg["video_url"] != second || msg["duration"].(float64) != 1 || msg["is_playing"] != false || msg["current_time"].(float64) != 0 {
+			t.Errorf("切换条目的播放状态不正确: %v", msg)
+		}
+		// 列表推送紧随播放状态之后，可能与其合并在同一帧中，从 REST 读取当前条目
+		var current map[string]interface{}
+		ts.doJSON(t, http.MethodGet, playlistPath, "", nil, &current)
+		if current["current_id"] != itemID(playlist, second) || current["version"].(float64) != 4 {
+			t.Errorf("当前条目不正确: %v", current)
+		}
+	})
+
+	t.Run("TestAutoAdvanceLoops", func(t *testing.T) {
+		if code := ts.doJSON(t, http.MethodPut, roomPath+"/settings", created.Token, map[string]interface{}{"playlist_mode": "loop"}, nil); code != http.StatusOK {
+			t.Fatalf("更新播放列表模式失败，状态码: %d", code)
+		}
+
+		// 第二集是最后一个条目，循环模式下播完回到第一个条目
+		conn.WriteJSON(map[string]interface{}{"type": "play"})
+		readUntil(t, conn, "play")
+		msg := readUntil(t, conn, "change_media")
+		if msg["video_url"] != third || msg["is_playing"] != true {
+			t.Errorf("播完后应自动切换并继续播放: %v", msg)
+		}
+
+		stored, _ := ts.rooms.GetRoom(created.Room.ID)
+		if stored.MediaURL != third || stored.CurrentItemID != itemID(playlist, third) {
+			t.Errorf("持久化的当前条目不正确: %s %s", stored.MediaURL, stored.CurrentItemID)
+		}
+	})
+
+	t.Run("TestSequentialEnd", func(t *testing.T) {
+		ts.doJSON(t, http.MethodPut, roomPath+"/settings", created.Token, map[string]interface{}{"playlist_mode": "sequential"}, nil)
+		if code := ts.doJSON(t, http.MethodPost, playlistPath+"/previous", created.Token, nil, nil); code != http.StatusBadRequest {
+			t.Errorf("第一个条目之前没有条目，状态码: %d", code)
+		}
+		if code := ts.doJSON(t, http.MethodPost, playlistPath+"/next?base_version=1", created.Token, nil, nil); code != http.StatusConflict {
+			t.Errorf("期望状态码: %d, 实际: %d", http.StatusConflict, code)
+		}
+	})
+
+	t.Run("TestRemoveCurrent", func(t *testing.T) {
+		var removed map[string]interface{}
+		if code := ts.doJSON(t, http.MethodDelete, playlistPath+"/"+itemID(playlist, third), created.Token, nil, &removed); code != http.StatusOK {
+			t.Fatalf("移除条目失败，状态码: %d", code)
+		}
+		if got := items(removed); len(got) != 2 || got[0] != first || removed["current_id"] != "" {
+			t.Errorf("移除当前条目后的播放列表不正确: %v", removed)
+		}
+		if code := ts.doJSON(t, http.MethodDelete, playlistPath+"/missing", created.Token, nil, nil); code != http.StatusNotFound {
+			t.Errorf("期望状态码: %d, 实际: %d", http.StatusNotFound, code)
+		}
+	})
+}
@@ -7,21 +7,32 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"xiaowo/backend/internal/config"
 	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/password"
 	"xiaowo/backend/internal/service"
+	"xiaowo/backend/internal/token"
 )
 
 // RoomHandler 房间相关API处理器
 type RoomHandler struct {
-	roomService   *service.RoomService
-	memberService *service.MemberService
+	roomService    *service.RoomService
+	memberService  *service.MemberService
+	sessionService *service.SessionService
+	presence       *service.PresenceService
+	tokens         *token.Manager
+	frontend       config.FrontendConfig
 }
 
 // NewRoomHandler 创建房间处理器
-func NewRoomHandler(roomService *service.RoomService, memberService *service.MemberService) *RoomHandler {
+func NewRoomHandler(roomService *service.RoomService, memberService *service.MemberService, sessionService *service.SessionService, presence *service.PresenceService, tokens *token.Manager, frontend config.FrontendConfig) *RoomHandler {
 	return &RoomHandler{
-		roomService:   roomService,
-		memberService: memberService,
+		roomService:    roomService,
+		memberService:  memberService,
+		sessionService: sessionService,
+		presence:       presence,
+		tokens:         tokens,
+		frontend:       frontend,
 	}
 }
 
@@ -31,6 +42,7 @@ func NewRoomHandler(roomService *service.RoomService, memberService *service.Mem
 // @Tags rooms
 // @Accept json
 // @Produce json
+// @Param session_id query string false "会话ID（不提供则创建匿名会话）"
 // @Param request body CreateRoomRequest true "创建房间请求"
 // @Success 200 {object} RoomResponse
 // @Router /api/v1/rooms [post]
@@ -52,8 +64,16 @@ func (h *RoomHandler) CreateRoom(c *gin.Context) {
 		return
 	}
 
-	// 生成匿名用户会话ID
-	sessionID := generateSessionID()
+	// 获取或创建创建者会话
+	session, err := resolveSession(h.sessionService, c.Query("session_id"), "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "创建会话失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+	sessionID := session.ID
 
 	// 转换为服务层的CreateRoomRequest
 	serviceReq := &service.CreateRoomRequest{
@@ -61,29 +81,33 @@ func (h *RoomHandler) CreateRoom(c *gin.Context) {
 		Description:   req.Description,
 		IsPrivate:     &req.IsPrivate,
 		Password:      req.Password,
-		MaxUsers:      &req.MaxUsers,
 		MediaURL:      req.MediaURL,
 		MediaType:     req.MediaType,
 		MediaTitle:    req.MediaTitle,
 		MediaDuration: int(req.MediaDuration),
-		Settings:      nil,
+		Settings:      req.Settings,
+	}
+	if req.MaxUsers > 0 {
+		serviceReq.MaxUsers = &req.MaxUsers
 	}
 
 	// 创建房间
 	room, err := h.roomService.CreateRoom(serviceReq, sessionID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(settingsErrorStatus(err), gin.H{
 			"error": "创建房间失败",
 			"detail": err.Error(),
 		})
 		return
 	}
 
-	// 自动创建者为房间成员
+	// 自动创建者为房间成员（房主）
 	member := &model.RoomMember{
 		RoomID:      room.ID,
 		SessionID:   sessionID,
-		Nickname:    generateDisplayName(),
+		Role:        model.RoleHost,
+		Nickname:    session.Nickname,
+		Avatar:      session.Avatar,
 		JoinedAt:    time.Now(),
 		LastSeen:    time.Now(),
 	}
@@ -96,23 +120,35 @@ func (h *RoomHandler) CreateRoom(c *gin.Context) {
 		return
 	}
 
+	if err := h.sessionService.JoinRoom(sessionID, room.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "更新会话房间失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
 	// 生成访问令牌
-	// token, err := generateRoomToken(room.ID, sessionID)
-	// if err != nil {
-	// 	c.JSON(http.StatusInternalServerError, gin.H{
-	// 		"error": "生成访问令牌失败",
-	// 		"detail": err.Error(),
-	// 	})
-	// 	return
-	// }
+	signed, claims, err := h.tokens.Issue(room.ID, sessionID, model.RoleHost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "生成访问令牌失败",
+			"detail": err.Error(),
+		})
+		return
+	}
 
 	// 返回创建结果
 	resp := &RoomResponse{
 		Room:        room,
+		Settings:    room.GetSettings(),
 		MemberCount: 1, // 创建者自动加入
 		CreatedBy:   room.CreatorSessionID,
 		CreatedAt:   room.CreatedAt,
 		IsCreator:   true, // 当前用户是创建者
+		SessionID:   sessionID,
+		Token:       signed,
+		ExpiresAt:   &claims.ExpiresAt.Time,
 	}
 
 	c.JSON(http.StatusCreated, resp)
@@ -150,6 +186,7 @@ func (h *RoomHandler) GetRoom(c *gin.Context) {
 
 	resp := &RoomDetailResponse{
 		Room:        room,
+		Settings:    room.GetSettings(),
 		MemberCount: memberCount,
 	}
 
@@ -163,6 +200,7 @@ func (h *RoomHandler) GetRoom(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param room_id path string true "房间ID"
+// @Param session_id query string false "会话ID（不提供则创建匿名会话）"
 // @Param request body JoinRoomRequest true "加入房间请求"
 // @Success 200 {object} JoinRoomResponse
 // @Router /api/v1/rooms/{room_id}/join [post]
@@ -185,8 +223,8 @@ func (h *RoomHandler) JoinRoom(c *gin.Context) {
 		return
 	}
 
-	// 验证房间是否存在
-	room, err := h.roomService.GetRoom(roomID)
+	// 验证房间是否存在（已关闭的房间不能加入）
+	room, err := h.roomService.GetJoinableRoom(roomID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "房间不存在",
@@ -195,14 +233,51 @@ func (h *RoomHandler) JoinRoom(c *gin.Context) {
 		return
 	}
 
-	// 验证房间密码（如果需要）
-	if room.IsPrivate && req.Password != room.Password {
+	// 验证房间密码（如果需要），连续输错后按来源IP临时锁定
+	if retryAfter, err := h.roomService.VerifyPassword(room, req.Password, c.ClientIP()); err != nil {
+		if errors.Is(err, password.ErrLocked) {
+			seconds := int((retryAfter + time.Second - 1) / time.Second)
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error": "密码错误次数过多，请稍后再试",
+				"retry_after": seconds,
+			})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": "房间密码错误",
 		})
 		return
 	}
 
+	// 房主可以关闭新成员加入
+	settings := room.GetSettings()
+	if !settings.GuestJoin {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "房间已关闭新成员加入",
+		})
+		return
+	}
+
+	// 获取或创建会话
+	session, err := resolveSession(h.sessionService, c.Query("session_id"), req.DisplayName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "创建会话失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+	sessionID := session.ID
+
+	// 已是房间成员的会话只能通过令牌刷新接口续期，避免凭会话ID冒领令牌
+	if h.memberService.IsMember(roomID, sessionID) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "已是房间成员，请使用令牌刷新接口",
+		})
+		return
+	}
+
 	// 检查房间人数限制
 	memberCount, err := h.memberService.GetMemberCount(roomID)
 	if err != nil {
@@ -220,18 +295,18 @@ func (h *RoomHandler) JoinRoom(c *gin.Context) {
 		return
 	}
 
-	// 生成会话ID和显示名称
-	sessionID := generateSessionID()
 	displayName := req.DisplayName
 	if displayName == "" {
-		displayName = generateDisplayName()
+		displayName = session.Nickname
 	}
 
 	// 添加房间成员
 	member := &model.RoomMember{
 		RoomID:    roomID,
 		SessionID: sessionID,
+		Role:      model.RoleMember,
 		Nickname:  displayName,
+		Avatar:    session.Avatar,
 		JoinedAt:  time.Now(),
 		LastSeen:  time.Now(),
 	}
@@ -244,8 +319,16 @@ func (h *RoomHandler) JoinRoom(c *gin.Context) {
 		return
 	}
 
+	if err := h.sessionService.JoinRoom(sessionID, roomID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "更新会话房间失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
 	// 生成访问令牌
-	token, err := generateRoomToken(roomID, sessionID)
+	signed, claims, err := h.tokens.Issue(roomID, sessionID, member.Role)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "生成访问令牌失败",
@@ -256,9 +339,12 @@ func (h *RoomHandler) JoinRoom(c *gin.Context) {
 
 	resp := &JoinRoomResponse{
 		Room:      room,
+		Settings:  settings,
 		SessionID: sessionID,
-		Token:     token,
-		JoinURL:   generateJoinURL(roomID, token),
+		Role:      member.Role,
+		Token:     signed,
+		ExpiresAt: claims.ExpiresAt.Time,
+		JoinURL:   h.frontend.JoinURL(roomID),
 	}
 
 	c.JSON(http.StatusOK, resp)
@@ -270,25 +356,15 @@ func (h *RoomHandler) JoinRoom(c *gin.Context) {
 // @Tags rooms
 // @Produce json
 // @Param room_id path string true "房间ID"
-// @Param session_id query string true "会话ID"
+// @Param Authorization header string true "Bearer 访问令牌"
 // @Success 200 {object} SuccessResponse
 // @Router /api/v1/rooms/{room_id} [delete]
 func (h *RoomHandler) CloseRoom(c *gin.Context) {
 	roomID := c.Param("room_id")
-	sessionID := c.Query("session_id")
-	
-	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "会话ID不能为空",
-		})
-		return
-	}
+	sessionID := getClaims(c).SessionID
 
-	// 验证权限（只有房间创建者可以关闭房间）
-	if !h.roomService.IsCreator(roomID, sessionID) {
-		c.JSON(http.StatusForbidden, gin.H{
-			"error": "只有房间创建者可以关闭房间",
-		})
+	// 验证权限（只有房主可以关闭房间）
+	if !authorizeRoomAction(c, h.roomService, roomID, sessionID, service.ActionManageRoom) {
 		return
 	}
 
@@ -312,19 +388,12 @@ func (h *RoomHandler) CloseRoom(c *gin.Context) {
 // @Tags rooms
 // @Produce json
 // @Param room_id path string true "房间ID"
-// @Param session_id query string true "会话ID"
+// @Param Authorization header string true "Bearer 访问令牌"
 // @Success 200 {object} SuccessResponse
 // @Router /api/v1/rooms/{room_id}/leave [post]
 func (h *RoomHandler) LeaveRoom(c *gin.Context) {
 	roomID := c.Param("room_id")
-	sessionID := c.Query("session_id")
-	
-	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "会话ID不能为空",
-		})
-		return
-	}
+	sessionID := getClaims(c).SessionID
 
 	// 移除房间成员
 	if err := h.memberService.RemoveMember(roomID, sessionID); err != nil {
@@ -335,6 +404,15 @@ func (h *RoomHandler) LeaveRoom(c *gin.Context) {
 		return
 	}
 
+	// 离开后该会话在房间内的令牌全部失效
+	if err := h.tokens.RevokeSession(roomID, sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "吊销访问令牌失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, SuccessResponse{
 		Message: "成功离开房间",
 	})
@@ -342,17 +420,17 @@ func (h *RoomHandler) LeaveRoom(c *gin.Context) {
 
 // GetRoomMembers 获取房间成员列表
 // @Summary 获取房间成员列表
-// @Description 获取房间的所有成员信息
+// @Description 获取房间的所有成员信息及在线状态（online/idle/away）
 // @Tags rooms
 // @Produce json
 // @Param room_id path string true "房间ID"
-// @Success 200 {array} model.RoomMember
+// @Success 200 {array} model.MemberPresence
 // @Router /api/v1/rooms/{room_id}/members [get]
 func (h *RoomHandler) GetRoomMembers(c *gin.Context) {
 	roomID := c.Param("room_id")
 	
-	// 获取房间成员列表
-	members, err := h.memberService.GetRoomMembers(roomID)
+	// 获取房间成员列表及在线状态
+	members, err := h.presence.GetRoomMembers(roomID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "获取房间成员列表失败",
@@ -371,13 +449,13 @@ func (h *RoomHandler) GetRoomMembers(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param room_id path string true "房间ID"
-// @Param session_id query string true "会话ID"
+// @Param Authorization header string true "Bearer 访问令牌"
 // @Param request body UpdateRoomRequest true "更新房间请求"
 // @Success 200 {object} RoomResponse
 // @Router /api/v1/rooms/{room_id} [put]
 func (h *RoomHandler) UpdateRoom(c *gin.Context) {
 	roomID := c.Param("room_id")
-	sessionID := c.Query("session_id")
+	sessionID := getClaims(c).SessionID
 	
 	var req UpdateRoomRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -388,17 +466,14 @@ func (h *RoomHandler) UpdateRoom(c *gin.Context) {
 		return
 	}
 
-	// 验证权限（只有房间创建者可以更新）
-	if !h.roomService.IsCreator(roomID, sessionID) {
-		c.JSON(http.StatusForbidden, gin.H{
-			"error": "只有房间创建者可以更新房间信息",
-		})
+	// 验证权限（只有房主可以更新）
+	if !authorizeRoomAction(c, h.roomService, roomID, sessionID, service.ActionManageRoom) {
 		return
 	}
 
 	// 转换为服务层的UpdateRoomRequest
 	serviceReq := &service.UpdateRoomRequest{
-		Settings: nil,
+		Settings: req.Settings,
 	}
 	if req.Name != "" {
 		serviceReq.Name = &req.Name
@@ -406,8 +481,14 @@ func (h *RoomHandler) UpdateRoom(c *gin.Context) {
 	if req.IsPrivate != nil {
 		serviceReq.IsPrivate = req.IsPrivate
 	}
-	if req.Password != "" {
-		serviceReq.Password = &req.Password
+	if req.Password != nil {
+		if len(*req.Password) > 50 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "密码过长",
+			})
+			return
+		}
+		serviceReq.Password = req.Password
 	}
 	if req.MaxUsers != nil {
 		serviceReq.MaxUsers = req.MaxUsers
@@ -416,7 +497,7 @@ func (h *RoomHandler) UpdateRoom(c *gin.Context) {
 	// 更新房间
 	room, err := h.roomService.UpdateRoom(roomID, serviceReq)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(settingsErrorStatus(err), gin.H{
 			"error": "更新房间失败",
 			"detail": err.Error(),
 		})
@@ -425,6 +506,7 @@ func (h *RoomHandler) UpdateRoom(c *gin.Context) {
 
 	resp := &RoomResponse{
 		Room:        room,
+		Settings:    room.GetSettings(),
 		MemberCount: 0, // 后续可以从memberService获取
 		CreatedBy:   room.CreatorSessionID,
 		CreatedAt:   room.CreatedAt,
@@ -440,14 +522,18 @@ func (h *RoomHandler) UpdateRoom(c *gin.Context) {
 // @Tags rooms
 // @Produce json
 // @Param room_id path string true "房间ID"
+// @Param Authorization header string true "Bearer 访问令牌"
 // @Success 200 {object} SuccessResponse
 // @Router /api/v1/rooms/{room_id}/play [post]
 func (h *RoomHandler) PlayVideo(c *gin.Context) {
 	roomID := c.Param("room_id")
+	if !authorizeRoomAction(c, h.roomService, roomID, getClaims(c).SessionID, service.ActionControlPlayback) {
+		return
+	}
 	
 	// 播放视频
 	if err := h.roomService.PlayVideo(roomID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(playbackErrorStatus(err), gin.H{
 			"error": "播放视频失败",
 			"detail": err.Error(),
 		})
@@ -465,14 +551,18 @@ func (h *RoomHandler) PlayVideo(c *gin.Context) {
 // @Tags rooms
 // @Produce json
 // @Param room_id path string true "房间ID"
+// @Param Authorization header string true "Bearer 访问令牌"
 // @Success 200 {object} SuccessResponse
 // @Router /api/v1/rooms/{room_id}/pause [post]
 func (h *RoomHandler) PauseVideo(c *gin.Context) {
 	roomID := c.Param("room_id")
+	if !authorizeRoomAction(c, h.roomService, roomID, getClaims(c).SessionID, service.ActionControlPlayback) {
+		return
+	}
 	
 	// 暂停视频
 	if err := h.roomService.PauseVideo(roomID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(playbackErrorStatus(err), gin.H{
 			"error": "暂停视频失败",
 			"detail": err.Error(),
 		})
@@ -491,11 +581,15 @@ func (h *RoomHandler) PauseVideo(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param room_id path string true "房间ID"
+// @Param Authorization header string true "Bearer 访问令牌"
 // @Param request body struct{CurrentTime float64 `json:"current_time"`} true "跳转请求"
 // @Success 200 {object} SuccessResponse
 // @Router /api/v1/rooms/{room_id}/seek [post]
 func (h *RoomHandler) SeekVideo(c *gin.Context) {
 	roomID := c.Param("room_id")
+	if !authorizeRoomAction(c, h.roomService, roomID, getClaims(c).SessionID, service.ActionSeek) {
+		return
+	}
 	
 	var req struct {
 		CurrentTime float64 `json:"current_time" binding:"required"`
@@ -511,7 +605,7 @@ func (h *RoomHandler) SeekVideo(c *gin.Context) {
 	
 	// 跳转视频
 	if err := h.roomService.SeekVideo(roomID, req.CurrentTime); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(playbackErrorStatus(err), gin.H{
 			"error": "跳转视频失败",
 			"detail": err.Error(),
 		})
@@ -523,6 +617,50 @@ func (h *RoomHandler) SeekVideo(c *gin.Context) {
 	})
 }
 
+// StartCountdown 倒计时开播
+// @Summary 倒计时开播
+// @Description 预约在 seconds 秒后让所有成员同时开始播放，在线成员收到带 execute_at 的 countdown 消息
+// @Tags rooms
+// @Accept json
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Param request body CountdownRequest true "倒计时请求"
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/rooms/{room_id}/countdown [post]
+func (h *RoomHandler) StartCountdown(c *gin.Context) {
+	roomID := c.Param("room_id")
+	if !authorizeRoomAction(c, h.roomService, roomID, getClaims(c).SessionID, service.ActionControlPlayback) {
+		return
+	}
+
+	var req CountdownRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的请求参数",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	room, err := h.roomService.StartCountdown(roomID, req.Seconds, req.StartTime)
+	if err != nil {
+		c.JSON(playbackErrorStatus(err), gin.H{
+			"error": "倒计时开播失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Message: "倒计时已开始",
+		Data: gin.H{
+			"execute_at": room.PlaybackUpdatedAt,
+			"version":    room.Version,
+		},
+	})
+}
+
 // GetPlaybackStatus 获取播放状态
 // @Summary 获取播放状态
 // @Description 获取房间内视频的当前播放状态
@@ -547,6 +685,99 @@ func (h *RoomHandler) GetPlaybackStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, status)
 }
 
+// UpdateSettings 更新房间设置
+// @Summary 更新房间设置
+// @Description 房主部分更新房间设置（含成员权限），未提供的字段保持不变，变更会推送给在线成员
+// @Tags rooms
+// @Accept json
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Param request body model.RoomSettingsPatch true "房间设置"
+// @Success 200 {object} model.RoomSettings
+// @Router /api/v1/rooms/{room_id}/settings [put]
+func (h *RoomHandler) UpdateSettings(c *gin.Context) {
+	roomID := c.Param("room_id")
+	if !authorizeRoomAction(c, h.roomService, roomID, getClaims(c).SessionID, service.ActionManageRoom) {
+		return
+	}
+
+	var patch model.RoomSettingsPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "无效的请求参数",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	room, err := h.roomService.UpdateSettings(roomID, &patch)
+	if err != nil {
+		c.JSON(settingsErrorStatus(err), gin.H{
+			"error": "更新房间设置失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, room.GetSettings())
+}
+
+// settingsErrorStatus 房间设置相关错误对应的HTTP状态码
+func settingsErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, model.ErrInvalidSettings), errors.Is(err, model.ErrInvalidMaxUsers), errors.Is(err, model.ErrInvalidMediaURL),
+		errors.Is(err, model.ErrRoomPasswordRequired):
+		return http.StatusBadRequest
+	case errors.Is(err, model.ErrRoomNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// authorizeRoomAction 校验成员操作权限，拒绝时写入 403 响应
+func authorizeRoomAction(c *gin.Context, roomService *service.RoomService, roomID, sessionID string, action service.RoomAction) bool {
+	err := roomService.Authorize(roomID, sessionID, action)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, model.ErrMemberMuted):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "你已被房主禁言",
+		})
+	case errors.Is(err, model.ErrNotRoomMember):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "你已不是房间成员",
+		})
+	case errors.Is(err, model.ErrPermissionDenied):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "没有权限执行该操作",
+			"detail": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "权限校验失败",
+			"detail": err.Error(),
+		})
+	}
+	return false
+}
+
+// playbackErrorStatus 播放控制错误对应的HTTP状态码
+func playbackErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, model.ErrRoomNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, model.ErrVersionConflict):
+		return http.StatusConflict
+	case errors.Is(err, model.ErrInvalidPlaybackState):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // ListRooms 获取房间列表
 // @Summary 获取房间列表
 // @Description 获取公开房间列表
@@ -584,6 +815,17 @@ func (h *RoomHandler) ListRooms(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
+// resolveSession 获取请求携带的有效会话，不存在或已过期时创建新的匿名会话
+func resolveSession(sessionService *service.SessionService, sessionID, nickname string) (*model.UserSession, error) {
+	if sessionID != "" {
+		if session, err := sessionService.GetSession(sessionID); err == nil {
+			return session, nil
+		}
+	}
+
+	return sessionService.CreateSession(nickname)
+}
+
 // validateCreateRoomRequest 验证创建房间请求
 func (h *RoomHandler) validateCreateRoomRequest(req *CreateRoomRequest) error {
 	if req.Name == "" {
@@ -594,10 +836,6 @@ func (h *RoomHandler) validateCreateRoomRequest(req *CreateRoomRequest) error {
 		return errors.New("房间名称过长")
 	}
 	
-	if req.MaxUsers <= 0 || req.MaxUsers > 1000 {
-		return errors.New("房间人数限制必须在1-1000之间")
-	}
-	
 	if req.IsPrivate && req.Password == "" {
 		return errors.New("私密房间必须设置密码")
 	}
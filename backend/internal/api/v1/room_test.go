@@ -0,0 +1,365 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/password"
+	"xiaowo/backend/internal/service"
+)
+
+// 测试 WebSocket 与 REST 共用同一份持久化的播放状态
+func TestPlayback_SharedBetweenWebSocketAndREST(t *testing.T) {
+	ts := newTestServer(t)
+	created := ts.createRoom(t)
+	roomPath := "/api/v1/rooms/" + created.Room.ID
+
+	conn, _, err := ts.dialRoom(created.Room.ID, created.Token)
+	if err != nil {
+		t.Fatalf("房主连接失败: %v", err)
+	}
+	defer conn.Close()
+	readUntil(t, conn, "room_state")
+
+	t.Run("TestWebSocketPlayPersisted", func(t *testing.T) {
+		conn.WriteJSON(map[string]interface{}{"type": "play", "start_time": 12.5, "base_version": 0})
+		msg := readUntil(t, conn, "play")
+		if msg["version"].(float64) != 1 {
+			t.Errorf("期望版本: 1, 实际: %v", msg["version"])
+		}
+
+		var status map[string]interface{}
+		if code := ts.doJSON(t, http.MethodGet, roomPath+"/status", "", nil, &status); code != http.StatusOK {
+			t.Fatalf("获取播放状态失败，状态码: %d", code)
+		}
+		if status["playback_state"] != model.PlaybackPlaying || status["current_time"].(float64) < 12.5 {
+			t.Errorf("REST 状态与 WebSocket 操作不一致: %v", status)
+		}
+	})
+
+	t.Run("TestRESTSeekBroadcast", func(t *testing.T) {
+		if code := ts.doJSON(t, http.MethodPost, roomPath+"/seek", created.Token, map[string]interface{}{"current_time": 60}, nil); code != http.StatusOK {
+			t.Fatalf("跳转失败，状态码: %d", code)
+		}
+		msg := readUntil(t, conn, "seek")
+		if msg["target_time"].(float64) != 60 || msg["version"].(float64) != 2 {
+			t.Errorf("广播的跳转状态不正确: %v", msg)
+		}
+	})
+
+	t.Run("TestPlayScheduledAhead", func(t *testing.T) {
+		before := time.Now().UnixMilli()
+		conn.WriteJSON(map[string]interface{}{"type": "rate", "playback_rate": 1.5})
+		msg := readUntil(t, conn, "rate")
+		executeAt, ok := msg["execute_at"].(float64)
+		if !ok || int64(executeAt) < before+300 {
+			t.Errorf("倍速指令应预约在安全余量之后生效: %v", msg)
+		}
+	})
+
+	t.Run("TestCountdownStart", func(t *testing.T) {
+		for _, seconds := range []int{0, 120} {
+			if code := ts.doJSON(t, http.MethodPost, roomPath+"/countdown", created.Token, map[string]interface{}{"seconds": seconds}, nil); code != http.StatusBadRequest {
+				t.Errorf("倒计时 %d 秒应被拒绝，状态码: %d", seconds, code)
+			}
+		}
+
+		before := time.Now().UnixMilli()
+		if code := ts.doJSON(t, http.MethodPost, roomPath+"/countdown", created.Token, map[string]interface{}{"seconds": 5, "start_time": 0.5}, nil); code != http.StatusOK {
+			t.Fatalf("倒计时开播失败，状态码: %d", code)
+		}
+		msg := readUntil(t, conn, "countdown")
+		executeAt, _ := msg["execute_at"].(float64)
+		if int64(executeAt) < before+5000 || int64(executeAt) > before+6000 || msg["current_time"].(float64) != 0.5 || msg["version"].(float64) != 4 {
+			t.Errorf("广播的倒计时状态不正确: %v", msg)
+		}
+
+		// 倒计时结束前位置保持不变
+		var status map[string]interface{}
+		ts.doJSON(t, http.MethodGet, roomPath+"/status", "", nil, &status)
+		if status["playback_state"] != model.PlaybackPlaying || status["current_time"].(float64) != 0.5 {
+			t.Errorf("倒计时期间播放状态不正确: %v", status)
+		}
+	})
+}
+
+// 测试房间设置的默认值、校验、部分合并和推送
+func TestRoomSettings_MergeAndPush(t *testing.T) {
+	ts := newTestServer(t)
+
+	var created RoomResponse
+	status := ts.postJSON(t, "/api/v1/rooms", map[string]interface{}{
+		"name":      "设置测试",
+		"max_users": 5,
+		"media_url": "https://example.com/video.mp4",
+		"settings":  map[string]interface{}{"quality": "720p", "auto_play": false},
+	}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("创建房间失败，状态码: %d", status)
+	}
+	if created.Settings.Quality != "720p" || created.Settings.AutoPlay || !created.Settings.ChatEnabled {
+		t.Errorf("创建时设置未按请求与默认值合并: %+v", created.Settings)
+	}
+	roomPath := "/api/v1/rooms/" + created.Room.ID
+
+	t.Run("TestInvalidSettingsRejected", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			settings map[string]interface{}
+		}{
+			{"Quality", map[string]interface{}{"quality": "8k"}},
+			{"PlaylistMode", map[string]interface{}{"playlist_mode": "repeat"}},
+			{"CredentialHeader", map[string]interface{}{"proxy_headers": map[string]string{"Cookie": "session=1"}}},
+		}
+		for _, tt := range tests {
+			if status := ts.doJSON(t, http.MethodPut, roomPath+"/settings", created.Token, tt.settings, nil); status != http.StatusBadRequest {
+				t.Errorf("%s: 期望状态码: %d, 实际: %d", tt.name, http.StatusBadRequest, status)
+			}
+		}
+	})
+
+	t.Run("TestPartialUpdatePushed", func(t *testing.T) {
+		conn, _, err := ts.dialRoom(created.Room.ID, created.Token)
+		if err != nil {
+			t.Fatalf("房主连接失败: %v", err)
+		}
+		defer conn.Close()
+		if state := readUntil(t, conn, "room_state"); state["settings"].(map[string]interface{})["quality"] != "720p" {
+			t.Errorf("room_state 应包含房间设置: %v", state["settings"])
+		}
+
+		if status := ts.doJSON(t, http.MethodPut, roomPath, created.Token, map[string]interface{}{
+			"settings": map[string]interface{}{"subtitle_lang": "en", "guest_join": false},
+		}, nil); status != http.StatusOK {
+			t.Fatalf("更新房间失败，状态码: %d", status)
+		}
+
+		pushed := readUntil(t, conn, "room_settings")["settings"].(map[string]interface{})
+		if pushed["subtitle_lang"] != "en" || pushed["quality"] != "720p" || pushed["auto_play"] != false {
+			t.Errorf("部分更新不应覆盖其他设置: %v", pushed)
+		}
+
+		status := ts.postJSON(t, roomPath+"/join", map[string]interface{}{"room_id": created.Room.ID}, nil)
+		if status != http.StatusForbidden {
+			t.Errorf("关闭新成员加入后期望状态码: %d, 实际: %d", http.StatusForbidden, status)
+		}
+	})
+}
+
+// 测试私密房间密码哈希存储、校验和防爆破锁定
+func TestRoomPassword_HashAndLockout(t *testing.T) {
+	ts := newTestServer(t)
+
+	var created RoomResponse
+	status := ts.postJSON(t, "/api/v1/rooms", map[string]interface{}{
+		"name":       "私密房间",
+		"max_users":  5,
+		"media_url":  "https://example.com/video.mp4",
+		"is_private": true,
+		"password":   "letmein",
+	}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("创建房间失败，状态码: %d", status)
+	}
+	roomID := created.Room.ID
+	joinPath := "/api/v1/rooms/" + roomID + "/join"
+
+	join := func(name, secret string) int {
+		return ts.postJSON(t, joinPath, map[string]interface{}{
+			"room_id":      roomID,
+			"display_name": name,
+			"password":     secret,
+		}, nil)
+	}
+
+	t.Run("TestStoredHashed", func(t *testing.T) {
+		stored, err := ts.rooms.GetRoom(roomID)
+		if err != nil {
+			t.Fatalf("读取房间失败: %v", err)
+		}
+		if stored.Password == "letmein" || !ValidatePassword("letmein", stored.Password) {
+			t.Errorf("房间密码应以哈希存储: %s", stored.Password)
+		}
+	})
+
+	t.Run("TestCorrectPassword", func(t *testing.T) {
+		if status := join("观众1", "letmein"); status != http.StatusOK {
+			t.Errorf("正确密码期望状态码: %d, 实际: %d", http.StatusOK, status)
+		}
+	})
+
+	t.Run("TestLockoutAfterFailures", func(t *testing.T) {
+		for i := 0; i < password.DefaultMaxAttempts-1; i++ {
+			if status := join("观众2", "wrong"); status != http.StatusUnauthorized {
+				t.Fatalf("错误密码期望状态码: %d, 实际: %d", http.StatusUnauthorized, status)
+			}
+		}
+		if status := join("观众2", "wrong"); status != http.StatusTooManyRequests {
+			t.Fatalf("达到失败上限期望状态码: %d, 实际: %d", http.StatusTooManyRequests, status)
+		}
+		if status := join("观众3", "letmein"); status != http.StatusTooManyRequests {
+			t.Errorf("锁定期间正确密码也应被拒绝，实际状态码: %d", status)
+		}
+	})
+
+	t.Run("TestForwardedForIgnored", func(t *testing.T) {
+		// 未配置可信代理时伪造的 X-Forwarded-For 不能绕过锁定
+		payload, _ := json.Marshal(map[string]interface{}{
+			"room_id":      roomID,
+			"display_name": "观众4",
+			"password":     "letmein",
+		})
+		req, _ := http.NewRequest(http.MethodPost, ts.api.URL+joinPath, bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("请求失败: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Errorf("伪造来源IP期望状态码: %d, 实际: %d", http.StatusTooManyRequests, resp.StatusCode)
+		}
+	})
+
+	t.Run("TestJanitorPrunesAttempts", func(t *testing.T) {
+		stats := ts.janitor.RunOnce()
+		if _, ok := stats.EntriesPruned["password_attempts"]; !ok {
+			t.Errorf("清理结果应包含密码错误记录: %+v", stats.EntriesPruned)
+		}
+	})
+}
+
+// 测试房主修改密码、清除密码改为公开房间，私密房间必须有密码
+func TestRoomPassword_Update(t *testing.T) {
+	ts := newTestServer(t)
+
+	var created RoomResponse
+	status := ts.postJSON(t, "/api/v1/rooms", map[string]interface{}{
+		"name":       "私密房间",
+		"media_url":  "https://example.com/video.mp4",
+		"is_private": true,
+		"password":   "letmein",
+	}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("创建房间失败，状态码: %d", status)
+	}
+	roomID := created.Room.ID
+	roomPath := "/api/v1/rooms/" + roomID
+
+	join := func(name, secret string) int {
+		return ts.postJSON(t, roomPath+"/join", map[string]interface{}{
+			"room_id":      roomID,
+			"display_name": name,
+			"password":     secret,
+		}, nil)
+	}
+	update := func(body map[string]interface{}) int {
+		return ts.doJSON(t, http.MethodPut, roomPath, created.Token, body, nil)
+	}
+
+	t.Run("TestNameOnlyKeepsPassword", func(t *testing.T) {
+		if status := update(map[string]interface{}{"name": "改名"}); status != http.StatusOK {
+			t.Fatalf("更新房间失败，状态码: %d", status)
+		}
+		if status := join("观众1", ""); status != http.StatusUnauthorized {
+			t.Errorf("未修改密码时仍需密码，实际状态码: %d", status)
+		}
+	})
+
+	t.Run("TestClearPasswordMakesPublic", func(t *testing.T) {
+		if status := update(map[string]interface{}{"password": ""}); status != http.StatusOK {
+			t.Fatalf("清除密码失败，状态码: %d", status)
+		}
+		room, _ := ts.rooms.GetRoom(roomID)
+		if room.IsPrivate || room.Password != "" || room.SyncplayPassword != "" {
+			t.Errorf("清除密码后应为公开房间: private=%v", room.IsPrivate)
+		}
+		if status := join("观众2", ""); status != http.StatusOK {
+			t.Errorf("公开房间无需密码，实际状态码: %d", status)
+		}
+	})
+
+	t.Run("TestPrivateRequiresPassword", func(t *testing.T) {
+		if status := update(map[string]interface{}{"is_private": true}); status != http.StatusBadRequest {
+			t.Errorf("没有密码的私密房间期望状态码: %d, 实际: %d", http.StatusBadRequest, status)
+		}
+		if status := update(map[string]interface{}{"is_private": true, "password": "newpass"}); status != http.StatusOK {
+			t.Fatalf("设置密码失败，状态码: %d", status)
+		}
+		if status := join("观众3", "newpass"); status != http.StatusOK {
+			t.Errorf("新密码期望状态码: %d, 实际: %d", http.StatusOK, status)
+		}
+	})
+
+	t.Run("TestMakePublic", func(t *testing.T) {
+		if status := update(map[string]interface{}{"is_private": false}); status != http.StatusOK {
+			t.Fatalf("改为公开失败，状态码: %d", status)
+		}
+		if room, _ := ts.rooms.GetRoom(roomID); room.IsPrivate || room.Password != "" {
+			t.Error("改为公开的房间应清除密码")
+		}
+	})
+}
+
+// 测试创建房间时按配置的人数上限补全和校验
+func TestRoomLimits_DefaultAndMax(t *testing.T) {
+	ts := newTestServer(t)
+	ts.rooms.SetLimits(service.RoomLimits{DefaultMaxUsers: 4, MaxUsers: 8})
+
+	var room RoomResponse
+	if status := ts.postJSON(t, "/api/v1/rooms", map[string]interface{}{
+		"name":      "默认人数",
+		"media_url": "https://example.com/video.mp4",
+	}, &room); status != http.StatusCreated || room.Room.MaxUsers != 4 {
+		t.Errorf("未指定人数时应使用默认上限，状态码: %d, 人数: %d", status, room.Room.MaxUsers)
+	}
+
+	if status := ts.postJSON(t, "/api/v1/rooms", map[string]interface{}{
+		"name":      "超出上限",
+		"max_users": 9,
+		"media_url": "https://example.com/video.mp4",
+	}, nil); status != http.StatusBadRequest {
+		t.Errorf("超出配置上限期望状态码: %d, 实际: %d", http.StatusBadRequest, status)
+	}
+}
+
+// 测试关闭房间后断开房间内的连接，之后不能再加入
+func TestRoomClose_EvictsAndRejectsJoin(t *testing.T) {
+	ts := newTestServer(t)
+	created := ts.createRoom(t)
+	roomID := created.Room.ID
+
+	host, _, err := ts.dialRoom(roomID, created.Token)
+	if err != nil {
+		t.Fatalf("房主连接失败: %v", err)
+	}
+	defer host.Close()
+	readUntil(t, host, "room_state")
+
+	if status := ts.doJSON(t, http.MethodDelete, "/api/v1/rooms/"+roomID, created.Token, nil, nil); status != http.StatusOK {
+		t.Fatalf("关闭房间失败，状态码: %d", status)
+	}
+	readUntil(t, host, "room_closed")
+	host.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		if _, _, err := host.ReadMessage(); err != nil {
+			break
+		}
+	}
+	if online := ts.hub.OnlineSessions(roomID); len(online) != 0 {
+		t.Errorf("关闭房间后不应有在线连接: %v", online)
+	}
+
+	status := ts.postJSON(t, "/api/v1/rooms/"+roomID+"/join", map[string]interface{}{
+		"room_id":      roomID,
+		"display_name": "迟到的朋友",
+	}, nil)
+	if status != http.StatusNotFound {
+		t.Errorf("已关闭的房间期望状态码: %d, 实际: %d", http.StatusNotFound, status)
+	}
+}
@@ -1,24 +1,35 @@
 package v1
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	gorillaWs "github.com/gorilla/websocket"
+	"xiaowo/backend/internal/metrics"
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/service"
+	"xiaowo/backend/internal/token"
 	"xiaowo/backend/internal/websocket"
 )
 
-// SetupRouter 设置路由
-func SetupRouter(roomHandler *RoomHandler, sessionHandler *SessionHandler, healthHandler *HealthHandler, versionHandler *VersionHandler) *gin.Engine {
+// SetupRouter 设置路由，mediaHandler 为空时不开放媒体代理
+func SetupRouter(roomHandler *RoomHandler, sessionHandler *SessionHandler, tokenHandler *TokenHandler, messageHandler *MessageHandler, memberHandler *MemberHandler, playlistHandler *PlaylistHandler, mediaHandler *MediaHandler, subtitleHandler *SubtitleHandler, danmakuHandler *DanmakuHandler, inviteHandler *InviteHandler, adminHandler *AdminHandler, healthHandler *HealthHandler, versionHandler *VersionHandler, tokens *token.Manager, allowOrigins, trustedProxies []string) *gin.Engine {
 	// 设置为发布模式（生产环境）
 	gin.SetMode(gin.ReleaseMode)
 	
 	router := gin.New()
+	// 只信任配置的反向代理转发的客户端IP，密码错误锁定等按IP计数的逻辑依赖于此
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		panic(err) // 加载配置时已校验过格式
+	}
 	
 	// 全局中间件
 	router.Use(gin.Logger())
+	router.Use(MetricsMiddleware())
 	router.Use(gin.Recovery())
-	router.Use(CORSMiddleware())
+	router.Use(CORSMiddleware(allowOrigins))
 	router.Use(HandleError())
 	
 	// 健康检查路由（不需要认证）
@@ -27,8 +38,11 @@ func SetupRouter(roomHandler *RoomHandler, sessionHandler *SessionHandler, healt
 		healthGroup.GET("/health", healthHandler.HealthCheck)
 		healthGroup.GET("/ready", healthHandler.ReadinessCheck)
 		healthGroup.GET("/version", versionHandler.GetVersion)
+		healthGroup.GET("/metrics", gin.WrapH(metrics.Handler()))
 	}
 	
+	auth := AuthMiddleware(tokens)
+	
 	// API v1 路由
 	v1 := router.Group("/api/v1")
 	{
@@ -38,15 +52,58 @@ func SetupRouter(roomHandler *RoomHandler, sessionHandler *SessionHandler, healt
 			roomGroup.POST("", roomHandler.CreateRoom)
 			roomGroup.GET("", roomHandler.ListRooms)
 			roomGroup.GET("/:room_id", roomHandler.GetRoom)
-			roomGroup.PUT("/:room_id", roomHandler.UpdateRoom)
-			roomGroup.DELETE("/:room_id", roomHandler.CloseRoom)
+			roomGroup.PUT("/:room_id", auth, roomHandler.UpdateRoom)
+			roomGroup.DELETE("/:room_id", auth, roomHandler.CloseRoom)
 			roomGroup.GET("/:room_id/members", roomHandler.GetRoomMembers)
+			roomGroup.POST("/:room_id/members/:session_id/mute", auth, memberHandler.MuteMember)
+			roomGroup.POST("/:room_id/members/:session_id/unmute", auth, memberHandler.UnmuteMember)
+			roomGroup.POST("/:room_id/members/:session_id/kick", auth, memberHandler.KickMember)
+			roomGroup.POST("/:room_id/members/:session_id/transfer-host", auth, memberHandler.TransferHost)
+			roomGroup.PUT("/:room_id/settings", auth, roomHandler.UpdateSettings)
 			roomGroup.POST("/:room_id/join", roomHandler.JoinRoom)
-			roomGroup.POST("/:room_id/leave", roomHandler.LeaveRoom)
-			roomGroup.POST("/:room_id/play", roomHandler.PlayVideo)
-			roomGroup.POST("/:room_id/pause", roomHandler.PauseVideo)
-			roomGroup.POST("/:room_id/seek", roomHandler.SeekVideo)
+			roomGroup.POST("/:room_id/leave", auth, roomHandler.LeaveRoom)
+			roomGroup.POST("/:room_id/play", auth, roomHandler.PlayVideo)
+			roomGroup.POST("/:room_id/pause", auth, roomHandler.PauseVideo)
+			roomGroup.POST("/:room_id/seek", auth, roomHandler.SeekVideo)
+			roomGroup.POST("/:room_id/countdown", auth, roomHandler.StartCountdown)
 			roomGroup.GET("/:room_id/status", roomHandler.GetPlaybackStatus)
+			roomGroup.GET("/:room_id/playlist", playlistHandler.GetPlaylist)
+			roomGroup.POST("/:room_id/playlist", auth, playlistHandler.AddItem)
+			roomGroup.POST("/:room_id/playlist/next", auth, playlistHandler.Next)
+			roomGroup.POST("/:room_id/playlist/previous", auth, playlistHandler.Previous)
+			roomGroup.DELETE("/:room_id/playlist/:item_id", auth, playlistHandler.RemoveItem)
+			roomGroup.PUT("/:room_id/playlist/:item_id/position", auth, playlistHandler.MoveItem)
+			roomGroup.POST("/:room_id/playlist/:item_id/current", auth, playlistHandler.SetCurrent)
+			roomGroup.GET("/:room_id/subtitles", subtitleHandler.ListSubtitles)
+			roomGroup.POST("/:room_id/subtitles", auth, subtitleHandler.AddSubtitle)
+			roomGroup.PUT("/:room_id/subtitles/current", auth, subtitleHandler.SelectSubtitle)
+			roomGroup.GET("/:room_id/subtitles/:subtitle_id", subtitleHandler.GetSubtitle)
+			roomGroup.PUT("/:room_id/subtitles/:subtitle_id/offset", auth, subtitleHandler.SetOffset)
+			roomGroup.DELETE("/:room_id/subtitles/:subtitle_id", auth, subtitleHandler.RemoveSubtitle)
+			roomGroup.GET("/:room_id/invites", auth, inviteHandler.ListInvites)
+			roomGroup.POST("/:room_id/invites", auth, inviteHandler.CreateInvite)
+			roomGroup.DELETE("/:room_id/invites/:code", auth, inviteHandler.RevokeInvite)
+			roomGroup.GET("/:room_id/danmaku", auth, danmakuHandler.ListDanmaku)
+			roomGroup.GET("/:room_id/danmaku/export", auth, danmakuHandler.ExportDanmaku)
+			if mediaHandler != nil {
+				roomGroup.GET("/:room_id/media/proxy", mediaHandler.Proxy)
+			}
+			roomGroup.GET("/:room_id/messages", auth, messageHandler.ListMessages)
+			roomGroup.GET("/:room_id/messages/search", auth, messageHandler.SearchMessages)
+		}
+		
+		// 邀请相关路由（凭邀请码加入，不需要认证）
+		inviteGroup := v1.Group("/invites")
+		{
+			inviteGroup.GET("/:code", inviteHandler.GetInvite)
+			inviteGroup.POST("/:code", inviteHandler.RedeemInvite)
+		}
+		
+		// 访问令牌相关路由
+		tokenGroup := v1.Group("/tokens", auth)
+		{
+			tokenGroup.POST("/refresh", tokenHandler.RefreshToken)
+			tokenGroup.POST("/revoke", tokenHandler.RevokeToken)
 		}
 		
 		// 会话相关路由
@@ -59,14 +116,22 @@ func SetupRouter(roomHandler *RoomHandler, sessionHandler *SessionHandler, healt
 			sessionGroup.GET("/:session_id/validate", sessionHandler.ValidateSession)
 			sessionGroup.DELETE("/:session_id", sessionHandler.DeleteSession)
 		}
+		
+		// 运维管理路由（需要管理令牌）
+		adminGroup := v1.Group("/admin", AdminMiddleware(adminHandler.token))
+		{
+			adminGroup.GET("/janitor", adminHandler.GetJanitorStats)
+		}
 	}
 	
 	return router
 }
 
 // SetupWebSocketRouter 设置 WebSocket 路由
-func SetupWebSocketRouter(hub *websocket.WebSocketHub) *gin.Engine {
+func SetupWebSocketRouter(hub *websocket.WebSocketHub, auth websocket.Authenticator, allowOrigins []string) *gin.Engine {
+	checkOrigin := NewOriginChecker(allowOrigins)
 	router := gin.New()
+	router.Use(gin.Recovery())
 	
 	// WebSocket 连接路由
 	router.GET("/ws/room/:room_id", func(c *gin.Context) {
@@ -85,83 +150,80 @@ func SetupWebSocketRouter(hub *websocket.WebSocketHub) *gin.Engine {
 			return
 		}
 		
-		// TODO: 验证令牌和房间权限
+		// 验证令牌和房间权限
+		sessionID, err := auth(token, roomID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "无效的访问令牌",
+				"detail": err.Error(),
+			})
+			c.Abort()
+			return
+		}
 		
 		// 升级为 WebSocket 连接
-		WebSocketHandler(c.Writer, c.Request, hub, roomID, token)
+		WebSocketHandler(c.Writer, c.Request, hub, roomID, sessionID, checkOrigin)
 	})
 	
 	return router
 }
 
-// WebSocketHandler WebSocket 连接处理器
-func WebSocketHandler(w http.ResponseWriter, r *http.Request, hub *websocket.WebSocketHub, roomID, token string) {
-	// 解析 token 获取 session_id
-	sessionID := parseTokenSessionID(token)
-	if sessionID == "" {
-		http.Error(w, "无效的访问令牌", http.StatusUnauthorized)
-		return
-	}
-	
+// WebSocketHandler WebSocket 连接处理器（调用前需已完成令牌校验）
+//
+// 断线重连的客户端在查询参数中带上 resume_token 和 last_seq（上一个连接收到的最后一条广播的 seq），
+// 在等待续接的时间内重连时只补发错过的广播，否则收到完整的 room_state。
+func WebSocketHandler(w http.ResponseWriter, r *http.Request, hub *websocket.WebSocketHub, roomID, sessionID string, checkOrigin func(origin string) bool) {
 	// 配置 WebSocket 升级器
 	upgrader := gorillaWs.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
 		CheckOrigin: func(r *http.Request) bool {
-			// 允许所有来源，实际生产环境应该配置允许的域名
-			return true
+			// 非浏览器客户端不携带 Origin
+			origin := r.Header.Get("Origin")
+			return origin == "" || checkOrigin(origin)
 		},
 	}
 	
-	// 升级 HTTP 连接为 WebSocket 连接
+	// 升级 HTTP 连接为 WebSocket 连接（失败时升级器已写回错误响应）
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		http.Error(w, "WebSocket 连接升级失败", http.StatusInternalServerError)
 		return
 	}
 	
 	// 注册连接到 hub
-	hub.Register(conn, roomID, sessionID)
+	hub.Register(conn, roomID, sessionID, parseResume(r))
 }
 
-// parseTokenSessionID 从令牌中解析会话ID
-func parseTokenSessionID(token string) string {
-	// 简化实现：从令牌中提取 session_id
-	// 实际应该使用 JWT 解析
-	
-	if len(token) < 20 {
-		return ""
+// parseResume 解析续接参数，未携带或格式错误时返回空（按新连接处理）
+func parseResume(r *http.Request) *websocket.ResumeRequest {
+	query := r.URL.Query()
+	resumeToken := query.Get("resume_token")
+	if resumeToken == "" {
+		return nil
 	}
-	
-	// 临时实现：假设 token 格式为 "xiaowo_roomid_sessionid_uuid"
-	parts := splitToken(token)
-	if len(parts) >= 3 {
-		return parts[2]
+	lastSeq, err := strconv.ParseInt(query.Get("last_seq"), 10, 64)
+	if err != nil || lastSeq < 0 {
+		return nil
 	}
-	
-	return ""
+	return &websocket.ResumeRequest{Token: resumeToken, LastSeq: lastSeq}
 }
 
-// splitToken 分割令牌
-func splitToken(token string) []string {
-	// 简化实现：按下划线分割
-	var parts []string
-	current := ""
-	
-	for _, char := range token {
-		if char == '_' {
-			if current != "" {
-				parts = append(parts, current)
-				current = ""
-			}
-		} else {
-			current += string(char)
+// NewRoomAuthenticator 创建房间令牌鉴权器：令牌签名有效且属于该房间，对应会话有效且仍是房间成员
+func NewRoomAuthenticator(tokens *token.Manager, sessionService *service.SessionService, memberService *service.MemberService) websocket.Authenticator {
+	return func(raw, roomID string) (string, error) {
+		claims, err := tokens.ParseForRoom(raw, roomID)
+		if err != nil {
+			return "", err
 		}
+		
+		if !sessionService.IsSessionValid(claims.SessionID) {
+			return "", model.ErrSessionExpired
+		}
+		
+		if !memberService.IsMember(roomID, claims.SessionID) {
+			return "", fmt.Errorf("session %s is not a member of room %s", claims.SessionID, roomID)
+		}
+		
+		return claims.SessionID, nil
 	}
-	
-	if current != "" {
-		parts = append(parts, current)
-	}
-	
-	return parts
-}
\ No newline at end of file
+}
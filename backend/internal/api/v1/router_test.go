@@ -0,0 +1,549 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	gorillaWs "github.com/gorilla/websocket"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"xiaowo/backend/internal/config"
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/proxy"
+	"xiaowo/backend/internal/repository"
+	"xiaowo/backend/internal/service"
+	"xiaowo/backend/internal/token"
+	"xiaowo/backend/internal/webrtc"
+	"xiaowo/backend/internal/websocket"
+)
+
+// 测试服务允许的跨域来源和管理令牌
+const (
+	testOrigin     = "http://localhost:3000"
+	testAdminToken = "router-test-admin-token"
+)
+
+// testServer 测试用的 HTTP/WebSocket 服务
+type testServer struct {
+	db       *gorm.DB
+	api      *httptest.Server
+	ws       *httptest.Server
+	hub      *websocket.WebSocketHub
+	sessions *service.SessionService
+	members  *service.MemberService
+	rooms    *service.RoomService
+	playlist *service.PlaylistService
+	tokens   *token.Manager
+	janitor  *service.Janitor
+	presence *service.PresenceService
+}
+
+// newTestServer 使用内存数据库启动完整的 API 与 WebSocket 服务
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("无法初始化测试数据库: %v", err)
+	}
+	if err := repository.MigrateDatabase(db); err != nil {
+		t.Fatalf("迁移数据库失败: %v", err)
+	}
+
+	roomRepo := repository.NewRoomRepo(db)
+	memberRepo := repository.NewRoomMemberRepo(db)
+	sessionRepo := repository.NewSessionRepo(db)
+	messageRepo := repository.NewMessageRepository(db)
+	playlistRepo := repository.NewPlaylistRepo(db)
+	subtitleRepo := repository.NewSubtitleRepo(db)
+	danmakuRepo := repository.NewDanmakuRepo(db)
+	inviteRepo := repository.NewInviteRepo(db)
+
+	roomService := service.NewRoomService(roomRepo, memberRepo)
+	memberService := service.NewMemberService(memberRepo, roomRepo)
+	sessionService := service.NewSessionService(sessionRepo)
+	messageService := service.NewMessageService(messageRepo, memberRepo)
+	playlistService := service.NewPlaylistService(playlistRepo, roomRepo)
+	subtitleService := service.NewSubtitleService(subtitleRepo, roomRepo, service.SubtitleOptions{})
+	danmakuService := service.NewDanmakuService(danmakuRepo, roomRepo, memberRepo, service.DanmakuOptions{RateLimit: 3, RateWindow: time.Minute})
+	inviteService := service.NewInviteService(inviteRepo, roomRepo)
+	presence := service.NewPresenceService(sessionRepo, memberRepo, roomRepo, service.PresenceOptions{})
+	janitor := service.NewJanitor(roomRepo, sessionRepo, messageRepo, service.JanitorOptions{
+		Interval:         time.Minute,
+		RoomGracePeriod:  30 * time.Minute,
+		RoomRetention:    7 * 24 * time.Hour,
+		MessageRetention: 30 * 24 * time.Hour,
+	})
+
+	tokens, err := token.NewManager("router-test-secret-value", time.Hour)
+	if err != nil {
+		t.Fatalf("创建令牌管理器失败: %v", err)
+	}
+	tokens.PersistWith(repository.NewTokenRepo(db))
+
+	// 测试上游都在本机，需要允许代理回环地址
+	mediaProxy, err := proxy.New(proxy.Options{Secret: "router-test-secret-value", AllowPrivate: true})
+	if err != nil {
+		t.Fatalf("创建媒体代理失败: %v", err)
+	}
+
+	auth := NewRoomAuthenticator(tokens, sessionService, memberService)
+	hub := websocket.NewWebSocketHub(websocket.HubOptions{
+		Authenticator: auth,
+		Playback:      roomService,
+		Chat:          messageService,
+		Permissions:   memberService,
+		Playlist:      playlistService,
+		Subtitles:     subtitleService,
+		Danmaku:       danmakuService,
+		Presence:      presence,
+		ICEServers: webrtc.NewICE(webrtc.Options{
+			STUNURLs:   []string{"stun:stun.example.com:3478"},
+			TURNURLs:   []string{"turn:turn.example.com:3478"},
+			TURNSecret: "router-test-turn-secret",
+		}).Servers,
+	})
+	roomService.OnPlaybackChange(hub.PublishPlayback)
+	memberService.OnMemberChange(hub.PublishMemberEvent)
+	roomService.OnSettingsChange(hub.PublishSettings)
+	roomService.OnRoomClosed(hub.EvictRoom)
+	roomService.ScheduleWith(hub.ScheduleLead)
+	playlistService.OnPlaybackChange(hub.PublishPlayback)
+	playlistService.OnPlaylistChange(hub.PublishPlaylist)
+	playlistService.ScheduleWith(hub.ScheduleLead)
+	subtitleService.OnSubtitleChange(hub.PublishSubtitles)
+	presence.OnMemberChange(func(event string, member *model.RoomMember) {
+		tokens.RevokeSession(member.RoomID, member.SessionID)
+		hub.PublishMemberEvent(event, member)
+	})
+	janitor.TrackLiveRooms(hub.ActiveRoomIDs)
+	janitor.OnRoomClosed(hub.EvictRoom)
+	janitor.PruneWith("password_attempts", roomService.PrunePasswordAttempts)
+	janitor.PruneWith("token_revocations", tokens.Prune)
+
+	allowOrigins := []string{testOrigin}
+	router := SetupRouter(
+		NewRoomHandler(roomService, memberService, sessionService, presence, tokens, config.DefaultFrontendConfig()),
+		NewSessionHandler(sessionService),
+		NewTokenHandler(tokens, memberService),
+		NewMessageHandler(messageService),
+		NewMemberHandler(roomService, memberService, tokens),
+		NewPlaylistHandler(roomService, playlistService),
+		NewMediaHandler(roomService, playlistService, tokens, mediaProxy),
+		NewSubtitleHandler(roomService, subtitleService),
+		NewDanmakuHandler(danmakuService),
+		NewInviteHandler(roomService, memberService, sessionService, inviteService, tokens, config.DefaultFrontendConfig()),
+		NewAdminHandler(janitor, testAdminToken),
+		NewHealthHandler(),
+		NewVersionHandler(),
+		tokens,
+		allowOrigins,
+		nil,
+	)
+
+	ts := &testServer{
+		db:       db,
+		api:      httptest.NewServer(router),
+		ws:       httptest.NewServer(SetupWebSocketRouter(hub, auth, allowOrigins)),
+		hub:      hub,
+		sessions: sessionService,
+		members:  memberService,
+		rooms:    roomService,
+		playlist: playlistService,
+		tokens:   tokens,
+		janitor:  janitor,
+		presence: presence,
+	}
+	t.Cleanup(func() {
+		ts.api.Close()
+		ts.ws.Close()
+		hub.Shutdown()
+		repository.Close(db)
+	})
+
+	return ts
+}
+
+// postJSON 发送 JSON 请求并解析响应
+func (ts *testServer) postJSON(t *testing.T, path string, body interface{}, out interface{}) int {
+	t.Helper()
+
+	payload, _ := json.Marshal(body)
+	resp, err := http.Post(ts.api.URL+path, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("请求 %s 失败: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("解析 %s 响应失败: %v", path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// createRoom 创建测试房间，返回房主的响应
+func (ts *testServer) createRoom(t *testing.T) *RoomResponse {
+	t.Helper()
+
+	var created RoomResponse
+	status := ts.postJSON(t, "/api/v1/rooms", map[string]interface{}{
+		"name":      "测试房间",
+		"max_users": 5,
+		"media_url": "https://example.com/video.mp4",
+	}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("创建房间失败，状态码: %d", status)
+	}
+	return &created
+}
+
+// dialRoom 使用令牌连接房间 WebSocket
+func (ts *testServer) dialRoom(roomID, token string) (*gorillaWs.Conn, *http.Response, error) {
+	url := "ws" + strings.TrimPrefix(ts.ws.URL, "http") + "/ws/room/" + roomID + "?token=" + token
+	return gorillaWs.DefaultDialer.Dial(url, nil)
+}
+
+// readUntil 读取消息直到出现指定类型
+func readUntil(t *testing.T, conn *gorillaWs.Conn, msgType string) map[string]interface{} {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("等待 %s 消息失败: %v", msgType, err)
+		}
+		// 写协程可能将多条消息以换行合并发送
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			var msg map[string]interface{}
+			if err := json.Unmarshal(line, &msg); err != nil {
+				continue
+			}
+			if msg["type"] == msgType {
+				return msg
+			}
+		}
+	}
+}
+
+// 测试房主和加入者都可以通过令牌建立实时连接
+func TestWebSocketRouter_Authentication(t *testing.T) {
+	ts := newTestServer(t)
+	created := ts.createRoom(t)
+
+	t.Run("TestCreatorCanConnect", func(t *testing.T) {
+		conn, _, err := ts.dialRoom(created.Room.ID, created.Token)
+		if err != nil {
+			t.Fatalf("房主连接失败: %v", err)
+		}
+		defer conn.Close()
+
+		readUntil(t, conn, "room_state")
+	})
+
+	t.Run("TestJoinedMemberCanConnect", func(t *testing.T) {
+		var joined JoinRoomResponse
+		status := ts.postJSON(t, "/api/v1/rooms/"+created.Room.ID+"/join", map[string]interface{}{
+			"room_id":      created.Room.ID,
+			"display_name": "观众",
+		}, &joined)
+		if status != http.StatusOK {
+			t.Fatalf("加入房间失败，状态码: %d", status)
+		}
+
+		conn, _, err := ts.dialRoom(created.Room.ID, joined.Token)
+		if err != nil {
+			t.Fatalf("成员连接失败: %v", err)
+		}
+		defer conn.Close()
+
+		readUntil(t, conn, "room_state")
+
+		// 重新认证同一令牌应该成功
+		conn.WriteJSON(map[string]interface{}{"type": "auth", "token": joined.Token})
+		readUntil(t, conn, "auth_success")
+	})
+
+	t.Run("TestForgedTokenRejected", func(t *testing.T) {
+		session, err := ts.sessions.CreateSession("路人")
+		if err != nil {
+			t.Fatalf("创建会话失败: %v", err)
+		}
+
+		// 旧的拼接格式令牌不再被接受
+		_, resp, err := ts.dialRoom(created.Room.ID, "xiaowo_"+created.Room.ID+"_"+created.SessionID)
+		if err == nil {
+			t.Fatal("拼接格式令牌不应该建立连接")
+		}
+		if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("期望状态码: %d, 实际: %v", http.StatusUnauthorized, resp)
+		}
+
+		// 签名有效但会话不是房间成员
+		signed, _, _ := ts.tokens.Issue(created.Room.ID, session.ID, model.RoleMember)
+		_, resp, err = ts.dialRoom(created.Room.ID, signed)
+		if err == nil {
+			t.Fatal("非成员令牌不应该建立连接")
+		}
+		if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("期望状态码: %d, 实际: %v", http.StatusUnauthorized, resp)
+		}
+	})
+
+	t.Run("TestTokenForOtherRoomRejected", func(t *testing.T) {
+		other := ts.createRoom(t)
+
+		_, resp, err := ts.dialRoom(created.Room.ID, other.Token)
+		if err == nil {
+			t.Fatal("其他房间的令牌不应该建立连接")
+		}
+		if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("期望状态码: %d, 实际: %v", http.StatusUnauthorized, resp)
+		}
+	})
+}
+
+// doJSON 发送带令牌的请求并返回状态码
+func (ts *testServer) doJSON(t *testing.T, method, path, bearer string, body interface{}, out interface{}) int {
+	t.Helper()
+
+	payload, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, ts.api.URL+path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("请求 %s 失败: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp.StatusCode
+}
+
+// 测试受保护接口的令牌校验、刷新和吊销
+func TestAuthMiddleware_TokenLifecycle(t *testing.T) {
+	ts := newTestServer(t)
+	created := ts.createRoom(t)
+	other := ts.createRoom(t)
+	roomPath := "/api/v1/rooms/" + created.Room.ID
+
+	t.Run("TestMissingToken", func(t *testing.T) {
+		if status := ts.doJSON(t, http.MethodPost, roomPath+"/play", "", nil, nil); status != http.StatusUnauthorized {
+			t.Errorf("期望状态码: %d, 实际: %d", http.StatusUnauthorized, status)
+		}
+	})
+
+	t.Run("TestTokenForOtherRoom", func(t *testing.T) {
+		if status := ts.doJSON(t, http.MethodPost, roomPath+"/play", other.Token, nil, nil); status != http.StatusForbidden {
+			t.Errorf("期望状态码: %d, 实际: %d", http.StatusForbidden, status)
+		}
+	})
+
+	t.Run("TestRefreshAndRevoke", func(t *testing.T) {
+		var refreshed TokenResponse
+		if status := ts.doJSON(t, http.MethodPost, "/api/v1/tokens/refresh", created.Token, nil, &refreshed); status != http.StatusOK {
+			t.Fatalf("刷新令牌失败，状态码: %d", status)
+		}
+		if refreshed.Role != model.RoleHost || refreshed.RoomID != created.Room.ID {
+			t.Errorf("刷新后的令牌信息不正确: %+v", refreshed)
+		}
+
+		// 旧令牌已失效
+		if status := ts.doJSON(t, http.MethodPost, roomPath+"/play", created.Token, nil, nil); status != http.StatusUnauthorized {
+			t.Errorf("期望状态码: %d, 实际: %d", http.StatusUnauthorized, status)
+		}
+
+		if status := ts.doJSON(t, http.MethodPost, "/api/v1/tokens/revoke", refreshed.Token, nil, nil); status != http.StatusOK {
+			t.Fatalf("吊销令牌失败，状态码: %d", status)
+		}
+		if _, _, err := ts.dialRoom(created.Room.ID, refreshed.Token); err == nil {
+			t.Error("已吊销的令牌不应该建立连接")
+		}
+	})
+}
+
+// joinRoom 以新会话加入房间
+func (ts *testServer) joinRoom(t *testing.T, roomID, name string) *JoinRoomResponse {
+	t.Helper()
+
+	var joined JoinRoomResponse
+	status := ts.postJSON(t, "/api/v1/rooms/"+roomID+"/join", map[string]interface{}{
+		"room_id":      roomID,
+		"display_name": name,
+	}, &joined)
+	if status != http.StatusOK {
+		t.Fatalf("加入房间失败，状态码: %d", status)
+	}
+	return &joined
+}
+
+// 测试跨域来源白名单同时作用于 REST 和 WebSocket
+func TestConfig_Origins(t *testing.T) {
+	ts := newTestServer(t)
+	created := ts.createRoom(t)
+
+	t.Run("TestCORSAllowedOrigin", func(t *testing.T) {
+		for origin, want := range map[string]int{testOrigin: http.StatusOK, "https://evil.example.com": http.StatusForbidden} {
+			req, _ := http.NewRequest(http.MethodOptions, ts.api.URL+"/api/v1/rooms", nil)
+			req.Header.Set("Origin", origin)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("预检请求失败: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != want {
+				t.Errorf("来源 %s 期望状态码: %d, 实际: %d", origin, want, resp.StatusCode)
+			}
+			if allowed := resp.Header.Get("Access-Control-Allow-Origin"); (want == http.StatusOK) != (allowed == origin) {
+				t.Errorf("来源 %s 的 Access-Control-Allow-Origin 不正确: %q", origin, allowed)
+			}
+		}
+	})
+
+	t.Run("TestWebSocketOrigin", func(t *testing.T) {
+		url := "ws" + strings.TrimPrefix(ts.ws.URL, "http") + "/ws/room/" + created.Room.ID + "?token=" + created.Token
+		if _, _, err := gorillaWs.DefaultDialer.Dial(url, http.Header{"Origin": {"https://evil.example.com"}}); err == nil {
+			t.Error("不在白名单的来源不应建立 WebSocket 连接")
+		}
+		conn, _, err := gorillaWs.DefaultDialer.Dial(url, http.Header{"Origin": {testOrigin}})
+		if err != nil {
+			t.Fatalf("白名单来源应能建立连接: %v", err)
+		}
+		conn.Close()
+	})
+}
+
+// 测试 /metrics 暴露 HTTP、WebSocket 和同步指标
+func TestMetrics_Endpoint(t *testing.T) {
+	ts := newTestServer(t)
+	created := ts.createRoom(t)
+
+	conn, _, err := ts.dialRoom(created.Room.ID, created.Token)
+	if err != nil {
+		t.Fatalf("房主连接失败: %v", err)
+	}
+	defer conn.Close()
+	readUntil(t, conn, "room_state")
+
+	// 暂停在 0 秒时上报 0 秒，误差在容忍范围内；ping 在其后处理，收到 pong 说明 sync 已处理
+	conn.WriteJSON(map[string]interface{}{"type": "sync", "room_id": created.Room.ID, "data": map[string]interface{}{"current_time": 0}})
+	conn.WriteJSON(map[string]interface{}{"type": "ping", "client_send_time": time.Now().UnixMilli()})
+	readUntil(t, conn, "pong")
+
+	resp, err := http.Get(ts.api.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("请求 /metrics 失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("期望状态码: %d, 实际: %d", http.StatusOK, resp.StatusCode)
+	}
+	var body bytes.Buffer
+	body.ReadFrom(resp.Body)
+
+	for _, series := range []string{
+		`http_requests_total{method="POST",route="/api/v1/rooms",status="201"}`,
+		`http_request_duration_seconds_bucket{method="POST",route="/api/v1/rooms"`,
+		`xiaowo_websocket_connections_active`,
+		`xiaowo_active_rooms`,
+		`xiaowo_websocket_messages_total{direction="in",type="sync"}`,
+		`xiaowo_websocket_messages_total{direction="out",type="room_state"}`,
+		`xiaowo_room_sync_success_total`,
+		`xiaowo_calibration_rtt_seconds_count`,
+		`xiaowo_clock_offset_seconds_count`,
+	} {
+		if !strings.Contains(body.String(), series) {
+			t.Errorf("指标输出缺少: %s", series)
+		}
+	}
+}
+
+// 测试 WebSocket 路由把 resume_token 和 last_seq 交给 Hub 续接会话
+func TestWebSocketRouter_Resume(t *testing.T) {
+	ts := newTestServer(t)
+	created := ts.createRoom(t)
+
+	conn, _, err := ts.dialRoom(created.Room.ID, created.Token)
+	if err != nil {
+		t.Fatalf("房主连接失败: %v", err)
+	}
+	state := readUntil(t, conn, "room_state")
+	resumeToken, _ := state["resume_token"].(string)
+	if resumeToken == "" || state["seq"] == nil {
+		t.Fatalf("room_state 应包含续接令牌和序号: %v", state)
+	}
+	conn.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.ws.URL, "http") + "/ws/room/" + created.Room.ID +
+		"?token=" + created.Token + "&resume_token=" + resumeToken + "&last_seq=" + strconv.FormatInt(int64(state["seq"].(float64)), 10)
+	back, _, err := gorillaWs.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("重连失败: %v", err)
+	}
+	defer back.Close()
+	readUntil(t, back, "resumed")
+}
+
+// 测试各路由组的挂载、公开访问和令牌校验
+func TestRouter_Routes(t *testing.T) {
+	ts := newTestServer(t)
+	created := ts.createRoom(t)
+	guest := ts.joinRoom(t, created.Room.ID, "观众")
+	roomPath := "/api/v1/rooms/" + created.Room.ID
+
+	tests := []struct {
+		method string
+		path   string
+		bearer string
+		want   int
+	}{
+		{http.MethodGet, "/health", "", http.StatusOK},
+		{http.MethodGet, "/version", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/rooms", "", http.StatusOK},
+		{http.MethodGet, roomPath, "", http.StatusOK},
+		{http.MethodGet, roomPath + "/members", "", http.StatusOK},
+		{http.MethodGet, roomPath + "/status", "", http.StatusOK},
+		{http.MethodGet, roomPath + "/playlist", "", http.StatusOK},
+		{http.MethodGet, roomPath + "/subtitles", "", http.StatusOK},
+		{http.MethodPost, roomPath + "/play", "", http.StatusUnauthorized},
+		{http.MethodPut, roomPath + "/settings", guest.Token, http.StatusForbidden},
+		{http.MethodPost, roomPath + "/members/" + created.SessionID + "/kick", guest.Token, http.StatusForbidden},
+		{http.MethodPost, roomPath + "/playlist", "", http.StatusUnauthorized},
+		{http.MethodPost, roomPath + "/subtitles", "", http.StatusUnauthorized},
+		{http.MethodGet, roomPath + "/messages", "", http.StatusUnauthorized},
+		{http.MethodGet, roomPath + "/messages", guest.Token, http.StatusOK},
+		{http.MethodGet, roomPath + "/messages/search?q=电影", guest.Token, http.StatusOK},
+		{http.MethodGet, roomPath + "/danmaku", "", http.StatusUnauthorized},
+		{http.MethodGet, roomPath + "/danmaku", guest.Token, http.StatusOK},
+		{http.MethodGet, roomPath + "/danmaku/export", guest.Token, http.StatusOK},
+		{http.MethodGet, roomPath + "/media/proxy", "", http.StatusUnauthorized},
+		{http.MethodGet, roomPath + "/invites", guest.Token, http.StatusForbidden},
+		{http.MethodGet, roomPath + "/invites", created.Token, http.StatusOK},
+		{http.MethodGet, "/api/v1/invites/NOSUCHCODE", "", http.StatusNotFound},
+		{http.MethodPost, "/api/v1/tokens/refresh", "", http.StatusUnauthorized},
+		{http.MethodGet, "/api/v1/sessions/" + created.SessionID, "", http.StatusOK},
+		{http.MethodGet, "/api/v1/admin/janitor", "", http.StatusUnauthorized},
+		{http.MethodGet, "/api/v1/admin/janitor", testAdminToken, http.StatusOK},
+		{http.MethodGet, "/api/v1/unknown", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if status := ts.doJSON(t, tt.method, tt.path, tt.bearer, nil, nil); status != tt.want {
+			t.Errorf("%s %s 期望状态码: %d, 实际: %d", tt.method, tt.path, tt.want, status)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package v1
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/service"
+)
+
+// multipartOverhead 上传字幕时表单字段和分隔符允许占用的额外字节数
+const multipartOverhead = 64 << 10
+
+// SubtitleHandler 房间字幕相关API处理器
+type SubtitleHandler struct {
+	roomService     *service.RoomService
+	subtitleService *service.SubtitleService
+}
+
+// NewSubtitleHandler 创建字幕处理器
+func NewSubtitleHandler(roomService *service.RoomService, subtitleService *service.SubtitleService) *SubtitleHandler {
+	return &SubtitleHandler{
+		roomService:     roomService,
+		subtitleService: subtitleService,
+	}
+}
+
+// ListSubtitles 获取字幕列表
+// @Summary 获取字幕列表
+// @Description 获取房间的字幕轨道和当前显示的轨道
+// @Tags subtitle
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Success 200 {object} model.SubtitleTracks
+// @Router /api/v1/rooms/{room_id}/subtitles [get]
+func (h *SubtitleHandler) ListSubtitles(c *gin.Context) {
+	tracks, err := h.subtitleService.GetTracks(c.Param("room_id"))
+	if err != nil {
+		c.JSON(subtitleErrorStatus(err), gin.H{
+			"error":  "获取字幕列表失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, tracks)
+}
+
+// AddSubtitle 添加字幕
+// @Summary 添加字幕
+// @Description 按地址下载或上传 SRT、ASS、VTT 字幕，转换为 WebVTT 保存；房间没有字幕时自动选中，变更会推送给在线成员
+// @Tags subtitle
+// @Accept json,mpfd
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Param request body AddSubtitleRequest false "按地址添加（JSON）"
+// @Param file formData file false "上传的字幕文件（multipart）"
+// @Success 201 {object} model.Subtitle
+// @Failure 413 {object} map[string]interface{} "字幕文件过大"
+// @Router /api/v1/rooms/{room_id}/subtitles [post]
+func (h *SubtitleHandler) AddSubtitle(c *gin.Context) {
+	roomID := c.Param("room_id")
+	sessionID := getClaims(c).SessionID
+	if !authorizeRoomAction(c, h.roomService, roomID, sessionID, service.ActionEditPlaylist) {
+		return
+	}
+
+	var req *service.AddSubtitleRequest
+	var err error
+	if c.ContentType() == gin.MIMEMultipartPOSTForm {
+		req, err = h.uploadRequest(c)
+	} else {
+		var body AddSubtitleRequest
+		if err = c.ShouldBindJSON(&body); err == nil {
+			req = &service.AddSubtitleRequest{URL: body.URL, Label: body.Label, Lang: body.Lang, Offset: body.Offset}
+		}
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.JSON(status, gin.H{
+			"error":  "无效的请求参数",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	track, err := h.subtitleService.AddSubtitle(c.Request.Context(), roomID, sessionID, req)
+	if err != nil {
+		c.JSON(subtitleErrorStatus(err), gin.H{
+			"error":  "添加字幕失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, track)
+}
+
+// uploadRequest 读取 multipart 表单中上传的字幕文件和字段
+func (h *SubtitleHandler) uploadRequest(c *gin.Context) (*service.AddSubtitleRequest, error) {
+	maxSize := h.subtitleService.MaxSize()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+multipartOverhead)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	if header.Size > maxSize {
+		return nil, &http.MaxBytesError{Limit: maxSize}
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &service.AddSubtitleRequest{
+		Filename: header.Filename,
+		Data:     data,
+		Label:    c.PostForm("label"),
+		Lang:     c.PostForm("lang"),
+	}
+	if offset := c.PostForm("offset"); offset != "" {
+		if req.Offset, err = strconv.ParseFloat(offset, 64); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// GetSubtitle 获取字幕内容
+// @Summary 获取字幕内容
+// @Description 返回应用了时间偏移的 WebVTT，可直接作为 <track> 的地址
+// @Tags subtitle
+// @Produce text/vtt
+// @Param room_id path string true "房间ID"
+// @Param subtitle_id path string true "字幕ID"
+// @Success 200 {string} string "WebVTT"
+// @Router /api/v1/rooms/{room_id}/subtitles/{subtitle_id} [get]
+func (h *SubtitleHandler) GetSubtitle(c *gin.Context) {
+	vtt, err := h.subtitleService.VTT(c.Param("room_id"), c.Param("subtitle_id"))
+	if err != nil {
+		c.JSON(subtitleErrorStatus(err), gin.H{
+			"error":  "获取字幕失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	// 偏移随时可能调整，不允许缓存
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "text/vtt; charset=utf-8", vtt)
+}
+
+// SetOffset 调整字幕时间偏移
+// @Summary 调整字幕时间偏移
+// @Description 为正时字幕延后显示，变更会推送给在线成员
+// @Tags subtitle
+// @Accept json
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param subtitle_id path string true "字幕ID"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Param request body SubtitleOffsetRequest true "调整偏移请求"
+// @Success 200 {object} model.SubtitleTracks
+// @Router /api/v1/rooms/{room_id}/subtitles/{subtitle_id}/offset [put]
+func (h *SubtitleHandler) SetOffset(c *gin.Context) {
+	roomID := c.Param("room_id")
+	if !authorizeRoomAction(c, h.roomService, roomID, getClaims(c).SessionID, service.ActionEditPlaylist) {
+		return
+	}
+
+	var req SubtitleOffsetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "无效的请求参数",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	h.respond(c, "调整字幕偏移失败", roomID, h.subtitleService.SetOffset(roomID, c.Param("subtitle_id"), *req.Offset))
+}
+
+// RemoveSubtitle 删除字幕
+// @Summary 删除字幕
+// @Description 删除字幕轨道，删除当前显示的轨道时关闭字幕
+// @Tags subtitle
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param subtitle_id path string true "字幕ID"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Success 200 {object} model.SubtitleTracks
+// @Router /api/v1/rooms/{room_id}/subtitles/{subtitle_id} [delete]
+func (h *SubtitleHandler) RemoveSubtitle(c *gin.Context) {
+	roomID := c.Param("room_id")
+	if !authorizeRoomAction(c, h.roomService, roomID, getClaims(c).SessionID, service.ActionEditPlaylist) {
+		return
+	}
+
+	h.respond(c, "删除字幕失败", roomID, h.subtitleService.RemoveSubtitle(roomID, c.Param("subtitle_id")))
+}
+
+// SelectSubtitle 切换字幕
+// @Summary 切换字幕
+// @Description 切换所有成员显示的字幕轨道，subtitle_id 为空时关闭字幕
+// @Tags subtitle
+// @Accept json
+// @Produce json
+// @Param room_id path string true "房间ID"
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Param request body SelectSubtitleRequest true "切换字幕请求"
+// @Success 200 {object} model.SubtitleTracks
+// @Router /api/v1/rooms/{room_id}/subtitles/current [put]
+func (h *SubtitleHandler) SelectSubtitle(c *gin.Context) {
+	roomID := c.Param("room_id")
+	if !authorizeRoomAction(c, h.roomService, roomID, getClaims(c).SessionID, service.ActionControlPlayback) {
+		return
+	}
+
+	var req SelectSubtitleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "无效的请求参数",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	h.respond(c, "切换字幕失败", roomID, h.subtitleService.SelectSubtitle(roomID, req.SubtitleID))
+}
+
+// respond 返回修改后的字幕列表
+func (h *SubtitleHandler) respond(c *gin.Context, message, roomID string, err error) {
+	var tracks *model.SubtitleTracks
+	if err == nil {
+		tracks, err = h.subtitleService.GetTracks(roomID)
+	}
+	if err != nil {
+		c.JSON(subtitleErrorStatus(err), gin.H{
+			"error":  message,
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, tracks)
+}
+
+// subtitleErrorStatus 字幕错误对应的HTTP状态码
+func subtitleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, model.ErrRoomNotFound), errors.Is(err, model.ErrSubtitleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, model.ErrInvalidSubtitle), errors.Is(err, model.ErrSubtitleLimit):
+		return http.StatusBadRequest
+	case errors.Is(err, model.ErrSubtitleTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, model.ErrSubtitleFetch):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
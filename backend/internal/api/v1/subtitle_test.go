@@ -0,0 +1,165 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"xiaowo/backend/internal/model"
+)
+
+// 测试按地址和上传添加字幕、转换为 WebVTT、调整偏移，并推送给在线成员
+func TestSubtitle_AddConvertAndPush(t *testing.T) {
+	ts := newTestServer(t)
+	created := ts.createRoom(t)
+	basePath := "/api/v1/rooms/" + created.Room.ID + "/subtitles"
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/episode1.srt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("1\r\n00:00:01,000 --> 00:00:02,500\r\n<font color=red>你好</font>\r\n\r\n2\r\n00:00:03,000 --> 00:00:04,000\r\n<i>World</i>\r\n"))
+	}))
+	defer origin.Close()
+
+	conn, _, err := ts.dialRoom(created.Room.ID, created.Token)
+	if err != nil {
+		t.Fatalf("房主连接失败: %v", err)
+	}
+	defer conn.Close()
+	readUntil(t, conn, "room_state")
+
+	getVTT := func(t *testing.T, id string) string {
+		t.Helper()
+		resp, err := http.Get(ts.api.URL + basePath + "/" + id)
+		if err != nil {
+			t.Fatalf("获取字幕失败: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/vtt") {
+			t.Fatalf("期望 200 text/vtt，实际: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	var srt model.Subtitle
+	t.Run("TestAddByURL", func(t *testing.T) {
+		if code := ts.doJSON(t, http.MethodPost, basePath, "", map[string]interface{}{"url": origin.URL + "/episode1.srt"}, nil); code != http.StatusUnauthorized {
+			t.Errorf("期望状态码: %d, 实际: %d", http.StatusUnauthorized, code)
+		}
+
+		code := ts.doJSON(t, http.MethodPost, basePath, created.Token, map[string]interface{}{
+			"url":  origin.URL + "/episode1.srt",
+			"lang": "zh-CN",
+		}, &srt)
+		if code != http.StatusCreated {
+			t.Fatalf("期望状态码: %d, 实际: %d", http.StatusCreated, code)
+		}
+		if srt.Format != "srt" || srt.Label != "episode1" {
+			t.Errorf("期望格式 srt、名称 episode1，实际: %s %s", srt.Format, srt.Label)
+		}
+
+		// 第一条字幕自动选中
+		msg := readUntil(t, conn, "subtitle_change")
+		subtitles := msg["subtitles"].(map[string]interface{})
+		if subtitles["current_id"] != srt.ID {
+			t.Errorf("期望选中 %s，实际: %v", srt.ID, subtitles["current_id"])
+		}
+
+		if vtt := getVTT(t, srt.ID); !strings.HasPrefix(vtt, "WEBVTT\n") || !strings.Contains(vtt, "00:00:01.000 --> 00:00:02.500\n你好\n") {
+			t.Errorf("转换结果不符:\n%s", vtt)
+		}
+
+		if code := ts.doJSON(t, http.MethodPost, basePath, created.Token, map[string]interface{}{"url": origin.URL + "/missing.srt"}, nil); code != http.StatusBadGateway {
+			t.Errorf("期望状态码: %d, 实际: %d", http.StatusBadGateway, code)
+		}
+	})
+
+	t.Run("TestUploadASS", func(t *testing.T) {
+		ass := "[Script Info]\nScriptType: v4.00+\n\n[Events]\nFormat: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n" +
+			"Dialogue: 0,0:00:05.00,0:00:06.50,Default,,0,0,0,,{\\b1}第一行\\N第二行\n"
+
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("label", "特效字幕")
+		part, _ := form.CreateFormFile("file", "episode1.ass")
+		part.Write([]byte(ass))
+		form.Close()
+
+		req, _ := http.NewRequest(http.MethodPost, ts.api.URL+basePath, &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+created.Token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("上传字幕失败: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var uploaded model.Subtitle
+		json.NewDecoder(resp.Body).Decode(&uploaded)
+		if resp.StatusCode != http.StatusCreated || uploaded.Format != "ass" || uploaded.Label != "特效字幕" {
+			t.Fatalf("期望 201 ass 特效字幕，实际: %d %s %s", resp.StatusCode, uploaded.Format, uploaded.Label)
+		}
+		readUntil(t, conn, "subtitle_change")
+
+		if vtt := getVTT(t, uploaded.ID); !strings.Contains(vtt, "00:00:05.000 --> 00:00:06.500\n第一行\n第二行\n") {
+			t.Errorf("转换结果不符:\n%s", vtt)
+		}
+	})
+
+	t.Run("TestOffset", func(t *testing.T) {
+		var tracks model.SubtitleTracks
+		code := ts.doJSON(t, http.MethodPut, basePath+"/"+srt.ID+"/offset", created.Token, map[string]interface{}{"offset": -1.5}, &tracks)
+		if code != http.StatusOK {
+			t.Fatalf("期望状态码: %d, 实际: %d", http.StatusOK, code)
+		}
+		readUntil(t, conn, "subtitle_change")
+
+		// 第一条平移后从 0 开始截断，第二条整体提前
+		vtt := getVTT(t, srt.ID)
+		if !strings.Contains(vtt, "00:00:00.000 --> 00:00:01.000\n你好") || !strings.Contains(vtt, "00:00:01.500 --> 00:00:02.500\n<i>World</i>") {
+			t.Errorf("偏移结果不符:\n%s", vtt)
+		}
+	})
+
+	t.Run("TestSelectAndRemove", func(t *testing.T) {
+		viewer := ts.joinRoom(t, created.Room.ID, "观众")
+		if code := ts.doJSON(t, http.MethodPut, basePath+"/current", viewer.Token, map[string]string{"subtitle_id": ""}, nil); code != http.StatusOK {
+			t.Fatalf("成员默认可以切换字幕，状态码: %d", code)
+		}
+		readUntil(t, conn, "subtitle_change")
+		if code := ts.doJSON(t, http.MethodPut, basePath+"/current", viewer.Token, map[string]string{"subtitle_id": "missing"}, nil); code != http.StatusNotFound {
+			t.Errorf("期望状态码: %d, 实际: %d", http.StatusNotFound, code)
+		}
+
+		conn.WriteJSON(map[string]interface{}{"type": "subtitle_set", "subtitle_id": srt.ID})
+		msg := readUntil(t, conn, "subtitle_change")
+		if msg["subtitles"].(map[string]interface{})["current_id"] != srt.ID {
+			t.Errorf("期望通过实时连接选中 %s", srt.ID)
+		}
+
+		var tracks model.SubtitleTracks
+		if code := ts.doJSON(t, http.MethodDelete, basePath+"/"+srt.ID, created.Token, nil, &tracks); code != http.StatusOK {
+			t.Fatalf("删除字幕失败，状态码: %d", code)
+		}
+		if len(tracks.Tracks) != 1 || tracks.CurrentID != "" {
+			t.Errorf("期望剩余 1 条且关闭字幕，实际: %d %q", len(tracks.Tracks), tracks.CurrentID)
+		}
+
+		resp, err := http.Get(ts.api.URL + basePath + "/" + srt.ID)
+		if err != nil {
+			t.Fatalf("获取字幕失败: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("期望状态码: %d, 实际: %d", http.StatusNotFound, resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"log"
+	"time"
+
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/service"
+	"xiaowo/backend/internal/syncplay"
+	"xiaowo/backend/internal/token"
+)
+
+// NewSyncplayAuthenticator 创建 Syncplay 登录鉴权器
+//
+// 房间名附带该房间的访问令牌时以令牌对应的会话加入；否则按房间密码的 MD5 校验（公开房间留空即可），
+// 规则与加入房间接口相同：校验密码和错误次数锁定、是否允许新成员加入、人数上限，
+// 通过后以 Syncplay 用户名为昵称新建会话，成为普通成员；Syncplay 连接离开房间时移除该成员，
+// 不会在成员列表中留下无人使用的会话。以令牌登录的已有成员不受连接断开影响。
+func NewSyncplayAuthenticator(roomService *service.RoomService, memberService *service.MemberService, sessionService *service.SessionService, tokens *token.Manager) syncplay.Authenticator {
+	roomAuth := NewRoomAuthenticator(tokens, sessionService, memberService)
+	return func(creds syncplay.Credentials) (string, func(), error) {
+		roomID := creds.RoomID
+		if creds.Token != "" {
+			if sessionID, err := roomAuth(creds.Token, roomID); err == nil {
+				return sessionID, nil, nil
+			}
+		}
+
+		room, err := roomService.GetJoinableRoom(roomID)
+		if err != nil {
+			return "", nil, err
+		}
+		if _, err := roomService.VerifySyncplayPassword(room, creds.Password, creds.ClientIP); err != nil {
+			return "", nil, err
+		}
+		if !room.GetSettings().GuestJoin {
+			return "", nil, model.ErrGuestJoinDisabled
+		}
+
+		memberCount, err := memberService.GetMemberCount(roomID)
+		if err != nil {
+			return "", nil, err
+		}
+		if memberCount >= room.MaxUsers {
+			return "", nil, model.ErrRoomFull
+		}
+
+		session, err := sessionService.CreateSession(creds.Username)
+		if err != nil {
+			return "", nil, err
+		}
+		member := &model.RoomMember{
+			RoomID:    roomID,
+			SessionID: session.ID,
+			Role:      model.RoleMember,
+			Nickname:  session.Nickname,
+			Avatar:    session.Avatar,
+			JoinedAt:  time.Now(),
+			LastSeen:  time.Now(),
+		}
+		if err := memberService.AddMember(member); err != nil {
+			return "", nil, err
+		}
+		release := func() {
+			// 期间被转让为房主的成员保留，与离线超时的规则一致
+			if current, err := memberService.GetMember(roomID, session.ID); err != nil || current.Role == model.RoleHost {
+				return
+			}
+			if err := memberService.RemoveMember(roomID, session.ID); err != nil {
+				log.Printf("syncplay: remove member %s from room %s: %v", session.ID, roomID, err)
+			}
+		}
+		if err := sessionService.JoinRoom(session.ID, roomID); err != nil {
+			release()
+			return "", nil, err
+		}
+		return session.ID, release, nil
+	}
+}
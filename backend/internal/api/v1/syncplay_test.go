@@ -0,0 +1,269 @@
+package v1
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"xiaowo/backend/internal/syncplay"
+)
+
+// syncplayClient 测试用的 Syncplay 客户端，按行收发 JSON 消息
+type syncplayClient struct {
+	t        *testing.T
+	conn     net.Conn
+	reader   *bufio.Reader
+	ignoring float64 // 最近收到的服务器强制状态计数，回复 State 时需要确认
+}
+
+// dialSyncplay 连接 Syncplay 服务
+func dialSyncplay(t *testing.T, addr string) *syncplayClient {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("连接 Syncplay 服务失败: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &syncplayClient{t: t, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// send 发送一条协议消息
+func (c *syncplayClient) send(command string, payload interface{}) {
+	c.t.Helper()
+
+	data, _ := json.Marshal(map[string]interface{}{command: payload})
+	if _, err := c.conn.Write(append(data, '\r', '\n')); err != nil {
+		c.t.Fatalf("发送 %s 失败: %v", command, err)
+	}
+}
+
+// sendState 上报播放状态，附带对服务器强制状态的确认
+func (c *syncplayClient) sendState(position float64, paused, doSeek bool) {
+	c.t.Helper()
+
+	state := map[string]interface{}{
+		"playstate": map[string]interface{}{"position": position, "paused": paused, "doSeek": doSeek},
+		"ping":      map[string]interface{}{"clientRtt": 0},
+	}
+	if c.ignoring != 0 {
+		state["ignoringOnTheFly"] = map[string]interface{}{"server": c.ignoring}
+		c.ignoring = 0
+	}
+	c.send("State", state)
+}
+
+// readUntil 读取消息直到出现满足条件的指定命令，match 为空时匹配任意内容
+func (c *syncplayClient) readUntil(command string, match func(payload map[string]interface{}) bool) map[string]interface{} {
+	c.t.Helper()
+
+	c.conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		line, err := c.reader.ReadBytes('\n')
+		if err != nil {
+			c.t.Fatalf("等待 %s 消息失败: %v", command, err)
+		}
+		var msg map[string]map[string]interface{}
+		if err := json.Unmarshal(line, &msg); err != nil {
+			continue
+		}
+		if state, ok := msg["State"]; ok {
+			if ignoring, ok := state["ignoringOnTheFly"].(map[string]interface{}); ok {
+				if server, ok := ignoring["server"].(float64); ok {
+					c.ignoring = server
+				}
+			}
+		}
+		if payload, ok := msg[command]; ok && (match == nil || match(payload)) {
+			return payload
+		}
+	}
+}
+
+// hello 以 Syncplay 客户端的方式登录：密码发送 MD5，房间访问令牌附在房间名后
+func (c *syncplayClient) hello(username, roomID, password string) {
+	c.t.Helper()
+
+	if password != "" {
+		password = fmt.Sprintf("%x", md5.Sum([]byte(password)))
+	}
+	c.send("Hello", map[string]interface{}{
+		"username":    username,
+		"password":    password,
+		"room":        map[string]string{"name": roomID},
+		"version":     "1.2.255",
+		"realversion": "1.7.5",
+		"features":    map[string]interface{}{"sharedPlaylists": true, "chat": true},
+	})
+}
+
+// playstateOf 读取 State 中的播放状态
+func playstateOf(state map[string]interface{}) map[string]interface{} {
+	playstate, _ := state["playstate"].(map[string]interface{})
+	return playstate
+}
+
+// 测试 Syncplay 客户端与浏览器成员共享播放状态、聊天和成员列表
+func TestSyncplay_BridgeWithBrowser(t *testing.T) {
+	ts := newTestServer(t)
+	created := ts.createRoom(t)
+	roomID := created.Room.ID
+
+	server := syncplay.New(syncplay.Options{
+		Hub:           ts.hub,
+		Members:       ts.members,
+		Authenticator: NewSyncplayAuthenticator(ts.rooms, ts.members, ts.sessions, ts.tokens),
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	addr := listener.Addr().String()
+
+	browser, _, err := ts.dialRoom(roomID, created.Token)
+	if err != nil {
+		t.Fatalf("房主连接失败: %v", err)
+	}
+	defer browser.Close()
+	readUntil(t, browser, "room_state")
+
+	client := dialSyncplay(t, addr)
+
+	t.Run("TestLogin", func(t *testing.T) {
+		// 未配置证书时拒绝 TLS，客户端继续明文登录
+		client.send("TLS", map[string]string{"startTLS": "send"})
+		if reply := client.readUntil("TLS", nil); reply["startTLS"] != "false" {
+			t.Errorf("期望拒绝 TLS，实际: %v", reply)
+		}
+
+		client.hello("mpv用户", roomID, "")
+		hello := client.readUntil("Hello", nil)
+		if hello["username"] != "mpv用户" || hello["room"].(map[string]interface{})["name"] != roomID {
+			t.Errorf("Hello 回复不正确: %v", hello)
+		}
+		if features := hello["features"].(map[string]interface{}); features["chat"] != true {
+			t.Errorf("应声明支持聊天: %v", features)
+		}
+
+		state := playstateOf(client.readUntil("State", nil))
+		if state["paused"] != true || state["doSeek"] != true {
+			t.Errorf("登录后应下发房间的暂停状态: %v", state)
+		}
+		playlist := client.readUntil("Set", func(set map[string]interface{}) bool { return set["playlistChange"] != nil })
+		files := playlist["playlistChange"].(map[string]interface{})["files"].([]interface{})
+		if len(files) != 1 || files[0] != "https://example.com/video.mp4" {
+			t.Errorf("共享播放列表应为房间当前的媒体: %v", files)
+		}
+
+		if msg := readUntil(t, browser, "member_join"); msg["member_count"].(float64) != 2 {
+			t.Errorf("浏览器成员应收到 Syncplay 成员上线: %v", msg)
+		}
+	})
+
+	t.Run("TestBrowserPlay", func(t *testing.T) {
+		browser.WriteJSON(map[string]interface{}{"type": "play", "start_time": 10})
+		state := playstateOf(client.readUntil("State", func(state map[string]interface{}) bool {
+			return playstateOf(state)["paused"] == false
+		}))
+		if state["position"].(float64) < 10 || state["setBy"] != "xiaowo" {
+			t.Errorf("预约的开播生效后应下发播放状态: %v", state)
+		}
+	})
+
+	t.Run("TestSyncplayPause", func(t *testing.T) {
+		client.sendState(30, true, false)
+		msg := readUntil(t, browser, "pause")
+		if msg["current_time"].(float64) != 30 || msg["is_playing"] != false {
+			t.Errorf("浏览器应收到 Syncplay 成员的暂停: %v", msg)
+		}
+
+		// 回显以发起者的名义下发，客户端不会重复跳转
+		state := playstateOf(client.readUntil("State", func(state map[string]interface{}) bool {
+			return playstateOf(state)["paused"] == true
+		}))
+		if state["setBy"] != "mpv用户" || state["position"].(float64) != 30 {
+			t.Errorf("暂停回显不正确: %v", state)
+		}
+	})
+
+	t.Run("TestSyncplaySeek", func(t *testing.T) {
+		client.sendState(95, true, true)
+		if msg := readUntil(t, browser, "seek"); msg["target_time"].(float64) != 95 {
+			t.Errorf("浏览器应收到 Syncplay 成员的跳转: %v", msg)
+		}
+	})
+
+	t.Run("TestChat", func(t *testing.T) {
+		client.send("Chat", "大家好")
+		msg := readUntil(t, browser, "chat")
+		if msg["message"] != "大家好" || msg["display_name"] != "mpv用户" {
+			t.Errorf("浏览器收到的聊天消息不正确: %v", msg)
+		}
+
+		browser.WriteJSON(map[string]interface{}{"type": "chat", "message": "欢迎"})
+		chat := client.readUntil("Chat", func(chat map[string]interface{}) bool { return chat["message"] == "欢迎" })
+		if chat["username"] == "" {
+			t.Errorf("Syncplay 聊天消息应带发送者昵称: %v", chat)
+		}
+	})
+
+	t.Run("TestList", func(t *testing.T) {
+		client.send("List", nil)
+		list := client.readUntil("List", nil)
+		users, ok := list[roomID].(map[string]interface{})
+		if !ok || len(users) != 2 || users["mpv用户"] == nil {
+			t.Fatalf("成员列表应包含浏览器成员和 Syncplay 成员: %v", list)
+		}
+	})
+
+	t.Run("TestPrivateRoom", func(t *testing.T) {
+		var private RoomResponse
+		status := ts.postJSON(t, "/api/v1/rooms", map[string]interface{}{
+			"name":       "私密房间",
+			"max_users":  5,
+			"media_url":  "https://example.com/video.mp4",
+			"is_private": true,
+			"password":   "letmein",
+		}, &private)
+		if status != http.StatusCreated {
+			t.Fatalf("创建房间失败，状态码: %d", status)
+		}
+
+		wrong := dialSyncplay(t, addr)
+		wrong.hello("路人", private.Room.ID, "guess")
+		if msg := wrong.readUntil("Error", nil); msg["message"] != "房间密码错误" {
+			t.Errorf("错误密码应被拒绝: %v", msg)
+		}
+
+		guest := dialSyncplay(t, addr)
+		guest.hello("朋友", private.Room.ID, "letmein")
+		if hello := guest.readUntil("Hello", nil); hello["username"] != "朋友" {
+			t.Errorf("正确密码的 MD5 应能登录: %v", hello)
+		}
+
+		// 房间访问令牌以原有会话登录，沿用房间内的昵称
+		host := dialSyncplay(t, addr)
+		host.hello("mpv", private.Room.ID+":"+private.Token, "")
+		if hello := host.readUntil("Hello", nil); hello["username"] == "mpv" || hello["username"] == "" {
+			t.Errorf("令牌登录应沿用房主昵称: %v", hello)
+		}
+	})
+
+	t.Run("TestDisconnectRemovesGuest", func(t *testing.T) {
+		// 以用户名登录时新建的成员在断开后移出房间，不留下无人使用的会话
+		client.conn.Close()
+		if msg := readUntil(t, browser, "member_left"); msg["nickname"] != "mpv用户" {
+			t.Errorf("浏览器成员应收到 Syncplay 成员离开: %v", msg)
+		}
+		if count, _ := ts.members.GetMemberCount(roomID); count != 1 {
+			t.Errorf("断开后房间应只剩房主，实际成员数: %d", count)
+		}
+	})
+}
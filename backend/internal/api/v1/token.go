@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"xiaowo/backend/internal/service"
+	"xiaowo/backend/internal/token"
+)
+
+// TokenHandler 访问令牌相关API处理器
+type TokenHandler struct {
+	tokens        *token.Manager
+	memberService *service.MemberService
+}
+
+// NewTokenHandler 创建令牌处理器
+func NewTokenHandler(tokens *token.Manager, memberService *service.MemberService) *TokenHandler {
+	return &TokenHandler{
+		tokens:        tokens,
+		memberService: memberService,
+	}
+}
+
+// RefreshToken 刷新访问令牌
+// @Summary 刷新访问令牌
+// @Description 使用未过期的访问令牌换取新令牌，旧令牌随即失效
+// @Tags tokens
+// @Produce json
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Success 200 {object} TokenResponse
+// @Router /api/v1/tokens/refresh [post]
+func (h *TokenHandler) RefreshToken(c *gin.Context) {
+	claims := getClaims(c)
+
+	// 角色以当前成员记录为准，已离开房间的会话不能续期
+	member, err := h.memberService.GetMember(claims.RoomID, claims.SessionID)
+	if err != nil {
+		// 尽力吊销：即使失败，已离开的会话也无法再续期
+		h.tokens.RevokeSession(claims.RoomID, claims.SessionID)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "已不是房间成员",
+		})
+		return
+	}
+
+	signed, newClaims, err := h.tokens.Refresh(c.GetString("token"), member.Role)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":  "刷新访问令牌失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, TokenResponse{
+		Token:     signed,
+		RoomID:    newClaims.RoomID,
+		SessionID: newClaims.SessionID,
+		Role:      newClaims.Role,
+		ExpiresAt: newClaims.ExpiresAt.Time,
+	})
+}
+
+// RevokeToken 吊销访问令牌
+// @Summary 吊销访问令牌
+// @Description 使当前访问令牌立即失效
+// @Tags tokens
+// @Produce json
+// @Param Authorization header string true "Bearer 访问令牌"
+// @Success 200 {object} SuccessResponse
+// @Router /api/v1/tokens/revoke [post]
+func (h *TokenHandler) RevokeToken(c *gin.Context) {
+	if err := h.tokens.Revoke(getClaims(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "吊销访问令牌失败",
+			"detail": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Message: "访问令牌已吊销",
+	})
+}
@@ -2,10 +2,11 @@ package v1
 
 import (
 	"errors"
-	"strconv"
 	"time"
 
 	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/password"
+	"xiaowo/backend/internal/service"
 )
 
 // ==================== 请求结构体 ====================
@@ -16,7 +17,7 @@ type CreateRoomRequest struct {
 	Description string `json:"description" example:"一起看《阿凡达》"`                       // 房间描述
 	IsPrivate   bool   `json:"is_private" example:"false"`                               // 是否私密房间
 	Password    string `json:"password" example:""`                                      // 房间密码（私密房间必需）
-	MaxUsers    int    `json:"max_users" binding:"min=1,max=1000" example:"10"`          // 最大用户数
+	MaxUsers    int    `json:"max_users" binding:"omitempty,min=1,max=1000" example:"10"` // 最大用户数（不提供则使用默认值）
 	
 	// 媒体信息
 	MediaURL    string  `json:"media_url" binding:"required" example:"https://example.com/video.mp4"` // 媒体资源URL
@@ -24,14 +25,7 @@ type CreateRoomRequest struct {
 	MediaTitle  string  `json:"media_title" example:"阿凡达"`                                       // 媒体标题
 	MediaDuration float64 `json:"media_duration" example:"7200"`                                    // 媒体总时长(秒)
 	
-	Settings    struct {
-		AutoPlay       bool    `json:"auto_play" example:"true"`           // 自动播放
-		AllowSeek      bool    `json:"allow_seek" example:"true"`          // 允许拖拽
-		DefaultVolume  float64 `json:"default_volume" example:"1.0"`       // 默认音量
-		Quality        string  `json:"quality" example:"auto"`             // 默认画质
-		SubtitleOn     bool    `json:"subtitle_on" example:"false"`       // 字幕开关
-		SubtitleLang   string  `json:"subtitle_lang" example:"zh"`        // 字幕语言
-	} `json:"settings"` // 房间设置
+	Settings    *model.RoomSettingsPatch `json:"settings"` // 房间设置（未提供的字段使用默认值）
 }
 
 // JoinRoomRequest 加入房间请求
@@ -46,8 +40,8 @@ type UpdateRoomRequest struct {
 	Name        string `json:"name" binding:"max=100" example:"新的电影之夜"` // 房间名称
 	Description string `json:"description" example:"今晚看什么电影？"`         // 房间描述
 	IsPrivate   *bool  `json:"is_private"`                              // 是否私密房间
-	Password    string `json:"password" example:""`                    // 房间密码
-	MaxUsers    *int   `json:"max_users" binding:"min=1,max=1000"`     // 最大用户数
+	Password    *string `json:"password" example:""`                   // 房间密码：不提供时不变，空字符串清除密码并改为公开房间
+	MaxUsers    *int   `json:"max_users" binding:"omitempty,min=1,max=1000"` // 最大用户数
 	
 	// 媒体信息（可选更新）
 	MediaURL    *string  `json:"media_url" example:"https://example.com/video2.mp4"` // 媒体资源URL
@@ -55,14 +49,81 @@ type UpdateRoomRequest struct {
 	MediaTitle  *string  `json:"media_title" example:"新的视频标题"`                 // 媒体标题
 	MediaDuration *float64 `json:"media_duration" example:"5400"`                    // 媒体总时长(秒)
 	
-	Settings    struct {
-		AutoPlay       *bool    `json:"auto_play"`           // 自动播放
-		AllowSeek      *bool    `json:"allow_seek"`          // 允许拖拽
-		DefaultVolume  *float64 `json:"default_volume"`      // 默认音量
-		Quality        *string  `json:"quality"`             // 默认画质
-		SubtitleOn     *bool    `json:"subtitle_on"`         // 字幕开关
-		SubtitleLang   *string  `json:"subtitle_lang"`       // 字幕语言
-	} `json:"settings"` // 房间设置
+	Settings    *model.RoomSettingsPatch `json:"settings"` // 房间设置（未提供的字段保持不变）
+}
+
+// AddPlaylistItemRequest 添加播放列表条目请求
+type AddPlaylistItemRequest struct {
+	MediaURL      string  `json:"media_url" binding:"required" example:"https://example.com/episode2.mp4"` // 媒体资源URL
+	MediaType     string  `json:"media_type" example:"video"`                                            // 媒体类型
+	MediaTitle    string  `json:"media_title" example:"第二集"`                                            // 媒体标题
+	MediaDuration float64 `json:"media_duration" example:"1440"`                                         // 媒体总时长(秒)，用于播完自动切换
+	BaseVersion   *int    `json:"base_version"`                                                          // 基础列表版本号（乐观锁）
+}
+
+// MovePlaylistItemRequest 调整播放列表条目位置请求
+type MovePlaylistItemRequest struct {
+	Position    *int `json:"position" binding:"required" example:"0"` // 目标位置（从0开始）
+	BaseVersion *int `json:"base_version"`                            // 基础列表版本号（乐观锁）
+}
+
+// AddSubtitleRequest 按地址添加字幕请求（上传文件时使用 multipart 表单的同名字段）
+type AddSubtitleRequest struct {
+	URL    string  `json:"url" form:"url" binding:"required" example:"https://example.com/episode1.srt"` // 字幕地址（SRT、ASS、VTT 或 Bilibili JSON）
+	Label  string  `json:"label" form:"label" example:"简体中文"`                                           // 显示名称，为空时取文件名
+	Lang   string  `json:"lang" form:"lang" example:"zh-CN"`                                            // 语言标签
+	Offset float64 `json:"offset" form:"offset" example:"0"`                                            // 时间偏移（秒），为正时字幕延后显示
+}
+
+// SubtitleOffsetRequest 调整字幕时间偏移请求
+type SubtitleOffsetRequest struct {
+	Offset *float64 `json:"offset" binding:"required" example:"-1.5"` // 时间偏移（秒），为正时字幕延后显示
+}
+
+// SelectSubtitleRequest 切换字幕请求
+type SelectSubtitleRequest struct {
+	SubtitleID string `json:"subtitle_id" example:"550e8400-e29b-41d4-a716-446655440000"` // 字幕ID，为空时关闭字幕
+}
+
+// CreateInviteRequest 创建邀请请求
+type CreateInviteRequest struct {
+	Role      model.RoomRole `json:"role" example:"member"`  // 通过邀请加入的角色: member/host，为空时为 member
+	MaxUses   int            `json:"max_uses" example:"5"`   // 最多使用次数，0 表示不限
+	ExpiresIn int            `json:"expires_in" binding:"omitempty,min=0,max=2592000" example:"86400"` // 有效期（秒，最长30天），0 表示不过期
+}
+
+// RedeemInviteRequest 通过邀请加入房间请求
+type RedeemInviteRequest struct {
+	DisplayName string `json:"display_name" example:"电影爱好者"` // 显示名称（可选，不提供则使用会话昵称）
+}
+
+// InviteResponse 邀请响应
+type InviteResponse struct {
+	*model.Invite
+	URL string `json:"url"` // 邀请链接（只包含邀请码）
+}
+
+// InvitePreviewResponse 邀请预览响应（使用前查看要加入的房间）
+type InvitePreviewResponse struct {
+	Code        string         `json:"code"`                 // 邀请码
+	Room        *model.Room    `json:"room"`                 // 房间信息
+	MemberCount int            `json:"member_count"`         // 当前成员数量
+	Role        model.RoomRole `json:"role"`                 // 加入后的角色
+	ExpiresAt   *time.Time     `json:"expires_at,omitempty"` // 邀请过期时间
+}
+
+// DanmakuListResponse 按时间窗口查询弹幕的响应
+type DanmakuListResponse struct {
+	MediaURL string           `json:"media_url"` // 弹幕所属的媒体地址
+	From     float64          `json:"from"`      // 窗口起点（秒，含）
+	To       float64          `json:"to"`        // 窗口终点（秒，不含）
+	Danmaku  []*model.Danmaku `json:"danmaku"`   // 弹幕和表情回应（按时间点正序）
+}
+
+// CountdownRequest 倒计时开播请求
+type CountdownRequest struct {
+	Seconds   int      `json:"seconds" example:"5"`      // 倒计时秒数（1-60）
+	StartTime *float64 `json:"start_time" example:"0"`   // 开始播放的位置（秒），不提供则沿用当前位置
 }
 
 // CreateSessionRequest 创建会话请求
@@ -81,10 +142,14 @@ type UpdateSessionRequest struct {
 // RoomResponse 房间响应
 type RoomResponse struct {
 	Room        *model.Room `json:"room"`                  // 房间信息
+	Settings    model.RoomSettings `json:"settings"`       // 房间设置
 	MemberCount int         `json:"member_count"`          // 当前成员数量
 	CreatedBy   string      `json:"created_by"`            // 创建者显示名称
 	CreatedAt   time.Time   `json:"created_at"`            // 创建时间
 	IsCreator   bool        `json:"is_creator"`            // 当前用户是否为创建者
+	SessionID   string      `json:"session_id,omitempty"`  // 创建者会话ID（仅创建时返回）
+	Token       string      `json:"token,omitempty"`       // 创建者访问令牌（仅创建时返回）
+	ExpiresAt   *time.Time  `json:"expires_at,omitempty"`  // 访问令牌过期时间（仅创建时返回）
 }
 
 // SessionResponse 会话响应
@@ -113,6 +178,7 @@ type SessionValidationResponse struct {
 // RoomDetailResponse 房间详情响应
 type RoomDetailResponse struct {
 	Room        *model.Room `json:"room"`                  // 房间信息
+	Settings    model.RoomSettings `json:"settings"`       // 房间设置
 	MemberCount int         `json:"member_count"`          // 当前成员数量
 	CreatedBy   string      `json:"created_by"`            // 创建者显示名称
 	CreatedAt   time.Time   `json:"created_at"`            // 创建时间
@@ -121,10 +187,22 @@ type RoomDetailResponse struct {
 
 // JoinRoomResponse 加入房间响应
 type JoinRoomResponse struct {
-	Room      *model.Room `json:"room"`          // 房间信息
-	SessionID string      `json:"session_id"`    // 会话ID
-	Token     string      `json:"token"`         // 访问令牌
-	JoinURL   string      `json:"join_url"`      // 加入链接
+	Room      *model.Room    `json:"room"`          // 房间信息
+	Settings  model.RoomSettings `json:"settings"`  // 房间设置
+	SessionID string         `json:"session_id"`    // 会话ID
+	Role      model.RoomRole `json:"role"`          // 成员角色
+	Token     string         `json:"token"`         // 访问令牌
+	ExpiresAt time.Time      `json:"expires_at"`    // 令牌过期时间
+	JoinURL   string         `json:"join_url"`      // 房间链接（不含访问令牌）
+}
+
+// TokenResponse 访问令牌响应
+type TokenResponse struct {
+	Token     string         `json:"token"`      // 访问令牌
+	RoomID    string         `json:"room_id"`    // 房间ID
+	SessionID string         `json:"session_id"` // 会话ID
+	Role      model.RoomRole `json:"role"`       // 成员角色
+	ExpiresAt time.Time      `json:"expires_at"` // 过期时间
 }
 
 // RoomListResponse 房间列表响应
@@ -135,6 +213,32 @@ type RoomListResponse struct {
 	Size  int           `json:"size"`  // 每页数量
 }
 
+// MessageResponse 聊天消息响应
+type MessageResponse struct {
+	ID          string            `json:"id"`           // 消息ID
+	RoomID      string            `json:"room_id"`      // 房间ID
+	SessionID   string            `json:"session_id"`   // 发送者会话ID
+	DisplayName string            `json:"display_name"` // 发送者显示名称
+	MessageType model.MessageType `json:"message_type"` // 消息类型
+	Content     string            `json:"content"`      // 消息内容
+	CreatedAt   time.Time         `json:"created_at"`   // 发送时间
+}
+
+// JanitorStatsResponse 后台清理任务状态响应
+type JanitorStatsResponse struct {
+	Runs    int64                 `json:"runs"`     // 累计执行次数
+	LastRun *service.JanitorStats `json:"last_run"` // 最近一次执行结果，尚未执行时为空
+}
+
+// MessageListResponse 聊天记录列表响应
+type MessageListResponse struct {
+	Messages []*MessageResponse `json:"messages"` // 消息列表（按时间正序）
+	Total    int64              `json:"total"`    // 总数量
+	Page     int                `json:"page"`     // 当前页码
+	Size     int                `json:"size"`     // 每页数量
+	HasMore  bool               `json:"has_more"` // 是否有更早的消息
+}
+
 // SuccessResponse 成功响应
 type SuccessResponse struct {
 	Message string    `json:"message"` // 成功消息
@@ -159,11 +263,6 @@ type RoomsListResponse struct {
 
 // ==================== 工具函数 ====================
 
-// generateSessionID 生成匿名会话ID
-func generateSessionID() string {
-	return "sess_" + time.Now().Format("20060102150405") + "_" + strconv.FormatInt(time.Now().UnixNano(), 10)
-}
-
 // generateDisplayName 生成随机显示名称
 func generateDisplayName() string {
 	prefixes := []string{
@@ -183,43 +282,14 @@ func generateDisplayName() string {
 	return prefix + suffix
 }
 
-// generateRoomToken 生成房间访问令牌
-func generateRoomToken(roomID, sessionID string) (string, error) {
-	// 这里应该实现 JWT 令牌生成逻辑
-	// 为了简化 MVP，暂时使用简单的字符串拼接
-	// 实际生产环境应该使用 proper JWT library
-	
-	// 简化实现：使用简单的字符串拼接
-	token := "xiaowo_" + roomID + "_" + sessionID
-	
-	return token, nil
-}
-
-// generateJoinURL 生成加入链接
-func generateJoinURL(roomID, token string) string {
-	// 这里应该使用实际的前端域名
-	baseURL := "http://localhost:3000" // 开发环境
-	return baseURL + "/room/" + roomID + "?token=" + token
-}
-
-// ValidatePassword 验证房间密码
+// ValidatePassword 验证房间密码（恒定时间比较 bcrypt 哈希）
 func ValidatePassword(inputPassword, roomPassword string) bool {
-	if inputPassword == roomPassword {
-		return true
-	}
-	
-	// TODO: 实现密码哈希验证（生产环境需要）
-	// 这里暂时使用明文比较，MVP 简化实现
-	
-	return false
+	return password.Verify(roomPassword, inputPassword)
 }
 
-// HashPassword 密码哈希（预留接口）
-func HashPassword(password string) (string, error) {
-	// TODO: 实现密码哈希
-	// 生产环境应该使用 bcrypt 或 argon2
-	
-	return password, nil
+// HashPassword 密码哈希
+func HashPassword(plain string) (string, error) {
+	return password.Hash(plain)
 }
 
 // CheckRoomPermission 检查房间权限
@@ -0,0 +1,41 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// ErrClosed Broker 已关闭
+var ErrClosed = errors.New("broker closed")
+
+// Event 在实例之间同步的房间事件
+type Event struct {
+	Type      string          `json:"type"`
+	RoomID    string          `json:"room_id"`
+	Origin    string          `json:"origin"`               // 发布事件的实例ID
+	Version   int64           `json:"version,omitempty"`    // 播放状态版本，与播放无关的事件为 0
+	SessionID string          `json:"session_id,omitempty"` // 事件相关的会话
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// Handler 事件回调，同一订阅的事件按 Broker 收到的顺序串行回调
+type Handler func(event Event)
+
+// Subscription 房间订阅
+type Subscription interface {
+	// Unsubscribe 取消订阅，可重复调用
+	Unsubscribe() error
+}
+
+// Broker 房间事件发布订阅
+//
+// 事件按房间分发，发布方自己的订阅也会收到事件，订阅方需要根据 Origin 自行过滤。
+type Broker interface {
+	// Publish 向 event.RoomID 的所有订阅发布事件
+	Publish(ctx context.Context, event Event) error
+	// Subscribe 订阅房间事件，返回时订阅已生效
+	Subscribe(roomID string, handler Handler) (Subscription, error)
+	// Close 关闭 Broker 并取消所有订阅
+	Close() error
+}
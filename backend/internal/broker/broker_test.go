@@ -0,0 +1,197 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// recorder 记录收到的事件
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) handle(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) snapshot() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+// waitEvents 等待收到至少 n 个事件
+func (r *recorder) waitEvents(t *testing.T, n int) []Event {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		events := r.snapshot()
+		if len(events) >= n {
+			return events
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("期望收到 %d 个事件，实际: %d", n, len(events))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// newRedisBroker 基于内嵌 miniredis 创建 Redis Broker
+func newRedisBroker(t *testing.T) *Redis {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	b := NewRedis(client, "test:room:")
+	t.Cleanup(func() {
+		b.Close()
+		client.Close()
+	})
+	return b
+}
+
+// testBroker 两种实现共用的行为测试
+func testBroker(t *testing.T, newBroker func(t *testing.T) Broker) {
+	ctx := context.Background()
+
+	t.Run("TestOrderedDelivery", func(t *testing.T) {
+		b := newBroker(t)
+		var rec recorder
+		if _, err := b.Subscribe("ROOM01", rec.handle); err != nil {
+			t.Fatalf("订阅失败: %v", err)
+		}
+
+		for i := 1; i <= 100; i++ {
+			if err := b.Publish(ctx, Event{Type: "playback", RoomID: "ROOM01", Origin: "a", Version: int64(i)}); err != nil {
+				t.Fatalf("发布失败: %v", err)
+			}
+		}
+		for i, event := range rec.waitEvents(t, 100) {
+			if event.Version != int64(i+1) || event.Origin != "a" {
+				t.Fatalf("第 %d 个事件顺序或内容不正确: %+v", i+1, event)
+			}
+		}
+	})
+
+	t.Run("TestRoomsIsolatedAndFannedOut", func(t *testing.T) {
+		b := newBroker(t)
+		var first, second, other recorder
+		for _, sub := range []struct {
+			roomID string
+			rec    *recorder
+		}{{"ROOM01", &first}, {"ROOM01", &second}, {"ROOM02", &other}} {
+			if _, err := b.Subscribe(sub.roomID, sub.rec.handle); err != nil {
+				t.Fatalf("订阅失败: %v", err)
+			}
+		}
+
+		b.Publish(ctx, Event{Type: "chat", RoomID: "ROOM01", Data: []byte(`{"message":"hi"}`)})
+		b.Publish(ctx, Event{Type: "chat", RoomID: "ROOM02"})
+
+		for _, rec := range []*recorder{&first, &second, &other} {
+			events := rec.waitEvents(t, 1)
+			if len(events) != 1 {
+				t.Errorf("每个订阅期望收到 1 个事件，实际: %d", len(events))
+			}
+		}
+		if got := first.snapshot()[0]; got.RoomID != "ROOM01" || string(got.Data) != `{"message":"hi"}` {
+			t.Errorf("事件内容不正确: %+v", got)
+		}
+	})
+
+	t.Run("TestUnsubscribe", func(t *testing.T) {
+		b := newBroker(t)
+		var gone, kept recorder
+		sub, err := b.Subscribe("ROOM01", gone.handle)
+		if err != nil {
+			t.Fatalf("订阅失败: %v", err)
+		}
+		if _, err := b.Subscribe("ROOM01", kept.handle); err != nil {
+			t.Fatalf("订阅失败: %v", err)
+		}
+
+		sub.Unsubscribe()
+		sub.Unsubscribe()
+		b.Publish(ctx, Event{Type: "chat", RoomID: "ROOM01"})
+		kept.waitEvents(t, 1)
+		if events := gone.snapshot(); len(events) != 0 {
+			t.Errorf("取消订阅后不应再收到事件，实际: %d", len(events))
+		}
+	})
+
+	t.Run("TestResubscribe", func(t *testing.T) {
+		b := newBroker(t)
+		for i := 0; i < 3; i++ {
+			var rec recorder
+			sub, err := b.Subscribe("ROOM01", rec.handle)
+			if err != nil {
+				t.Fatalf("第 %d 次订阅失败: %v", i+1, err)
+			}
+			b.Publish(ctx, Event{Type: "chat", RoomID: "ROOM01", Version: int64(i)})
+			if events := rec.waitEvents(t, 1); events[0].Version != int64(i) {
+				t.Errorf("重新订阅后收到的事件不正确: %+v", events[0])
+			}
+			sub.Unsubscribe()
+		}
+	})
+
+	t.Run("TestConcurrentPublishers", func(t *testing.T) {
+		b := newBroker(t)
+		var rec recorder
+		if _, err := b.Subscribe("ROOM01", rec.handle); err != nil {
+			t.Fatalf("订阅失败: %v", err)
+		}
+
+		var wg sync.WaitGroup
+		for p := 0; p < 5; p++ {
+			wg.Add(1)
+			go func(origin string) {
+				defer wg.Done()
+				for i := 1; i <= 20; i++ {
+					b.Publish(ctx, Event{Type: "playback", RoomID: "ROOM01", Origin: origin, Version: int64(i)})
+				}
+			}(fmt.Sprintf("instance-%d", p))
+		}
+		wg.Wait()
+
+		// 不同发布方的事件可以交错，但同一发布方的事件保持顺序
+		last := make(map[string]int64)
+		for _, event := range rec.waitEvents(t, 100) {
+			if event.Version <= last[event.Origin] {
+				t.Fatalf("实例 %s 的事件乱序: %d 在 %d 之后", event.Origin, event.Version, last[event.Origin])
+			}
+			last[event.Origin] = event.Version
+		}
+	})
+
+	t.Run("TestClosed", func(t *testing.T) {
+		b := newBroker(t)
+		b.Close()
+		if _, err := b.Subscribe("ROOM01", func(Event) {}); err != ErrClosed {
+			t.Errorf("期望错误: %v, 实际: %v", ErrClosed, err)
+		}
+	})
+}
+
+func TestMemoryBroker(t *testing.T) {
+	testBroker(t, func(t *testing.T) Broker {
+		b := NewMemory()
+		t.Cleanup(func() { b.Close() })
+		return b
+	})
+}
+
+func TestRedisBroker(t *testing.T) {
+	testBroker(t, func(t *testing.T) Broker {
+		return newRedisBroker(t)
+	})
+}
@@ -0,0 +1,129 @@
+package broker
+
+import (
+	"context"
+	"sync"
+)
+
+// memoryQueueSize 每个订阅待回调事件的队列长度，队列满时发布方阻塞等待
+const memoryQueueSize = 256
+
+// Memory 进程内 Broker，用于单实例部署和测试
+type Memory struct {
+	mu     sync.RWMutex
+	subs   map[string]map[*memorySubscription]struct{}
+	closed bool
+}
+
+// memorySubscription 进程内订阅，由独立协程按顺序回调
+type memorySubscription struct {
+	broker *Memory
+	roomID string
+	events chan Event
+	quit   chan struct{}
+	once   sync.Once
+}
+
+// NewMemory 创建进程内 Broker
+func NewMemory() *Memory {
+	return &Memory{
+		subs: make(map[string]map[*memorySubscription]struct{}),
+	}
+}
+
+// Publish 实现 Broker
+func (b *Memory) Publish(ctx context.Context, event Event) error {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrClosed
+	}
+	subs := make([]*memorySubscription, 0, len(b.subs[event.RoomID]))
+	for sub := range b.subs[event.RoomID] {
+		subs = append(subs, sub)
+	}
+	b.mu.RUnlock()
+
+	for _, sub := range subs {
+		select {
+		case sub.events <- event:
+		case <-sub.quit:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Subscribe 实现 Broker
+func (b *Memory) Subscribe(roomID string, handler Handler) (Subscription, error) {
+	sub := &memorySubscription{
+		broker: b,
+		roomID: roomID,
+		events: make(chan Event, memoryQueueSize),
+		quit:   make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+	if b.subs[roomID] == nil {
+		b.subs[roomID] = make(map[*memorySubscription]struct{})
+	}
+	b.subs[roomID][sub] = struct{}{}
+
+	go sub.run(handler)
+	return sub, nil
+}
+
+// Close 实现 Broker
+func (b *Memory) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for roomID, subs := range b.subs {
+		for sub := range subs {
+			sub.stop()
+		}
+		delete(b.subs, roomID)
+	}
+	return nil
+}
+
+// run 按顺序回调事件，直到取消订阅
+func (s *memorySubscription) run(handler Handler) {
+	for {
+		select {
+		case event := <-s.events:
+			handler(event)
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// Unsubscribe 实现 Subscription
+func (s *memorySubscription) Unsubscribe() error {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	if subs, ok := s.broker.subs[s.roomID]; ok {
+		delete(subs, s)
+		if len(subs) == 0 {
+			delete(s.broker.subs, s.roomID)
+		}
+	}
+	s.stop()
+	return nil
+}
+
+// stop 停止回调协程（调用方需持有 broker.mu）
+func (s *memorySubscription) stop() {
+	s.once.Do(func() {
+		close(s.quit)
+	})
+}
@@ -0,0 +1,214 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DefaultChannelPrefix 默认频道前缀，房间频道为 prefix + roomID
+	DefaultChannelPrefix = "xiaowo:room:"
+
+	subscribeTimeout = 5 * time.Second // 等待订阅确认的超时时间
+	receiveRetry     = time.Second     // 连接断开后重试接收的间隔
+)
+
+// Redis 基于 Redis 发布订阅的 Broker，用于多实例部署
+//
+// 所有房间共用一条订阅连接，连接断开后由 go-redis 重连并重新订阅；
+// 同一频道的事件按 Redis 收到 PUBLISH 的顺序回调。
+type Redis struct {
+	client *redis.Client
+	prefix string
+	pubsub *redis.PubSub
+
+	mu       sync.Mutex
+	handlers map[string]map[*redisSubscription]Handler // 频道 → 订阅
+	pending  map[string]chan struct{}                  // 频道 → 等待订阅确认
+	closed   bool
+	quit     chan struct{}
+	done     chan struct{}
+}
+
+// redisSubscription Redis 频道订阅
+type redisSubscription struct {
+	broker  *Redis
+	channel string
+}
+
+// NewRedis 创建 Redis Broker，client 由调用方负责关闭
+func NewRedis(client *redis.Client, prefix string) *Redis {
+	if prefix == "" {
+		prefix = DefaultChannelPrefix
+	}
+	b := &Redis{
+		client:   client,
+		prefix:   prefix,
+		pubsub:   client.Subscribe(context.Background()),
+		handlers: make(map[string]map[*redisSubscription]Handler),
+		pending:  make(map[string]chan struct{}),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go b.receive()
+	return b
+}
+
+// Publish 实现 Broker
+func (b *Redis) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+	return b.client.Publish(ctx, b.prefix+event.RoomID, payload).Err()
+}
+
+// Subscribe 实现 Broker，等待 Redis 确认订阅后返回
+func (b *Redis) Subscribe(roomID string, handler Handler) (Subscription, error) {
+	sub := &redisSubscription{broker: b, channel: b.prefix + roomID}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil, ErrClosed
+	}
+	handlers, subscribed := b.handlers[sub.channel]
+	if !subscribed {
+		handlers = make(map[*redisSubscription]Handler)
+		b.handlers[sub.channel] = handlers
+	}
+	handlers[sub] = handler
+
+	ctx, cancel := context.WithTimeout(context.Background(), subscribeTimeout)
+	defer cancel()
+
+	confirmed, waiting := b.pending[sub.channel]
+	if !subscribed {
+		// 持锁发送 SUBSCRIBE，保证与同一频道的 UNSUBSCRIBE 按调用顺序发出
+		confirmed, waiting = make(chan struct{}), true
+		b.pending[sub.channel] = confirmed
+		if err := b.pubsub.Subscribe(ctx, sub.channel); err != nil {
+			b.mu.Unlock()
+			sub.Unsubscribe()
+			return nil, fmt.Errorf("subscribe %s: %w", sub.channel, err)
+		}
+	}
+	b.mu.Unlock()
+
+	if waiting {
+		select {
+		case <-confirmed:
+		case <-ctx.Done():
+			sub.Unsubscribe()
+			return nil, fmt.Errorf("subscribe %s: %w", sub.channel, ctx.Err())
+		}
+	}
+	return sub, nil
+}
+
+// Close 实现 Broker，不关闭 Redis 客户端
+func (b *Redis) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	close(b.quit)
+	b.handlers = make(map[string]map[*redisSubscription]Handler)
+	b.mu.Unlock()
+
+	err := b.pubsub.Close()
+	<-b.done
+	return err
+}
+
+// receive 接收订阅连接上的消息并分发，直到 Close
+func (b *Redis) receive() {
+	defer close(b.done)
+
+	ctx := context.Background()
+	for {
+		msg, err := b.pubsub.Receive(ctx)
+		if err != nil {
+			select {
+			case <-b.quit:
+				return
+			case <-time.After(receiveRetry):
+			}
+			log.Printf("broker: redis receive failed: %v", err)
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			if msg.Kind == "subscribe" {
+				b.confirm(msg.Channel)
+			}
+		case *redis.Message:
+			b.dispatch(msg.Channel, msg.Payload)
+		}
+	}
+}
+
+// confirm 通知等待中的订阅已生效
+func (b *Redis) confirm(channel string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if confirmed, ok := b.pending[channel]; ok {
+		close(confirmed)
+		delete(b.pending, channel)
+	}
+}
+
+// dispatch 解码事件并依次回调频道的订阅
+func (b *Redis) dispatch(channel, payload string) {
+	var event Event
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		log.Printf("broker: drop malformed event on %s: %v", channel, err)
+		return
+	}
+
+	b.mu.Lock()
+	handlers := make([]Handler, 0, len(b.handlers[channel]))
+	for _, handler := range b.handlers[channel] {
+		handlers = append(handlers, handler)
+	}
+	b.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+// Unsubscribe 实现 Subscription，频道没有订阅后退订
+func (s *redisSubscription) Unsubscribe() error {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	handlers, ok := b.handlers[s.channel]
+	if !ok {
+		return nil
+	}
+	if _, ok := handlers[s]; !ok {
+		return nil
+	}
+	delete(handlers, s)
+	if len(handlers) > 0 || b.closed {
+		return nil
+	}
+
+	delete(b.handlers, s.channel)
+	delete(b.pending, s.channel)
+	ctx, cancel := context.WithTimeout(context.Background(), subscribeTimeout)
+	defer cancel()
+	return b.pubsub.Unsubscribe(ctx, s.channel)
+}
@@ -0,0 +1,346 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// JWTConfig 房间访问令牌配置
+type JWTConfig struct {
+	// Secret 签名密钥，为空时启动时生成随机密钥（重启后已签发令牌全部失效）
+	Secret string        `yaml:"secret" env:"JWT_SECRET"`
+	TTL    time.Duration `yaml:"ttl"    env:"JWT_TTL"`
+}
+
+// minJWTSecretLen 签名密钥的最小长度，与 token.NewManager 的要求一致
+const minJWTSecretLen = 16
+
+// DefaultJWTConfig 默认令牌配置
+func DefaultJWTConfig() JWTConfig {
+	return JWTConfig{
+		TTL: 24 * time.Hour,
+	}
+}
+
+func (c JWTConfig) validate() []error {
+	var errs []error
+	if c.Secret != "" && len(c.Secret) < minJWTSecretLen {
+		errs = append(errs, fmt.Errorf("jwt.secret: too short (min %d characters)", minJWTSecretLen))
+	}
+	if c.TTL <= 0 {
+		errs = append(errs, errors.New("jwt.ttl: must be positive"))
+	}
+	return errs
+}
+
+// CORSConfig 跨域配置，同时用于 WebSocket 握手的来源校验
+type CORSConfig struct {
+	// AllowOrigins 允许的来源，"*" 表示允许所有来源（此时跨域请求不携带凭据）
+	AllowOrigins []string `yaml:"allow_origins" env:"CORS_ALLOW_ORIGINS"`
+}
+
+// DefaultCORSConfig 默认跨域配置，只允许默认的前端地址
+func DefaultCORSConfig() CORSConfig {
+	return CORSConfig{
+		AllowOrigins: []string{DefaultFrontendConfig().BaseURL},
+	}
+}
+
+// FrontendConfig 前端配置
+type FrontendConfig struct {
+	// BaseURL 前端地址，用于生成房间邀请链接
+	BaseURL string `yaml:"base_url" env:"FRONTEND_BASE_URL"`
+}
+
+// DefaultFrontendConfig 默认前端配置
+func DefaultFrontendConfig() FrontendConfig {
+	return FrontendConfig{
+		BaseURL: "http://localhost:3000",
+	}
+}
+
+func (c FrontendConfig) validate() []error {
+	u, err := url.Parse(c.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return []error{fmt.Errorf("frontend.base_url: %q is not an http(s) URL", c.BaseURL)}
+	}
+	return nil
+}
+
+// JoinURL 生成房间页面链接，不携带访问令牌（成员在本地保存令牌）
+func (c FrontendConfig) JoinURL(roomID string) string {
+	return strings.TrimRight(c.BaseURL, "/") + "/room/" + url.PathEscape(roomID)
+}
+
+// InviteURL 生成邀请链接，只携带邀请码
+func (c FrontendConfig) InviteURL(code string) string {
+	return strings.TrimRight(c.BaseURL, "/") + "/invite/" + url.PathEscape(code)
+}
+
+// RoomConfig 房间限制
+type RoomConfig struct {
+	DefaultMaxUsers   int           `yaml:"default_max_users"   env:"ROOM_DEFAULT_MAX_USERS"`
+	MaxUsers          int           `yaml:"max_users"           env:"ROOM_MAX_USERS"`
+	BufferingTimeout  time.Duration `yaml:"buffering_timeout"   env:"ROOM_BUFFERING_TIMEOUT"`   // 成员缓冲时暂停等待的最长时间
+	DanmakuRateLimit  int           `yaml:"danmaku_rate_limit"  env:"ROOM_DANMAKU_RATE_LIMIT"`  // 每个会话在限流窗口内最多发送的弹幕和表情数
+	DanmakuRateWindow time.Duration `yaml:"danmaku_rate_window" env:"ROOM_DANMAKU_RATE_WINDOW"` // 弹幕限流窗口
+	ResumeWindow      time.Duration `yaml:"resume_window"       env:"ROOM_RESUME_WINDOW"`       // 连接意外断开后等待客户端续接的时间
+	PresenceFlush     time.Duration `yaml:"presence_flush"      env:"ROOM_PRESENCE_FLUSH"`      // 批量写入在线状态和最后在线时间的间隔
+	OfflineTimeout    time.Duration `yaml:"offline_timeout"     env:"ROOM_OFFLINE_TIMEOUT"`     // 成员离线多久后自动离开房间（房主除外）
+}
+
+// DefaultRoomConfig 默认房间限制
+func DefaultRoomConfig() RoomConfig {
+	return RoomConfig{
+		DefaultMaxUsers:   10,
+		MaxUsers:          1000,
+		BufferingTimeout:  15 * time.Second,
+		DanmakuRateLimit:  5,
+		DanmakuRateWindow: 10 * time.Second,
+		ResumeWindow:      30 * time.Second,
+		PresenceFlush:     5 * time.Second,
+		OfflineTimeout:    30 * time.Minute,
+	}
+}
+
+func (c RoomConfig) validate() []error {
+	var errs []error
+	if c.MaxUsers < 1 || c.MaxUsers > 1000 {
+		errs = append(errs, fmt.Errorf("room.max_users: %d out of range 1-1000", c.MaxUsers))
+	}
+	if c.DefaultMaxUsers < 1 || c.DefaultMaxUsers > c.MaxUsers {
+		errs = append(errs, fmt.Errorf("room.default_max_users: %d must be between 1 and room.max_users", c.DefaultMaxUsers))
+	}
+	if c.BufferingTimeout < time.Second {
+		errs = append(errs, errors.New("room.buffering_timeout: must be at least 1s"))
+	}
+	if c.DanmakuRateLimit < 1 {
+		errs = append(errs, errors.New("room.danmaku_rate_limit: must be at least 1"))
+	}
+	if c.DanmakuRateWindow < time.Second {
+		errs = append(errs, errors.New("room.danmaku_rate_window: must be at least 1s"))
+	}
+	if c.ResumeWindow < time.Second {
+		errs = append(errs, errors.New("room.resume_window: must be at least 1s"))
+	}
+	if c.PresenceFlush < 100*time.Millisecond || c.PresenceFlush > time.Minute {
+		errs = append(errs, errors.New("room.presence_flush: must be between 100ms and 1m"))
+	}
+	if c.OfflineTimeout < 5*time.Minute {
+		errs = append(errs, errors.New("room.offline_timeout: must be at least 5m"))
+	}
+	return errs
+}
+
+// JanitorConfig 后台清理任务配置
+type JanitorConfig struct {
+	Enabled          bool          `yaml:"enabled"           env:"JANITOR_ENABLED"`
+	Interval         time.Duration `yaml:"interval"          env:"JANITOR_INTERVAL"`
+	RoomGracePeriod  time.Duration `yaml:"room_grace_period" env:"JANITOR_ROOM_GRACE_PERIOD"` // 房间无人在线多久后停用
+	RoomRetention    time.Duration `yaml:"room_retention"    env:"JANITOR_ROOM_RETENTION"`    // 停用或关闭的房间保留多久后删除
+	MessageRetention time.Duration `yaml:"message_retention" env:"JANITOR_MESSAGE_RETENTION"` // 聊天记录保留时长
+}
+
+// DefaultJanitorConfig 默认清理配置
+func DefaultJanitorConfig() JanitorConfig {
+	return JanitorConfig{
+		Enabled:          true,
+		Interval:         5 * time.Minute,
+		RoomGracePeriod:  30 * time.Minute,
+		RoomRetention:    7 * 24 * time.Hour,
+		MessageRetention: 30 * 24 * time.Hour,
+	}
+}
+
+func (c JanitorConfig) validate() []error {
+	var errs []error
+	if c.Interval < time.Second {
+		errs = append(errs, errors.New("janitor.interval: must be at least 1s"))
+	}
+	if c.RoomGracePeriod <= 0 {
+		errs = append(errs, errors.New("janitor.room_grace_period: must be positive"))
+	}
+	if c.RoomRetention <= 0 {
+		errs = append(errs, errors.New("janitor.room_retention: must be positive"))
+	}
+	if c.MessageRetention <= 0 {
+		errs = append(errs, errors.New("janitor.message_retention: must be positive"))
+	}
+	return errs
+}
+
+// BrokerConfig 跨实例房间事件配置
+type BrokerConfig struct {
+	// Driver memory 为单实例部署，redis 为多实例部署（所有实例连接同一个 Redis）
+	Driver        string `yaml:"driver"         env:"BROKER_DRIVER"`
+	RedisURL      string `yaml:"redis_url"      env:"REDIS_URL"`
+	ChannelPrefix string `yaml:"channel_prefix" env:"BROKER_CHANNEL_PREFIX"`
+}
+
+// DefaultBrokerConfig 默认跨实例配置
+func DefaultBrokerConfig() BrokerConfig {
+	return BrokerConfig{
+		Driver:        "memory",
+		RedisURL:      "redis://localhost:6379/0",
+		ChannelPrefix: "xiaowo:room:",
+	}
+}
+
+func (c BrokerConfig) validate() []error {
+	switch c.Driver {
+	case "memory":
+		return nil
+	case "redis":
+		u, err := url.Parse(c.RedisURL)
+		if err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+			return []error{fmt.Errorf("broker.redis_url: %q is not a redis URL", c.RedisURL)}
+		}
+		return nil
+	default:
+		return []error{fmt.Errorf("broker.driver: %q must be memory or redis", c.Driver)}
+	}
+}
+
+// MediaConfig 媒体地址探测和代理配置
+type MediaConfig struct {
+	// ProbeEnabled 创建房间和添加播放列表条目时是否探测媒体地址，补全类型、时长和标题
+	ProbeEnabled  bool          `yaml:"probe_enabled"   env:"MEDIA_PROBE_ENABLED"`
+	ProbeTimeout  time.Duration `yaml:"probe_timeout"   env:"MEDIA_PROBE_TIMEOUT"`
+	ProbeCacheTTL time.Duration `yaml:"probe_cache_ttl" env:"MEDIA_PROBE_CACHE_TTL"` // 探测结果缓存时间
+
+	// ProxyEnabled 是否开放 /api/v1/rooms/:room_id/media/proxy 透传代理
+	ProxyEnabled      bool          `yaml:"proxy_enabled"        env:"MEDIA_PROXY_ENABLED"`
+	ProxyAllowPrivate bool          `yaml:"proxy_allow_private"  env:"MEDIA_PROXY_ALLOW_PRIVATE"` // 是否允许代理和探测内网、回环地址
+	ProxyTimeout      time.Duration `yaml:"proxy_timeout"        env:"MEDIA_PROXY_TIMEOUT"`
+	ProxyTargetTTL    time.Duration `yaml:"proxy_target_ttl"     env:"MEDIA_PROXY_TARGET_TTL"` // 播放列表中签名分片地址的有效期
+	ProxyCacheDir     string        `yaml:"proxy_cache_dir"      env:"MEDIA_PROXY_CACHE_DIR"`  // 分片缓存目录，为空时不缓存
+	ProxySliceSize    int64         `yaml:"proxy_slice_size"     env:"MEDIA_PROXY_SLICE_SIZE"` // 缓存分片大小（字节）
+	ProxyCacheMaxSize int64         `yaml:"proxy_cache_max_size" env:"MEDIA_PROXY_CACHE_MAX_SIZE"`
+
+	// 字幕下载和上传限制，按地址下载字幕同样受 ProxyAllowPrivate 约束
+	SubtitleTimeout time.Duration `yaml:"subtitle_timeout"  env:"MEDIA_SUBTITLE_TIMEOUT"`
+	SubtitleMaxSize int64         `yaml:"subtitle_max_size" env:"MEDIA_SUBTITLE_MAX_SIZE"` // 单个字幕文件的最大字节数
+}
+
+// DefaultMediaConfig 默认媒体探测配置
+func DefaultMediaConfig() MediaConfig {
+	return MediaConfig{
+		ProbeEnabled:  true,
+		ProbeTimeout:  5 * time.Second,
+		ProbeCacheTTL: 10 * time.Minute,
+
+		ProxyEnabled:      true,
+		ProxyTimeout:      15 * time.Second,
+		ProxyTargetTTL:    12 * time.Hour,
+		ProxySliceSize:    1 << 20,
+		ProxyCacheMaxSize: 1 << 30,
+
+		SubtitleTimeout: 10 * time.Second,
+		SubtitleMaxSize: 2 << 20,
+	}
+}
+
+func (c MediaConfig) validate() []error {
+	var errs []error
+	if c.ProbeTimeout < 100*time.Millisecond {
+		errs = append(errs, errors.New("media.probe_timeout: must be at least 100ms"))
+	}
+	if c.ProbeCacheTTL <= 0 {
+		errs = append(errs, errors.New("media.probe_cache_ttl: must be positive"))
+	}
+	if c.ProxyTimeout < time.Second {
+		errs = append(errs, errors.New("media.proxy_timeout: must be at least 1s"))
+	}
+	if c.ProxyTargetTTL < time.Minute {
+		errs = append(errs, errors.New("media.proxy_target_ttl: must be at least 1m"))
+	}
+	if c.ProxySliceSize < 64<<10 || c.ProxySliceSize > 64<<20 {
+		errs = append(errs, fmt.Errorf("media.proxy_slice_size: %d out of range 64KiB-64MiB", c.ProxySliceSize))
+	}
+	if c.ProxyCacheMaxSize < c.ProxySliceSize {
+		errs = append(errs, errors.New("media.proxy_cache_max_size: must be at least media.proxy_slice_size"))
+	}
+	if c.SubtitleTimeout < time.Second {
+		errs = append(errs, errors.New("media.subtitle_timeout: must be at least 1s"))
+	}
+	if c.SubtitleMaxSize < 1<<10 || c.SubtitleMaxSize > 32<<20 {
+		errs = append(errs, fmt.Errorf("media.subtitle_max_size: %d out of range 1KiB-32MiB", c.SubtitleMaxSize))
+	}
+	return errs
+}
+
+// SyncplayConfig Syncplay 协议服务配置，供 mpv、VLC 等桌面播放器通过 Syncplay 客户端加入房间
+type SyncplayConfig struct {
+	Enabled bool   `yaml:"enabled"  env:"SYNCPLAY_ENABLED"`
+	Addr    string `yaml:"addr"     env:"SYNCPLAY_ADDR"`
+	TLSCert string `yaml:"tls_cert" env:"SYNCPLAY_TLS_CERT"` // 证书文件，和 tls_key 同时配置时允许客户端协商 TLS
+	TLSKey  string `yaml:"tls_key"  env:"SYNCPLAY_TLS_KEY"`
+	MOTD    string `yaml:"motd"     env:"SYNCPLAY_MOTD"` // 登录后显示的欢迎消息
+}
+
+// DefaultSyncplayConfig 默认 Syncplay 配置（默认关闭，端口与 Syncplay 官方服务器相同）
+func DefaultSyncplayConfig() SyncplayConfig {
+	return SyncplayConfig{
+		Addr: ":8999",
+	}
+}
+
+func (c SyncplayConfig) validate() []error {
+	if !c.Enabled {
+		return nil
+	}
+	var errs []error
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("syncplay.addr: %q is not host:port", c.Addr))
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		errs = append(errs, errors.New("syncplay.tls_cert and syncplay.tls_key must be set together"))
+	}
+	return errs
+}
+
+// WebRTCConfig 房间语音/视频通话的 ICE 服务器配置
+type WebRTCConfig struct {
+	STUNURLs   []string      `yaml:"stun_urls"   env:"WEBRTC_STUN_URLS"`
+	TURNURLs   []string      `yaml:"turn_urls"   env:"WEBRTC_TURN_URLS"`
+	TURNSecret string        `yaml:"turn_secret" env:"WEBRTC_TURN_SECRET"` // 与 TURN 服务器共享的密钥（coturn 的 static-auth-secret）
+	TURNTTL    time.Duration `yaml:"turn_ttl"    env:"WEBRTC_TURN_TTL"`    // 下发的 TURN 临时凭据有效期
+}
+
+// DefaultWebRTCConfig 默认通话配置（不配置 ICE 服务器，只能在可直连的网络之间通话）
+func DefaultWebRTCConfig() WebRTCConfig {
+	return WebRTCConfig{
+		TURNTTL: 24 * time.Hour,
+	}
+}
+
+func (c WebRTCConfig) validate() []error {
+	var errs []error
+	for _, u := range c.STUNURLs {
+		if !strings.HasPrefix(u, "stun:") && !strings.HasPrefix(u, "stuns:") {
+			errs = append(errs, fmt.Errorf("webrtc.stun_urls: %q is not a stun(s): URL", u))
+		}
+	}
+	for _, u := range c.TURNURLs {
+		if !strings.HasPrefix(u, "turn:") && !strings.HasPrefix(u, "turns:") {
+			errs = append(errs, fmt.Errorf("webrtc.turn_urls: %q is not a turn(s): URL", u))
+		}
+	}
+	if len(c.TURNURLs) > 0 && c.TURNSecret == "" {
+		errs = append(errs, errors.New("webrtc.turn_secret: required when webrtc.turn_urls is set"))
+	}
+	if c.TURNTTL < time.Minute {
+		errs = append(errs, errors.New("webrtc.turn_ttl: must be at least 1m"))
+	}
+	return errs
+}
+
+// AdminConfig 管理接口配置
+type AdminConfig struct {
+	// Token 管理接口访问令牌，为空时不开放管理接口
+	Token string `yaml:"token" env:"ADMIN_TOKEN"`
+}
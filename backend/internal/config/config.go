@@ -0,0 +1,102 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config 应用配置
+//
+// 加载顺序：默认值 → YAML 配置文件 → 环境变量，后者覆盖前者。
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	JWT      JWTConfig      `yaml:"jwt"`
+	CORS     CORSConfig     `yaml:"cors"`
+	Frontend FrontendConfig `yaml:"frontend"`
+	Room     RoomConfig     `yaml:"room"`
+	Janitor  JanitorConfig  `yaml:"janitor"`
+	Broker   BrokerConfig   `yaml:"broker"`
+	Media    MediaConfig    `yaml:"media"`
+	Syncplay SyncplayConfig `yaml:"syncplay"`
+	WebRTC   WebRTCConfig   `yaml:"webrtc"`
+	Admin    AdminConfig    `yaml:"admin"`
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() *Config {
+	return &Config{
+		Server:   DefaultServerConfig(),
+		Database: DefaultDatabaseConfig(),
+		JWT:      DefaultJWTConfig(),
+		CORS:     DefaultCORSConfig(),
+		Frontend: DefaultFrontendConfig(),
+		Room:     DefaultRoomConfig(),
+		Janitor:  DefaultJanitorConfig(),
+		Broker:   DefaultBrokerConfig(),
+		Media:    DefaultMediaConfig(),
+		Syncplay: DefaultSyncplayConfig(),
+		WebRTC:   DefaultWebRTCConfig(),
+		Admin:    AdminConfig{},
+	}
+}
+
+// Load 加载配置，path 为空时只使用默认值和环境变量
+func Load(path string) (*Config, error) {
+	conf := DefaultConfig()
+
+	if path != "" {
+		if err := conf.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(conf); err != nil {
+		return nil, fmt.Errorf("load config from env: %w", err)
+	}
+
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// readFile 从 YAML 文件读取配置，未出现的字段保留原值
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate 校验配置，返回所有不合法的配置项
+func (c *Config) Validate() error {
+	var errs []error
+	errs = append(errs, c.Server.validate()...)
+	errs = append(errs, c.Database.validate()...)
+	errs = append(errs, c.JWT.validate()...)
+	errs = append(errs, c.Frontend.validate()...)
+	errs = append(errs, c.Room.validate()...)
+	errs = append(errs, c.Janitor.validate()...)
+	errs = append(errs, c.Broker.validate()...)
+	errs = append(errs, c.Media.validate()...)
+	errs = append(errs, c.Syncplay.validate()...)
+	errs = append(errs, c.WebRTC.validate()...)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
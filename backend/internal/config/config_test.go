@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	return path
+}
+
+// 测试配置文件与环境变量的加载顺序
+func TestLoad(t *testing.T) {
+	t.Run("TestDefaults", func(t *testing.T) {
+		conf, err := Load("")
+		if err != nil {
+			t.Fatalf("加载默认配置失败: %v", err)
+		}
+		if conf.Server.Addr() != ":8080" || conf.Database.Path != "xiaowo.db" {
+			t.Errorf("默认配置不正确: %+v", conf)
+		}
+	})
+
+	t.Run("TestFileThenEnv", func(t *testing.T) {
+		path := writeConfig(t, `
+server:
+  port: 9000
+  read_timeout: 5s
+database:
+  path: /data/test.db
+cors:
+  allow_origins: ["https://a.example.com"]
+frontend:
+  base_url: https://watch.example.com/
+`)
+		t.Setenv("SERVER_PORT", "9100")
+		t.Setenv("CORS_ALLOW_ORIGINS", "https://b.example.com, https://c.example.com")
+		t.Setenv("JWT_TTL", "2h")
+
+		conf, err := Load(path)
+		if err != nil {
+			t.Fatalf("加载配置失败: %v", err)
+		}
+		if conf.Server.Port != 9100 {
+			t.Errorf("环境变量应覆盖配置文件，端口: %d", conf.Server.Port)
+		}
+		if conf.Server.ReadTimeout != 5*time.Second || conf.Server.WSPort != 8081 {
+			t.Errorf("配置文件未覆盖的字段应保留默认值: %+v", conf.Server)
+		}
+		if conf.Database.Path != "/data/test.db" || conf.JWT.TTL != 2*time.Hour {
+			t.Errorf("配置加载结果不正确: %+v %+v", conf.Database, conf.JWT)
+		}
+		if len(conf.CORS.AllowOrigins) != 2 || conf.CORS.AllowOrigins[1] != "https://c.example.com" {
+			t.Errorf("来源列表解析不正确: %v", conf.CORS.AllowOrigins)
+		}
+		if got := conf.Frontend.JoinURL("ABC123"); got != "https://watch.example.com/room/ABC123" {
+			t.Errorf("房间链接不正确: %s", got)
+		}
+		if got := conf.Frontend.InviteURL("K7QX2M9PLA"); got != "https://watch.example.com/invite/K7QX2M9PLA" {
+			t.Errorf("邀请链接不正确: %s", got)
+		}
+	})
+
+	t.Run("TestValidationErrors", func(t *testing.T) {
+		path := writeConfig(t, `
+server:
+  port: 70000
+  trusted_proxies: [10.0.0.0/8, proxy.internal]
+jwt:
+  secret: dev-secret-key
+room:
+  default_max_users: 20
+  max_users: 10
+broker:
+  driver: kafka
+webrtc:
+  turn_urls: [turn:turn.example.com:3478]
+`)
+		_, err := Load(path)
+		if err == nil {
+			t.Fatal("非法配置应返回错误")
+		}
+		for _, key := range []string{"server.port", "server.trusted_proxies", "jwt.secret", "room.default_max_users", "broker.driver", "webrtc.turn_secret"} {
+			if !strings.Contains(err.Error(), key) {
+				t.Errorf("错误信息应包含 %s: %v", key, err)
+			}
+		}
+	})
+
+	t.Run("TestUnknownField", func(t *testing.T) {
+		if _, err := Load(writeConfig(t, "server:\n  prot: 9000\n")); err == nil {
+			t.Error("未知配置项应返回错误")
+		}
+	})
+
+	t.Run("TestInvalidEnv", func(t *testing.T) {
+		t.Setenv("SERVER_IDLE_TIMEOUT", "soon")
+		if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "SERVER_IDLE_TIMEOUT") {
+			t.Errorf("无法解析的环境变量应返回错误: %v", err)
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DatabaseConfig SQLite 数据库配置
+type DatabaseConfig struct {
+	Path            string        `yaml:"path"              env:"DB_SOURCE"`
+	MaxOpenConns    int           `yaml:"max_open_conns"    env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"    env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+}
+
+// DefaultDatabaseConfig 默认数据库配置
+//
+// SQLite 建议单连接写入，避免并发写时出现 "database is locked"。
+func DefaultDatabaseConfig() DatabaseConfig {
+	return DatabaseConfig{
+		Path:            "xiaowo.db",
+		MaxOpenConns:    1,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: time.Hour,
+	}
+}
+
+func (c DatabaseConfig) validate() []error {
+	var errs []error
+	if c.Path == "" {
+		errs = append(errs, errors.New("database.path: must not be empty"))
+	}
+	if c.MaxOpenConns < 1 {
+		errs = append(errs, fmt.Errorf("database.max_open_conns: %d must be at least 1", c.MaxOpenConns))
+	}
+	if c.MaxIdleConns < 0 || c.MaxIdleConns > c.MaxOpenConns {
+		errs = append(errs, fmt.Errorf("database.max_idle_conns: %d must be between 0 and max_open_conns", c.MaxIdleConns))
+	}
+	if c.ConnMaxLifetime < 0 {
+		errs = append(errs, errors.New("database.conn_max_lifetime: must not be negative"))
+	}
+	return errs
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// ServerConfig HTTP 与 WebSocket 服务配置
+type ServerConfig struct {
+	Host         string        `yaml:"host"          env:"SERVER_HOST"`
+	Port         int           `yaml:"port"          env:"SERVER_PORT"`
+	WSPort       int           `yaml:"ws_port"       env:"SERVER_WS_PORT"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"  env:"SERVER_READ_TIMEOUT"`
+	WriteTimeout time.Duration `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"  env:"SERVER_IDLE_TIMEOUT"`
+
+	// WebSocket 服务器超时（长连接，通常比普通 HTTP 更宽松）
+	WSReadTimeout  time.Duration `yaml:"ws_read_timeout"  env:"SERVER_WS_READ_TIMEOUT"`
+	WSWriteTimeout time.Duration `yaml:"ws_write_timeout" env:"SERVER_WS_WRITE_TIMEOUT"`
+	WSIdleTimeout  time.Duration `yaml:"ws_idle_timeout"  env:"SERVER_WS_IDLE_TIMEOUT"`
+
+	// TrustedProxies 可信反向代理的 IP 或网段，只有来自这些地址的请求才采用 X-Forwarded-For
+	// 中的客户端IP；为空时不信任任何代理，直接使用连接的远端地址
+	TrustedProxies []string `yaml:"trusted_proxies" env:"SERVER_TRUSTED_PROXIES"`
+}
+
+// DefaultServerConfig 默认服务配置
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		Host:           "",
+		Port:           8080,
+		WSPort:         8081,
+		ReadTimeout:    10 * time.Second,
+		WriteTimeout:   10 * time.Second,
+		IdleTimeout:    60 * time.Second,
+		WSReadTimeout:  30 * time.Second,
+		WSWriteTimeout: 30 * time.Second,
+		WSIdleTimeout:  120 * time.Second,
+	}
+}
+
+// Addr HTTP 服务监听地址
+func (c ServerConfig) Addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// WSAddr WebSocket 服务监听地址
+func (c ServerConfig) WSAddr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.WSPort))
+}
+
+func (c ServerConfig) validate() []error {
+	var errs []error
+	if !validPort(c.Port) {
+		errs = append(errs, fmt.Errorf("server.port: %d out of range 1-65535", c.Port))
+	}
+	if !validPort(c.WSPort) {
+		errs = append(errs, fmt.Errorf("server.ws_port: %d out of range 1-65535", c.WSPort))
+	}
+	if c.Port == c.WSPort {
+		errs = append(errs, errors.New("server.port and server.ws_port must differ"))
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		if net.ParseIP(proxy) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(proxy); err != nil {
+			errs = append(errs, fmt.Errorf("server.trusted_proxies: %q is not an IP or CIDR", proxy))
+		}
+	}
+
+	timeouts := map[string]time.Duration{
+		"server.read_timeout":     c.ReadTimeout,
+		"server.write_timeout":    c.WriteTimeout,
+		"server.idle_timeout":     c.IdleTimeout,
+		"server.ws_read_timeout":  c.WSReadTimeout,
+		"server.ws_write_timeout": c.WSWriteTimeout,
+		"server.ws_idle_timeout":  c.WSIdleTimeout,
+	}
+	for _, name := range sortedKeys(timeouts) {
+		if timeouts[name] <= 0 {
+			errs = append(errs, fmt.Errorf("%s: must be positive", name))
+		}
+	}
+	return errs
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
@@ -0,0 +1,141 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// hlsPlaylist 解析后的 HLS 播放列表
+type hlsPlaylist struct {
+	master   bool
+	variants []Variant
+	duration float64 // 各分片时长之和
+	ended    bool    // 有 EXT-X-ENDLIST 或播放列表类型为 VOD
+}
+
+// probeHLS 解析 HLS 播放列表：主播放列表取出码率列表，再读取第一路码率的媒体播放列表获取时长
+//
+// 媒体播放列表没有结束标记时视为直播，时长为 0。
+func (p *Prober) probeHLS(ctx context.Context, base *url.URL, info *Info) error {
+	playlist, err := p.fetchPlaylist(ctx, base.String())
+	if err != nil {
+		return err
+	}
+
+	if playlist.master {
+		if len(playlist.variants) == 0 {
+			return fmt.Errorf("%w: master playlist without variants", ErrNotMedia)
+		}
+		info.Variants = playlist.variants
+		// 按码率从高到低排列，媒体播放列表的时长各码率相同，读取第一路即可
+		first := playlist.variants[0].URL
+		sort.SliceStable(info.Variants, func(i, j int) bool {
+			return info.Variants[i].Bandwidth > info.Variants[j].Bandwidth
+		})
+
+		playlist, err = p.fetchPlaylist(ctx, first)
+		if err != nil {
+			return err
+		}
+		if playlist.master {
+			return fmt.Errorf("%w: nested master playlist", ErrNotMedia)
+		}
+	}
+
+	info.Live = !playlist.ended
+	if !info.Live {
+		info.Duration = playlist.duration
+	}
+	return nil
+}
+
+// fetchPlaylist 下载并解析播放列表，相对地址按跟随重定向后的地址解析
+func (p *Prober) fetchPlaylist(ctx context.Context, rawURL string) (*hlsPlaylist, error) {
+	data, final, err := p.get(ctx, rawURL, maxPlaylistSize)
+	if err != nil {
+		return nil, err
+	}
+	return parsePlaylist(data, final)
+}
+
+// parsePlaylist 解析 M3U8 文本
+func parsePlaylist(data []byte, base *url.URL) (*hlsPlaylist, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxPlaylistSize)
+
+	if !scanner.Scan() || !strings.HasPrefix(strings.TrimPrefix(scanner.Text(), "\ufeff"), "#EXTM3U") {
+		return nil, fmt.Errorf("%w: missing #EXTM3U header", ErrNotMedia)
+	}
+
+	playlist := &hlsPlaylist{}
+	var pending *Variant // 等待下一行地址的 EXT-X-STREAM-INF
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			playlist.master = true
+			attrs := parseAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+			bandwidth, _ := strconv.Atoi(attrs["BANDWIDTH"])
+			pending = &Variant{Bandwidth: bandwidth, Resolution: attrs["RESOLUTION"], Codecs: attrs["CODECS"]}
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value := strings.TrimPrefix(line, "#EXTINF:")
+			if i := strings.IndexByte(value, ','); i >= 0 {
+				value = value[:i]
+			}
+			if seconds, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && seconds > 0 {
+				playlist.duration += seconds
+			}
+		case line == "#EXT-X-ENDLIST", line == "#EXT-X-PLAYLIST-TYPE:VOD":
+			playlist.ended = true
+		case strings.HasPrefix(line, "#"):
+		case pending != nil:
+			ref, err := base.Parse(line)
+			if err == nil {
+				pending.URL = ref.String()
+				playlist.variants = append(playlist.variants, *pending)
+			}
+			pending = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotMedia, err)
+	}
+	return playlist, nil
+}
+
+// parseAttributes 解析属性列表（KEY=VALUE,KEY="VALUE,WITH,COMMAS"）
+func parseAttributes(list string) map[string]string {
+	attrs := make(map[string]string)
+	for len(list) > 0 {
+		eq := strings.IndexByte(list, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.TrimSpace(list[:eq])
+		list = list[eq+1:]
+
+		var value string
+		if strings.HasPrefix(list, `"`) {
+			end := strings.IndexByte(list[1:], '"')
+			if end < 0 {
+				value, list = list[1:], ""
+			} else {
+				value, list = list[1:end+1], list[end+2:]
+			}
+		} else if comma := strings.IndexByte(list, ','); comma >= 0 {
+			value, list = list[:comma], list[comma:]
+		} else {
+			value, list = list, ""
+		}
+		attrs[key] = value
+		list = strings.TrimPrefix(list, ",")
+	}
+	return attrs
+}
@@ -0,0 +1,227 @@
+package media
+
+import (
+	"context"
+	"encoding/binary"
+	"strings"
+)
+
+const (
+	mp4HeadSize  = 64 << 10 // 首次读取的字节数，moov 在文件开头时通常已包含
+	mp4MaxMoov   = 4 << 20  // moov 最多读取的字节数
+	mp4MaxBoxes  = 64       // 顶层盒子最多遍历的个数
+	mp4BoxHeader = 8
+)
+
+// mp4Movie moov 中解析出的信息
+type mp4Movie struct {
+	duration float64
+	title    string
+	hasVideo bool
+	hasAudio bool
+}
+
+// probeMP4 遍历顶层盒子找到 moov，读取时长、标题和轨道类型
+//
+// moov 可能在文件开头（faststart）或 mdat 之后，后者通过范围请求跳过 mdat 读取。
+func (p *Prober) probeMP4(ctx context.Context, rawURL string, info *Info) {
+	head, err := p.readRange(ctx, rawURL, 0, mp4HeadSize)
+	if err != nil {
+		return
+	}
+
+	// 文件短于首次读取的长度时已全部读入，不需要再发范围请求
+	complete := int64(len(head)) < mp4HeadSize
+
+	var offset int64
+	for i := 0; i < mp4MaxBoxes; i++ {
+		header := sliceAt(head, offset, 16)
+		if len(header) < 16 && !complete {
+			if header, err = p.readRange(ctx, rawURL, offset, 16); err != nil {
+				return
+			}
+		}
+		size, boxType, headerSize, ok := parseBoxHeader(header)
+		if !ok {
+			return
+		}
+		if size == 0 && info.Size > 0 {
+			size = info.Size - offset // 延伸到文件末尾
+		}
+
+		if boxType == "moov" {
+			length := size
+			if length <= 0 || length > mp4MaxMoov {
+				length = mp4MaxMoov
+			}
+			moov := sliceAt(head, offset, length)
+			if int64(len(moov)) < length && !complete {
+				if moov, err = p.readRange(ctx, rawURL, offset, length); err != nil {
+					return
+				}
+			}
+			if int64(len(moov)) <= headerSize {
+				return
+			}
+			movie := parseMoov(moov[headerSize:])
+			info.Duration = movie.duration
+			info.Title = movie.title
+			if movie.hasAudio && !movie.hasVideo {
+				info.MediaType = TypeAudio
+			}
+			return
+		}
+
+		if size < headerSize {
+			return
+		}
+		offset += size
+		if info.Size > 0 && offset >= info.Size {
+			return
+		}
+	}
+}
+
+// parseMoov 解析 moov 盒子的内容
+func parseMoov(data []byte) mp4Movie {
+	var movie mp4Movie
+	eachBox(data, func(boxType string, payload []byte) {
+		switch boxType {
+		case "mvhd":
+			movie.duration = parseMvhd(payload)
+		case "trak":
+			switch trackHandler(payload) {
+			case "vide":
+				movie.hasVideo = true
+			case "soun":
+				movie.hasAudio = true
+			}
+		case "udta":
+			movie.title = parseTitle(payload)
+		}
+	})
+	return movie
+}
+
+// parseMvhd 由 mvhd 的时间刻度和时长计算秒数
+func parseMvhd(payload []byte) float64 {
+	if len(payload) < 1 {
+		return 0
+	}
+	var timescale uint32
+	var duration uint64
+	switch payload[0] {
+	case 0:
+		if len(payload) < 20 {
+			return 0
+		}
+		timescale = binary.BigEndian.Uint32(payload[12:16])
+		duration = uint64(binary.BigEndian.Uint32(payload[16:20]))
+	case 1:
+		if len(payload) < 32 {
+			return 0
+		}
+		timescale = binary.BigEndian.Uint32(payload[20:24])
+		duration = binary.BigEndian.Uint64(payload[24:32])
+	default:
+		return 0
+	}
+	// 时长全 1 表示未知
+	if timescale == 0 || duration == 0xFFFFFFFF || duration == 0xFFFFFFFFFFFFFFFF {
+		return 0
+	}
+	return float64(duration) / float64(timescale)
+}
+
+// trackHandler 读取 trak/mdia/hdlr 的处理类型（vide、soun 等）
+func trackHandler(trak []byte) string {
+	var handler string
+	eachBox(trak, func(boxType string, payload []byte) {
+		if boxType != "mdia" {
+			return
+		}
+		eachBox(payload, func(boxType string, payload []byte) {
+			// hdlr: version/flags(4) pre_defined(4) handler_type(4)
+			if boxType == "hdlr" && len(payload) >= 12 {
+				handler = string(payload[8:12])
+			}
+		})
+	})
+	return handler
+}
+
+// parseTitle 读取 udta/meta/ilst/©nam/data 中的标题
+func parseTitle(udta []byte) string {
+	var title string
+	eachBox(udta, func(boxType string, meta []byte) {
+		// meta 是完整盒子，内容前有 4 字节 version/flags
+		if boxType != "meta" || len(meta) < 4 {
+			return
+		}
+		eachBox(meta[4:], func(boxType string, ilst []byte) {
+			if boxType != "ilst" {
+				return
+			}
+			eachBox(ilst, func(boxType string, item []byte) {
+				if boxType != "\xa9nam" {
+					return
+				}
+				eachBox(item, func(boxType string, data []byte) {
+					// data: type(4) locale(4) value
+					if boxType == "data" && len(data) > 8 && title == "" {
+						title = strings.TrimSpace(string(data[8:]))
+					}
+				})
+			})
+		})
+	})
+	return title
+}
+
+// eachBox 遍历 data 中依次排列的盒子，截断的盒子被忽略
+func eachBox(data []byte, fn func(boxType string, payload []byte)) {
+	for len(data) >= mp4BoxHeader {
+		size, boxType, headerSize, ok := parseBoxHeader(data)
+		if !ok {
+			return
+		}
+		if size == 0 {
+			size = int64(len(data))
+		}
+		if size < headerSize || size > int64(len(data)) {
+			return
+		}
+		fn(boxType, data[headerSize:size])
+		data = data[size:]
+	}
+}
+
+// parseBoxHeader 解析盒子头部，返回盒子总大小（0 表示延伸到末尾）、类型和头部长度
+func parseBoxHeader(data []byte) (size int64, boxType string, headerSize int64, ok bool) {
+	if len(data) < mp4BoxHeader {
+		return 0, "", 0, false
+	}
+	size = int64(binary.BigEndian.Uint32(data[0:4]))
+	boxType = string(data[4:8])
+	headerSize = mp4BoxHeader
+	if size == 1 {
+		if len(data) < 16 {
+			return 0, "", 0, false
+		}
+		size = int64(binary.BigEndian.Uint64(data[8:16]))
+		headerSize = 16
+	}
+	return size, boxType, headerSize, true
+}
+
+// sliceAt 返回 data 中 [offset, offset+length) 的部分，超出范围时截断
+func sliceAt(data []byte, offset, length int64) []byte {
+	if offset >= int64(len(data)) {
+		return nil
+	}
+	end := offset + length
+	if end > int64(len(data)) {
+		end = int64(len(data))
+	}
+	return data[offset:end]
+}
@@ -0,0 +1,400 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaowo/backend/internal/proxy"
+)
+
+// ErrNotMedia 地址明确不是可播放的媒体（返回 4xx、网页或无法解析的播放列表）
+var ErrNotMedia = errors.New("not a playable media resource")
+
+// 媒体格式
+const (
+	FormatHLS   = "hls"
+	FormatMP4   = "mp4"
+	FormatOther = "other"
+)
+
+// 媒体类型，与房间的 media_type 取值一致
+const (
+	TypeVideo  = "video"
+	TypeAudio  = "audio"
+	TypeStream = "stream" // 直播流
+)
+
+const (
+	// DefaultTimeout 默认单次探测的超时时间
+	DefaultTimeout = 5 * time.Second
+	// DefaultCacheTTL 默认探测结果缓存时间
+	DefaultCacheTTL = 10 * time.Minute
+	// DefaultCacheSize 默认最多缓存的地址数
+	DefaultCacheSize = 1024
+
+	maxPlaylistSize = 1 << 20 // HLS 播放列表最大读取字节数
+)
+
+// Info 媒体探测结果
+type Info struct {
+	URL         string    `json:"url"`
+	Format      string    `json:"format"`       // hls/mp4/other
+	MediaType   string    `json:"media_type"`   // video/audio/stream
+	ContentType string    `json:"content_type"` // 不含参数的 Content-Type
+	Size        int64     `json:"size,omitempty"`
+	Duration    float64   `json:"duration"` // 总时长（秒），直播或无法确定时为 0
+	Live        bool      `json:"live"`
+	Title       string    `json:"title"`
+	Variants    []Variant `json:"variants,omitempty"` // HLS 主播放列表中的码率
+}
+
+// Variant HLS 主播放列表中的一路码率
+type Variant struct {
+	URL        string `json:"url"`
+	Bandwidth  int    `json:"bandwidth"`
+	Resolution string `json:"resolution,omitempty"`
+	Codecs     string `json:"codecs,omitempty"`
+}
+
+// Options 探测器配置
+type Options struct {
+	Client    *http.Client  // HTTP 客户端（为空时使用拒绝内网和回环地址的 proxy.NewClient）
+	Timeout   time.Duration // 单次探测的超时时间（为空时使用 DefaultTimeout）
+	CacheTTL  time.Duration // 探测结果缓存时间（为空时使用 DefaultCacheTTL）
+	CacheSize int           // 最多缓存的地址数（为空时使用 DefaultCacheSize）
+}
+
+// Prober 媒体地址探测器
+//
+// 通过 HEAD（服务器不支持时退回单字节的范围 GET）获取类型和大小，
+// 再按格式解析 HLS 播放列表或 MP4 的 moov 盒子获取时长。成功的结果按地址缓存。
+type Prober struct {
+	client  *http.Client
+	timeout time.Duration
+	ttl     time.Duration
+	size    int
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+// cacheEntry 缓存的探测结果
+type cacheEntry struct {
+	info    Info
+	expires time.Time
+}
+
+// NewProber 创建媒体探测器
+func NewProber(opts Options) *Prober {
+	p := &Prober{
+		client:  opts.Client,
+		timeout: opts.Timeout,
+		ttl:     opts.CacheTTL,
+		size:    opts.CacheSize,
+		cache:   make(map[string]cacheEntry),
+	}
+	if p.timeout <= 0 {
+		p.timeout = DefaultTimeout
+	}
+	if p.client == nil {
+		p.client = proxy.NewClient(p.timeout, false)
+	}
+	if p.ttl <= 0 {
+		p.ttl = DefaultCacheTTL
+	}
+	if p.size <= 0 {
+		p.size = DefaultCacheSize
+	}
+	return p
+}
+
+// Probe 探测媒体地址，返回结果的副本
+//
+// 地址明确不是媒体时返回包装了 ErrNotMedia 的错误；网络错误和超时原样返回，
+// 调用方可以据此区分“拒绝地址”和“暂时无法确认”。
+func (p *Prober) Probe(ctx context.Context, rawURL string) (*Info, error) {
+	if info, ok := p.cached(rawURL); ok {
+		return info, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	info, err := p.probe(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	p.store(rawURL, info)
+	return p.copyOf(info), nil
+}
+
+// probe 执行一次探测
+func (p *Prober) probe(ctx context.Context, rawURL string) (*Info, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: unsupported URL %q", ErrNotMedia, rawURL)
+	}
+
+	head, err := p.head(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{
+		URL:         rawURL,
+		ContentType: head.contentType,
+		Size:        head.size,
+		MediaType:   TypeVideo,
+	}
+	ext := strings.ToLower(path.Ext(u.Path))
+
+	switch {
+	case isHLS(head.contentType, ext):
+		info.Format = FormatHLS
+		if err := p.probeHLS(ctx, head.url, info); err != nil {
+			return nil, err
+		}
+	case isMP4(head.contentType, ext):
+		info.Format = FormatMP4
+		// 服务器不支持范围请求或 moov 无法解析时只缺少时长，不影响播放
+		p.probeMP4(ctx, rawURL, info)
+	case isNotMedia(head.contentType):
+		return nil, fmt.Errorf("%w: content type %s", ErrNotMedia, head.contentType)
+	default:
+		info.Format = FormatOther
+	}
+
+	if info.Live {
+		info.MediaType = TypeStream
+	} else if info.MediaType != TypeAudio && isAudio(head.contentType, ext) {
+		info.MediaType = TypeAudio
+	}
+	if info.Title == "" {
+		info.Title = titleOf(head.filename, head.url)
+	}
+	return info, nil
+}
+
+// headResult HEAD 请求得到的资源信息
+type headResult struct {
+	url         *url.URL // 跟随重定向后的地址，用于解析相对地址
+	contentType string
+	size        int64
+	filename    string // Content-Disposition 中的文件名
+}
+
+// head 获取资源的类型和大小，服务器不支持 HEAD 时退回单字节的范围 GET
+func (p *Prober) head(ctx context.Context, rawURL string) (*headResult, error) {
+	resp, err := p.do(ctx, http.MethodHead, rawURL, "")
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, err = p.do(ctx, http.MethodGet, rawURL, "bytes=0-0")
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("%w: HTTP %d", ErrNotMedia, resp.StatusCode)
+	}
+
+	result := &headResult{url: resp.Request.URL, size: resp.ContentLength}
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+		result.contentType = strings.ToLower(mediaType)
+	}
+	if resp.StatusCode == http.StatusPartialContent {
+		result.size = totalSize(resp.Header.Get("Content-Range"))
+	}
+	if result.size < 0 {
+		result.size = 0
+	}
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		result.filename = params["filename"]
+	}
+	return result, nil
+}
+
+// get 读取资源的前 limit 字节
+func (p *Prober) get(ctx context.Context, rawURL string, limit int64) ([]byte, *url.URL, error) {
+	resp, err := p.do(ctx, http.MethodGet, rawURL, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, nil, fmt.Errorf("%w: HTTP %d", ErrNotMedia, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit))
+	return data, resp.Request.URL, err
+}
+
+// readRange 读取资源 [offset, offset+length) 范围内的字节，可能短于 length
+func (p *Prober) readRange(ctx context.Context, rawURL string, offset, length int64) ([]byte, error) {
+	resp, err := p.do(ctx, http.MethodGet, rawURL, fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+	case resp.StatusCode == http.StatusOK && offset == 0:
+		// 服务器忽略了范围，从头读取同样可用
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("range request: HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, length))
+}
+
+// do 发送请求
+func (p *Prober) do(ctx context.Context, method, rawURL, byteRange string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotMedia, err)
+	}
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+	return p.client.Do(req)
+}
+
+// cached 读取未过期的缓存结果
+func (p *Prober) cached(rawURL string) (*Info, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.cache[rawURL]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(p.cache, rawURL)
+		return nil, false
+	}
+	info := entry.info
+	return p.copyOf(&info), true
+}
+
+// store 缓存探测结果，缓存已满时先清理过期项，仍然满时清空
+func (p *Prober) store(rawURL string, info *Info) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.cache) >= p.size {
+		now := time.Now()
+		for key, entry := range p.cache {
+			if now.After(entry.expires) {
+				delete(p.cache, key)
+			}
+		}
+		if len(p.cache) >= p.size {
+			p.cache = make(map[string]cacheEntry)
+		}
+	}
+	p.cache[rawURL] = cacheEntry{info: *p.copyOf(info), expires: time.Now().Add(p.ttl)}
+}
+
+// copyOf 复制探测结果，避免调用方修改缓存
+func (p *Prober) copyOf(info *Info) *Info {
+	copied := *info
+	copied.Variants = append([]Variant(nil), info.Variants...)
+	return &copied
+}
+
+// totalSize 解析 Content-Range 中的总大小（bytes 0-0/12345）
+func totalSize(contentRange string) int64 {
+	i := strings.LastIndexByte(contentRange, '/')
+	if i < 0 {
+		return 0
+	}
+	size, err := strconv.ParseInt(contentRange[i+1:], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// isHLS 是否为 HLS 播放列表
+func isHLS(contentType, ext string) bool {
+	switch contentType {
+	case "application/vnd.apple.mpegurl", "application/x-mpegurl", "audio/mpegurl", "audio/x-mpegurl":
+		return true
+	}
+	return ext == ".m3u8"
+}
+
+// isMP4 是否为 MP4 家族的容器
+func isMP4(contentType, ext string) bool {
+	switch contentType {
+	case "video/mp4", "audio/mp4", "video/quicktime", "video/x-m4v":
+		return true
+	}
+	switch ext {
+	case ".mp4", ".m4v", ".m4a", ".mov":
+		return true
+	}
+	return false
+}
+
+// isAudio 是否为纯音频
+func isAudio(contentType, ext string) bool {
+	if strings.HasPrefix(contentType, "audio/") && !isHLS(contentType, "") {
+		return true
+	}
+	switch ext {
+	case ".mp3", ".m4a", ".aac", ".flac", ".ogg", ".wav", ".opus":
+		return true
+	}
+	return false
+}
+
+// isNotMedia 类型明确不是媒体（网页、图片、文档等）
+func isNotMedia(contentType string) bool {
+	switch {
+	case strings.HasPrefix(contentType, "text/"), strings.HasPrefix(contentType, "image/"):
+		return true
+	case contentType == "application/json", contentType == "application/xml", contentType == "application/pdf":
+		return true
+	}
+	return false
+}
+
+// genericNames 不适合作为标题的常见文件名，改用上级目录名
+var genericNames = map[string]bool{
+	"index": true, "playlist": true, "master": true, "prog_index": true, "manifest": true, "video": true, "stream": true,
+}
+
+// titleOf 由 Content-Disposition 文件名或地址路径推断标题
+func titleOf(filename string, u *url.URL) string {
+	name := filename
+	if name == "" {
+		name = path.Base(u.Path)
+		if stem := strings.TrimSuffix(name, path.Ext(name)); genericNames[strings.ToLower(stem)] {
+			if dir := path.Base(path.Dir(u.Path)); dir != "/" && dir != "." {
+				name = dir
+			}
+		}
+	}
+	if name == "/" || name == "." {
+		return ""
+	}
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	name = strings.TrimSuffix(name, path.Ext(name))
+	return strings.TrimSpace(strings.NewReplacer("_", " ", "+", " ").Replace(name))
+}
@@ -0,0 +1,238 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"xiaowo/backend/internal/proxy"
+)
+
+// box 构造 MP4 盒子
+func box(boxType string, payloads ...[]byte) []byte {
+	content := bytes.Join(payloads, nil)
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(8+len(content)))
+	copy(header[4:], boxType)
+	return append(header, content...)
+}
+
+// mvhd 构造版本 0 的 mvhd 盒子
+func mvhd(timescale, duration uint32) []byte {
+	payload := make([]byte, 100)
+	binary.BigEndian.PutUint32(payload[12:], timescale)
+	binary.BigEndian.PutUint32(payload[16:], duration)
+	return box("mvhd", payload)
+}
+
+// trak 构造只带处理类型的轨道
+func trak(handler string) []byte {
+	hdlr := make([]byte, 24)
+	copy(hdlr[8:], handler)
+	return box("trak", box("mdia", box("hdlr", hdlr)))
+}
+
+// titleBox 构造带 ©nam 标题的 udta
+func titleBox(title string) []byte {
+	data := box("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte(title))
+	return box("udta", box("meta", []byte{0, 0, 0, 0}, box("ilst", box("\xa9nam", data))))
+}
+
+// mediaServer 提供测试媒体的本地服务器，记录每个路径的请求数
+type mediaServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func newMediaServer(t *testing.T, routes map[string]http.HandlerFunc) *mediaServer {
+	t.Helper()
+	s := &mediaServer{requests: make(map[string]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.Path]++
+		s.mu.Unlock()
+		if handler, ok := routes[r.URL.Path]; ok {
+			handler(w, r)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *mediaServer) count(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// serveBytes 支持 HEAD 和范围请求的静态内容
+func serveBytes(contentType string, data []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}
+}
+
+func TestProber(t *testing.T) {
+	ctx := context.Background()
+	movie := box("moov", mvhd(1000, 90500), trak("vide"), trak("soun"), titleBox("Big Buck Bunny"))
+	ftyp := box("ftyp", []byte("isom\x00\x00\x02\x00isomiso2mp41"))
+	mdat := box("mdat", make([]byte, 200<<10))
+
+	server := newMediaServer(t, map[string]http.HandlerFunc{
+		"/faststart.mp4":  serveBytes("video/mp4", bytes.Join([][]byte{ftyp, movie, box("mdat", make([]byte, 1024))}, nil)),
+		"/moov_last.mp4":  serveBytes("video/mp4", bytes.Join([][]byte{ftyp, mdat, movie}, nil)),
+		"/music/song.m4a": serveBytes("audio/mp4", bytes.Join([][]byte{ftyp, box("moov", mvhd(44100, 44100*30), trak("soun"))}, nil)),
+		"/movie/master.m3u8": serveBytes("application/vnd.apple.mpegurl", []byte(strings.Join([]string{
+			"#EXTM3U",
+			`#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360,CODECS="avc1.4d401e,mp4a.40.2"`,
+			"low/index.m3u8",
+			`#EXT-X-STREAM-INF:BANDWIDTH=2400000,RESOLUTION=1280x720,CODECS="avc1.4d401f,mp4a.40.2"`,
+			"/movie/high/index.m3u8",
+		}, "\n"))),
+		"/movie/low/index.m3u8": serveBytes("application/vnd.apple.mpegurl", []byte(
+			"#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:10.0,\nseg0.ts\n#EXTINF:10.0,\nseg1.ts\n#EXTINF:5.5,\nseg2.ts\n#EXT-X-ENDLIST\n")),
+		"/live/stream.m3u8": serveBytes("application/x-mpegURL", []byte(
+			"#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXT-X-MEDIA-SEQUENCE:120\n#EXTINF:6.0,\nseg120.ts\n#EXTINF:6.0,\nseg121.ts\n")),
+		"/clip": func(w http.ResponseWriter, r *http.Request) {
+			// 不支持 HEAD 的服务器
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Content-Disposition", `attachment; filename="Holiday_Trip.webm"`)
+			serveBytes("video/webm", make([]byte, 5000))(w, r)
+		},
+		"/page.html": serveBytes("text/html; charset=utf-8", []byte("<html></html>")),
+	})
+
+	t.Run("TestDefaultClientBlocksPrivate", func(t *testing.T) {
+		// 未指定客户端时不访问内网和回环地址，避免探测被用于访问内部服务
+		if _, err := NewProber(Options{}).Probe(ctx, server.URL+"/faststart.mp4"); !errors.Is(err, proxy.ErrBlockedTarget) {
+			t.Errorf("默认客户端应拒绝回环地址，实际: %v", err)
+		}
+	})
+
+	t.Run("TestMP4FastStart", func(t *testing.T) {
+		info, err := NewProber(Options{Client: http.DefaultClient}).Probe(ctx, server.URL+"/faststart.mp4")
+		if err != nil {
+			t.Fatalf("探测失败: %v", err)
+		}
+		if info.Format != FormatMP4 || info.MediaType != TypeVideo || info.Duration != 90.5 || info.Title != "Big Buck Bunny" || info.Live {
+			t.Errorf("探测结果不正确: %+v", info)
+		}
+	})
+
+	t.Run("TestMP4MoovAtEnd", func(t *testing.T) {
+		info, err := NewProber(Options{Client: http.DefaultClient}).Probe(ctx, server.URL+"/moov_last.mp4")
+		if err != nil {
+			t.Fatalf("探测失败: %v", err)
+		}
+		if info.Duration != 90.5 || info.Size != int64(len(ftyp)+len(mdat)+len(movie)) {
+			t.Errorf("应跳过 mdat 读取文件末尾的 moov: %+v", info)
+		}
+	})
+
+	t.Run("TestAudioOnlyMP4", func(t *testing.T) {
+		info, err := NewProber(Options{Client: http.DefaultClient}).Probe(ctx, server.URL+"/music/song.m4a")
+		if err != nil {
+			t.Fatalf("探测失败: %v", err)
+		}
+		if info.MediaType != TypeAudio || info.Duration != 30 || info.Title != "song" {
+			t.Errorf("探测结果不正确: %+v", info)
+		}
+	})
+
+	t.Run("TestHLSMaster", func(t *testing.T) {
+		info, err := NewProber(Options{Client: http.DefaultClient}).Probe(ctx, server.URL+"/movie/master.m3u8")
+		if err != nil {
+			t.Fatalf("探测失败: %v", err)
+		}
+		if info.Format != FormatHLS || info.Live || info.Duration != 25.5 || info.Title != "movie" {
+			t.Errorf("探测结果不正确: %+v", info)
+		}
+		if len(info.Variants) != 2 || info.Variants[0].Bandwidth != 2400000 || info.Variants[0].URL != server.URL+"/movie/high/index.m3u8" ||
+			info.Variants[1].URL != server.URL+"/movie/low/index.m3u8" || info.Variants[1].Codecs != "avc1.4d401e,mp4a.40.2" {
+			t.Errorf("码率列表不正确: %+v", info.Variants)
+		}
+	})
+
+	t.Run("TestHLSLive", func(t *testing.T) {
+		info, err := NewProber(Options{Client: http.DefaultClient}).Probe(ctx, server.URL+"/live/stream.m3u8")
+		if err != nil {
+			t.Fatalf("探测失败: %v", err)
+		}
+		if !info.Live || info.MediaType != TypeStream || info.Duration != 0 {
+			t.Errorf("没有结束标记的播放列表应为直播: %+v", info)
+		}
+	})
+
+	t.Run("TestRangeFallback", func(t *testing.T) {
+		info, err := NewProber(Options{Client: http.DefaultClient}).Probe(ctx, server.URL+"/clip")
+		if err != nil {
+			t.Fatalf("探测失败: %v", err)
+		}
+		if info.Format != FormatOther || info.ContentType != "video/webm" || info.Size != 5000 || info.Title != "Holiday Trip" {
+			t.Errorf("探测结果不正确: %+v", info)
+		}
+	})
+
+	t.Run("TestNotMedia", func(t *testing.T) {
+		prober := NewProber(Options{Client: http.DefaultClient})
+		for _, rawURL := range []string{server.URL + "/missing.mp4", server.URL + "/page.html", "ftp://example.com/a.mp4"} {
+			if _, err := prober.Probe(ctx, rawURL); !errors.Is(err, ErrNotMedia) {
+				t.Errorf("%s 期望错误: %v, 实际: %v", rawURL, ErrNotMedia, err)
+			}
+		}
+	})
+
+	t.Run("TestUnreachable", func(t *testing.T) {
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+		if _, err := NewProber(Options{Client: http.DefaultClient, Timeout: time.Second}).Probe(ctx, closed.URL+"/a.mp4"); err == nil || errors.Is(err, ErrNotMedia) {
+			t.Errorf("网络错误不应视为非媒体地址: %v", err)
+		}
+	})
+
+	t.Run("TestCached", func(t *testing.T) {
+		prober := NewProber(Options{Client: http.DefaultClient})
+		first, err := prober.Probe(ctx, server.URL+"/movie/master.m3u8")
+		if err != nil {
+			t.Fatalf("探测失败: %v", err)
+		}
+		before := server.count("/movie/master.m3u8")
+		first.Variants[0].URL = "modified"
+
+		second, err := prober.Probe(ctx, server.URL+"/movie/master.m3u8")
+		if err != nil {
+			t.Fatalf("探测失败: %v", err)
+		}
+		if server.count("/movie/master.m3u8") != before {
+			t.Error("缓存有效期内不应再次请求")
+		}
+		if second.Variants[0].URL == "modified" {
+			t.Error("修改返回结果不应影响缓存")
+		}
+	})
+
+	t.Run("TestCacheExpires", func(t *testing.T) {
+		prober := NewProber(Options{Client: http.DefaultClient, CacheTTL: 20 * time.Millisecond})
+		prober.Probe(ctx, server.URL+"/faststart.mp4")
+		before := server.count("/faststart.mp4")
+		time.Sleep(40 * time.Millisecond)
+		prober.Probe(ctx, server.URL+"/faststart.mp4")
+		if server.count("/faststart.mp4") == before {
+			t.Error("缓存过期后应重新探测")
+		}
+	})
+}
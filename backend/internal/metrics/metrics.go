@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "xiaowo"
+
+// Registry 应用指标注册表（不使用全局默认注册表，避免第三方库指标混入）
+var Registry = prometheus.NewRegistry()
+
+// HTTP 指标（名称与 monitoring 下的告警规则、Grafana 面板保持一致）
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP 请求数",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP 请求耗时",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+// WebSocket Hub 指标
+var (
+	WebSocketConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "websocket_connections_active",
+		Help:      "当前 WebSocket 连接数",
+	})
+
+	ActiveRooms = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_rooms",
+		Help:      "Hub 中缓存的房间数",
+	})
+
+	WebSocketMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "websocket_messages_total",
+		Help:      "WebSocket 消息数（direction: in/out）",
+	}, []string{"direction", "type"})
+
+	WebSocketSendDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "websocket_send_dropped_total",
+		Help:      "发送队列已满而丢弃并断开的连接数",
+	})
+
+	WebSocketResumes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "websocket_resumes_total",
+		Help:      "断线重连续接次数（result: replayed 补发错过的事件/snapshot 缺口过大改发完整状态）",
+	}, []string{"result"})
+
+	CalibrationRTT = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "calibration_rtt_seconds",
+		Help:      "对时测得的平滑往返时延",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.2, 0.4, 0.8, 1.6},
+	})
+
+	ClockOffset = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "clock_offset_seconds",
+		Help:      "对时测得的客户端时钟偏移（绝对值）",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 30, 120},
+	})
+)
+
+// 同步指标
+var (
+	SyncChecks = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "room_sync_total",
+		Help:      "处理的客户端同步上报数",
+	})
+
+	SyncInTolerance = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "room_sync_success_total",
+		Help:      "误差在容忍范围内、无需纠正的同步上报数",
+	})
+
+	SyncActions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sync_actions_total",
+		Help:      "下发的同步纠正指令数（action: seek/playback_rate）",
+	}, []string{"action"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		WebSocketConnections,
+		ActiveRooms,
+		WebSocketMessages,
+		WebSocketSendDropped,
+		WebSocketResumes,
+		CalibrationRTT,
+		ClockOffset,
+		SyncChecks,
+		SyncInTolerance,
+		SyncActions,
+	)
+}
+
+// Handler 指标抓取接口
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"time"
+)
+
+// DanmakuKind distinguishes bullet comments from quick reactions
+type DanmakuKind string
+
+const (
+	DanmakuKindComment  DanmakuKind = "danmaku"  // bullet comment drawn over the video
+	DanmakuKindReaction DanmakuKind = "reaction" // emoji reaction
+)
+
+// Danmaku display modes, same values as the Bilibili danmaku format
+const (
+	DanmakuModeScroll = 1 // scrolls right to left
+	DanmakuModeBottom = 4 // fixed at the bottom
+	DanmakuModeTop    = 5 // fixed at the top
+)
+
+// DanmakuDefaultColor is white, encoded as 0xRRGGBB
+const DanmakuDefaultColor = 0xFFFFFF
+
+// MaxDanmakuLength is the maximum number of characters of a bullet comment
+const MaxDanmakuLength = 100
+
+// Reactions are the emoji members may react with
+var Reactions = []string{"👍", "❤️", "😂", "😮", "😢", "👏", "🔥", "🎉"}
+
+// IsReaction reports whether emoji is one of Reactions
+func IsReaction(emoji string) bool {
+	for _, reaction := range Reactions {
+		if reaction == emoji {
+			return true
+		}
+	}
+	return false
+}
+
+// Danmaku is a bullet comment or reaction anchored to a point of a room's media,
+// replayed to anyone watching the same media in the room later
+type Danmaku struct {
+	ID        string      `gorm:"primaryKey;type:text" json:"id"`                                         // 弹幕ID (UUID)
+	RoomID    string      `gorm:"type:text;not null;index:idx_danmaku_media,priority:1" json:"room_id"`   // 房间ID
+	MediaURL  string      `gorm:"type:text;not null;index:idx_danmaku_media,priority:2" json:"media_url"` // 发送时房间播放的媒体地址
+	Time      float64     `gorm:"type:real;not null;index:idx_danmaku_media,priority:3" json:"time"`      // 媒体时间点 (秒)
+	Kind      DanmakuKind `gorm:"type:text;not null;default:'danmaku'" json:"kind"`                       // 类型: danmaku/reaction
+	Content   string      `gorm:"type:text;not null" json:"content"`                                      // 弹幕内容或表情
+	Mode      int         `gorm:"type:integer;default:1" json:"mode"`                                     // 显示模式: 1 滚动/4 底部/5 顶部
+	Color     int         `gorm:"type:integer;default:16777215" json:"color"`                             // 颜色 0xRRGGBB
+	SessionID string      `gorm:"type:text;not null;index" json:"session_id"`                             // 发送者会话ID
+	Nickname  string      `gorm:"type:text" json:"nickname"`                                              // 发送时的昵称
+	CreatedAt time.Time   `gorm:"type:datetime;default:CURRENT_TIMESTAMP" json:"created_at"`              // 发送时间
+
+	// Relations
+	Room *Room `gorm:"foreignKey:RoomID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// TableName overrides the table name
+func (Danmaku) TableName() string {
+	return "room_danmaku"
+}
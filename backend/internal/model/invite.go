@@ -0,0 +1,67 @@
+package model
+
+import (
+	"time"
+)
+
+// Invite is a shareable code that admits new members to a room without the room password
+type Invite struct {
+	Code      string     `gorm:"primaryKey;type:text" json:"code"`                          // 邀请码
+	RoomID    string     `gorm:"type:text;not null;index" json:"room_id"`                   // 房间ID
+	Role      RoomRole   `gorm:"type:text;not null;default:'member'" json:"role"`           // 通过邀请加入的成员角色
+	MaxUses   int        `gorm:"type:integer;not null;default:0" json:"max_uses"`           // 最多使用次数，0 表示不限
+	Uses      int        `gorm:"type:integer;not null;default:0" json:"uses"`               // 已使用次数
+	ExpiresAt *time.Time `gorm:"type:datetime" json:"expires_at,omitempty"`                 // 过期时间，为空表示不过期
+	RevokedAt *time.Time `gorm:"type:datetime" json:"revoked_at,omitempty"`                 // 吊销时间
+	CreatedBy string     `gorm:"type:text" json:"created_by"`                               // 创建者会话ID
+	CreatedAt time.Time  `gorm:"type:datetime;default:CURRENT_TIMESTAMP" json:"created_at"` // 创建时间
+
+	// Relations
+	Room        *Room        `gorm:"foreignKey:RoomID;constraint:OnDelete:CASCADE" json:"-"`
+	Redemptions []*InviteUse `gorm:"foreignKey:Code;references:Code;constraint:OnDelete:CASCADE" json:"redemptions,omitempty"`
+}
+
+// TableName overrides the table name
+func (Invite) TableName() string {
+	return "room_invites"
+}
+
+// Check reports why the invite cannot be used at now, or nil if it can
+func (i *Invite) Check(now time.Time) error {
+	switch {
+	case i.RevokedAt != nil:
+		return ErrInviteRevoked
+	case i.ExpiresAt != nil && !now.Before(*i.ExpiresAt):
+		return ErrInviteExpired
+	case i.MaxUses > 0 && i.Uses >= i.MaxUses:
+		return ErrInviteExhausted
+	}
+	return nil
+}
+
+// CheckRoom reports whether the invite may admit members to room: the room must not be
+// deleted and the invite must have been created for it, not for an earlier room with the same ID.
+// Rooms deactivated for being idle still accept invites and become active again on redemption.
+func (i *Invite) CheckRoom(room *Room) error {
+	if room.Status == RoomStatusDeleted {
+		return ErrRoomNotFound
+	}
+	if i.RoomID != room.ID || i.CreatedAt.Before(room.CreatedAt) {
+		return ErrInviteNotFound
+	}
+	return nil
+}
+
+// InviteUse records a session joining a room through an invite
+type InviteUse struct {
+	ID        string    `gorm:"primaryKey;type:text" json:"id"`                         // 记录ID (UUID)
+	Code      string    `gorm:"type:text;not null;index" json:"code"`                   // 邀请码
+	SessionID string    `gorm:"type:text;not null" json:"session_id"`                   // 加入的会话ID
+	Nickname  string    `gorm:"type:text" json:"nickname"`                              // 加入时的昵称
+	UsedAt    time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP" json:"used_at"` // 使用时间
+}
+
+// TableName overrides the table name
+func (InviteUse) TableName() string {
+	return "room_invite_uses"
+}
@@ -23,4 +23,17 @@ func (RoomMember) TableName() string {
 	return "room_members"
 }
 
+// PresenceState represents whether a room member is currently connected
+type PresenceState string
 
+const (
+	PresenceOnline PresenceState = "online" // 已连接
+	PresenceIdle   PresenceState = "idle"   // 已连接，客户端报告空闲
+	PresenceAway   PresenceState = "away"   // 未连接，超过离线时限后自动离开房间
+)
+
+// MemberPresence is a room member together with its live presence state
+type MemberPresence struct {
+	*RoomMember
+	Presence PresenceState `json:"presence"` // 在线状态: online/idle/away
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -56,6 +57,17 @@ func (m *Message) GetSenderNickname() string {
 	return "未知用户"
 }
 
+// GetDisplayName returns the sender's nickname at send time, falling back to the session nickname
+func (m *Message) GetDisplayName() string {
+	var metadata struct {
+		Nickname string `json:"nickname"`
+	}
+	if err := json.Unmarshal([]byte(m.Metadata), &metadata); err == nil && metadata.Nickname != "" {
+		return metadata.Nickname
+	}
+	return m.GetSenderNickname()
+}
+
 // GetSenderAvatar returns the sender's avatar from the session if available
 func (m *Message) GetSenderAvatar() string {
 	if m.Session != nil {
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"time"
+)
+
+// MaxPlaylistItems is the maximum number of items in a room's playlist
+const MaxPlaylistItems = 500
+
+// Playlist modes, stored in RoomSettings.PlaylistMode
+const (
+	PlaylistModeSequential = "sequential" // stop after the last item
+	PlaylistModeLoop       = "loop"       // wrap around to the first item
+	PlaylistModeShuffle    = "shuffle"    // pick a random other item
+)
+
+// PlaylistItem is a media entry in a room's playlist
+type PlaylistItem struct {
+	ID            string    `gorm:"primaryKey;type:text" json:"id"`                                         // 条目ID (UUID)
+	RoomID        string    `gorm:"type:text;not null;index:idx_playlist_room_position" json:"room_id"`     // 房间ID
+	Position      int       `gorm:"type:integer;not null;index:idx_playlist_room_position" json:"position"` // 在列表中的位置 (从0开始)
+	MediaURL      string    `gorm:"type:text;not null" json:"media_url"`                                    // 媒体资源URL
+	MediaType     string    `gorm:"type:text;default:'video'" json:"media_type"`                            // 媒体类型: video/audio/stream
+	MediaTitle    string    `gorm:"type:text" json:"media_title"`                                           // 媒体标题
+	MediaDuration float64   `gorm:"type:real;default:0" json:"media_duration"`                              // 媒体总时长 (秒)
+	MediaInfo     JSON      `gorm:"type:text" json:"media_info"`                                            // 媒体探测结果 (JSON格式，未探测时为空)
+	AddedBy       string    `gorm:"type:text" json:"added_by"`                                              // 添加者会话ID
+	CreatedAt     time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP" json:"created_at"`              // 创建时间
+
+	// Relations
+	Room *Room `gorm:"foreignKey:RoomID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// TableName overrides the table name
+func (PlaylistItem) TableName() string {
+	return "room_playlist_items"
+}
+
+// Playlist is a room's ordered playlist together with the item being played
+type Playlist struct {
+	RoomID    string          `json:"room_id"`
+	Items     []*PlaylistItem `json:"items"`
+	CurrentID string          `json:"current_id"` // empty when nothing is selected
+	Version   int             `json:"version"`    // bumped by every change, including switching items
+}
+
+// IndexOf returns the index of the item with the given ID, or -1
+func (p *Playlist) IndexOf(itemID string) int {
+	for i, item := range p.Items {
+		if item.ID == itemID {
+			return i
+		}
+	}
+	return -1
+}
+
+// Current returns the item being played, or nil
+func (p *Playlist) Current() *PlaylistItem {
+	if i := p.IndexOf(p.CurrentID); i >= 0 {
+		return p.Items[i]
+	}
+	return nil
+}
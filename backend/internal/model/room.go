@@ -12,15 +12,21 @@ type Room struct {
 	CreatorSessionID   string     `gorm:"type:text;not null" json:"creator_session_id"`        // 创建者会话ID
 	IsPrivate          bool       `gorm:"type:integer;default:0" json:"is_private"`            // 是否私密房间
 	Password           string     `gorm:"column:room_password;type:text" json:"-"`             // 房间密码 (如有)
+	SyncplayPassword   string     `gorm:"type:text" json:"-"`                                  // 房间密码 MD5 的哈希，供 Syncplay 客户端登录校验
 	MaxUsers           int        `gorm:"type:integer;default:7" json:"max_users"`             // 最大用户数 (固定为7)
 	Status             RoomStatus `gorm:"type:text;default:'active';index" json:"status"`      // 房间状态: active/inactive
 	MediaURL           string     `gorm:"type:text;not null" json:"media_url"`                 // 媒体资源URL
 	MediaType          string     `gorm:"type:text;default:'video'" json:"media_type"`         // 媒体类型: video/audio/stream
 	MediaTitle         string     `gorm:"type:text" json:"media_title"`                        // 媒体标题
 	MediaDuration      float64    `gorm:"type:real;default:0" json:"media_duration"`           // 媒体总时长 (秒)
+	MediaInfo          JSON       `gorm:"type:text" json:"media_info"`                         // 媒体探测结果 (JSON格式，未探测时为空)
+	CurrentItemID      string     `gorm:"type:text" json:"current_item_id"`                    // 当前播放的播放列表条目ID，媒体字段为该条目的副本
+	PlaylistVersion    int        `gorm:"type:integer;default:0" json:"playlist_version"`      // 播放列表版本号
+	SubtitleID         string     `gorm:"type:text" json:"subtitle_id"`                        // 当前显示的字幕轨道ID，为空表示关闭字幕
 	PlaybackState      string     `gorm:"type:text;default:'paused'" json:"playback_state"`    // 播放状态: playing/paused/stopped
 	CurrentTime        float64    `gorm:"type:real;default:0" json:"current_time"`             // 当前播放时间 (秒)
 	PlaybackRate       float64    `gorm:"type:real;default:1.0" json:"playback_rate"`          // 播放速率 (1.0=正常, 1.5=1.5倍速)
+	PlaybackUpdatedAt  int64      `gorm:"type:integer;default:0" json:"playback_updated_at"`   // 播放进度基准时间 (毫秒时间戳)
 	Settings           JSON       `gorm:"type:text;default:'{}'" json:"settings"`              // 房间设置 (JSON格式)
 	Version            int        `gorm:"type:integer;default:0" json:"version"`               // 乐观锁版本号
 	LastActiveAt       time.Time  `gorm:"type:datetime;default:CURRENT_TIMESTAMP;index" json:"last_active_at"`      // 最后活跃时间
@@ -32,6 +38,56 @@ type Room struct {
 	Members []RoomMember `gorm:"foreignKey:RoomID;constraint:OnDelete:CASCADE" json:"members,omitempty"`
 }
 
+// Playback states
+const (
+	PlaybackPlaying = "playing"
+	PlaybackPaused  = "paused"
+	PlaybackStopped = "stopped"
+)
+
+// RoomPermissions describes what non-host members may do, a subset of RoomSettings
+type RoomPermissions struct {
+	HostOnlyControl bool `json:"host_only_control"` // only the host may play/pause/seek/change rate
+	AllowSeek       bool `json:"allow_seek"`        // members may seek
+	ChatEnabled     bool `json:"chat_enabled"`      // members may send chat messages
+	CallEnabled     bool `json:"call_enabled"`      // members may join the voice/video call
+}
+
+// GetSettings parses Settings into the typed schema, falling back to defaults
+func (r *Room) GetSettings() RoomSettings {
+	return ParseRoomSettings(r.Settings)
+}
+
+// GetPermissions returns the permission flags from Settings
+func (r *Room) GetPermissions() RoomPermissions {
+	return r.GetSettings().Permissions()
+}
+
+// IsPlaying reports whether the room is currently playing
+func (r *Room) IsPlaying() bool {
+	return r.PlaybackState == PlaybackPlaying
+}
+
+// PlaybackPositionAt extrapolates the playback position at server time at (unix milliseconds)
+func (r *Room) PlaybackPositionAt(at int64) float64 {
+	position := r.CurrentTime
+	if r.IsPlaying() && r.PlaybackUpdatedAt > 0 && at > r.PlaybackUpdatedAt {
+		rate := r.PlaybackRate
+		if rate <= 0 {
+			rate = 1.0
+		}
+		position += float64(at-r.PlaybackUpdatedAt) / 1000 * rate
+	}
+
+	if position < 0 {
+		return 0
+	}
+	if r.MediaDuration > 0 && position > r.MediaDuration {
+		return r.MediaDuration
+	}
+	return position
+}
+
 // IsFull checks if the room has reached maximum user capacity
 func (r *Room) IsFull(currentMemberCount int) bool {
 	return currentMemberCount >= r.MaxUsers
@@ -42,12 +98,15 @@ func (r *Room) IsCreator(sessionID string) bool {
 	return r.CreatorSessionID == sessionID
 }
 
-//State returns the current GetPlayback playback state as JSON
+// GetPlaybackState returns the playback state with the position extrapolated to now
 func (r *Room) GetPlaybackState() map[string]interface{} {
+	now := time.Now()
 	return map[string]interface{}{
 		"playback_state": r.PlaybackState,
-		"current_time":   r.CurrentTime,
+		"is_playing":     r.IsPlaying(),
+		"current_time":   r.PlaybackPositionAt(now.UnixMilli()),
 		"playback_rate":  r.PlaybackRate,
+		"last_updated":   now.UnixMilli(),
 		"media_url":      r.MediaURL,
 		"media_title":    r.MediaTitle,
 		"version":        r.Version,
@@ -11,6 +11,7 @@ type UserSessionStatus string
 
 const (
 	StatusOnline  UserSessionStatus = "online"  // 在线
+	StatusIdle    UserSessionStatus = "idle"    // 在线但客户端报告空闲
 	StatusOffline UserSessionStatus = "offline" // 离线
 )
 
@@ -37,9 +38,9 @@ func (s *UserSession) IsExpired() bool {
 	return time.Now().After(s.ExpiresAt)
 }
 
-// IsOnline checks if the session is online
+// IsOnline checks if the session is online (idle sessions are still connected)
 func (s *UserSession) IsOnline() bool {
-	return s.Status == StatusOnline
+	return s.Status == StatusOnline || s.Status == StatusIdle
 }
 
 // UpdateLastSeen updates the last seen timestamp and sets status to online
@@ -55,5 +56,5 @@ func (s *UserSession) SetOffline() {
 
 // IsActive checks if the session is active (not expired, not deleted and in a room)
 func (s *UserSession) IsActive() bool {
-	return !s.IsExpired() && s.RoomID != nil && s.Status == StatusOnline
+	return !s.IsExpired() && s.RoomID != nil && s.IsOnline()
 }
\ No newline at end of file
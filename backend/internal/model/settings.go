@@ -0,0 +1,197 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidSettings is returned when room settings fail validation
+var ErrInvalidSettings = errors.New("invalid room settings")
+
+// Supported video qualities
+var validQualities = map[string]bool{
+	"auto":  true,
+	"1080p": true,
+	"720p":  true,
+	"480p":  true,
+	"360p":  true,
+}
+
+// Supported playlist modes
+var validPlaylistModes = map[string]bool{
+	PlaylistModeSequential: true,
+	PlaylistModeLoop:       true,
+	PlaylistModeShuffle:    true,
+}
+
+// Headers the media proxy may inject on behalf of a room. Credentials such as
+// Cookie are excluded because settings are visible to anyone who can see the room.
+var validProxyHeaders = map[string]bool{
+	"Referer":    true,
+	"Origin":     true,
+	"User-Agent": true,
+}
+
+// maxProxyHeaderLength caps the length of an injected header value
+const maxProxyHeaderLength = 1024
+
+// subtitleLangPattern matches BCP 47 style language tags such as zh, en, zh-CN
+var subtitleLangPattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})?$`)
+
+// RoomSettings is the typed schema stored as JSON in Room.Settings
+type RoomSettings struct {
+	AutoPlay        bool    `json:"auto_play"`         // start playback automatically for new viewers
+	AllowSeek       bool    `json:"allow_seek"`        // members may seek
+	HostOnlyControl bool    `json:"host_only_control"` // only the host may play/pause/seek/change rate
+	ChatEnabled     bool    `json:"chat_enabled"`      // members may send chat messages
+	GuestJoin       bool    `json:"guest_join"`        // new members may join the room
+	DefaultVolume   float64 `json:"default_volume"`    // default volume, 0-1
+	Quality         string  `json:"quality"`           // preferred quality: auto/1080p/720p/480p/360p
+	SubtitleOn      bool    `json:"subtitle_on"`       // subtitles shown by default
+	SubtitleLang    string  `json:"subtitle_lang"`     // preferred subtitle language tag
+	PlaylistMode    string  `json:"playlist_mode"`     // what follows the current item: sequential/loop/shuffle
+	CallEnabled     bool    `json:"call_enabled"`      // members may join the voice/video call
+
+	ProxyHeaders map[string]string `json:"proxy_headers,omitempty"` // headers injected by the media proxy, e.g. Referer for hotlink protection
+}
+
+// RoomSettingsPatch is a partial update of RoomSettings; nil fields are left unchanged
+type RoomSettingsPatch struct {
+	AutoPlay        *bool    `json:"auto_play,omitempty"`
+	AllowSeek       *bool    `json:"allow_seek,omitempty"`
+	HostOnlyControl *bool    `json:"host_only_control,omitempty"`
+	ChatEnabled     *bool    `json:"chat_enabled,omitempty"`
+	GuestJoin       *bool    `json:"guest_join,omitempty"`
+	DefaultVolume   *float64 `json:"default_volume,omitempty"`
+	Quality         *string  `json:"quality,omitempty"`
+	SubtitleOn      *bool    `json:"subtitle_on,omitempty"`
+	SubtitleLang    *string  `json:"subtitle_lang,omitempty"`
+	PlaylistMode    *string  `json:"playlist_mode,omitempty"`
+	CallEnabled     *bool    `json:"call_enabled,omitempty"`
+
+	ProxyHeaders map[string]string `json:"proxy_headers,omitempty"` // nil leaves the headers unchanged, an empty object clears them
+}
+
+// DefaultRoomSettings returns the settings used for new rooms and missing keys
+func DefaultRoomSettings() RoomSettings {
+	return RoomSettings{
+		AutoPlay:        true,
+		AllowSeek:       true,
+		HostOnlyControl: false,
+		ChatEnabled:     true,
+		GuestJoin:       true,
+		DefaultVolume:   1.0,
+		Quality:         "auto",
+		SubtitleOn:      false,
+		SubtitleLang:    "zh",
+		PlaylistMode:    PlaylistModeSequential,
+		CallEnabled:     true,
+	}
+}
+
+// ParseRoomSettings decodes stored settings, filling missing keys with defaults
+func ParseRoomSettings(data JSON) RoomSettings {
+	settings := DefaultRoomSettings()
+	if data != "" {
+		json.Unmarshal([]byte(data), &settings)
+	}
+	return settings
+}
+
+// Merge applies a partial update and returns the result
+func (s RoomSettings) Merge(patch *RoomSettingsPatch) RoomSettings {
+	if patch == nil {
+		return s
+	}
+	if patch.AutoPlay != nil {
+		s.AutoPlay = *patch.AutoPlay
+	}
+	if patch.AllowSeek != nil {
+		s.AllowSeek = *patch.AllowSeek
+	}
+	if patch.HostOnlyControl != nil {
+		s.HostOnlyControl = *patch.HostOnlyControl
+	}
+	if patch.ChatEnabled != nil {
+		s.ChatEnabled = *patch.ChatEnabled
+	}
+	if patch.GuestJoin != nil {
+		s.GuestJoin = *patch.GuestJoin
+	}
+	if patch.DefaultVolume != nil {
+		s.DefaultVolume = *patch.DefaultVolume
+	}
+	if patch.Quality != nil {
+		s.Quality = *patch.Quality
+	}
+	if patch.SubtitleOn != nil {
+		s.SubtitleOn = *patch.SubtitleOn
+	}
+	if patch.SubtitleLang != nil {
+		s.SubtitleLang = *patch.SubtitleLang
+	}
+	if patch.PlaylistMode != nil {
+		s.PlaylistMode = *patch.PlaylistMode
+	}
+	if patch.CallEnabled != nil {
+		s.CallEnabled = *patch.CallEnabled
+	}
+	if patch.ProxyHeaders != nil {
+		s.ProxyHeaders = nil
+		for key, value := range patch.ProxyHeaders {
+			if s.ProxyHeaders == nil {
+				s.ProxyHeaders = make(map[string]string, len(patch.ProxyHeaders))
+			}
+			s.ProxyHeaders[http.CanonicalHeaderKey(key)] = value
+		}
+	}
+	return s
+}
+
+// Validate checks that all values are within their allowed ranges
+func (s RoomSettings) Validate() error {
+	if s.DefaultVolume < 0 || s.DefaultVolume > 1 {
+		return fmt.Errorf("%w: default_volume must be between 0 and 1", ErrInvalidSettings)
+	}
+	if !validQualities[s.Quality] {
+		return fmt.Errorf("%w: unsupported quality %q", ErrInvalidSettings, s.Quality)
+	}
+	if !subtitleLangPattern.MatchString(s.SubtitleLang) {
+		return fmt.Errorf("%w: invalid subtitle_lang %q", ErrInvalidSettings, s.SubtitleLang)
+	}
+	if !validPlaylistModes[s.PlaylistMode] {
+		return fmt.Errorf("%w: unsupported playlist_mode %q", ErrInvalidSettings, s.PlaylistMode)
+	}
+	for key, value := range s.ProxyHeaders {
+		if !validProxyHeaders[key] {
+			return fmt.Errorf("%w: proxy header %q is not allowed", ErrInvalidSettings, key)
+		}
+		if len(value) > maxProxyHeaderLength || strings.ContainsAny(value, "\r\n\x00") {
+			return fmt.Errorf("%w: invalid value for proxy header %q", ErrInvalidSettings, key)
+		}
+	}
+	return nil
+}
+
+// ToJSON encodes the settings for storage in Room.Settings
+func (s RoomSettings) ToJSON() (JSON, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return JSON(data), nil
+}
+
+// Permissions returns the permission flags
+func (s RoomSettings) Permissions() RoomPermissions {
+	return RoomPermissions{
+		HostOnlyControl: s.HostOnlyControl,
+		AllowSeek:       s.AllowSeek,
+		ChatEnabled:     s.ChatEnabled,
+		CallEnabled:     s.CallEnabled,
+	}
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+)
+
+// MaxSubtitleTracks is the maximum number of subtitle tracks attached to a room
+const MaxSubtitleTracks = 20
+
+// Subtitle is a subtitle track attached to a room, stored converted to WebVTT
+type Subtitle struct {
+	ID        string    `gorm:"primaryKey;type:text" json:"id"`                            // 字幕ID (UUID)
+	RoomID    string    `gorm:"type:text;not null;index" json:"room_id"`                   // 房间ID
+	Label     string    `gorm:"type:text;not null" json:"label"`                           // 显示名称
+	Lang      string    `gorm:"type:text" json:"lang"`                                     // 语言标签，如 zh、en
+	Format    string    `gorm:"type:text" json:"format"`                                   // 原始格式: srt/vtt/ass/bilibili
+	SourceURL string    `gorm:"type:text" json:"source_url,omitempty"`                     // 来源地址（上传的字幕为空）
+	Offset    float64   `gorm:"type:real;default:0" json:"offset"`                         // 时间偏移 (秒)，为正时字幕延后显示
+	Content   string    `gorm:"type:text;not null" json:"-"`                               // 转换后的 WebVTT（未应用偏移）
+	AddedBy   string    `gorm:"type:text" json:"added_by"`                                 // 添加者会话ID
+	CreatedAt time.Time `gorm:"type:datetime;default:CURRENT_TIMESTAMP" json:"created_at"` // 创建时间
+
+	// Relations
+	Room *Room `gorm:"foreignKey:RoomID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// TableName overrides the table name
+func (Subtitle) TableName() string {
+	return "room_subtitles"
+}
+
+// SubtitleTracks is a room's subtitle tracks together with the one everybody is shown
+type SubtitleTracks struct {
+	RoomID    string      `json:"room_id"`
+	Tracks    []*Subtitle `json:"tracks"`
+	CurrentID string      `json:"current_id"` // empty when subtitles are off
+}
+
+// Current returns the selected track, or nil
+func (t *SubtitleTracks) Current() *Subtitle {
+	for _, track := range t.Tracks {
+		if track.ID == t.CurrentID {
+			return track
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+)
+
+// RevokedToken is a room access token revoked before it expired (logout, refresh)
+type RevokedToken struct {
+	ID        string    `gorm:"primaryKey;type:text" json:"id"`                 // 令牌ID (jti)
+	ExpiresAt time.Time `gorm:"type:datetime;not null;index" json:"expires_at"` // 令牌过期时间，之后记录可以删除
+}
+
+// TableName overrides the table name
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
+
+// TokenGeneration is the current token generation of a session in a room.
+// Tokens issued with an older generation are revoked (leaving, being kicked).
+type TokenGeneration struct {
+	RoomID     string    `gorm:"primaryKey;type:text" json:"room_id"`               // 房间ID
+	SessionID  string    `gorm:"primaryKey;type:text" json:"session_id"`            // 会话ID
+	Generation int64     `gorm:"type:integer;not null;default:0" json:"generation"` // 当前令牌代数
+	UpdatedAt  time.Time `gorm:"type:datetime;index" json:"updated_at"`             // 最后一次递增或签发时间
+}
+
+// TableName overrides the table name
+func (TokenGeneration) TableName() string {
+	return "token_generations"
+}
@@ -22,11 +22,15 @@ func (j *JSON) Scan(value interface{}) error {
 		*j = ""
 		return nil
 	}
-	bytes, ok := value.([]byte)
-	if !ok {
+	switch v := value.(type) {
+	case []byte:
+		*j = JSON(v)
+	case string:
+		// SQLite 驱动对 TEXT 列返回 string
+		*j = JSON(v)
+	default:
 		return errors.New("type assertion to []byte failed")
 	}
-	*j = JSON(bytes)
 	return nil
 }
 
@@ -35,12 +39,34 @@ var (
 	ErrVersionConflict    = errors.New("version conflict: optimistic locking failed")
 	ErrRoomNotFound       = errors.New("room not found")
 	ErrRoomFull           = errors.New("room is full")
+	ErrInvalidMaxUsers    = errors.New("max users out of allowed range")
 	ErrRoomPasswordInvalid = errors.New("invalid room password")
+	ErrRoomPasswordRequired = errors.New("private room requires a password")
+	ErrGuestJoinDisabled  = errors.New("room is not accepting new members")
 	ErrSessionNotFound    = errors.New("session not found")
 	ErrSessionExpired     = errors.New("session expired")
 	ErrNotRoomCreator     = errors.New("not room creator")
 	ErrInvalidMediaURL    = errors.New("invalid media URL")
 	ErrInvalidPlaybackState = errors.New("invalid playback state")
+	ErrNotRoomMember      = errors.New("not a room member")
+	ErrPermissionDenied   = errors.New("permission denied")
+	ErrMemberMuted        = errors.New("member is muted")
+	ErrPlaylistItemNotFound = errors.New("playlist item not found")
+	ErrPlaylistFull       = errors.New("playlist is full")
+	ErrPlaylistEnd        = errors.New("no more items in playlist")
+	ErrSubtitleNotFound   = errors.New("subtitle not found")
+	ErrInvalidSubtitle    = errors.New("invalid subtitle")
+	ErrSubtitleLimit      = errors.New("too many subtitle tracks")
+	ErrSubtitleTooLarge   = errors.New("subtitle file too large")
+	ErrSubtitleFetch      = errors.New("failed to fetch subtitle")
+	ErrInvalidDanmaku     = errors.New("invalid danmaku")
+	ErrDanmakuRateLimited = errors.New("sending danmaku too fast")
+	ErrInviteNotFound     = errors.New("invite not found")
+	ErrInviteExpired      = errors.New("invite has expired")
+	ErrInviteExhausted    = errors.New("invite has reached its use limit")
+	ErrInviteRevoked      = errors.New("invite has been revoked")
+	ErrInvalidInvite      = errors.New("invalid invite")
+	ErrAlreadyMember      = errors.New("already a room member")
 	
 	// Message errors
 	ErrMessageNotFound    = errors.New("message not found")
@@ -0,0 +1,149 @@
+package password
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 密码错误
+var (
+	ErrEmptyPassword = errors.New("password is empty")
+	ErrLocked        = errors.New("too many failed password attempts")
+)
+
+// Hash 使用 bcrypt 生成密码哈希
+func Hash(plain string) (string, error) {
+	if plain == "" {
+		return "", ErrEmptyPassword
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(plain), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// Verify 以恒定时间比较明文密码与哈希
+func Verify(hashed, plain string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(plain)) == nil
+}
+
+// SyncplayDigest 返回 Syncplay 客户端登录时发送的密码形式：明文的 MD5（十六进制小写）
+func SyncplayDigest(plain string) string {
+	sum := md5.Sum([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsHash 判断存储值是否已经是 bcrypt 哈希（用于迁移明文密码）
+func IsHash(value string) bool {
+	_, err := bcrypt.Cost([]byte(value))
+	return err == nil
+}
+
+// 默认防爆破策略
+const (
+	DefaultMaxAttempts = 5
+	DefaultWindow      = 15 * time.Minute
+	DefaultLockout     = 15 * time.Minute
+)
+
+// attempts 单个房间+来源IP的失败记录
+type attempts struct {
+	count       int
+	firstFailed time.Time
+	lockedUntil time.Time
+}
+
+// Guard 按房间和来源IP统计密码错误次数，超过阈值后临时锁定
+//
+// 记录保存在内存中，仅用于减缓短房间ID被枚举爆破。
+type Guard struct {
+	maxAttempts int
+	window      time.Duration
+	lockout     time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*attempts
+}
+
+// NewGuard 创建防爆破计数器，window 内失败 maxAttempts 次后锁定 lockout 时长
+func NewGuard(maxAttempts int, window, lockout time.Duration) *Guard {
+	return &Guard{
+		maxAttempts: maxAttempts,
+		window:      window,
+		lockout:     lockout,
+		now:         time.Now,
+		entries:     make(map[string]*attempts),
+	}
+}
+
+// Check 返回剩余锁定时长，未锁定时返回 0
+func (g *Guard) Check(roomID, ip string) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	entry, ok := g.entries[guardKey(roomID, ip)]
+	if !ok {
+		return 0
+	}
+	if remaining := entry.lockedUntil.Sub(g.now()); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Fail 记录一次失败，达到阈值时返回锁定时长
+func (g *Guard) Fail(roomID, ip string) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	key := guardKey(roomID, ip)
+	entry, ok := g.entries[key]
+	if !ok || now.Sub(entry.firstFailed) > g.window {
+		entry = &attempts{firstFailed: now}
+		g.entries[key] = entry
+	}
+
+	entry.count++
+	if entry.count >= g.maxAttempts {
+		entry.lockedUntil = now.Add(g.lockout)
+		entry.count = 0
+		entry.firstFailed = now
+		return g.lockout
+	}
+	return 0
+}
+
+// Reset 验证成功后清除失败记录
+func (g *Guard) Reset(roomID, ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.entries, guardKey(roomID, ip))
+}
+
+// Prune 清理已过期的记录，返回清理数量
+func (g *Guard) Prune() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	pruned := 0
+	for key, entry := range g.entries {
+		if now.After(entry.lockedUntil) && now.Sub(entry.firstFailed) > g.window {
+			delete(g.entries, key)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+func guardKey(roomID, ip string) string {
+	return roomID + "|" + ip
+}
@@ -0,0 +1,80 @@
+package password
+
+import (
+	"testing"
+	"time"
+)
+
+// 测试密码哈希与校验
+func TestHashAndVerify(t *testing.T) {
+	hashed, err := Hash("secret1")
+	if err != nil {
+		t.Fatalf("生成哈希失败: %v", err)
+	}
+	if hashed == "secret1" || !IsHash(hashed) {
+		t.Errorf("密码不应以明文存储: %s", hashed)
+	}
+	if !Verify(hashed, "secret1") {
+		t.Error("正确密码校验失败")
+	}
+	if Verify(hashed, "secret2") {
+		t.Error("错误密码不应通过校验")
+	}
+	if IsHash("secret1") {
+		t.Error("明文不应被识别为哈希")
+	}
+	if _, err := Hash(""); err != ErrEmptyPassword {
+		t.Errorf("空密码期望错误: %v, 实际: %v", ErrEmptyPassword, err)
+	}
+}
+
+// 测试失败次数统计与锁定
+// 测试 Syncplay 客户端的密码形式
+func TestSyncplayDigest(t *testing.T) {
+	// Syncplay 客户端发送 hashlib.md5(password).hexdigest()
+	if got := SyncplayDigest("letmein"); got != "0d107d09f5bbe40cade3de5c71e9e9b7" {
+		t.Errorf("unexpected digest: %s", got)
+	}
+}
+
+func TestGuard_Lockout(t *testing.T) {
+	now := time.Now()
+	guard := NewGuard(3, time.Minute, 5*time.Minute)
+	guard.now = func() time.Time { return now }
+
+	t.Run("TestLockAfterMaxAttempts", func(t *testing.T) {
+		guard.Fail("ROOM01", "1.1.1.1")
+		guard.Fail("ROOM01", "1.1.1.1")
+		if guard.Check("ROOM01", "1.1.1.1") != 0 {
+			t.Fatal("未达到阈值前不应锁定")
+		}
+		if locked := guard.Fail("ROOM01", "1.1.1.1"); locked != 5*time.Minute {
+			t.Errorf("期望锁定: %v, 实际: %v", 5*time.Minute, locked)
+		}
+		if guard.Check("ROOM01", "1.1.1.1") == 0 {
+			t.Error("达到阈值后应锁定")
+		}
+	})
+
+	t.Run("TestScopedByRoomAndIP", func(t *testing.T) {
+		if guard.Check("ROOM01", "2.2.2.2") != 0 || guard.Check("ROOM02", "1.1.1.1") != 0 {
+			t.Error("锁定不应影响其他IP或其他房间")
+		}
+	})
+
+	t.Run("TestLockExpires", func(t *testing.T) {
+		now = now.Add(6 * time.Minute)
+		if guard.Check("ROOM01", "1.1.1.1") != 0 {
+			t.Error("锁定时间过后应解除")
+		}
+	})
+
+	t.Run("TestResetOnSuccess", func(t *testing.T) {
+		guard.Fail("ROOM03", "1.1.1.1")
+		guard.Fail("ROOM03", "1.1.1.1")
+		guard.Reset("ROOM03", "1.1.1.1")
+		if locked := guard.Fail("ROOM03", "1.1.1.1"); locked != 0 {
+			t.Error("验证成功后失败次数应清零")
+		}
+	})
+}
@@ -0,0 +1,390 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const metaFile = "meta.json"
+
+// sliceMeta 缓存的资源信息
+type sliceMeta struct {
+	Cacheable    bool   `json:"cacheable"` // 上游支持范围请求且不是播放列表
+	Size         int64  `json:"size"`
+	ContentType  string `json:"content_type"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// sliceCache 按固定大小分片缓存上游资源的磁盘缓存
+//
+// 每个资源一个目录（目标地址和注入请求头的哈希），目录下保存 meta.json 和以序号命名的分片。
+// 总大小超过上限时按最近访问时间淘汰分片。
+type sliceCache struct {
+	dir       string
+	sliceSize int64
+	maxSize   int64
+
+	mu       sync.Mutex
+	size     int64
+	evicting bool
+}
+
+// newSliceCache 创建分片缓存并统计已有缓存的大小
+func newSliceCache(dir string, sliceSize, maxSize int64) (*sliceCache, error) {
+	if sliceSize <= 0 {
+		sliceSize = DefaultSliceSize
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultCacheMaxSize
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create proxy cache dir: %w", err)
+	}
+
+	c := &sliceCache{dir: dir, sliceSize: sliceSize, maxSize: maxSize}
+	for _, slice := range c.slices() {
+		c.size += slice.size
+	}
+	return c, nil
+}
+
+// key 资源的缓存键，注入的请求头可能影响上游返回的内容
+func (c *sliceCache) key(target Target) string {
+	keys := make([]string, 0, len(target.Headers))
+	for key := range target.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	h.Write([]byte(target.URL))
+	for _, key := range keys {
+		h.Write([]byte("\n" + key + ": " + target.Headers[key]))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *sliceCache) path(key string, name string) string {
+	return filepath.Join(c.dir, key[:2], key, name)
+}
+
+// loadMeta 读取资源信息
+func (c *sliceCache) loadMeta(key string) (*sliceMeta, bool) {
+	data, err := os.ReadFile(c.path(key, metaFile))
+	if err != nil {
+		return nil, false
+	}
+	var meta sliceMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, false
+	}
+	return &meta, true
+}
+
+// storeMeta 保存资源信息
+func (c *sliceCache) storeMeta(key string, meta *sliceMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return c.write(c.path(key, metaFile), data)
+}
+
+// loadSlice 读取分片并刷新访问时间
+func (c *sliceCache) loadSlice(key string, index int64) ([]byte, bool) {
+	name := c.path(key, strconv.FormatInt(index, 10))
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, false
+	}
+	now := time.Now()
+	os.Chtimes(name, now, now)
+	return data, true
+}
+
+// storeSlice 保存分片，超过上限时在后台淘汰
+func (c *sliceCache) storeSlice(key string, index int64, data []byte) error {
+	if err := c.write(c.path(key, strconv.FormatInt(index, 10)), data); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.size += int64(len(data))
+	evict := c.size > c.maxSize && !c.evicting
+	if evict {
+		c.evicting = true
+	}
+	c.mu.Unlock()
+
+	if evict {
+		go c.evict()
+	}
+	return nil
+}
+
+// write 先写临时文件再改名，并发写同一分片时不会读到不完整的内容
+func (c *sliceCache) write(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// cachedSlice 磁盘上的一个分片
+type cachedSlice struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// slices 列出磁盘上的全部分片
+func (c *sliceCache) slices() []cachedSlice {
+	var slices []cachedSlice
+	filepath.WalkDir(c.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || entry.Name() == metaFile || strings.HasPrefix(entry.Name(), ".tmp-") {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			slices = append(slices, cachedSlice{path: path, size: info.Size(), modTime: info.ModTime()})
+		}
+		return nil
+	})
+	return slices
+}
+
+// evict 按访问时间从旧到新删除分片，直到总大小降到上限的 90%
+func (c *sliceCache) evict() {
+	slices := c.slices()
+	sort.Slice(slices, func(i, j int) bool {
+		return slices[i].modTime.Before(slices[j].modTime)
+	})
+
+	var total int64
+	for _, slice := range slices {
+		total += slice.size
+	}
+	target := c.maxSize / 10 * 9
+	for _, slice := range slices {
+		if total <= target {
+			break
+		}
+		if err := os.Remove(slice.path); err == nil {
+			total -= slice.size
+		}
+	}
+
+	c.mu.Lock()
+	c.size = total
+	c.evicting = false
+	c.mu.Unlock()
+}
+
+// serveCached 通过分片缓存返回资源
+//
+// 上游不支持范围请求或资源是播放列表时返回 false，由调用方直接透传。
+func (p *Proxy) serveCached(w http.ResponseWriter, r *http.Request, target Target) (bool, error) {
+	c := p.cache
+	key := c.key(target)
+
+	meta, ok := c.loadMeta(key)
+	if !ok {
+		var err error
+		if meta, err = p.fetchMeta(r, target, key); err != nil || meta == nil {
+			return false, err
+		}
+	}
+	if !meta.Cacheable {
+		return false, nil
+	}
+
+	start, end, partial, ok := parseRange(r.Header.Get("Range"), meta.Size)
+	if !ok {
+		w.Header().Set("Content-Range", "bytes */"+strconv.FormatInt(meta.Size, 10))
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return true, nil
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", meta.ContentType)
+	header.Set("Accept-Ranges", "bytes")
+	header.Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+	if meta.ETag != "" {
+		header.Set("ETag", meta.ETag)
+	}
+	if meta.LastModified != "" {
+		header.Set("Last-Modified", meta.LastModified)
+	}
+	status := http.StatusOK
+	if partial {
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, meta.Size))
+		status = http.StatusPartialContent
+	}
+	w.WriteHeader(status)
+
+	for index := start / c.sliceSize; index <= end/c.sliceSize; index++ {
+		data, err := p.slice(r.Context(), r, target, key, index, meta.Size)
+		if err != nil {
+			logStreamError(target.URL, err)
+			return true, nil
+		}
+
+		sliceStart := index * c.sliceSize
+		from, to := int64(0), int64(len(data))
+		if start > sliceStart {
+			from = start - sliceStart
+		}
+		if end+1-sliceStart < to {
+			to = end + 1 - sliceStart
+		}
+		if _, err := w.Write(data[from:to]); err != nil {
+			return true, nil
+		}
+	}
+	return true, nil
+}
+
+// fetchMeta 读取第一个分片确认上游支持范围请求，同时获得资源总大小
+//
+// 上游返回错误时不记录信息、返回 nil，由调用方直接透传上游的响应。
+func (p *Proxy) fetchMeta(r *http.Request, target Target, key string) (*sliceMeta, error) {
+	c := p.cache
+	resp, err := p.fetch(r.Context(), r, target, fmt.Sprintf("bytes=0-%d", c.sliceSize-1))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, nil
+	}
+
+	meta := &sliceMeta{
+		Size:         totalSize(resp.Header.Get("Content-Range")),
+		ContentType:  resp.Header.Get("Content-Type"),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	meta.Cacheable = resp.StatusCode == http.StatusPartialContent && meta.Size > 0 && !isPlaylist(contentType(resp.Header), "")
+	if !meta.Cacheable {
+		return meta, c.storeMeta(key, meta)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, c.sliceSize))
+	if err != nil {
+		return nil, fmt.Errorf("read slice: %w", err)
+	}
+	if int64(len(data)) != min(c.sliceSize, meta.Size) {
+		return nil, fmt.Errorf("short slice: got %d bytes", len(data))
+	}
+	if err := c.storeSlice(key, 0, data); err != nil {
+		return nil, err
+	}
+	return meta, c.storeMeta(key, meta)
+}
+
+// slice 读取分片，未缓存时从上游获取
+func (p *Proxy) slice(ctx context.Context, r *http.Request, target Target, key string, index, size int64) ([]byte, error) {
+	c := p.cache
+	if data, ok := c.loadSlice(key, index); ok {
+		return data, nil
+	}
+
+	start := index * c.sliceSize
+	end := min(start+c.sliceSize, size) - 1
+	resp, err := p.fetch(ctx, r, target, fmt.Sprintf("bytes=%d-%d", start, end))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("slice %d: HTTP %d", index, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, end-start+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != end-start+1 {
+		return nil, fmt.Errorf("slice %d: got %d bytes", index, len(data))
+	}
+	if err := c.storeSlice(key, index, data); err != nil {
+		logStreamError(target.URL, err)
+	}
+	return data, nil
+}
+
+// parseRange 解析单个字节范围，没有 Range 或格式不支持时返回整个资源
+//
+// ok 为 false 表示范围无法满足。
+func parseRange(header string, size int64) (start, end int64, partial, ok bool) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, size - 1, false, true
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, size - 1, false, true
+	}
+
+	if first == "" {
+		// bytes=-N 最后 N 个字节
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false, false
+		}
+		return max(size-n, 0), size - 1, true, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false, false
+	}
+	end = size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, 0, false, false
+		}
+		end = min(end, size-1)
+	}
+	return start, end, true, true
+}
+
+// totalSize 解析 Content-Range 中的总大小（bytes 0-1023/12345）
+func totalSize(contentRange string) int64 {
+	i := strings.LastIndexByte(contentRange, '/')
+	if i < 0 {
+		return 0
+	}
+	size, err := strconv.ParseInt(contentRange[i+1:], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return size
+}
@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// uriAttribute 匹配 EXT-X-KEY、EXT-X-MAP、EXT-X-MEDIA 等标签中的 URI 属性
+var uriAttribute = regexp.MustCompile(`URI="([^"]*)"`)
+
+// isPlaylist 是否为 HLS 播放列表
+func isPlaylist(contentType, rawPath string) bool {
+	switch contentType {
+	case "application/vnd.apple.mpegurl", "application/x-mpegurl", "audio/mpegurl", "audio/x-mpegurl":
+		return true
+	}
+	return strings.HasSuffix(strings.ToLower(rawPath), ".m3u8")
+}
+
+// rewritePlaylist 把播放列表中的分片、子播放列表和密钥地址改写为代理地址
+//
+// 相对地址按 base（跟随重定向后的播放列表地址）解析；data:、skd:// 等非 HTTP 地址保持不变。
+func rewritePlaylist(data []byte, base *url.URL, proxyURL func(target string) string) []byte {
+	rewrite := func(ref string) string {
+		u, err := base.Parse(strings.TrimSpace(ref))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return ref
+		}
+		return proxyURL(u.String())
+	}
+
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxPlaylistSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#"):
+			line = uriAttribute.ReplaceAllStringFunc(line, func(attr string) string {
+				return `URI="` + rewrite(uriAttribute.FindStringSubmatch(attr)[1]) + `"`
+			})
+		default:
+			line = rewrite(line)
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,270 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// 代理错误
+var (
+	ErrInvalidTarget    = errors.New("invalid proxy target")
+	ErrBlockedTarget    = errors.New("proxy target is a private or loopback address")
+	ErrInvalidSignature = errors.New("invalid proxy signature")
+)
+
+const (
+	// DefaultTargetTTL 默认签名地址有效期，需覆盖一部影片的播放时长
+	DefaultTargetTTL = 12 * time.Hour
+	// DefaultTimeout 默认连接上游和等待响应头的超时时间
+	DefaultTimeout = 15 * time.Second
+	// DefaultSliceSize 默认缓存分片大小
+	DefaultSliceSize = 1 << 20
+	// DefaultCacheMaxSize 默认磁盘缓存上限
+	DefaultCacheMaxSize = 1 << 30
+
+	maxPlaylistSize = 8 << 20 // 改写的 HLS 播放列表最大字节数
+)
+
+// 转发给上游的客户端请求头
+var forwardRequestHeaders = []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since", "User-Agent", "Accept"}
+
+// 返回给客户端的上游响应头（不包含 Set-Cookie 等）
+var forwardResponseHeaders = []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "Last-Modified", "ETag", "Cache-Control", "Expires"}
+
+// Options 代理配置
+type Options struct {
+	Secret       string        // 签名密钥
+	TargetTTL    time.Duration // 签名地址有效期（为空时使用 DefaultTargetTTL）
+	Timeout      time.Duration // 连接上游和等待响应头的超时（为空时使用 DefaultTimeout）
+	AllowPrivate bool          // 是否允许代理内网和回环地址
+	CacheDir     string        // 分片缓存目录，为空时不缓存
+	SliceSize    int64         // 缓存分片大小（为空时使用 DefaultSliceSize）
+	CacheMaxSize int64         // 磁盘缓存上限（为空时使用 DefaultCacheMaxSize）
+}
+
+// Target 一次代理请求的目标
+type Target struct {
+	RoomID  string
+	URL     string
+	Headers map[string]string // 注入上游请求的房间请求头（Referer 等）
+}
+
+// Proxy 媒体透传代理
+//
+// 转发 Range 请求并流式返回上游响应；HLS 播放列表中的地址改写为签名的代理地址，
+// 浏览器播放器后续请求分片时不需要携带房间令牌。上游地址在建立连接时校验，
+// 重定向到内网地址同样会被拒绝。
+type Proxy struct {
+	client *http.Client
+	signer signer
+	cache  *sliceCache
+}
+
+// New 创建媒体代理
+func New(opts Options) (*Proxy, error) {
+	if len(opts.Secret) < 16 {
+		return nil, fmt.Errorf("proxy secret too short (min 16 characters)")
+	}
+	if opts.TargetTTL <= 0 {
+		opts.TargetTTL = DefaultTargetTTL
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	p := &Proxy{
+		client: NewClient(opts.Timeout, opts.AllowPrivate),
+		signer: newSigner(opts.Secret, opts.TargetTTL),
+	}
+
+	if opts.CacheDir != "" {
+		cache, err := newSliceCache(opts.CacheDir, opts.SliceSize, opts.CacheMaxSize)
+		if err != nil {
+			return nil, err
+		}
+		p.cache = cache
+	}
+	return p, nil
+}
+
+// Sign 为房间签名代理目标地址
+func (p *Proxy) Sign(roomID, target string) string {
+	return p.signer.sign(roomID, target, time.Now())
+}
+
+// Verify 校验签名，返回目标地址
+func (p *Proxy) Verify(roomID, signed string) (string, error) {
+	return p.signer.verify(roomID, signed, time.Now())
+}
+
+// Serve 代理一次请求
+//
+// 返回错误时尚未向客户端写入任何内容，由调用方决定错误响应；上游的 4xx/5xx 响应原样返回。
+func (p *Proxy) Serve(w http.ResponseWriter, r *http.Request, target Target) error {
+	u, err := url.Parse(target.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidTarget, target.URL)
+	}
+
+	// 视频流可能持续数小时，取消服务器的整体写超时
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	if p.cache != nil && !isPlaylist("", u.Path) {
+		served, err := p.serveCached(w, r, target)
+		if served || err != nil {
+			return err
+		}
+	}
+
+	resp, err := p.fetch(r.Context(), r, target, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK && isPlaylist(contentType(resp.Header), u.Path) {
+		return p.servePlaylist(w, r, target.RoomID, resp)
+	}
+
+	copyHeaders(w.Header(), resp.Header)
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+	return nil
+}
+
+// servePlaylist 改写播放列表中的地址后返回
+func (p *Proxy) servePlaylist(w http.ResponseWriter, r *http.Request, roomID string, resp *http.Response) error {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPlaylistSize+1))
+	if err != nil {
+		return fmt.Errorf("read playlist: %w", err)
+	}
+	if len(data) > maxPlaylistSize {
+		return fmt.Errorf("playlist exceeds %d bytes", maxPlaylistSize)
+	}
+
+	self := r.URL.Path
+	data = rewritePlaylist(data, resp.Request.URL, func(target string) string {
+		return self + "?target=" + url.QueryEscape(p.Sign(roomID, target))
+	})
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	// 直播播放列表随时变化，分片地址的签名也会过期
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return nil
+}
+
+// fetch 向上游发送请求，byteRange 不为空时替换客户端的 Range
+func (p *Proxy) fetch(ctx context.Context, r *http.Request, target Target, byteRange string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTarget, err)
+	}
+
+	for _, key := range forwardRequestHeaders {
+		if value := r.Header.Get(key); value != "" {
+			req.Header.Set(key, value)
+		}
+	}
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+		req.Header.Del("If-Range")
+	}
+	for key, value := range target.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrBlockedTarget) {
+			return nil, ErrBlockedTarget
+		}
+		return nil, fmt.Errorf("fetch %s: %w", target.URL, err)
+	}
+	return resp, nil
+}
+
+// NewClient 创建访问外部地址的 HTTP 客户端
+//
+// allowPrivate 为 false 时拒绝连接内网、回环和链路本地地址，重定向同样受限；
+// 服务端按用户提供的地址发起请求（如下载字幕）时应使用它。
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = checkAddress
+	}
+	return &http.Client{Transport: &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConnsPerHost:   16,
+		IdleConnTimeout:       90 * time.Second,
+	}}
+}
+
+// checkAddress 拒绝连接内网、回环和链路本地地址（在 DNS 解析之后校验，防止 DNS 重绑定）
+func checkAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || isPrivateIP(ip) {
+		return fmt.Errorf("%w: %s", ErrBlockedTarget, host)
+	}
+	return nil
+}
+
+// isPrivateIP 是否为内网、回环、链路本地或未指定地址
+func isPrivateIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		// 100.64.0.0/10 运营商级 NAT
+		if ip[0] == 100 && ip[1]&0xc0 == 64 {
+			return true
+		}
+	}
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+// copyHeaders 复制允许返回给客户端的响应头
+func copyHeaders(dst, src http.Header) {
+	for _, key := range forwardResponseHeaders {
+		if value := src.Get(key); value != "" {
+			dst.Set(key, value)
+		}
+	}
+}
+
+// contentType 不含参数的小写 Content-Type
+func contentType(header http.Header) string {
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+// logStreamError 记录已开始写响应后的上游错误（客户端主动断开不记录）
+func logStreamError(target string, err error) {
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Printf("media proxy %s: %v", target, err)
+	}
+}
@@ -0,0 +1,298 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testSecret = "proxy-test-secret-value"
+
+// upstream 记录请求数的上游服务器
+type upstream struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func newUpstream(t *testing.T, routes map[string]http.HandlerFunc) *upstream {
+	t.Helper()
+	u := &upstream{requests: make(map[string]int)}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.mu.Lock()
+		u.requests[r.URL.Path]++
+		u.mu.Unlock()
+		if handler, ok := routes[r.URL.Path]; ok {
+			handler(w, r)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+func (u *upstream) count(path string) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.requests[path]
+}
+
+// serveBytes 支持范围请求的静态内容
+func serveBytes(contentType string, data []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}
+}
+
+// serve 通过代理请求目标地址
+func serve(t *testing.T, p *Proxy, target Target, byteRange string) (*httptest.ResponseRecorder, error) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/rooms/"+target.RoomID+"/media/proxy", nil)
+	if byteRange != "" {
+		r.Header.Set("Range", byteRange)
+	}
+	w := httptest.NewRecorder()
+	return w, p.Serve(w, r, target)
+}
+
+func newTestProxy(t *testing.T, opts Options) *Proxy {
+	t.Helper()
+	opts.Secret = testSecret
+	p, err := New(opts)
+	if err != nil {
+		t.Fatalf("创建代理失败: %v", err)
+	}
+	return p
+}
+
+func TestProxy(t *testing.T) {
+	video := make([]byte, 10000)
+	for i := range video {
+		video[i] = byte(i % 251)
+	}
+
+	server := newUpstream(t, map[string]http.HandlerFunc{
+		"/video.mp4": serveBytes("video/mp4", video),
+		"/show/master.m3u8": serveBytes("application/vnd.apple.mpegurl", []byte(strings.Join([]string{
+			"#EXTM3U",
+			`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="zh",URI="audio/zh.m3u8"`,
+			"#EXT-X-STREAM-INF:BANDWIDTH=800000,AUDIO=\"aud\"",
+			"hd/index.m3u8",
+		}, "\n"))),
+		"/show/hd/index.m3u8": serveBytes("text/plain", []byte(strings.Join([]string{
+			"#EXTM3U",
+			`#EXT-X-KEY:METHOD=AES-128,URI="/keys/1.key"`,
+			`#EXT-X-SESSION-DATA:DATA-ID="x",URI="data:text/plain,hi"`,
+			"#EXTINF:6.0,",
+			"seg0.ts",
+			"#EXTINF:6.0,",
+			"https://cdn.example.com/seg1.ts",
+			"#EXT-X-ENDLIST",
+		}, "\n"))),
+		"/protected.mp4": func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Referer") != "https://example.com/" {
+				http.Error(w, "hotlink", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Set-Cookie", "session=secret")
+			serveBytes("video/mp4", video[:100])(w, r)
+		},
+	})
+
+	t.Run("TestRangePassThrough", func(t *testing.T) {
+		p := newTestProxy(t, Options{AllowPrivate: true})
+		w, err := serve(t, p, Target{RoomID: "r1", URL: server.URL + "/video.mp4"}, "bytes=100-199")
+		if err != nil {
+			t.Fatalf("代理失败: %v", err)
+		}
+		if w.Code != http.StatusPartialContent || w.Header().Get("Content-Range") != "bytes 100-199/10000" {
+			t.Errorf("期望 206 和 Content-Range，实际: %d %q", w.Code, w.Header().Get("Content-Range"))
+		}
+		if !bytes.Equal(w.Body.Bytes(), video[100:200]) {
+			t.Error("返回的内容与上游不一致")
+		}
+	})
+
+	t.Run("TestPlaylistRewrite", func(t *testing.T) {
+		p := newTestProxy(t, Options{AllowPrivate: true})
+		w, err := serve(t, p, Target{RoomID: "r1", URL: server.URL + "/show/hd/index.m3u8"}, "")
+		if err != nil {
+			t.Fatalf("代理失败: %v", err)
+		}
+		if w.Header().Get("Content-Type") != "application/vnd.apple.mpegurl" {
+			t.Errorf("播放列表的 Content-Type 不正确: %s", w.Header().Get("Content-Type"))
+		}
+
+		var targets []string
+		for _, line := range strings.Split(w.Body.String(), "\n") {
+			ref := line
+			if match := uriAttribute.FindStringSubmatch(line); match != nil {
+				ref = match[1]
+			} else if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if strings.HasPrefix(ref, "data:") {
+				continue
+			}
+			u, err := url.Parse(ref)
+			if err != nil || u.Path != "/api/v1/rooms/r1/media/proxy" {
+				t.Fatalf("地址未改写为代理地址: %s", ref)
+			}
+			target, err := p.Verify("r1", u.Query().Get("target"))
+			if err != nil {
+				t.Fatalf("签名校验失败: %v", err)
+			}
+			targets = append(targets, target)
+		}
+
+		want := []string{server.URL + "/keys/1.key", server.URL + "/show/hd/seg0.ts", "https://cdn.example.com/seg1.ts"}
+		if strings.Join(targets, " ") != strings.Join(want, " ") {
+			t.Errorf("改写后的目标地址: %v, 期望: %v", targets, want)
+		}
+		if !strings.Contains(w.Body.String(), `URI="data:text/plain,hi"`) {
+			t.Error("data: 地址不应改写")
+		}
+	})
+
+	t.Run("TestMasterPlaylistRewrite", func(t *testing.T) {
+		p := newTestProxy(t, Options{AllowPrivate: true})
+		w, err := serve(t, p, Target{RoomID: "r1", URL: server.URL + "/show/master.m3u8"}, "")
+		if err != nil {
+			t.Fatalf("代理失败: %v", err)
+		}
+		if strings.Contains(w.Body.String(), "hd/index.m3u8\n") || strings.Contains(w.Body.String(), `URI="audio/zh.m3u8"`) {
+			t.Errorf("子播放列表地址未改写:\n%s", w.Body.String())
+		}
+	})
+
+	t.Run("TestInjectHeaders", func(t *testing.T) {
+		p := newTestProxy(t, Options{AllowPrivate: true})
+		target := Target{RoomID: "r1", URL: server.URL + "/protected.mp4"}
+		if w, _ := serve(t, p, target, ""); w.Code != http.StatusForbidden {
+			t.Errorf("未注入 Referer 时期望上游返回 403，实际: %d", w.Code)
+		}
+
+		target.Headers = map[string]string{"Referer": "https://example.com/"}
+		w, err := serve(t, p, target, "")
+		if err != nil || w.Code != http.StatusOK || w.Body.Len() != 100 {
+			t.Fatalf("注入 Referer 后应返回内容: %d %v", w.Code, err)
+		}
+		if w.Header().Get("Set-Cookie") != "" {
+			t.Error("不应转发上游的 Set-Cookie")
+		}
+	})
+
+	t.Run("TestBlockPrivateTargets", func(t *testing.T) {
+		p := newTestProxy(t, Options{})
+		if _, err := serve(t, p, Target{RoomID: "r1", URL: server.URL + "/video.mp4"}, ""); !errors.Is(err, ErrBlockedTarget) {
+			t.Errorf("期望错误: %v, 实际: %v", ErrBlockedTarget, err)
+		}
+		if _, err := serve(t, p, Target{RoomID: "r1", URL: "file:///etc/passwd"}, ""); !errors.Is(err, ErrInvalidTarget) {
+			t.Errorf("期望错误: %v, 实际: %v", ErrInvalidTarget, err)
+		}
+	})
+
+	t.Run("TestSliceCache", func(t *testing.T) {
+		p := newTestProxy(t, Options{AllowPrivate: true, CacheDir: t.TempDir(), SliceSize: 1024})
+		target := Target{RoomID: "r1", URL: server.URL + "/video.mp4"}
+
+		// 跨越多个分片的范围
+		w, err := serve(t, p, target, "bytes=1000-5000")
+		if err != nil {
+			t.Fatalf("代理失败: %v", err)
+		}
+		if w.Code != http.StatusPartialContent || w.Header().Get("Content-Range") != "bytes 1000-5000/10000" || !bytes.Equal(w.Body.Bytes(), video[1000:5001]) {
+			t.Fatalf("缓存返回的范围不正确: %d %q", w.Code, w.Header().Get("Content-Range"))
+		}
+
+		before := server.count("/video.mp4")
+		w, _ = serve(t, p, target, "bytes=2048-4095")
+		if !bytes.Equal(w.Body.Bytes(), video[2048:4096]) {
+			t.Error("缓存命中时返回的内容不正确")
+		}
+		if server.count("/video.mp4") != before {
+			t.Error("已缓存的分片不应再请求上游")
+		}
+
+		w, _ = serve(t, p, target, "")
+		if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), video) {
+			t.Errorf("不带 Range 时应返回完整内容: %d", w.Code)
+		}
+
+		if w, _ = serve(t, p, target, "bytes=20000-"); w.Code != http.StatusRequestedRangeNotSatisfiable {
+			t.Errorf("期望状态码: %d, 实际: %d", http.StatusRequestedRangeNotSatisfiable, w.Code)
+		}
+	})
+
+	t.Run("TestSliceCacheSkipsPlaylists", func(t *testing.T) {
+		p := newTestProxy(t, Options{AllowPrivate: true, CacheDir: t.TempDir(), SliceSize: 1024})
+		// 地址不以 .m3u8 结尾，只能由 Content-Type 识别
+		w, err := serve(t, p, Target{RoomID: "r1", URL: server.URL + "/show/master.m3u8?v=1"}, "")
+		if err != nil {
+			t.Fatalf("代理失败: %v", err)
+		}
+		if !strings.Contains(w.Body.String(), "target=") {
+			t.Errorf("播放列表应改写而不是缓存:\n%s", w.Body.String())
+		}
+	})
+
+	t.Run("TestSliceCacheEviction", func(t *testing.T) {
+		p := newTestProxy(t, Options{AllowPrivate: true, CacheDir: t.TempDir(), SliceSize: 1024, CacheMaxSize: 4096})
+		serve(t, p, Target{RoomID: "r1", URL: server.URL + "/video.mp4"}, "")
+
+		deadline := time.Now().Add(time.Second)
+		for {
+			p.cache.mu.Lock()
+			size, evicting := p.cache.size, p.cache.evicting
+			p.cache.mu.Unlock()
+			if !evicting && size <= 4096 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("缓存大小应降到上限以内，实际: %d", size)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+func TestSigner(t *testing.T) {
+	s := newSigner(testSecret, time.Hour)
+	now := time.Now()
+	signed := s.sign("r1", "https://cdn.example.com/a.ts", now)
+
+	if target, err := s.verify("r1", signed, now); err != nil || target != "https://cdn.example.com/a.ts" {
+		t.Errorf("签名校验失败: %q %v", target, err)
+	}
+
+	parts := strings.Split(signed, ".")
+	cases := []struct {
+		name   string
+		roomID string
+		signed string
+		at     time.Time
+	}{
+		{"其他房间", "r2", signed, now},
+		{"过期", "r1", signed, now.Add(2 * time.Hour)},
+		{"篡改地址", "r1", "aHR0cHM6Ly9ldmlsLmV4YW1wbGUuY29t." + parts[1] + "." + parts[2], now},
+		{"篡改时间", "r1", parts[0] + ".9999999999." + parts[2], now},
+		{"格式错误", "r1", "not-a-signature", now},
+	}
+	for _, tc := range cases {
+		if _, err := s.verify(tc.roomID, tc.signed, tc.at); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: 期望错误: %v, 实际: %v", tc.name, ErrInvalidSignature, err)
+		}
+	}
+
+	if _, err := newSigner("another-secret-value", time.Hour).verify("r1", signed, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("其他密钥签名的地址应校验失败: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// signer 为代理目标地址签名，签名绑定房间和过期时间
+//
+// 签名格式: base64url(目标地址).过期时间(unix 秒).base64url(HMAC-SHA256)
+type signer struct {
+	key []byte
+	ttl time.Duration
+}
+
+// newSigner 由服务端密钥派生代理专用的签名密钥，避免与房间令牌共用同一把密钥
+func newSigner(secret string, ttl time.Duration) signer {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("xiaowo media proxy"))
+	return signer{key: mac.Sum(nil), ttl: ttl}
+}
+
+// sign 签名目标地址
+func (s signer) sign(roomID, target string, now time.Time) string {
+	expires := strconv.FormatInt(now.Add(s.ttl).Unix(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(target)) + "." + expires + "." + s.mac(roomID, target, expires)
+}
+
+// verify 校验签名并返回目标地址
+func (s signer) verify(roomID, signed string, now time.Time) (string, error) {
+	parts := strings.Split(signed, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("%w: malformed", ErrInvalidSignature)
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("%w: malformed target", ErrInvalidSignature)
+	}
+	target := string(raw)
+
+	if !hmac.Equal([]byte(parts[2]), []byte(s.mac(roomID, target, parts[1]))) {
+		return "", fmt.Errorf("%w: signature mismatch", ErrInvalidSignature)
+	}
+
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || now.Unix() > expires {
+		return "", fmt.Errorf("%w: expired", ErrInvalidSignature)
+	}
+	return target, nil
+}
+
+func (s signer) mac(roomID, target, expires string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(roomID + "\n" + expires + "\n" + target))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"xiaowo/backend/internal/model"
+)
+
+// DanmakuRepository interface defines all danmaku operations, keyed by room and media
+type DanmakuRepository interface {
+	Create(danmaku *model.Danmaku) error
+	ListByWindow(roomID, mediaURL string, from, to float64, limit int) ([]*model.Danmaku, error)
+	ListByMedia(roomID, mediaURL string, kind model.DanmakuKind, limit int) ([]*model.Danmaku, error)
+}
+
+// DanmakuRepo implements DanmakuRepository
+type DanmakuRepo struct {
+	db *gorm.DB
+}
+
+// NewDanmakuRepo creates a new danmaku repository
+func NewDanmakuRepo(db *gorm.DB) *DanmakuRepo {
+	return &DanmakuRepo{db: db}
+}
+
+// Create stores a danmaku
+func (r *DanmakuRepo) Create(danmaku *model.Danmaku) error {
+	if danmaku.ID == "" {
+		danmaku.ID = uuid.New().String()
+	}
+	if danmaku.CreatedAt.IsZero() {
+		danmaku.CreatedAt = time.Now()
+	}
+	if err := r.db.Create(danmaku).Error; err != nil {
+		return fmt.Errorf("failed to create danmaku: %w", err)
+	}
+	return nil
+}
+
+// ListByWindow returns the danmaku of a media whose time falls in [from, to), ordered by time
+func (r *DanmakuRepo) ListByWindow(roomID, mediaURL string, from, to float64, limit int) ([]*model.Danmaku, error) {
+	var danmaku []*model.Danmaku
+	err := r.db.Where("room_id = ? AND media_url = ? AND time >= ? AND time < ?", roomID, mediaURL, from, to).
+		Order("time ASC, created_at ASC").Limit(limit).Find(&danmaku).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list danmaku: %w", err)
+	}
+	return danmaku, nil
+}
+
+// ListByMedia returns the danmaku of a media ordered by time, optionally only one kind
+func (r *DanmakuRepo) ListByMedia(roomID, mediaURL string, kind model.DanmakuKind, limit int) ([]*model.Danmaku, error) {
+	var danmaku []*model.Danmaku
+	query := r.db.Where("room_id = ? AND media_url = ?", roomID, mediaURL)
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if err := query.Order("time ASC, created_at ASC").Limit(limit).Find(&danmaku).Error; err != nil {
+		return nil, fmt.Errorf("failed to list danmaku: %w", err)
+	}
+	return danmaku, nil
+}
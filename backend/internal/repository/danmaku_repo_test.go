@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"xiaowo/backend/internal/model"
+)
+
+func TestDanmakuRepo_List(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewDanmakuRepo(db)
+	room := newTestRoom(t, db)
+	const other = "https://example.com/other.mp4"
+
+	now := time.Now()
+	rows := []*model.Danmaku{
+		{Content: "b", Time: 30, Kind: model.DanmakuKindComment, MediaURL: room.MediaURL, CreatedAt: now},
+		{Content: "a", Time: 12.5, Kind: model.DanmakuKindComment, MediaURL: room.MediaURL, CreatedAt: now},
+		{Content: "🔥", Time: 30, Kind: model.DanmakuKindReaction, MediaURL: room.MediaURL, CreatedAt: now.Add(time.Second)},
+		{Content: "c", Time: 40, Kind: model.DanmakuKindComment, MediaURL: room.MediaURL, CreatedAt: now},
+		{Content: "x", Time: 20, Kind: model.DanmakuKindComment, MediaURL: other, CreatedAt: now},
+	}
+	for _, danmaku := range rows {
+		danmaku.RoomID, danmaku.SessionID = room.ID, "s1"
+		if err := repo.Create(danmaku); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	join := func(danmaku []*model.Danmaku) string {
+		var s string
+		for _, d := range danmaku {
+			s += d.Content
+		}
+		return s
+	}
+
+	t.Run("TestListByWindow", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			mediaURL string
+			from, to float64
+			limit    int
+			want     string
+		}{
+			{"OrderedByTime", room.MediaURL, 0, 60, 10, "ab🔥c"},
+			{"EndExclusive", room.MediaURL, 12.5, 40, 10, "ab🔥"},
+			{"Limit", room.MediaURL, 0, 60, 2, "ab"},
+			{"OtherMedia", other, 0, 60, 10, "x"},
+			{"Empty", room.MediaURL, 50, 60, 10, ""},
+		}
+		for _, tt := range tests {
+			got, err := repo.ListByWindow(room.ID, tt.mediaURL, tt.from, tt.to, tt.limit)
+			if err != nil {
+				t.Fatalf("%s: ListByWindow failed: %v", tt.name, err)
+			}
+			if join(got) != tt.want {
+				t.Errorf("%s: expected %q, got %q", tt.name, tt.want, join(got))
+			}
+		}
+	})
+
+	t.Run("TestListByMedia", func(t *testing.T) {
+		tests := []struct {
+			kind model.DanmakuKind
+			want string
+		}{
+			{"", "ab🔥c"},
+			{model.DanmakuKindComment, "abc"},
+			{model.DanmakuKindReaction, "🔥"},
+		}
+		for _, tt := range tests {
+			got, err := repo.ListByMedia(room.ID, room.MediaURL, tt.kind, 10)
+			if err != nil {
+				t.Fatalf("ListByMedia(%q) failed: %v", tt.kind, err)
+			}
+			if join(got) != tt.want {
+				t.Errorf("kind %q: expected %q, got %q", tt.kind, tt.want, join(got))
+			}
+		}
+	})
+}
@@ -5,12 +5,15 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/password"
 )
 
 // Config 数据库配置结构
@@ -27,8 +30,13 @@ type Config struct {
 
 // DefaultConfig 默认数据库配置
 func DefaultConfig() *Config {
+	return NewConfig("xiaowo.db")
+}
+
+// NewConfig 使用指定数据库文件创建配置，其余参数取默认值
+func NewConfig(path string) *Config {
 	return &Config{
-		DSN:        getDatabaseDSN(),
+		DSN:        getDatabaseDSN(path),
 		MaxOpen:    25,
 		MaxIdle:    10,
 		Lifetime:   5 * time.Minute,
@@ -40,12 +48,7 @@ func DefaultConfig() *Config {
 }
 
 // getDatabaseDSN 获取数据库DSN
-func getDatabaseDSN() string {
-	dbSource := os.Getenv("DB_SOURCE")
-	if dbSource == "" {
-		dbSource = "xiaowo.db"
-	}
-	
+func getDatabaseDSN(dbSource string) string {
 	return fmt.Sprintf("%s?"+
 		"cache=shared&"+
 		"mode=rwc&"+
@@ -228,14 +231,109 @@ func MigrateDatabase(db *gorm.DB) error {
 	}
 
 	// 自动迁移数据库模式
-	if err := db.AutoMigrate(); err != nil {
+	if err := db.AutoMigrate(
+		&model.UserSession{},
+		&model.Room{},
+		&model.RoomMember{},
+		&model.Message{},
+		&model.PlaylistItem{},
+		&model.Subtitle{},
+		&model.Danmaku{},
+		&model.Invite{},
+		&model.InviteUse{},
+		&model.RevokedToken{},
+		&model.TokenGeneration{},
+	); err != nil {
 		return fmt.Errorf("database migration failed: %w", err)
 	}
 
+	if err := migrateRoomPasswords(db); err != nil {
+		return fmt.Errorf("room password migration failed: %w", err)
+	}
+
+	if err := migrateRoomPlaylists(db); err != nil {
+		return fmt.Errorf("room playlist migration failed: %w", err)
+	}
+
 	log.Println("数据库迁移完成")
 	return nil
 }
 
+// migrateRoomPasswords 将历史明文房间密码重新以 bcrypt 哈希存储
+func migrateRoomPasswords(db *gorm.DB) error {
+	var rooms []model.Room
+	if err := db.Select("id", "room_password").Where("room_password IS NOT NULL AND room_password != ''").Find(&rooms).Error; err != nil {
+		return err
+	}
+
+	migrated := 0
+	for _, room := range rooms {
+		if password.IsHash(room.Password) {
+			continue
+		}
+		hashed, err := password.Hash(room.Password)
+		if err != nil {
+			return err
+		}
+		if err := db.Model(&model.Room{}).Where("id = ?", room.ID).UpdateColumn("room_password", hashed).Error; err != nil {
+			return err
+		}
+		migrated++
+	}
+
+	if migrated > 0 {
+		log.Printf("已迁移 %d 个明文房间密码", migrated)
+	}
+	return nil
+}
+
+// migrateRoomPlaylists 为没有播放列表的历史房间创建首个条目，并设为当前播放条目
+func migrateRoomPlaylists(db *gorm.DB) error {
+	var rooms []model.Room
+	if err := db.Where("(current_item_id IS NULL OR current_item_id = '') AND media_url != ''").Find(&rooms).Error; err != nil {
+		return err
+	}
+
+	migrated := 0
+	for _, room := range rooms {
+		var count int64
+		if err := db.Model(&model.PlaylistItem{}).Where("room_id = ?", room.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		// 已有条目的房间是当前条目被移除，保持原样
+		if count > 0 {
+			continue
+		}
+
+		item := model.PlaylistItem{
+			ID:            uuid.New().String(),
+			RoomID:        room.ID,
+			MediaURL:      room.MediaURL,
+			MediaType:     room.MediaType,
+			MediaTitle:    room.MediaTitle,
+			MediaDuration: room.MediaDuration,
+			MediaInfo:     room.MediaInfo,
+			AddedBy:       room.CreatorSessionID,
+			CreatedAt:     room.CreatedAt,
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+			return tx.Model(&model.Room{}).Where("id = ?", room.ID).UpdateColumn("current_item_id", item.ID).Error
+		})
+		if err != nil {
+			return err
+		}
+		migrated++
+	}
+
+	if migrated > 0 {
+		log.Printf("已为 %d 个房间创建播放列表", migrated)
+	}
+	return nil
+}
+
 // ValidateSchema 验证数据库模式
 func ValidateSchema(db *gorm.DB) error {
 	if db == nil {
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"testing"
+
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/password"
+)
+
+func TestMigrateDatabase_HashesPlaintextPasswords(t *testing.T) {
+	db := newTestDB(t)
+	legacy := newTestRoom(t, db)
+	hashed := newTestRoom(t, db)
+
+	hash, err := password.Hash("already")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	db.Model(&model.Room{}).Where("id = ?", legacy.ID).UpdateColumn("room_password", "legacy1")
+	db.Model(&model.Room{}).Where("id = ?", hashed.ID).UpdateColumn("room_password", hash)
+
+	// migrating twice must not hash the hashes again
+	for i := 0; i < 2; i++ {
+		if err := MigrateDatabase(db); err != nil {
+			t.Fatalf("MigrateDatabase failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		roomID, secret string
+	}{
+		{legacy.ID, "legacy1"},
+		{hashed.ID, "already"},
+	}
+	for _, tt := range tests {
+		var room model.Room
+		db.Select("id", "room_password").First(&room, "id = ?", tt.roomID)
+		if !password.IsHash(room.Password) || !password.Verify(room.Password, tt.secret) {
+			t.Errorf("room %s: expected a hash of %q, got %q", tt.roomID, tt.secret, room.Password)
+		}
+	}
+	var stored model.Room
+	db.Select("room_password").First(&stored, "id = ?", hashed.ID)
+	if stored.Password != hash {
+		t.Error("an existing hash should be left untouched")
+	}
+}
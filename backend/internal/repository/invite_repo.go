@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"xiaowo/backend/internal/model"
+)
+
+// InviteRepository interface defines all room invite operations
+type InviteRepository interface {
+	Create(invite *model.Invite) error
+	GetByCode(code string) (*model.Invite, error)
+	ListByRoom(roomID string) ([]*model.Invite, error)
+	Revoke(roomID, code string, revokedAt time.Time) error
+	Redeem(code string, member *model.RoomMember, now time.Time) (*model.Invite, error)
+}
+
+// InviteRepo implements InviteRepository
+type InviteRepo struct {
+	db *gorm.DB
+}
+
+// NewInviteRepo creates a new invite repository
+func NewInviteRepo(db *gorm.DB) *InviteRepo {
+	return &InviteRepo{db: db}
+}
+
+// Create stores a new invite for an existing room
+func (r *InviteRepo) Create(invite *model.Invite) error {
+	invite.CreatedAt = time.Now()
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var rooms int64
+		if err := tx.Model(&model.Room{}).Where("id = ?", invite.RoomID).Count(&rooms).Error; err != nil {
+			return fmt.Errorf("failed to check room: %w", err)
+		}
+		if rooms == 0 {
+			return fmt.Errorf("%w: %s", model.ErrRoomNotFound, invite.RoomID)
+		}
+
+		if err := tx.Create(invite).Error; err != nil {
+			return fmt.Errorf("failed to create invite: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetByCode retrieves an invite by its code
+func (r *InviteRepo) GetByCode(code string) (*model.Invite, error) {
+	var invite model.Invite
+	if err := r.db.Where("code = ?", code).First(&invite).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %s", model.ErrInviteNotFound, code)
+		}
+		return nil, fmt.Errorf("failed to get invite: %w", err)
+	}
+	return &invite, nil
+}
+
+// ListByRoom returns the invites of a room, newest first, with their redemptions
+func (r *InviteRepo) ListByRoom(roomID string) ([]*model.Invite, error) {
+	var invites []*model.Invite
+	err := r.db.Preload("Redemptions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("used_at ASC")
+	}).Where("room_id = ?", roomID).Order("created_at DESC, code ASC").Find(&invites).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invites: %w", err)
+	}
+	return invites, nil
+}
+
+// Revoke marks an invite of a room as revoked; revoking twice keeps the first time
+func (r *InviteRepo) Revoke(roomID, code string, revokedAt time.Time) error {
+	var invite model.Invite
+	if err := r.db.Where("code = ? AND room_id = ?", code, roomID).First(&invite).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %s", model.ErrInviteNotFound, code)
+		}
+		return fmt.Errorf("failed to get invite: %w", err)
+	}
+	if invite.RevokedAt != nil {
+		return nil
+	}
+
+	err := r.db.Model(&model.Invite{}).Where("code = ?", code).Update("revoked_at", revokedAt).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke invite: %w", err)
+	}
+	return nil
+}
+
+// Redeem uses an invite to add member to the invite's room with the invite's role.
+// The use is counted, recorded and the member created in one transaction, so concurrent
+// redemptions can neither exceed the use limit nor the room's member limit.
+func (r *InviteRepo) Redeem(code string, member *model.RoomMember, now time.Time) (*model.Invite, error) {
+	var invite model.Invite
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("code = ?", code).First(&invite).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: %s", model.ErrInviteNotFound, code)
+			}
+			return fmt.Errorf("failed to get invite: %w", err)
+		}
+		if err := invite.Check(now); err != nil {
+			return err
+		}
+
+		var room model.Room
+		if err := tx.Where("id = ?", invite.RoomID).First(&room).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: %s", model.ErrRoomNotFound, invite.RoomID)
+			}
+			return fmt.Errorf("failed to get room: %w", err)
+		}
+		if err := invite.CheckRoom(&room); err != nil {
+			return fmt.Errorf("%w: %s", err, code)
+		}
+
+		var existing int64
+		if err := tx.Model(&model.RoomMember{}).Where("room_id = ? AND session_id = ?", room.ID, member.SessionID).Count(&existing).Error; err != nil {
+			return fmt.Errorf("failed to check membership: %w", err)
+		}
+		if existing > 0 {
+			return model.ErrAlreadyMember
+		}
+
+		var members int64
+		if err := tx.Model(&model.RoomMember{}).Where("room_id = ?", room.ID).Count(&members).Error; err != nil {
+			return fmt.Errorf("failed to count members: %w", err)
+		}
+		if int(members) >= room.MaxUsers {
+			return fmt.Errorf("%w: %d/%d", model.ErrRoomFull, members, room.MaxUsers)
+		}
+
+		// the use limit is checked again by the update in case another redemption got there first
+		result := tx.Model(&model.Invite{}).
+			Where("code = ? AND (max_uses = 0 OR uses < max_uses)", code).
+			UpdateColumn("uses", gorm.Expr("uses + 1"))
+		if result.Error != nil {
+			return fmt.Errorf("failed to count invite use: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return model.ErrInviteExhausted
+		}
+		invite.Uses++
+
+		if member.ID == "" {
+			member.ID = uuid.New().String()
+		}
+		member.RoomID = room.ID
+		member.Role = invite.Role
+		member.JoinedAt = now
+		member.LastSeen = now
+		if err := tx.Create(member).Error; err != nil {
+			return fmt.Errorf("failed to add member: %w", err)
+		}
+
+		use := &model.InviteUse{
+			ID:        uuid.New().String(),
+			Code:      code,
+			SessionID: member.SessionID,
+			Nickname:  member.Nickname,
+			UsedAt:    now,
+		}
+		if err := tx.Create(use).Error; err != nil {
+			return fmt.Errorf("failed to record invite use: %w", err)
+		}
+
+		// a room deactivated while idle comes back with its new member
+		err := tx.Model(&model.Room{}).Where("id = ?", room.ID).Updates(map[string]interface{}{
+			"status":         model.RoomStatusActive,
+			"last_active_at": now,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to reactivate room: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"xiaowo/backend/internal/model"
+)
+
+func TestInviteRepo_Redeem(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewInviteRepo(db)
+	now := time.Now()
+	past := now.Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		invite  model.Invite
+		setup   func(room *model.Room)
+		wantErr error
+	}{
+		{name: "Valid", invite: model.Invite{Role: model.RoleMember, MaxUses: 2}},
+		{name: "HostRole", invite: model.Invite{Role: model.RoleHost}},
+		{name: "Expired", invite: model.Invite{Role: model.RoleMember, ExpiresAt: &past}, wantErr: model.ErrInviteExpired},
+		{name: "Revoked", invite: model.Invite{Role: model.RoleMember, RevokedAt: &past}, wantErr: model.ErrInviteRevoked},
+		{name: "Exhausted", invite: model.Invite{Role: model.RoleMember, MaxUses: 1, Uses: 1}, wantErr: model.ErrInviteExhausted},
+		{
+			// deactivated by the janitor while idle, reactivated by the redemption
+			name:   "RoomInactive",
+			invite: model.Invite{Role: model.RoleMember},
+			setup: func(room *model.Room) {
+				db.Model(room).Update("status", model.RoomStatusInactive)
+			},
+		},
+		{
+			name:   "RoomDeleted",
+			invite: model.Invite{Role: model.RoleMember},
+			setup: func(room *model.Room) {
+				db.Model(room).Update("status", model.RoomStatusDeleted)
+			},
+			wantErr: model.ErrRoomNotFound,
+		},
+		{
+			// the invite was left over from an earlier room with the same ID
+			name:   "PredatesRoom",
+			invite: model.Invite{Role: model.RoleHost},
+			setup: func(room *model.Room) {
+				db.Model(&model.Invite{}).Where("room_id = ?", room.ID).Update("created_at", room.CreatedAt.Add(-time.Hour))
+			},
+			wantErr: model.ErrInviteNotFound,
+		},
+		{
+			name:   "RoomFull",
+			invite: model.Invite{Role: model.RoleMember},
+			setup: func(room *model.Room) {
+				db.Model(room).Update("max_users", 1)
+				db.Create(&model.RoomMember{ID: room.ID + "-host", RoomID: room.ID, SessionID: "host"})
+			},
+			wantErr: model.ErrRoomFull,
+		},
+		{
+			name:   "AlreadyMember",
+			invite: model.Invite{Role: model.RoleMember},
+			setup: func(room *model.Room) {
+				db.Create(&model.RoomMember{ID: room.ID + "-guest", RoomID: room.ID, SessionID: "guest"})
+			},
+			wantErr: model.ErrAlreadyMember,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := newTestRoom(t, db)
+			invite := tt.invite
+			invite.Code = "CODE" + room.ID
+			invite.RoomID = room.ID
+			if err := repo.Create(&invite); err != nil {
+				t.Fatalf("failed to create invite: %v", err)
+			}
+			if tt.setup != nil {
+				tt.setup(room)
+			}
+
+			member := &model.RoomMember{SessionID: "guest", Nickname: "guest"}
+			redeemed, err := repo.Redeem(invite.Code, member, now)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				if n := count(t, db, &model.RoomMember{}, room.ID); n > 1 {
+					t.Errorf("a rejected invite must not add a member, %d members", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Redeem failed: %v", err)
+			}
+			if redeemed.Uses != invite.Uses+1 || member.RoomID != room.ID || member.Role != invite.Role {
+				t.Errorf("unexpected redemption: invite %+v, member %+v", redeemed, member)
+			}
+			var uses []*model.InviteUse
+			db.Where("code = ?", invite.Code).Find(&uses)
+			if len(uses) != 1 || uses[0].SessionID != "guest" || uses[0].Nickname != "guest" {
+				t.Errorf("the use should be recorded, got %+v", uses)
+			}
+			var stored model.Room
+			db.First(&stored, "id = ?", room.ID)
+			if stored.Status != model.RoomStatusActive {
+				t.Errorf("the room should be active after redemption, got %s", stored.Status)
+			}
+		})
+	}
+}
+
+func TestInviteRepo_RedeemUseLimit(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewInviteRepo(db)
+	room := newTestRoom(t, db)
+	invite := &model.Invite{Code: "LIMITED", RoomID: room.ID, Role: model.RoleMember, MaxUses: 1}
+	if err := repo.Create(invite); err != nil {
+		t.Fatalf("failed to create invite: %v", err)
+	}
+
+	// another redemption counted the last use after this one loaded the invite
+	db.Callback().Update().Before("gorm:update").Register("test:race", func(tx *gorm.DB) {
+		if tx.Statement.Table == "room_invites" {
+			tx.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Exec("UPDATE room_invites SET uses = max_uses WHERE code = ?", invite.Code)
+		}
+	})
+	defer db.Callback().Update().Remove("test:race")
+
+	_, err := repo.Redeem(invite.Code, &model.RoomMember{SessionID: "late"}, time.Now())
+	if !errors.Is(err, model.ErrInviteExhausted) {
+		t.Fatalf("expected %v, got %v", model.ErrInviteExhausted, err)
+	}
+	if n := count(t, db, &model.RoomMember{}, room.ID); n != 0 {
+		t.Errorf("no member should be added past the use limit, got %d", n)
+	}
+}
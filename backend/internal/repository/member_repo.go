@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"xiaowo/backend/internal/model"
 
 	"gorm.io/gorm"
@@ -14,6 +16,8 @@ type RoomMemberRepository interface {
 	FindBySessionAndRoom(sessionID, roomID string) (*model.RoomMember, error)
 	Update(member *model.RoomMember) error
 	CountMembers(roomID string) (int64, error)
+	TouchMembers(roomID string, sessionIDs []string, seenAt time.Time) error
+	FindStale(seenBefore time.Time) ([]*model.RoomMember, error)
 }
 
 // RoomMemberRepo implements RoomMemberRepository
@@ -60,3 +64,21 @@ func (r *RoomMemberRepo) CountMembers(roomID string) (int64, error) {
 	}
 	return count, nil
 }
+
+func (r *RoomMemberRepo) TouchMembers(roomID string, sessionIDs []string, seenAt time.Time) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+	return r.db.Model(&model.RoomMember{}).
+		Where("room_id = ? AND session_id IN ?", roomID, sessionIDs).
+		Update("last_seen", seenAt).Error
+}
+
+// FindStale returns non-host members not seen since seenBefore
+func (r *RoomMemberRepo) FindStale(seenBefore time.Time) ([]*model.RoomMember, error) {
+	var members []*model.RoomMember
+	if err := r.db.Where("last_seen < ? AND role <> ?", seenBefore, model.RoleHost).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
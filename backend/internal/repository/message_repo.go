@@ -30,6 +30,7 @@ type MessageRepository interface {
 	// Utility operations
 	SearchMessages(roomID string, keyword string, filter map[string]interface{}, page, size int) ([]*model.Message, int64, error)
 	CleanupOldMessages(roomID string, daysOld int) (int64, error)
+	CleanupMessagesBefore(cutoff time.Time) (int64, error)
 	ValidateMessageType(messageType model.MessageType) error
 	GenerateMessageID() string
 }
@@ -365,6 +366,15 @@ func (r *messageRepository) CleanupOldMessages(roomID string, daysOld int) (int6
 	return result.RowsAffected, nil
 }
 
+// CleanupMessagesBefore deletes messages of all rooms created before cutoff
+func (r *messageRepository) CleanupMessagesBefore(cutoff time.Time) (int64, error) {
+	result := r.db.Where("created_at < ?", cutoff).Delete(&model.Message{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
 // ValidateMessageType validates the message type
 func (r *messageRepository) ValidateMessageType(messageType model.MessageType) error {
 	switch messageType {
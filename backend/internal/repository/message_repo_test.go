@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"xiaowo/backend/internal/model"
+)
+
+func TestMessageRepo_HistoryAndSearch(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewMessageRepository(db)
+	room := newTestRoom(t, db)
+
+	start := time.Now().Add(-time.Minute)
+	for i, content := range []string{"大家好，开始看电影", "第二条消息", "电影结束了"} {
+		message := &model.Message{
+			RoomID:      room.ID,
+			SessionID:   "s1",
+			MessageType: model.MessageTypeChat,
+			Content:     content,
+			CreatedAt:   start.Add(time.Duration(i) * time.Second),
+		}
+		if err := repo.Create(message); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	contents := func(messages []*model.Message) []string {
+		var out []string
+		for _, message := range messages {
+			out = append(out, message.Content)
+		}
+		return out
+	}
+
+	t.Run("TestPagedNewestFirstChronological", func(t *testing.T) {
+		tests := []struct {
+			page, size int
+			want       []string
+		}{
+			{1, 2, []string{"第二条消息", "电影结束了"}},
+			{2, 2, []string{"大家好，开始看电影"}},
+			{3, 2, nil},
+		}
+		for _, tt := range tests {
+			messages, total, err := repo.GetMessagesByRoom(room.ID, nil, tt.page, tt.size)
+			if err != nil {
+				t.Fatalf("GetMessagesByRoom failed: %v", err)
+			}
+			got := contents(messages)
+			if total != 3 || len(got) != len(tt.want) {
+				t.Fatalf("page %d: expected %v of 3, got %v of %d", tt.page, tt.want, got, total)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("page %d: expected %v, got %v", tt.page, tt.want, got)
+				}
+			}
+		}
+	})
+
+	t.Run("TestSearch", func(t *testing.T) {
+		messages, total, err := repo.SearchMessages(room.ID, "电影", nil, 1, 10)
+		if err != nil {
+			t.Fatalf("SearchMessages failed: %v", err)
+		}
+		if got := contents(messages); total != 2 || len(got) != 2 || got[0] != "大家好，开始看电影" {
+			t.Errorf("expected both matches in chronological order, got %v of %d", got, total)
+		}
+		if _, total, _ := repo.SearchMessages(room.ID, "不存在", nil, 1, 10); total != 0 {
+			t.Errorf("expected no matches, got %d", total)
+		}
+	})
+}
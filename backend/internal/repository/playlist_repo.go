@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"xiaowo/backend/internal/model"
+)
+
+// PlaylistRepository interface defines all playlist operations
+//
+// Every mutation bumps the room's playlist_version in the same transaction and
+// fails with model.ErrVersionConflict when the expected version is stale.
+type PlaylistRepository interface {
+	ListByRoom(roomID string) ([]*model.PlaylistItem, error)
+	GetByID(roomID, itemID string) (*model.PlaylistItem, error)
+	Add(item *model.PlaylistItem, expectedVersion int) error
+	Remove(roomID, itemID string, expectedVersion int) error
+	Move(roomID, itemID string, position int, expectedVersion int) error
+	SetCurrent(roomID string, item *model.PlaylistItem, playback map[string]interface{}, expectedVersion, expectedPlaybackVersion int) error
+}
+
+// PlaylistRepo implements PlaylistRepository
+type PlaylistRepo struct {
+	db *gorm.DB
+}
+
+// NewPlaylistRepo creates a new playlist repository
+func NewPlaylistRepo(db *gorm.DB) *PlaylistRepo {
+	return &PlaylistRepo{db: db}
+}
+
+// ListByRoom returns the playlist items of a room in order
+func (r *PlaylistRepo) ListByRoom(roomID string) ([]*model.PlaylistItem, error) {
+	var items []*model.PlaylistItem
+	if err := r.db.Where("room_id = ?", roomID).Order("position ASC").Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("failed to list playlist: %w", err)
+	}
+	return items, nil
+}
+
+// GetByID retrieves a playlist item of a room
+func (r *PlaylistRepo) GetByID(roomID, itemID string) (*model.PlaylistItem, error) {
+	var item model.PlaylistItem
+	if err := r.db.Where("id = ? AND room_id = ?", itemID, roomID).First(&item).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %s", model.ErrPlaylistItemNotFound, itemID)
+		}
+		return nil, fmt.Errorf("failed to get playlist item: %w", err)
+	}
+	return &item, nil
+}
+
+// Add appends an item to the end of the room's playlist
+func (r *PlaylistRepo) Add(item *model.PlaylistItem, expectedVersion int) error {
+	if item.ID == "" {
+		item.ID = uuid.New().String()
+	}
+	if item.MediaType == "" {
+		item.MediaType = "video"
+	}
+	item.CreatedAt = time.Now()
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := bumpPlaylistVersion(tx, item.RoomID, expectedVersion); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&model.PlaylistItem{}).Where("room_id = ?", item.RoomID).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count playlist items: %w", err)
+		}
+		if count >= model.MaxPlaylistItems {
+			return model.ErrPlaylistFull
+		}
+
+		item.Position = int(count)
+		if err := tx.Create(item).Error; err != nil {
+			return fmt.Errorf("failed to add playlist item: %w", err)
+		}
+		return nil
+	})
+}
+
+// Remove deletes an item and closes the gap it leaves; removing the current
+// item clears the room's current_item_id but keeps its media loaded
+func (r *PlaylistRepo) Remove(roomID, itemID string, expectedVersion int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := bumpPlaylistVersion(tx, roomID, expectedVersion); err != nil {
+			return err
+		}
+
+		var item model.PlaylistItem
+		if err := tx.Where("id = ? AND room_id = ?", itemID, roomID).First(&item).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: %s", model.ErrPlaylistItemNotFound, itemID)
+			}
+			return fmt.Errorf("failed to get playlist item: %w", err)
+		}
+
+		if err := tx.Delete(&item).Error; err != nil {
+			return fmt.Errorf("failed to remove playlist item: %w", err)
+		}
+		if err := tx.Model(&model.PlaylistItem{}).
+			Where("room_id = ? AND position > ?", roomID, item.Position).
+			UpdateColumn("position", gorm.Expr("position - 1")).Error; err != nil {
+			return fmt.Errorf("failed to reorder playlist: %w", err)
+		}
+		return tx.Model(&model.Room{}).
+			Where("id = ? AND current_item_id = ?", roomID, itemID).
+			UpdateColumn("current_item_id", "").Error
+	})
+}
+
+// Move places an item at position (clamped to the playlist bounds), shifting
+// the items in between
+func (r *PlaylistRepo) Move(roomID, itemID string, position int, expectedVersion int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := bumpPlaylistVersion(tx, roomID, expectedVersion); err != nil {
+			return err
+		}
+
+		var item model.PlaylistItem
+		if err := tx.Where("id = ? AND room_id = ?", itemID, roomID).First(&item).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: %s", model.ErrPlaylistItemNotFound, itemID)
+			}
+			return fmt.Errorf("failed to get playlist item: %w", err)
+		}
+
+		var count int64
+		if err := tx.Model(&model.PlaylistItem{}).Where("room_id = ?", roomID).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count playlist items: %w", err)
+		}
+		if position < 0 {
+			position = 0
+		}
+		if position >= int(count) {
+			position = int(count) - 1
+		}
+		if position == item.Position {
+			return nil
+		}
+
+		shift := tx.Model(&model.PlaylistItem{}).Where("room_id = ?", roomID)
+		if position < item.Position {
+			shift = shift.Where("position >= ? AND position < ?", position, item.Position).
+				UpdateColumn("position", gorm.Expr("position + 1"))
+		} else {
+			shift = shift.Where("position > ? AND position <= ?", item.Position, position).
+				UpdateColumn("position", gorm.Expr("position - 1"))
+		}
+		if shift.Error != nil {
+			return fmt.Errorf("failed to reorder playlist: %w", shift.Error)
+		}
+		return tx.Model(&item).UpdateColumn("position", position).Error
+	})
+}
+
+// SetCurrent loads item into the room, copying its media fields and applying
+// the playback updates, checking both the playlist and playback versions
+func (r *PlaylistRepo) SetCurrent(roomID string, item *model.PlaylistItem, playback map[string]interface{}, expectedVersion, expectedPlaybackVersion int) error {
+	updates := map[string]interface{}{
+		"current_item_id":  item.ID,
+		"media_url":        item.MediaURL,
+		"media_type":       item.MediaType,
+		"media_title":      item.MediaTitle,
+		"media_duration":   item.MediaDuration,
+		"media_info":       item.MediaInfo,
+		"playlist_version": gorm.Expr("playlist_version + 1"),
+		"version":          gorm.Expr("version + 1"),
+		"updated_at":       time.Now(),
+		"last_active_at":   time.Now(),
+	}
+	for key, value := range playback {
+		updates[key] = value
+	}
+
+	result := r.db.Model(&model.Room{}).
+		Where("id = ? AND playlist_version = ? AND version = ?", roomID, expectedVersion, expectedPlaybackVersion).
+		Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to set current playlist item: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w", model.ErrVersionConflict)
+	}
+	return nil
+}
+
+// bumpPlaylistVersion increments the room's playlist version inside tx
+func bumpPlaylistVersion(tx *gorm.DB, roomID string, expectedVersion int) error {
+	result := tx.Model(&model.Room{}).
+		Where("id = ? AND playlist_version = ?", roomID, expectedVersion).
+		Updates(map[string]interface{}{
+			"playlist_version": gorm.Expr("playlist_version + 1"),
+			"last_active_at":   time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update playlist version: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w", model.ErrVersionConflict)
+	}
+	return nil
+}
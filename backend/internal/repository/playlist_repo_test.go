@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"xiaowo/backend/internal/model"
+)
+
+// playlistURLs returns the media URLs of the room's playlist in order
+func playlistURLs(t *testing.T, repo *PlaylistRepo, roomID string) []string {
+	t.Helper()
+
+	items, err := repo.ListByRoom(roomID)
+	if err != nil {
+		t.Fatalf("ListByRoom failed: %v", err)
+	}
+	urls := make([]string, 0, len(items))
+	for _, item := range items {
+		urls = append(urls, item.MediaURL)
+	}
+	return urls
+}
+
+func TestPlaylistRepo_Mutations(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewPlaylistRepo(db)
+	room := newTestRoom(t, db)
+	first := room.MediaURL
+	const second, third = "https://example.com/ep2.mp4", "https://example.com/ep3.mp4"
+
+	add := func(url string, version int) (*model.PlaylistItem, error) {
+		item := &model.PlaylistItem{RoomID: room.ID, MediaURL: url}
+		return item, repo.Add(item, version)
+	}
+
+	if _, err := add(second, 0); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	last, err := add(third, 1)
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, err := add(third, 1); !errors.Is(err, model.ErrVersionConflict) {
+		t.Errorf("expected ErrVersionConflict for a stale version, got %v", err)
+	}
+	if last.Position != 2 || last.MediaType != "video" {
+		t.Errorf("expected the item appended as video at position 2, got %+v", last)
+	}
+
+	tests := []struct {
+		name     string
+		position int
+		want     []string
+	}{
+		{"ToFront", 0, []string{third, first, second}},
+		{"ClampedToEnd", 10, []string{first, second, third}},
+		{"Middle", 1, []string{first, third, second}},
+	}
+	version := 2
+	for _, tt := range tests {
+		t.Run("TestMove"+tt.name, func(t *testing.T) {
+			if err := repo.Move(room.ID, last.ID, tt.position, version); err != nil {
+				t.Fatalf("Move failed: %v", err)
+			}
+			version++
+			got := playlistURLs(t, repo, room.ID)
+			for i := range tt.want {
+				if i >= len(got) || got[i] != tt.want[i] {
+					t.Fatalf("expected order %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+
+	t.Run("TestRemoveCurrent", func(t *testing.T) {
+		stored, _ := NewRoomRepo(db).GetByID(room.ID)
+		if err := repo.Remove(room.ID, stored.CurrentItemID, version); err != nil {
+			t.Fatalf("Remove failed: %v", err)
+		}
+		if got := playlistURLs(t, repo, room.ID); len(got) != 2 || got[0] != third || got[1] != second {
+			t.Errorf("expected the gap to be closed, got %v", got)
+		}
+		stored, _ = NewRoomRepo(db).GetByID(room.ID)
+		if stored.CurrentItemID != "" || stored.MediaURL != first {
+			t.Errorf("expected the current item cleared and the media kept, got %q %q", stored.CurrentItemID, stored.MediaURL)
+		}
+		if err := repo.Remove(room.ID, "missing", version+1); !errors.Is(err, model.ErrPlaylistItemNotFound) {
+			t.Errorf("expected ErrPlaylistItemNotFound, got %v", err)
+		}
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 
 	"xiaowo/backend/internal/model"
@@ -33,6 +34,9 @@ type RoomRepository interface {
 	ValidateMediaURL(url string) error
 	ValidatePlaybackState(state map[string]interface{}) error
 	CleanupInactiveRooms() (int64, error)
+	TouchRooms(roomIDs []string) error
+	DeactivateIdleRooms(idleBefore time.Time, exclude []string) ([]string, error)
+	PurgeInactiveRooms(inactiveBefore time.Time, exclude []string) ([]string, error)
 }
 
 // RoomRepo implements RoomRepository
@@ -80,7 +84,30 @@ func (r *RoomRepo) Create(room *model.Room) error {
 	room.LastActiveAt = time.Now()
 	room.Version = 0
 
-	if err := r.db.Create(room).Error; err != nil {
+	// The room's media becomes the first playlist item
+	item := &model.PlaylistItem{
+		ID:            uuid.New().String(),
+		RoomID:        room.ID,
+		MediaURL:      room.MediaURL,
+		MediaType:     room.MediaType,
+		MediaTitle:    room.MediaTitle,
+		MediaDuration: room.MediaDuration,
+		MediaInfo:     room.MediaInfo,
+		AddedBy:       room.CreatorSessionID,
+		CreatedAt:     room.CreatedAt,
+	}
+	if item.MediaType == "" {
+		item.MediaType = "video"
+	}
+	room.CurrentItemID = item.ID
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(room).Error; err != nil {
+			return err
+		}
+		return tx.Create(item).Error
+	})
+	if err != nil {
 		return fmt.Errorf("failed to create room: %w", err)
 	}
 
@@ -370,17 +397,12 @@ func (r *RoomRepo) LeaveRoom(roomID, sessionID string) error {
 		return fmt.Errorf("failed to get room: %w", err)
 	}
 
-	// Update member as inactive
-	result := tx.Model(&model.RoomMember{}).
-		Where("room_id = ? AND session_id = ? AND is_active = ?", roomID, sessionID, true).
-		Updates(map[string]interface{}{
-			"is_active": false,
-			"left_at":   time.Now(),
-		})
+	// Remove member
+	result := tx.Where("room_id = ? AND session_id = ?", roomID, sessionID).Delete(&model.RoomMember{})
 
 	if result.Error != nil {
 		tx.Rollback()
-		return fmt.Errorf("failed to update room member: %w", result.Error)
+		return fmt.Errorf("failed to remove room member: %w", result.Error)
 	}
 
 	if result.RowsAffected == 0 {
@@ -388,8 +410,8 @@ func (r *RoomRepo) LeaveRoom(roomID, sessionID string) error {
 		return fmt.Errorf("session not found in room: %s", sessionID)
 	}
 
-	// Check if room should be set as inactive
-	activeMemberCount, err := r.GetMemberCountWithDB(tx, roomID)
+	// Record when the last member left; the janitor deactivates the room after its grace period
+	memberCount, err := r.GetMemberCountWithDB(tx, roomID)
 	if err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to get member count: %w", err)
@@ -399,9 +421,8 @@ func (r *RoomRepo) LeaveRoom(roomID, sessionID string) error {
 		"updated_at": time.Now(),
 	}
 
-	if activeMemberCount == 0 {
+	if memberCount == 0 {
 		room.SetLastMemberLeft()
-		updates["status"] = model.RoomStatusInactive
 		updates["last_member_left_at"] = room.LastMemberLeftAt
 	}
 
@@ -587,15 +608,105 @@ func (r *RoomRepo) ValidatePlaybackState(state map[string]interface{}) error {
 // CleanupInactiveRooms removes inactive rooms older than specified duration
 func (r *RoomRepo) CleanupInactiveRooms() (int64, error) {
 	// Delete rooms that have been inactive for more than 7 days
-	cutoff := time.Now().Add(-7 * 24 * time.Hour)
+	purged, err := r.PurgeInactiveRooms(time.Now().Add(-7*24*time.Hour), nil)
+	return int64(len(purged)), err
+}
 
-	result := r.db.Where("status = ? AND last_active_at < ?", model.RoomStatusInactive, cutoff).Delete(&model.Room{})
-	
-	if result.Error != nil {
-		return 0, fmt.Errorf("failed to cleanup inactive rooms: %w", result.Error)
+// TouchRooms marks rooms with live connections as active; closed rooms stay closed
+func (r *RoomRepo) TouchRooms(roomIDs []string) error {
+	if len(roomIDs) == 0 {
+		return nil
+	}
+
+	err := r.db.Model(&model.Room{}).Where("id IN ? AND status != ?", roomIDs, model.RoomStatusDeleted).Updates(map[string]interface{}{
+		"status":         model.RoomStatusActive,
+		"last_active_at": time.Now(),
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to touch rooms: %w", err)
+	}
+	return nil
+}
+
+// DeactivateIdleRooms marks active rooms with no activity and no member departures
+// since idleBefore as inactive, returning their IDs
+func (r *RoomRepo) DeactivateIdleRooms(idleBefore time.Time, exclude []string) ([]string, error) {
+	var roomIDs []string
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&model.Room{}).
+			Where("status = ? AND last_active_at < ?", model.RoomStatusActive, idleBefore).
+			Where("last_member_left_at IS NULL OR last_member_left_at < ?", idleBefore)
+		if len(exclude) > 0 {
+			query = query.Where("id NOT IN ?", exclude)
+		}
+		if err := query.Pluck("id", &roomIDs).Error; err != nil {
+			return err
+		}
+		if len(roomIDs) == 0 {
+			return nil
+		}
+
+		return tx.Model(&model.Room{}).Where("id IN ?", roomIDs).Updates(map[string]interface{}{
+			"status":     model.RoomStatusInactive,
+			"updated_at": time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to deactivate idle rooms: %w", err)
+	}
+
+	return roomIDs, nil
+}
+
+// PurgeInactiveRooms deletes rooms that have been inactive or closed since inactiveBefore
+// together with their members, messages, playlists, subtitles, danmaku and invites, returning their IDs
+func (r *RoomRepo) PurgeInactiveRooms(inactiveBefore time.Time, exclude []string) ([]string, error) {
+	var roomIDs []string
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&model.Room{}).
+			Where("status IN ? AND last_active_at < ?", []model.RoomStatus{model.RoomStatusInactive, model.RoomStatusDeleted}, inactiveBefore)
+		if len(exclude) > 0 {
+			query = query.Where("id NOT IN ?", exclude)
+		}
+		if err := query.Pluck("id", &roomIDs).Error; err != nil {
+			return err
+		}
+		if len(roomIDs) == 0 {
+			return nil
+		}
+
+		if err := tx.Where("room_id IN ?", roomIDs).Delete(&model.RoomMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("room_id IN ?", roomIDs).Delete(&model.Message{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("room_id IN ?", roomIDs).Delete(&model.PlaylistItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("room_id IN ?", roomIDs).Delete(&model.Subtitle{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("room_id IN ?", roomIDs).Delete(&model.Danmaku{}).Error; err != nil {
+			return err
+		}
+		// invites must not outlive their room: a new room may reuse the ID
+		codes := tx.Model(&model.Invite{}).Select("code").Where("room_id IN ?", roomIDs)
+		if err := tx.Where("code IN (?)", codes).Delete(&model.InviteUse{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("room_id IN ?", roomIDs).Delete(&model.Invite{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", roomIDs).Delete(&model.Room{}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to purge inactive rooms: %w", err)
 	}
 
-	return result.RowsAffected, nil
+	return roomIDs, nil
 }
 
 // Helper methods
@@ -630,7 +741,7 @@ func (r *RoomRepo) validateRoomCreate(room *model.Room) error {
 func (r *RoomRepo) GetMemberCountWithDB(db *gorm.DB, roomID string) (int, error) {
 	var count int64
 	err := db.Model(&model.RoomMember{}).
-		Where("room_id = ? AND is_active = ?", roomID, true).
+		Where("room_id = ?", roomID).
 		Count(&count).Error
 
 	return int(count), err
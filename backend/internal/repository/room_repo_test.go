@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"xiaowo/backend/internal/model"
+)
+
+// newTestDB opens a migrated in-memory database private to the test
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := MigrateDatabase(db); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	t.Cleanup(func() { Close(db) })
+	return db
+}
+
+// newTestRoom creates an active room
+func newTestRoom(t *testing.T, db *gorm.DB) *model.Room {
+	t.Helper()
+
+	room := &model.Room{Name: "test room", CreatorSessionID: "host", MediaURL: "https://example.com/video.mp4", MaxUsers: 5}
+	if err := NewRoomRepo(db).Create(room); err != nil {
+		t.Fatalf("failed to create room: %v", err)
+	}
+	return room
+}
+
+// count returns the number of rows of value's table belonging to roomID
+func count(t *testing.T, db *gorm.DB, value interface{}, roomID string) int64 {
+	t.Helper()
+
+	var n int64
+	if err := db.Model(value).Where("room_id = ?", roomID).Count(&n).Error; err != nil {
+		t.Fatalf("failed to count rows: %v", err)
+	}
+	return n
+}
+
+func TestRoomRepo_PurgeInactiveRooms(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewRoomRepo(db)
+	stale := newTestRoom(t, db)
+	closed := newTestRoom(t, db)
+	live := newTestRoom(t, db)
+
+	for _, room := range []*model.Room{stale, closed, live} {
+		rows := []interface{}{
+			&model.RoomMember{ID: room.ID + "-m", RoomID: room.ID, SessionID: "s1"},
+			&model.Danmaku{ID: room.ID + "-d", RoomID: room.ID, MediaURL: room.MediaURL, Content: "hi", SessionID: "s1"},
+			&model.Invite{Code: room.ID + "-i", RoomID: room.ID, Role: model.RoleHost},
+			&model.InviteUse{ID: room.ID + "-u", Code: room.ID + "-i", SessionID: "s1"},
+		}
+		for _, row := range rows {
+			if err := db.Create(row).Error; err != nil {
+				t.Fatalf("failed to create row: %v", err)
+			}
+		}
+	}
+	db.Model(&model.Room{}).Where("id = ?", stale.ID).
+		Updates(map[string]interface{}{"status": model.RoomStatusInactive, "last_active_at": time.Now().Add(-48 * time.Hour)})
+	db.Model(&model.Room{}).Where("id = ?", closed.ID).
+		Updates(map[string]interface{}{"status": model.RoomStatusDeleted, "last_active_at": time.Now().Add(-48 * time.Hour)})
+
+	purged, err := repo.PurgeInactiveRooms(time.Now().Add(-24*time.Hour), nil)
+	if err != nil {
+		t.Fatalf("PurgeInactiveRooms failed: %v", err)
+	}
+	want := []string{stale.ID, closed.ID}
+	sort.Strings(want)
+	sort.Strings(purged)
+	if len(purged) != 2 || purged[0] != want[0] || purged[1] != want[1] {
+		t.Fatalf("expected %v to be purged, got %v", want, purged)
+	}
+
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{"members", &model.RoomMember{}},
+		{"danmaku", &model.Danmaku{}},
+		{"invites", &model.Invite{}},
+	}
+	for _, tt := range tests {
+		for _, roomID := range []string{stale.ID, closed.ID} {
+			if n := count(t, db, tt.value, roomID); n != 0 {
+				t.Errorf("%s of the purged room %s should be deleted, %d left", tt.name, roomID, n)
+			}
+		}
+		if n := count(t, db, tt.value, live.ID); n != 1 {
+			t.Errorf("%s of the active room should be kept, got %d", tt.name, n)
+		}
+	}
+
+	var uses []string
+	db.Model(&model.InviteUse{}).Order("id").Pluck("id", &uses)
+	if len(uses) != 1 || uses[0] != live.ID+"-u" {
+		t.Errorf("only invite uses of the active room should be kept, got %v", uses)
+	}
+}
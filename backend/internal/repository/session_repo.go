@@ -15,9 +15,11 @@ import (
 type SessionRepository interface {
 	Create(nickname string) (*model.UserSession, error)
 	GetByID(sessionID string) (*model.UserSession, error)
+	GetByIDs(sessionIDs []string) ([]*model.UserSession, error)
 	Update(sessionID string, updates map[string]interface{}) (*model.UserSession, error)
 	UpdateLastSeen(sessionID string) error
 	UpdateStatus(sessionID, status string) error
+	UpdatePresence(sessionIDs []string, status string, seenAt time.Time) error
 	JoinRoom(sessionID, roomID string) error
 	LeaveRoom(sessionID string) error
 	GetActiveSessions() ([]*model.UserSession, error)
@@ -86,6 +88,20 @@ func (r *SessionRepo) GetByID(sessionID string) (*model.UserSession, error) {
 	return &session, nil
 }
 
+// GetByIDs retrieves the sessions with the given IDs, skipping unknown ones
+func (r *SessionRepo) GetByIDs(sessionIDs []string) ([]*model.UserSession, error) {
+	var sessions []*model.UserSession
+	if len(sessionIDs) == 0 {
+		return sessions, nil
+	}
+
+	if err := r.db.Where("id IN ?", sessionIDs).Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
 // Update updates session information
 func (r *SessionRepo) Update(sessionID string, updates map[string]interface{}) (*model.UserSession, error) {
 	var session model.UserSession
@@ -161,8 +177,8 @@ func (r *SessionRepo) UpdateLastSeen(sessionID string) error {
 // UpdateStatus updates the session status
 func (r *SessionRepo) UpdateStatus(sessionID string, status string) error {
 	// Validate status
-	if status != string(model.StatusOnline) && status != string(model.StatusOffline) {
-		return fmt.Errorf("invalid status: %v (must be 'online' or 'offline')", status)
+	if !validStatus(status) {
+		return fmt.Errorf("invalid status: %v (must be 'online', 'idle' or 'offline')", status)
 	}
 
 	// Start transaction for atomic update
@@ -206,6 +222,30 @@ func (r *SessionRepo) UpdateStatus(sessionID string, status string) error {
 	return nil
 }
 
+// UpdatePresence updates last_seen_at of several sessions in one statement,
+// and their status too unless status is empty
+func (r *SessionRepo) UpdatePresence(sessionIDs []string, status string, seenAt time.Time) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+
+	updates := map[string]interface{}{
+		"last_seen_at": seenAt,
+	}
+	if status != "" {
+		if !validStatus(status) {
+			return fmt.Errorf("invalid status: %v (must be 'online', 'idle' or 'offline')", status)
+		}
+		updates["status"] = status
+	}
+
+	if err := r.db.Model(&model.UserSession{}).Where("id IN ?", sessionIDs).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update presence: %w", err)
+	}
+
+	return nil
+}
+
 // JoinRoom adds a session to a room
 func (r *SessionRepo) JoinRoom(sessionID, roomID string) error {
 	var session model.UserSession
@@ -317,8 +357,8 @@ func (r *SessionRepo) GetByStatus(status string) ([]*model.UserSession, error) {
 	var sessions []*model.UserSession
 	
 	// Validate status
-	if status != string(model.StatusOnline) && status != string(model.StatusOffline) {
-		return nil, fmt.Errorf("invalid status: %s (must be 'online' or 'offline')", status)
+	if !validStatus(status) {
+		return nil, fmt.Errorf("invalid status: %s (must be 'online', 'idle' or 'offline')", status)
 	}
 	
 	err := r.db.Where("status = ?", status).Find(&sessions).Error
@@ -380,6 +420,15 @@ func (r *SessionRepo) CleanupExpired() (int64, error) {
 	return result.RowsAffected, nil
 }
 
+// validStatus checks if status is a known session status
+func validStatus(status string) bool {
+	switch model.UserSessionStatus(status) {
+	case model.StatusOnline, model.StatusIdle, model.StatusOffline:
+		return true
+	}
+	return false
+}
+
 // GenerateNickname generates a random fun nickname
 func (r *SessionRepo) GenerateNickname() string {
 	adjectives := []string{
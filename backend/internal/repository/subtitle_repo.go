@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"xiaowo/backend/internal/model"
+)
+
+// SubtitleRepository interface defines all subtitle track operations
+type SubtitleRepository interface {
+	ListByRoom(roomID string) ([]*model.Subtitle, error)
+	GetByID(roomID, subtitleID string) (*model.Subtitle, error)
+	Add(subtitle *model.Subtitle) error
+	UpdateOffset(roomID, subtitleID string, offset float64) error
+	Remove(roomID, subtitleID string) error
+	Select(roomID, subtitleID string) error
+}
+
+// SubtitleRepo implements SubtitleRepository
+type SubtitleRepo struct {
+	db *gorm.DB
+}
+
+// NewSubtitleRepo creates a new subtitle repository
+func NewSubtitleRepo(db *gorm.DB) *SubtitleRepo {
+	return &SubtitleRepo{db: db}
+}
+
+// ListByRoom returns the subtitle tracks of a room in the order they were added, without content
+func (r *SubtitleRepo) ListByRoom(roomID string) ([]*model.Subtitle, error) {
+	var subtitles []*model.Subtitle
+	err := r.db.Omit("content").Where("room_id = ?", roomID).Order("created_at ASC, id ASC").Find(&subtitles).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subtitles: %w", err)
+	}
+	return subtitles, nil
+}
+
+// GetByID retrieves a subtitle track of a room including its content
+func (r *SubtitleRepo) GetByID(roomID, subtitleID string) (*model.Subtitle, error) {
+	var subtitle model.Subtitle
+	if err := r.db.Where("id = ? AND room_id = ?", subtitleID, roomID).First(&subtitle).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %s", model.ErrSubtitleNotFound, subtitleID)
+		}
+		return nil, fmt.Errorf("failed to get subtitle: %w", err)
+	}
+	return &subtitle, nil
+}
+
+// Add attaches a subtitle track to a room, failing with model.ErrSubtitleLimit when the room is full
+func (r *SubtitleRepo) Add(subtitle *model.Subtitle) error {
+	if subtitle.ID == "" {
+		subtitle.ID = uuid.New().String()
+	}
+	subtitle.CreatedAt = time.Now()
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var rooms int64
+		if err := tx.Model(&model.Room{}).Where("id = ?", subtitle.RoomID).Count(&rooms).Error; err != nil {
+			return fmt.Errorf("failed to check room: %w", err)
+		}
+		if rooms == 0 {
+			return fmt.Errorf("%w: %s", model.ErrRoomNotFound, subtitle.RoomID)
+		}
+
+		var count int64
+		if err := tx.Model(&model.Subtitle{}).Where("room_id = ?", subtitle.RoomID).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count subtitles: %w", err)
+		}
+		if count >= model.MaxSubtitleTracks {
+			return model.ErrSubtitleLimit
+		}
+
+		if err := tx.Create(subtitle).Error; err != nil {
+			return fmt.Errorf("failed to add subtitle: %w", err)
+		}
+		return nil
+	})
+}
+
+// UpdateOffset changes the time offset of a subtitle track
+func (r *SubtitleRepo) UpdateOffset(roomID, subtitleID string, offset float64) error {
+	result := r.db.Model(&model.Subtitle{}).Where("id = ? AND room_id = ?", subtitleID, roomID).Update("offset", offset)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update subtitle offset: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s", model.ErrSubtitleNotFound, subtitleID)
+	}
+	return nil
+}
+
+// Remove deletes a subtitle track, turning subtitles off if it was the selected one
+func (r *SubtitleRepo) Remove(roomID, subtitleID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND room_id = ?", subtitleID, roomID).Delete(&model.Subtitle{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to remove subtitle: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: %s", model.ErrSubtitleNotFound, subtitleID)
+		}
+
+		err := tx.Model(&model.Room{}).Where("id = ? AND subtitle_id = ?", roomID, subtitleID).Update("subtitle_id", "").Error
+		if err != nil {
+			return fmt.Errorf("failed to clear selected subtitle: %w", err)
+		}
+		return nil
+	})
+}
+
+// Select sets the subtitle track shown to the room; an empty ID turns subtitles off
+func (r *SubtitleRepo) Select(roomID, subtitleID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if subtitleID != "" {
+			var count int64
+			if err := tx.Model(&model.Subtitle{}).Where("id = ? AND room_id = ?", subtitleID, roomID).Count(&count).Error; err != nil {
+				return fmt.Errorf("failed to check subtitle: %w", err)
+			}
+			if count == 0 {
+				return fmt.Errorf("%w: %s", model.ErrSubtitleNotFound, subtitleID)
+			}
+		}
+
+		result := tx.Model(&model.Room{}).Where("id = ?", roomID).Update("subtitle_id", subtitleID)
+		if result.Error != nil {
+			return fmt.Errorf("failed to select subtitle: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: %s", model.ErrRoomNotFound, roomID)
+		}
+		return nil
+	})
+}
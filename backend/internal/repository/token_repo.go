@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"xiaowo/backend/internal/model"
+)
+
+// TokenRepository interface defines the room access token revocation records
+type TokenRepository interface {
+	RevokeToken(id string, expiresAt time.Time) error
+	IsTokenRevoked(id string) (bool, error)
+	Generation(roomID, sessionID string) (int64, error)
+	IssueGeneration(roomID, sessionID string, now time.Time) (int64, error)
+	NextGeneration(roomID, sessionID string, now time.Time) error
+	Prune(now, generationsBefore time.Time) (int64, error)
+}
+
+// TokenRepo implements TokenRepository, shared by all instances using the same database
+type TokenRepo struct {
+	db *gorm.DB
+}
+
+// NewTokenRepo creates a new token repository
+func NewTokenRepo(db *gorm.DB) *TokenRepo {
+	return &TokenRepo{db: db}
+}
+
+// RevokeToken records a revoked token until it expires; revoking twice is a no-op
+func (r *TokenRepo) RevokeToken(id string, expiresAt time.Time) error {
+	err := r.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.RevokedToken{ID: id, ExpiresAt: expiresAt}).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// IsTokenRevoked reports whether a token has been revoked
+func (r *TokenRepo) IsTokenRevoked(id string) (bool, error) {
+	var n int64
+	if err := r.db.Model(&model.RevokedToken{}).Where("id = ?", id).Count(&n).Error; err != nil {
+		return false, fmt.Errorf("failed to check revoked token: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Generation returns the current token generation of a session in a room, 0 if never revoked
+func (r *TokenRepo) Generation(roomID, sessionID string) (int64, error) {
+	var generation model.TokenGeneration
+	err := r.db.Where("room_id = ? AND session_id = ?", roomID, sessionID).First(&generation).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get token generation: %w", err)
+	}
+	return generation.Generation, nil
+}
+
+// IssueGeneration returns the generation a new token is issued with and marks the
+// generation row as updated at now, so it outlives every token issued with it
+func (r *TokenRepo) IssueGeneration(roomID, sessionID string, now time.Time) (int64, error) {
+	var generation int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.TokenGeneration{}).
+			Where("room_id = ? AND session_id = ?", roomID, sessionID).
+			Update("updated_at", now).Error
+		if err != nil {
+			return fmt.Errorf("failed to touch token generation: %w", err)
+		}
+		generation, err = NewTokenRepo(tx).Generation(roomID, sessionID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return generation, nil
+}
+
+// NextGeneration increments the token generation of a session in a room
+func (r *TokenRepo) NextGeneration(roomID, sessionID string, now time.Time) error {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "room_id"}, {Name: "session_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"generation": gorm.Expr("token_generations.generation + 1"),
+			"updated_at": now,
+		}),
+	}).Create(&model.TokenGeneration{RoomID: roomID, SessionID: sessionID, Generation: 1, UpdatedAt: now}).Error
+	if err != nil {
+		return fmt.Errorf("failed to increment token generation: %w", err)
+	}
+	return nil
+}
+
+// Prune deletes the records of tokens expired before now and the generations last
+// incremented or issued before generationsBefore, returning the number of deleted rows
+func (r *TokenRepo) Prune(now, generationsBefore time.Time) (int64, error) {
+	var pruned int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("expires_at < ?", now).Delete(&model.RevokedToken{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to prune revoked tokens: %w", result.Error)
+		}
+		pruned += result.RowsAffected
+
+		result = tx.Where("updated_at < ?", generationsBefore).Delete(&model.TokenGeneration{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to prune token generations: %w", result.Error)
+		}
+		pruned += result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return pruned, nil
+}
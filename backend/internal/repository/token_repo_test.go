@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenRepo_Revocations(t *testing.T) {
+	repo := NewTokenRepo(newTestDB(t))
+	now := time.Now()
+
+	t.Run("TestRevokeToken", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if err := repo.RevokeToken("jti-1", now.Add(time.Hour)); err != nil {
+				t.Fatalf("revoke %d failed: %v", i, err)
+			}
+		}
+		tests := []struct {
+			id      string
+			revoked bool
+		}{
+			{"jti-1", true},
+			{"jti-2", false},
+		}
+		for _, tt := range tests {
+			if revoked, err := repo.IsTokenRevoked(tt.id); err != nil || revoked != tt.revoked {
+				t.Errorf("%s: expected revoked %v, got %v (%v)", tt.id, tt.revoked, revoked, err)
+			}
+		}
+	})
+
+	t.Run("TestNextGeneration", func(t *testing.T) {
+		if generation, err := repo.Generation("ROOM01", "s1"); err != nil || generation != 0 {
+			t.Fatalf("expected generation 0 before any revocation, got %d (%v)", generation, err)
+		}
+		for want := int64(1); want <= 2; want++ {
+			if err := repo.NextGeneration("ROOM01", "s1", now); err != nil {
+				t.Fatalf("failed to increment generation: %v", err)
+			}
+			if generation, _ := repo.Generation("ROOM01", "s1"); generation != want {
+				t.Errorf("expected generation %d, got %d", want, generation)
+			}
+		}
+		if generation, _ := repo.Generation("ROOM02", "s1"); generation != 0 {
+			t.Errorf("generations of other rooms must not change, got %d", generation)
+		}
+	})
+
+	t.Run("TestPrune", func(t *testing.T) {
+		if err := repo.RevokeToken("jti-expired", now.Add(-time.Minute)); err != nil {
+			t.Fatalf("revoke failed: %v", err)
+		}
+		if err := repo.NextGeneration("ROOM03", "s1", now.Add(-2*time.Hour)); err != nil {
+			t.Fatalf("failed to increment generation: %v", err)
+		}
+
+		pruned, err := repo.Prune(now, now.Add(-time.Hour))
+		if err != nil || pruned != 2 {
+			t.Fatalf("expected 2 pruned records, got %d (%v)", pruned, err)
+		}
+		if revoked, _ := repo.IsTokenRevoked("jti-1"); !revoked {
+			t.Error("unexpired revocations must be kept")
+		}
+		if generation, _ := repo.Generation("ROOM01", "s1"); generation != 2 {
+			t.Errorf("recent generations must be kept, got %d", generation)
+		}
+	})
+	t.Run("TestIssueKeepsGeneration", func(t *testing.T) {
+		// Kicked two hours ago, re-issued just now: the row must outlive the new token
+		if err := repo.NextGeneration("ROOM04", "s1", now.Add(-2*time.Hour)); err != nil {
+			t.Fatalf("failed to increment generation: %v", err)
+		}
+		if generation, err := repo.IssueGeneration("ROOM04", "s1", now); err != nil || generation != 1 {
+			t.Fatalf("expected issue with generation 1, got %d (%v)", generation, err)
+		}
+		if generation, err := repo.IssueGeneration("ROOM05", "s1", now); err != nil || generation != 0 {
+			t.Fatalf("expected issue with generation 0 without a row, got %d (%v)", generation, err)
+		}
+
+		if _, err := repo.Prune(now, now.Add(-time.Hour)); err != nil {
+			t.Fatalf("prune failed: %v", err)
+		}
+		if err := repo.NextGeneration("ROOM04", "s1", now); err != nil {
+			t.Fatalf("failed to increment generation: %v", err)
+		}
+		if generation, _ := repo.Generation("ROOM04", "s1"); generation != 2 {
+			t.Errorf("kicking again must revoke the re-issued token, got generation %d", generation)
+		}
+	})
+}
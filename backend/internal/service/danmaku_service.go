@@ -0,0 +1,302 @@
+package service
+
+import (
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/repository"
+)
+
+// 弹幕限制
+const (
+	DefaultDanmakuRateLimit   = 5                // 每个会话在限流窗口内最多发送的弹幕和表情数
+	DefaultDanmakuRateWindow  = 10 * time.Second // 限流窗口
+	DefaultDanmakuWindowLimit = 500              // 按时间窗口查询时默认返回的条数
+	MaxDanmakuWindowLimit     = 2000
+	MaxDanmakuExport          = 20000 // 导出的最大条数
+)
+
+// bilibiliFontSize 导出的弹幕字号（Bilibili 的标准字号）
+const bilibiliFontSize = 25
+
+// DanmakuOptions 弹幕服务配置
+type DanmakuOptions struct {
+	RateLimit  int           // 每个会话在 RateWindow 内最多发送的条数（为空时使用 DefaultDanmakuRateLimit）
+	RateWindow time.Duration // 限流窗口（为空时使用 DefaultDanmakuRateWindow）
+}
+
+// DanmakuRequest 发送弹幕请求
+type DanmakuRequest struct {
+	Kind    model.DanmakuKind // 类型，为空时为弹幕
+	Content string            // 弹幕内容，表情时为 model.Reactions 之一
+	Time    *float64          // 媒体时间点（秒），为空时取服务器推算的当前播放位置
+	Mode    int               // 显示模式，为 0 时滚动显示
+	Color   *int              // 颜色 0xRRGGBB，为空时为白色
+}
+
+// DanmakuService 弹幕和表情回应业务逻辑服务
+//
+// 弹幕按房间和发送时房间播放的媒体地址保存，之后在同一房间重新播放该媒体时可以按时间点回放。
+// 发送频率按会话限制，计数保存在内存中（多实例部署时每个实例分别计数）。
+type DanmakuService struct {
+	danmakuRepo repository.DanmakuRepository
+	roomRepo    repository.RoomRepository
+	memberRepo  repository.RoomMemberRepository
+	limiter     *rateLimiter
+}
+
+// NewDanmakuService 创建弹幕服务
+func NewDanmakuService(danmakuRepo repository.DanmakuRepository, roomRepo repository.RoomRepository, memberRepo repository.RoomMemberRepository, opts DanmakuOptions) *DanmakuService {
+	if opts.RateLimit <= 0 {
+		opts.RateLimit = DefaultDanmakuRateLimit
+	}
+	if opts.RateWindow <= 0 {
+		opts.RateWindow = DefaultDanmakuRateWindow
+	}
+	return &DanmakuService{
+		danmakuRepo: danmakuRepo,
+		roomRepo:    roomRepo,
+		memberRepo:  memberRepo,
+		limiter:     newRateLimiter(opts.RateLimit, opts.RateWindow),
+	}
+}
+
+// SendDanmaku 校验并保存弹幕或表情回应，发送者身份以房间成员记录为准
+//
+// 超过发送频率时返回 model.ErrDanmakuRateLimited，内容不合法时返回 model.ErrInvalidDanmaku。
+func (s *DanmakuService) SendDanmaku(roomID, sessionID string, req *DanmakuRequest) (*model.Danmaku, error) {
+	danmaku, err := newDanmaku(req)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := s.memberRepo.FindBySessionAndRoom(sessionID, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("sender is not a room member: %w", err)
+	}
+	room, err := s.roomRepo.GetByID(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Time != nil {
+		danmaku.Time = *req.Time
+	} else {
+		danmaku.Time = room.PlaybackPositionAt(time.Now().UnixMilli())
+	}
+	if math.IsNaN(danmaku.Time) || math.IsInf(danmaku.Time, 0) || danmaku.Time < 0 ||
+		(room.MediaDuration > 0 && danmaku.Time > room.MediaDuration) {
+		return nil, fmt.Errorf("%w: time out of range", model.ErrInvalidDanmaku)
+	}
+
+	if !s.limiter.allow(sessionID) {
+		return nil, model.ErrDanmakuRateLimited
+	}
+
+	danmaku.RoomID = roomID
+	danmaku.MediaURL = room.MediaURL
+	danmaku.SessionID = sessionID
+	danmaku.Nickname = member.Nickname
+	if err := s.danmakuRepo.Create(danmaku); err != nil {
+		return nil, err
+	}
+	return danmaku, nil
+}
+
+// ListWindow 获取媒体在 [from, to) 时间段内的弹幕（按时间正序），mediaURL 为空时取房间当前的媒体
+//
+// 返回实际查询的媒体地址。
+func (s *DanmakuService) ListWindow(roomID, mediaURL string, from, to float64, limit int) ([]*model.Danmaku, string, error) {
+	if math.IsNaN(from) || math.IsNaN(to) || from < 0 || to <= from {
+		return nil, "", fmt.Errorf("%w: window must satisfy 0 <= from < to", model.ErrInvalidDanmaku)
+	}
+	if limit <= 0 || limit > MaxDanmakuWindowLimit {
+		limit = DefaultDanmakuWindowLimit
+	}
+
+	mediaURL, err := s.resolveMedia(roomID, mediaURL)
+	if err != nil {
+		return nil, "", err
+	}
+	danmaku, err := s.danmakuRepo.ListByWindow(roomID, mediaURL, from, to, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	return danmaku, mediaURL, nil
+}
+
+// ExportXML 以 Bilibili XML 弹幕格式导出媒体的弹幕（不含表情回应），mediaURL 为空时取房间当前的媒体
+func (s *DanmakuService) ExportXML(roomID, mediaURL string) ([]byte, error) {
+	mediaURL, err := s.resolveMedia(roomID, mediaURL)
+	if err != nil {
+		return nil, err
+	}
+	danmaku, err := s.danmakuRepo.ListByMedia(roomID, mediaURL, model.DanmakuKindComment, MaxDanmakuExport)
+	if err != nil {
+		return nil, err
+	}
+	return writeBilibiliXML(danmaku)
+}
+
+// resolveMedia 校验房间存在，mediaURL 为空时返回房间当前的媒体地址
+func (s *DanmakuService) resolveMedia(roomID, mediaURL string) (string, error) {
+	room, err := s.roomRepo.GetByID(roomID)
+	if err != nil {
+		return "", err
+	}
+	if mediaURL == "" {
+		mediaURL = room.MediaURL
+	}
+	return mediaURL, nil
+}
+
+// newDanmaku 校验请求中与房间无关的字段
+func newDanmaku(req *DanmakuRequest) (*model.Danmaku, error) {
+	danmaku := &model.Danmaku{
+		Kind:    req.Kind,
+		Content: strings.TrimSpace(req.Content),
+		Mode:    req.Mode,
+		Color:   model.DanmakuDefaultColor,
+	}
+	if req.Color != nil {
+		danmaku.Color = *req.Color
+	}
+	if danmaku.Kind == "" {
+		danmaku.Kind = model.DanmakuKindComment
+	}
+	if danmaku.Mode == 0 {
+		danmaku.Mode = model.DanmakuModeScroll
+	}
+
+	switch danmaku.Kind {
+	case model.DanmakuKindComment:
+		// 弹幕单行显示
+		danmaku.Content = strings.Join(strings.Fields(danmaku.Content), " ")
+		if danmaku.Content == "" {
+			return nil, fmt.Errorf("%w: content is empty", model.ErrInvalidDanmaku)
+		}
+		if utf8.RuneCountInString(danmaku.Content) > model.MaxDanmakuLength {
+			return nil, fmt.Errorf("%w: content longer than %d characters", model.ErrInvalidDanmaku, model.MaxDanmakuLength)
+		}
+	case model.DanmakuKindReaction:
+		if !model.IsReaction(danmaku.Content) {
+			return nil, fmt.Errorf("%w: unsupported reaction %q", model.ErrInvalidDanmaku, danmaku.Content)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown kind %q", model.ErrInvalidDanmaku, danmaku.Kind)
+	}
+
+	switch danmaku.Mode {
+	case model.DanmakuModeScroll, model.DanmakuModeBottom, model.DanmakuModeTop:
+	default:
+		return nil, fmt.Errorf("%w: unknown mode %d", model.ErrInvalidDanmaku, danmaku.Mode)
+	}
+	if danmaku.Color < 0 || danmaku.Color > 0xFFFFFF {
+		return nil, fmt.Errorf("%w: color must be 0xRRGGBB", model.ErrInvalidDanmaku)
+	}
+	return danmaku, nil
+}
+
+// bilibiliDocument Bilibili XML 弹幕文件
+type bilibiliDocument struct {
+	XMLName    xml.Name          `xml:"i"`
+	ChatServer string            `xml:"chatserver"`
+	ChatID     int               `xml:"chatid"`
+	Mission    int               `xml:"mission"`
+	MaxLimit   int               `xml:"maxlimit"`
+	State      int               `xml:"state"`
+	RealName   int               `xml:"real_name"`
+	Source     string            `xml:"source"`
+	Comments   []bilibiliComment `xml:"d"`
+}
+
+// bilibiliComment 一条弹幕，p 依次为时间点、模式、字号、颜色、发送时间戳、弹幕池、发送者哈希、弹幕ID
+type bilibiliComment struct {
+	P    string `xml:"p,attr"`
+	Text string `xml:",chardata"`
+}
+
+// writeBilibiliXML 编码 Bilibili XML 弹幕文件
+func writeBilibiliXML(danmaku []*model.Danmaku) ([]byte, error) {
+	doc := bilibiliDocument{
+		ChatServer: "chat.bilibili.com",
+		MaxLimit:   MaxDanmakuExport,
+		Source:     "k-v",
+		Comments:   make([]bilibiliComment, 0, len(danmaku)),
+	}
+	for _, d := range danmaku {
+		p := strings.Join([]string{
+			strconv.FormatFloat(d.Time, 'f', 5, 64),
+			strconv.Itoa(d.Mode),
+			strconv.Itoa(bilibiliFontSize),
+			strconv.Itoa(d.Color),
+			strconv.FormatInt(d.CreatedAt.Unix(), 10),
+			"0",
+			fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(d.SessionID))),
+			strconv.FormatInt(d.CreatedAt.UnixNano(), 10),
+		}, ",")
+		doc.Comments = append(doc.Comments, bilibiliComment{P: p, Text: d.Content})
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// rateLimiter 按键统计滑动窗口内的次数
+type rateLimiter struct {
+	limit  int
+	window time.Duration
+	now    func() time.Time
+
+	mu   sync.Mutex
+	hits map[string][]time.Time
+}
+
+// newRateLimiter 创建限流器，window 内最多允许 limit 次
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:  limit,
+		window: window,
+		now:    time.Now,
+		hits:   make(map[string][]time.Time),
+	}
+}
+
+// allow 记录一次请求，超过限制时返回 false（不计入次数）
+func (l *rateLimiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	cutoff := now.Add(-l.window)
+	hits := l.hits[key]
+	for len(hits) > 0 && !hits[0].After(cutoff) {
+		hits = hits[1:]
+	}
+	if len(hits) >= l.limit {
+		l.hits[key] = hits
+		return false
+	}
+	l.hits[key] = append(hits, now)
+
+	// 顺带清理窗口内没有请求的键，避免长期运行时无限增长
+	if len(l.hits) > 1024 {
+		for k, h := range l.hits {
+			if len(h) == 0 || !h[len(h)-1].After(cutoff) {
+				delete(l.hits, k)
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/repository"
+)
+
+// 邀请限制
+const (
+	MaxInviteUses  = 1000
+	MaxInviteTTL   = 30 * 24 * time.Hour
+	inviteCodeLen  = 10
+	inviteAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // 去掉容易混淆的 I、O、0、1
+)
+
+// CreateInviteRequest 创建邀请请求
+type CreateInviteRequest struct {
+	Role    model.RoomRole // 通过邀请加入的成员角色，为空时为普通成员
+	MaxUses int            // 最多使用次数，0 表示不限
+	TTL     time.Duration  // 有效期，0 表示不过期
+}
+
+// InviteService 房间邀请业务逻辑服务
+//
+// 邀请码只用于加入房间：持有者无需房间密码即可成为成员，加入后另行签发访问令牌。
+// 邀请可设置有效期、使用次数和加入后的角色，房主可随时吊销；每次使用都会记录。
+type InviteService struct {
+	inviteRepo repository.InviteRepository
+	roomRepo   repository.RoomRepository
+	now        func() time.Time
+}
+
+// NewInviteService 创建邀请服务
+func NewInviteService(inviteRepo repository.InviteRepository, roomRepo repository.RoomRepository) *InviteService {
+	return &InviteService{
+		inviteRepo: inviteRepo,
+		roomRepo:   roomRepo,
+		now:        time.Now,
+	}
+}
+
+// CreateInvite 为房间创建邀请
+func (s *InviteService) CreateInvite(roomID, createdBy string, req *CreateInviteRequest) (*model.Invite, error) {
+	role := req.Role
+	if role == "" {
+		role = model.RoleMember
+	}
+	if role != model.RoleMember && role != model.RoleHost {
+		return nil, fmt.Errorf("%w: unknown role %q", model.ErrInvalidInvite, role)
+	}
+	if req.MaxUses < 0 || req.MaxUses > MaxInviteUses {
+		return nil, fmt.Errorf("%w: max uses must be between 0 and %d", model.ErrInvalidInvite, MaxInviteUses)
+	}
+	if req.TTL < 0 || req.TTL > MaxInviteTTL {
+		return nil, fmt.Errorf("%w: expiry must be within %s", model.ErrInvalidInvite, MaxInviteTTL)
+	}
+
+	code, err := generateInviteCode()
+	if err != nil {
+		return nil, err
+	}
+	invite := &model.Invite{
+		Code:      code,
+		RoomID:    roomID,
+		Role:      role,
+		MaxUses:   req.MaxUses,
+		CreatedBy: createdBy,
+	}
+	if req.TTL > 0 {
+		expiresAt := s.now().Add(req.TTL)
+		invite.ExpiresAt = &expiresAt
+	}
+
+	if err := s.inviteRepo.Create(invite); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// GetInvite 获取可以使用的邀请及其房间，邀请已过期、已用完、已吊销或房间已关闭时返回对应错误
+func (s *InviteService) GetInvite(code string) (*model.Invite, *model.Room, error) {
+	invite, err := s.inviteRepo.GetByCode(code)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := invite.Check(s.now()); err != nil {
+		return nil, nil, err
+	}
+	room, err := s.roomRepo.GetByID(invite.RoomID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := invite.CheckRoom(room); err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", err, code)
+	}
+	return invite, room, nil
+}
+
+// ListInvites 获取房间的全部邀请（包括已失效的）及使用记录
+func (s *InviteService) ListInvites(roomID string) ([]*model.Invite, error) {
+	return s.inviteRepo.ListByRoom(roomID)
+}
+
+// RevokeInvite 吊销房间的邀请，已加入的成员不受影响
+func (s *InviteService) RevokeInvite(roomID, code string) error {
+	return s.inviteRepo.Revoke(roomID, code, s.now())
+}
+
+// Redeem 使用邀请将会话加入房间，member 的房间和角色由邀请决定
+func (s *InviteService) Redeem(code string, member *model.RoomMember) (*model.Invite, error) {
+	return s.inviteRepo.Redeem(code, member, s.now())
+}
+
+// generateInviteCode 生成随机邀请码
+func generateInviteCode() (string, error) {
+	buf := make([]byte, inviteCodeLen)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate invite code: %w", err)
+	}
+	for i, b := range buf {
+		// 字母表长度为 32，取模没有偏差
+		buf[i] = inviteAlphabet[int(b)%len(inviteAlphabet)]
+	}
+	return string(buf), nil
+}
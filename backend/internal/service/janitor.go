@@ -0,0 +1,183 @@
+package service
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"xiaowo/backend/internal/repository"
+)
+
+// JanitorOptions 后台清理任务配置
+type JanitorOptions struct {
+	Interval         time.Duration // 执行间隔
+	RoomGracePeriod  time.Duration // 房间无人在线多久后停用
+	RoomRetention    time.Duration // 停用或关闭的房间保留多久后删除
+	MessageRetention time.Duration // 聊天记录保留时长
+}
+
+// JanitorStats 一次清理任务的执行结果
+type JanitorStats struct {
+	StartedAt        time.Time      `json:"started_at"`
+	DurationMs       int64          `json:"duration_ms"`
+	RoomsTouched     int            `json:"rooms_touched"`            // 有在线连接而刷新活跃时间的房间数
+	RoomsDeactivated int            `json:"rooms_deactivated"`        // 停用的空闲房间数
+	RoomsPurged      int            `json:"rooms_purged"`             // 删除的停用房间数
+	SessionsExpired  int64          `json:"sessions_expired"`         // 清理的过期会话数
+	MessagesPruned   int64          `json:"messages_pruned"`          // 清理的过期聊天记录数
+	EntriesPruned    map[string]int `json:"entries_pruned,omitempty"` // 各内存记录清理的过期条目数
+	Errors           []string       `json:"errors,omitempty"`
+}
+
+// RoomClosedListener 房间被停用、关闭或删除时的回调
+type RoomClosedListener func(roomID string)
+
+// Pruner 清理内存中过期的记录，返回清理数量
+type Pruner func() int
+
+// Janitor 定期停用空闲房间、删除长期停用的房间、清理过期会话和聊天记录
+type Janitor struct {
+	roomRepo    repository.RoomRepository
+	sessionRepo repository.SessionRepository
+	messageRepo repository.MessageRepository
+	opts        JanitorOptions
+
+	liveRooms    func() []string
+	onRoomClosed RoomClosedListener
+	pruners      map[string]Pruner
+
+	mu       sync.Mutex // 保证同一时间只有一次清理在执行
+	statsMu  sync.RWMutex
+	lastRun  *JanitorStats
+	runs     int64
+	quit     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewJanitor 创建后台清理任务
+func NewJanitor(roomRepo repository.RoomRepository, sessionRepo repository.SessionRepository, messageRepo repository.MessageRepository, opts JanitorOptions) *Janitor {
+	return &Janitor{
+		roomRepo:    roomRepo,
+		sessionRepo: sessionRepo,
+		messageRepo: messageRepo,
+		opts:        opts,
+		quit:        make(chan struct{}),
+	}
+}
+
+// TrackLiveRooms 设置在线房间来源，这些房间不会被停用或删除，需在启动阶段设置
+func (j *Janitor) TrackLiveRooms(liveRooms func() []string) {
+	j.liveRooms = liveRooms
+}
+
+// OnRoomClosed 注册房间停用/删除回调（用于清理实时连接缓存），需在启动阶段设置
+func (j *Janitor) OnRoomClosed(listener RoomClosedListener) {
+	j.onRoomClosed = listener
+}
+
+// PruneWith 注册随每次清理执行的内存记录清理函数（如密码错误计数），需在启动阶段设置
+func (j *Janitor) PruneWith(name string, prune Pruner) {
+	if j.pruners == nil {
+		j.pruners = make(map[string]Pruner)
+	}
+	j.pruners[name] = prune
+}
+
+// Start 按配置间隔在后台执行清理
+func (j *Janitor) Start() {
+	go func() {
+		ticker := time.NewTicker(j.opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				j.RunOnce()
+			case <-j.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台清理
+func (j *Janitor) Stop() {
+	j.stopOnce.Do(func() {
+		close(j.quit)
+	})
+}
+
+// RunOnce 立即执行一次清理，单个步骤失败不影响其他步骤
+func (j *Janitor) RunOnce() JanitorStats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	stats := JanitorStats{StartedAt: time.Now()}
+	fail := func(step string, err error) {
+		stats.Errors = append(stats.Errors, step+": "+err.Error())
+	}
+
+	var live []string
+	if j.liveRooms != nil {
+		live = j.liveRooms()
+	}
+	if err := j.roomRepo.TouchRooms(live); err != nil {
+		fail("touch rooms", err)
+	} else {
+		stats.RoomsTouched = len(live)
+	}
+
+	deactivated, err := j.roomRepo.DeactivateIdleRooms(stats.StartedAt.Add(-j.opts.RoomGracePeriod), live)
+	if err != nil {
+		fail("deactivate rooms", err)
+	}
+	stats.RoomsDeactivated = len(deactivated)
+
+	purged, err := j.roomRepo.PurgeInactiveRooms(stats.StartedAt.Add(-j.opts.RoomRetention), live)
+	if err != nil {
+		fail("purge rooms", err)
+	}
+	stats.RoomsPurged = len(purged)
+
+	if stats.SessionsExpired, err = j.sessionRepo.CleanupExpired(); err != nil {
+		fail("expire sessions", err)
+	}
+	if stats.MessagesPruned, err = j.messageRepo.CleanupMessagesBefore(stats.StartedAt.Add(-j.opts.MessageRetention)); err != nil {
+		fail("prune messages", err)
+	}
+
+	if len(j.pruners) > 0 {
+		stats.EntriesPruned = make(map[string]int, len(j.pruners))
+		for name, prune := range j.pruners {
+			stats.EntriesPruned[name] = prune()
+		}
+	}
+
+	if j.onRoomClosed != nil {
+		for _, roomID := range append(deactivated, purged...) {
+			j.onRoomClosed(roomID)
+		}
+	}
+
+	stats.DurationMs = time.Since(stats.StartedAt).Milliseconds()
+	j.statsMu.Lock()
+	j.lastRun = &stats
+	j.runs++
+	j.statsMu.Unlock()
+
+	if len(stats.Errors) > 0 {
+		log.Printf("janitor run finished with errors: %v", stats.Errors)
+	}
+	return stats
+}
+
+// LastRun 返回最近一次清理结果和累计执行次数，尚未执行时结果为空
+func (j *Janitor) LastRun() (*JanitorStats, int64) {
+	j.statsMu.RLock()
+	defer j.statsMu.RUnlock()
+
+	if j.lastRun == nil {
+		return nil, j.runs
+	}
+	stats := *j.lastRun
+	return &stats, j.runs
+}
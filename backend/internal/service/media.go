@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"xiaowo/backend/internal/media"
+	"xiaowo/backend/internal/model"
+)
+
+// MediaProber 媒体地址探测接口，由 media.Prober 实现
+type MediaProber interface {
+	Probe(ctx context.Context, rawURL string) (*media.Info, error)
+}
+
+// mediaFields 媒体字段，创建房间和添加播放列表条目共用
+type mediaFields struct {
+	URL      string
+	Type     string
+	Title    string
+	Duration float64
+	Info     model.JSON
+}
+
+// probeMedia 探测媒体地址并补全媒体字段
+//
+// 探测到的类型和时长覆盖客户端提供的值，标题仅在客户端未提供时填入。
+// 地址确定不是媒体时返回 ErrInvalidMediaURL；网络错误等无法确定的情况保留客户端的值。
+func probeMedia(prober MediaProber, fields *mediaFields) error {
+	if prober == nil {
+		return nil
+	}
+
+	info, err := prober.Probe(context.Background(), fields.URL)
+	if errors.Is(err, media.ErrNotMedia) {
+		return fmt.Errorf("%w: %v", model.ErrInvalidMediaURL, err)
+	}
+	if err != nil {
+		log.Printf("media probe failed for %s: %v", fields.URL, err)
+		return nil
+	}
+
+	fields.Type = info.MediaType
+	if info.Duration > 0 || info.Live {
+		fields.Duration = info.Duration
+	}
+	if fields.Title == "" {
+		fields.Title = info.Title
+	}
+	if data, err := json.Marshal(info); err == nil {
+		fields.Info = model.JSON(data)
+	}
+	return nil
+}
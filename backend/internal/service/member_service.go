@@ -1,15 +1,31 @@
 package service
 
 import (
+	"fmt"
 	"xiaowo/backend/internal/model"
 	"xiaowo/backend/internal/repository"
 	"time"
+
+	"github.com/google/uuid"
+)
+
+// 成员事件
+const (
+	MemberEventMuted       = "member_muted"
+	MemberEventUnmuted     = "member_unmuted"
+	MemberEventKicked      = "member_kicked"
+	MemberEventRoleChanged = "member_role_changed"
+	MemberEventLeft        = "member_left" // 主动离开或离线超时被移出房间（见 PresenceService）
 )
 
+// MemberListener 成员状态变更回调
+type MemberListener func(event string, member *model.RoomMember)
+
 // MemberService 房间成员业务逻辑服务
 type MemberService struct {
 	memberRepo repository.RoomMemberRepository
 	roomRepo   repository.RoomRepository
+	onChange   MemberListener
 }
 
 // NewMemberService 创建成员服务
@@ -22,15 +38,34 @@ func NewMemberService(memberRepo repository.RoomMemberRepository, roomRepo repos
 
 // AddMember 添加房间成员
 func (s *MemberService) AddMember(member *model.RoomMember) error {
+	if member.ID == "" {
+		member.ID = uuid.New().String()
+	}
+	if member.Role == "" {
+		member.Role = model.RoleMember
+	}
 	member.JoinedAt = time.Now()
 	member.LastSeen = time.Now()
 
 	return s.memberRepo.Join(member)
 }
 
-// RemoveMember 移除房间成员
+// RemoveMember 移除房间成员，最后一位成员离开时记录离开时间（供清理任务判断空房间）
 func (s *MemberService) RemoveMember(roomID, sessionID string) error {
-	return s.memberRepo.Leave(roomID, sessionID)
+	member, _ := s.GetMember(roomID, sessionID)
+	if err := s.memberRepo.Leave(roomID, sessionID); err != nil {
+		return err
+	}
+	if member != nil {
+		s.notify(MemberEventLeft, member)
+	}
+
+	if count, err := s.memberRepo.CountMembers(roomID); err == nil && count == 0 {
+		if _, err := s.roomRepo.Update(roomID, map[string]interface{}{"last_member_left_at": time.Now()}); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // GetMember 获取房间成员信息
@@ -64,4 +99,90 @@ func (s *MemberService) UpdateMemberActivity(roomID, sessionID string) error {
 func (s *MemberService) IsMember(roomID, sessionID string) bool {
 	_, err := s.GetMember(roomID, sessionID)
 	return err == nil
-}
\ No newline at end of file
+}
+
+// OnMemberChange 注册成员状态变更回调（用于通知实时连接），需在启动阶段设置
+func (s *MemberService) OnMemberChange(listener MemberListener) {
+	s.onChange = listener
+}
+
+// SetMuted 禁言或解除禁言，房主不能被禁言
+func (s *MemberService) SetMuted(roomID, sessionID string, muted bool) (*model.RoomMember, error) {
+	member, err := s.GetMember(roomID, sessionID)
+	if err != nil {
+		return nil, model.ErrNotRoomMember
+	}
+	if member.Role == model.RoleHost {
+		return nil, fmt.Errorf("%w: cannot mute the host", model.ErrPermissionDenied)
+	}
+
+	member.IsMuted = muted
+	if err := s.memberRepo.Update(member); err != nil {
+		return nil, err
+	}
+
+	event := MemberEventUnmuted
+	if muted {
+		event = MemberEventMuted
+	}
+	s.notify(event, member)
+	return member, nil
+}
+
+// Kick 将成员移出房间，房主不能被移出
+func (s *MemberService) Kick(roomID, sessionID string) (*model.RoomMember, error) {
+	member, err := s.GetMember(roomID, sessionID)
+	if err != nil {
+		return nil, model.ErrNotRoomMember
+	}
+	if member.Role == model.RoleHost {
+		return nil, fmt.Errorf("%w: cannot kick the host", model.ErrPermissionDenied)
+	}
+
+	if err := s.memberRepo.Leave(roomID, sessionID); err != nil {
+		return nil, err
+	}
+
+	s.notify(MemberEventKicked, member)
+	return member, nil
+}
+
+// TransferHost 将房主身份转让给另一位成员
+func (s *MemberService) TransferHost(roomID, fromSessionID, toSessionID string) (*model.RoomMember, error) {
+	if fromSessionID == toSessionID {
+		return nil, fmt.Errorf("%w: already the host", model.ErrPermissionDenied)
+	}
+
+	from, err := s.GetMember(roomID, fromSessionID)
+	if err != nil {
+		return nil, model.ErrNotRoomMember
+	}
+	if from.Role != model.RoleHost {
+		return nil, fmt.Errorf("%w: host only", model.ErrPermissionDenied)
+	}
+	to, err := s.GetMember(roomID, toSessionID)
+	if err != nil {
+		return nil, model.ErrNotRoomMember
+	}
+
+	to.Role = model.RoleHost
+	to.IsMuted = false
+	if err := s.memberRepo.Update(to); err != nil {
+		return nil, err
+	}
+	from.Role = model.RoleMember
+	if err := s.memberRepo.Update(from); err != nil {
+		return nil, err
+	}
+
+	s.notify(MemberEventRoleChanged, from)
+	s.notify(MemberEventRoleChanged, to)
+	return to, nil
+}
+
+// notify 触发成员状态变更回调
+func (s *MemberService) notify(event string, member *model.RoomMember) {
+	if s.onChange != nil {
+		s.onChange(event, member)
+	}
+}
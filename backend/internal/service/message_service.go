@@ -0,0 +1,96 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/repository"
+)
+
+// 聊天记录分页上限
+const (
+	DefaultHistoryLimit = 50
+	MaxHistoryPageSize  = 100
+)
+
+// MessageService 聊天消息业务逻辑服务
+type MessageService struct {
+	messageRepo repository.MessageRepository
+	memberRepo  repository.RoomMemberRepository
+}
+
+// NewMessageService 创建消息服务
+func NewMessageService(messageRepo repository.MessageRepository, memberRepo repository.RoomMemberRepository) *MessageService {
+	return &MessageService{
+		messageRepo: messageRepo,
+		memberRepo:  memberRepo,
+	}
+}
+
+// SendChat 保存聊天消息，发送者身份以房间成员记录为准
+func (s *MessageService) SendChat(roomID, sessionID, content string) (*model.Message, error) {
+	member, err := s.memberRepo.FindBySessionAndRoom(sessionID, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("sender is not a room member: %w", err)
+	}
+
+	metadata, _ := json.Marshal(map[string]string{
+		"nickname": member.Nickname,
+		"avatar":   member.Avatar,
+	})
+
+	message := &model.Message{
+		ID:          uuid.New().String(),
+		RoomID:      roomID,
+		SessionID:   sessionID,
+		MessageType: model.MessageTypeChat,
+		Content:     strings.TrimSpace(content),
+		Metadata:    model.JSON(metadata),
+		CreatedAt:   time.Now(),
+	}
+
+	if err := s.messageRepo.Create(message); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// GetRecentMessages 获取房间最近的消息（按时间正序）
+func (s *MessageService) GetRecentMessages(roomID string, limit int) ([]*model.Message, error) {
+	if limit <= 0 || limit > MaxHistoryPageSize {
+		limit = DefaultHistoryLimit
+	}
+	return s.messageRepo.GetRecentMessages(roomID, limit)
+}
+
+// GetHistory 分页获取房间聊天记录
+func (s *MessageService) GetHistory(roomID string, page, size int) ([]*model.Message, int64, error) {
+	page, size = normalizePage(page, size)
+	return s.messageRepo.GetMessagesByRoom(roomID, map[string]interface{}{}, page, size)
+}
+
+// SearchMessages 按关键词搜索房间聊天记录
+func (s *MessageService) SearchMessages(roomID, keyword string, page, size int) ([]*model.Message, int64, error) {
+	page, size = normalizePage(page, size)
+	return s.messageRepo.SearchMessages(roomID, strings.TrimSpace(keyword), map[string]interface{}{}, page, size)
+}
+
+// normalizePage 规范分页参数
+func normalizePage(page, size int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = DefaultHistoryLimit
+	}
+	if size > MaxHistoryPageSize {
+		size = MaxHistoryPageSize
+	}
+	return page, size
+}
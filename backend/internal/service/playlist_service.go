@@ -0,0 +1,318 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/repository"
+)
+
+// advanceTolerance 自动切换下一条目时允许的播放位置误差（秒）
+const advanceTolerance = 0.5
+
+// PlaylistListener 播放列表变更回调
+type PlaylistListener func(playlist *model.Playlist)
+
+// PlaylistService 房间播放列表业务逻辑服务
+//
+// 房间的媒体字段是当前条目的副本，切换条目时连同播放状态一起写入并递增播放版本号；
+// 列表的增删、排序、切换都递增列表版本号，客户端携带 base_version 时按乐观锁校验。
+type PlaylistService struct {
+	playlistRepo repository.PlaylistRepository
+	roomRepo     repository.RoomRepository
+	onChange     PlaylistListener
+	onPlayback   PlaybackListener
+	leadTime     LeadTimeFunc
+	prober       MediaProber
+}
+
+// NewPlaylistService 创建播放列表服务
+func NewPlaylistService(playlistRepo repository.PlaylistRepository, roomRepo repository.RoomRepository) *PlaylistService {
+	return &PlaylistService{
+		playlistRepo: playlistRepo,
+		roomRepo:     roomRepo,
+	}
+}
+
+// OnPlaylistChange 注册播放列表变更回调（用于向实时连接广播），需在启动阶段设置
+func (s *PlaylistService) OnPlaylistChange(listener PlaylistListener) {
+	s.onChange = listener
+}
+
+// OnPlaybackChange 注册切换条目时的播放状态变更回调，需在启动阶段设置
+func (s *PlaylistService) OnPlaybackChange(listener PlaybackListener) {
+	s.onPlayback = listener
+}
+
+// ScheduleWith 注册切换条目后开始播放的提前量计算函数，需在启动阶段设置
+func (s *PlaylistService) ScheduleWith(leadTime LeadTimeFunc) {
+	s.leadTime = leadTime
+}
+
+// ProbeWith 注册媒体地址探测器，添加条目时补全媒体类型、时长和标题，需在启动阶段设置
+func (s *PlaylistService) ProbeWith(prober MediaProber) {
+	s.prober = prober
+}
+
+// GetPlaylist 获取房间的播放列表
+func (s *PlaylistService) GetPlaylist(roomID string) (*model.Playlist, error) {
+	room, err := s.roomRepo.GetByID(roomID)
+	if err != nil {
+		return nil, err
+	}
+	return s.load(room)
+}
+
+// AddItem 在列表末尾添加条目
+func (s *PlaylistService) AddItem(roomID, sessionID string, item *model.PlaylistItem, baseVersion *int) (*model.Playlist, error) {
+	if err := s.roomRepo.ValidateMediaURL(item.MediaURL); err != nil {
+		return nil, model.ErrInvalidMediaURL
+	}
+	if item.MediaDuration < 0 {
+		return nil, fmt.Errorf("%w: negative media duration", model.ErrInvalidPlaybackState)
+	}
+
+	fields := mediaFields{URL: item.MediaURL, Type: item.MediaType, Title: item.MediaTitle, Duration: item.MediaDuration}
+	if err := probeMedia(s.prober, &fields); err != nil {
+		return nil, err
+	}
+	item.MediaType = fields.Type
+	item.MediaTitle = fields.Title
+	item.MediaDuration = fields.Duration
+	item.MediaInfo = fields.Info
+
+	item.ID = ""
+	item.RoomID = roomID
+	item.AddedBy = sessionID
+	return s.mutate(roomID, baseVersion, func(room *model.Room, version int) error {
+		return s.playlistRepo.Add(item, version)
+	})
+}
+
+// RemoveItem 移除条目，移除当前条目时正在播放的媒体保持不变
+func (s *PlaylistService) RemoveItem(roomID, itemID string, baseVersion *int) (*model.Playlist, error) {
+	return s.mutate(roomID, baseVersion, func(room *model.Room, version int) error {
+		return s.playlistRepo.Remove(roomID, itemID, version)
+	})
+}
+
+// MoveItem 将条目移动到指定位置（从0开始，超出范围时移到两端）
+func (s *PlaylistService) MoveItem(roomID, itemID string, position int, baseVersion *int) (*model.Playlist, error) {
+	return s.mutate(roomID, baseVersion, func(room *model.Room, version int) error {
+		return s.playlistRepo.Move(roomID, itemID, position, version)
+	})
+}
+
+// SetCurrent 切换到指定条目，从头开始并保持原来的播放/暂停状态
+func (s *PlaylistService) SetCurrent(roomID, itemID string, baseVersion *int) (*model.Playlist, error) {
+	return s.mutate(roomID, baseVersion, func(room *model.Room, version int) error {
+		item, err := s.playlistRepo.GetByID(roomID, itemID)
+		if err != nil {
+			return err
+		}
+		return s.switchTo(room, item, version, room.IsPlaying())
+	})
+}
+
+// Next 按房间的播放列表模式切换到下一条目
+func (s *PlaylistService) Next(roomID string, baseVersion *int) (*model.Playlist, error) {
+	return s.skip(roomID, 1, baseVersion)
+}
+
+// Previous 切换到上一条目（随机模式下按列表顺序循环）
+func (s *PlaylistService) Previous(roomID string, baseVersion *int) (*model.Playlist, error) {
+	return s.skip(roomID, -1, baseVersion)
+}
+
+// Advance 当前条目播放结束后自动切换到下一条目，列表已播完时暂停在结尾
+//
+// playbackVersion 为调用方观察到播放结束时的播放版本号，期间有人操作过播放（或其他实例已切换）
+// 时返回 model.ErrVersionConflict，因此多个实例同时触发时只有一个生效。
+func (s *PlaylistService) Advance(roomID string, playbackVersion int) error {
+	room, err := s.roomRepo.GetByID(roomID)
+	if err != nil {
+		return err
+	}
+	if room.Version != playbackVersion {
+		return model.ErrVersionConflict
+	}
+	now := time.Now().UnixMilli()
+	if !room.IsPlaying() || room.MediaDuration <= 0 || room.PlaybackPositionAt(now) < room.MediaDuration-advanceTolerance {
+		return fmt.Errorf("%w: current media has not ended", model.ErrInvalidPlaybackState)
+	}
+
+	items, err := s.playlistRepo.ListByRoom(roomID)
+	if err != nil {
+		return err
+	}
+	next, err := nextIndex(items, room.CurrentItemID, room.GetSettings().PlaylistMode, 1)
+	if errors.Is(err, model.ErrPlaylistEnd) {
+		updates := map[string]interface{}{
+			"playback_state":      model.PlaybackPaused,
+			"current_time":        room.MediaDuration,
+			"playback_updated_at": now,
+		}
+		if err := s.roomRepo.UpdateWithVersion(roomID, updates, room.Version); err != nil {
+			return err
+		}
+		if updated, err := s.roomRepo.GetByID(roomID); err == nil && s.onPlayback != nil {
+			s.onPlayback(updated, PlaybackActionPause)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.switchTo(room, items[next], room.PlaylistVersion, true); err != nil {
+		return err
+	}
+	_, err = s.changed(roomID, room.Version)
+	return err
+}
+
+// skip 相对当前条目前后切换
+func (s *PlaylistService) skip(roomID string, step int, baseVersion *int) (*model.Playlist, error) {
+	return s.mutate(roomID, baseVersion, func(room *model.Room, version int) error {
+		items, err := s.playlistRepo.ListByRoom(roomID)
+		if err != nil {
+			return err
+		}
+		next, err := nextIndex(items, room.CurrentItemID, room.GetSettings().PlaylistMode, step)
+		if err != nil {
+			return err
+		}
+		return s.switchTo(room, items[next], version, room.IsPlaying())
+	})
+}
+
+// switchTo 将条目载入房间并从头开始，开始播放时预约在所有成员都能收到之后生效
+func (s *PlaylistService) switchTo(room *model.Room, item *model.PlaylistItem, version int, playing bool) error {
+	state := model.PlaybackPaused
+	updatedAt := time.Now().UnixMilli()
+	if playing {
+		state = model.PlaybackPlaying
+		if s.leadTime != nil {
+			updatedAt += s.leadTime(room.ID).Milliseconds()
+		}
+	}
+
+	playback := map[string]interface{}{
+		"playback_state":      state,
+		"current_time":        0.0,
+		"playback_updated_at": updatedAt,
+	}
+	return s.playlistRepo.SetCurrent(room.ID, item, playback, version, room.Version)
+}
+
+// mutate 校验客户端基于的列表版本后执行修改，成功后广播最新的播放列表
+//
+// 版本冲突时同时返回最新的播放列表，供客户端基于新版本重试。
+func (s *PlaylistService) mutate(roomID string, baseVersion *int, apply func(room *model.Room, version int) error) (*model.Playlist, error) {
+	room, err := s.roomRepo.GetByID(roomID)
+	if err != nil {
+		return nil, err
+	}
+	if baseVersion != nil && *baseVersion != room.PlaylistVersion {
+		return s.conflict(roomID)
+	}
+
+	if err := apply(room, room.PlaylistVersion); err != nil {
+		if errors.Is(err, model.ErrVersionConflict) {
+			return s.conflict(roomID)
+		}
+		return nil, err
+	}
+
+	return s.changed(roomID, room.Version)
+}
+
+// conflict 返回最新的播放列表和版本冲突错误
+func (s *PlaylistService) conflict(roomID string) (*model.Playlist, error) {
+	latest, err := s.GetPlaylist(roomID)
+	if err != nil {
+		return nil, model.ErrVersionConflict
+	}
+	return latest, model.ErrVersionConflict
+}
+
+// changed 重新加载播放列表并通知回调，播放版本号与修改前不同说明切换了条目，先下发新的播放状态
+func (s *PlaylistService) changed(roomID string, playbackVersion int) (*model.Playlist, error) {
+	room, err := s.roomRepo.GetByID(roomID)
+	if err != nil {
+		return nil, err
+	}
+	playlist, err := s.load(room)
+	if err != nil {
+		return nil, err
+	}
+
+	if room.Version != playbackVersion && s.onPlayback != nil {
+		s.onPlayback(room, PlaybackActionChangeMedia)
+	}
+	if s.onChange != nil {
+		s.onChange(playlist)
+	}
+	return playlist, nil
+}
+
+// load 组装房间的播放列表
+func (s *PlaylistService) load(room *model.Room) (*model.Playlist, error) {
+	items, err := s.playlistRepo.ListByRoom(room.ID)
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []*model.PlaylistItem{}
+	}
+	return &model.Playlist{
+		RoomID:    room.ID,
+		Items:     items,
+		CurrentID: room.CurrentItemID,
+		Version:   room.PlaylistVersion,
+	}, nil
+}
+
+// nextIndex 按播放列表模式计算相对当前条目前后 step 的条目下标
+//
+// 当前条目已被移除时从第一个条目开始；顺序模式越过两端返回 model.ErrPlaylistEnd；
+// 循环模式首尾相接；随机模式向后切换时随机选择另一个条目，向前切换与循环模式相同。
+func nextIndex(items []*model.PlaylistItem, currentID, mode string, step int) (int, error) {
+	n := len(items)
+	if n == 0 {
+		return -1, model.ErrPlaylistEnd
+	}
+	current := -1
+	for i, item := range items {
+		if item.ID == currentID {
+			current = i
+			break
+		}
+	}
+	if current < 0 {
+		return 0, nil
+	}
+
+	switch {
+	case mode == model.PlaylistModeShuffle && step > 0:
+		if n == 1 {
+			return 0, nil
+		}
+		next := rand.Intn(n - 1)
+		if next >= current {
+			next++
+		}
+		return next, nil
+	case mode == model.PlaylistModeLoop || mode == model.PlaylistModeShuffle:
+		return ((current+step)%n + n) % n, nil
+	default:
+		next := current + step
+		if next < 0 || next >= n {
+			return -1, model.ErrPlaylistEnd
+		}
+		return next, nil
+	}
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/repository"
+)
+
+// 在线状态默认配置
+const (
+	DefaultPresenceFlushInterval = 5 * time.Second
+	DefaultPresenceAwayAfter     = 2 * time.Minute // 实时连接每分钟至少一次心跳
+	DefaultOfflineTimeout        = 30 * time.Minute
+)
+
+// presenceSweepInterval 检查离线超时成员的间隔
+const presenceSweepInterval = time.Minute
+
+// PresenceOptions 在线状态配置
+type PresenceOptions struct {
+	FlushInterval  time.Duration // 批量写入会话状态和成员最后在线时间的间隔
+	AwayAfter      time.Duration // 超过该时间没有心跳的成员视为离开（实例异常退出时连接来不及下线）
+	OfflineTimeout time.Duration // 成员离线超过该时间后自动离开房间（房主除外）
+}
+
+// presenceKey 房间内的会话
+type presenceKey struct {
+	roomID    string
+	sessionID string
+}
+
+// presenceUpdate 待写入的在线状态，status 为空表示只刷新最后在线时间
+type presenceUpdate struct {
+	status model.UserSessionStatus
+	seenAt time.Time
+}
+
+// PresenceService 维护会话在线状态和成员最后在线时间
+//
+// 实时连接的上线、下线和心跳先记入内存，按 FlushInterval 合并写入存储；
+// 后台任务定期将离线超过 OfflineTimeout 的成员移出房间。
+type PresenceService struct {
+	sessionRepo repository.SessionRepository
+	memberRepo  repository.RoomMemberRepository
+	roomRepo    repository.RoomRepository
+	opts        PresenceOptions
+	now         func() time.Time
+	onChange    MemberListener
+
+	mu       sync.Mutex
+	pending  map[presenceKey]presenceUpdate
+	quit     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewPresenceService 创建在线状态服务，未设置的选项使用默认值
+func NewPresenceService(sessionRepo repository.SessionRepository, memberRepo repository.RoomMemberRepository, roomRepo repository.RoomRepository, opts PresenceOptions) *PresenceService {
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultPresenceFlushInterval
+	}
+	if opts.AwayAfter <= 0 {
+		opts.AwayAfter = DefaultPresenceAwayAfter
+	}
+	if opts.OfflineTimeout <= 0 {
+		opts.OfflineTimeout = DefaultOfflineTimeout
+	}
+	return &PresenceService{
+		sessionRepo: sessionRepo,
+		memberRepo:  memberRepo,
+		roomRepo:    roomRepo,
+		opts:        opts,
+		now:         time.Now,
+		pending:     make(map[presenceKey]presenceUpdate),
+		quit:        make(chan struct{}),
+	}
+}
+
+// OnMemberChange 注册成员离线超时被移出房间的回调（事件为 MemberEventLeft），需在启动阶段设置
+func (s *PresenceService) OnMemberChange(listener MemberListener) {
+	s.onChange = listener
+}
+
+// SetStatus 记录会话在房间内上线、空闲或下线
+func (s *PresenceService) SetStatus(roomID, sessionID string, status model.UserSessionStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[presenceKey{roomID, sessionID}] = presenceUpdate{status: status, seenAt: s.now()}
+}
+
+// Touch 记录会话的心跳，只刷新最后在线时间
+func (s *PresenceService) Touch(roomID, sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := presenceKey{roomID, sessionID}
+	update := s.pending[key]
+	update.seenAt = s.now()
+	s.pending[key] = update
+}
+
+// Flush 将内存中的在线状态写入存储：会话按状态、成员按房间各合并为一条语句
+func (s *PresenceService) Flush() error {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make(map[presenceKey]presenceUpdate)
+	s.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	// 同一批写入使用相同的时间，误差不超过 FlushInterval
+	seenAt := s.now()
+	byStatus := make(map[model.UserSessionStatus][]string)
+	byRoom := make(map[string][]string)
+	for key, update := range pending {
+		byStatus[update.status] = append(byStatus[update.status], key.sessionID)
+		byRoom[key.roomID] = append(byRoom[key.roomID], key.sessionID)
+	}
+
+	var firstErr error
+	for status, sessionIDs := range byStatus {
+		if err := s.sessionRepo.UpdatePresence(sessionIDs, string(status), seenAt); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for roomID, sessionIDs := range byRoom {
+		if err := s.memberRepo.TouchMembers(roomID, sessionIDs, seenAt); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// GetRoomMembers 获取房间所有成员及其在线状态
+//
+// 尚未写入存储的状态变更优先；会话离线或超过 AwayAfter 没有心跳的成员为 away。
+func (s *PresenceService) GetRoomMembers(roomID string) ([]*model.MemberPresence, error) {
+	members, err := s.memberRepo.FindMembers(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionIDs := make([]string, 0, len(members))
+	for _, member := range members {
+		sessionIDs = append(sessionIDs, member.SessionID)
+	}
+	sessions, err := s.sessionRepo.GetByIDs(sessionIDs)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make(map[string]model.UserSessionStatus, len(sessions))
+	for _, session := range sessions {
+		statuses[session.ID] = session.Status
+	}
+
+	s.mu.Lock()
+	for _, member := range members {
+		if update, ok := s.pending[presenceKey{roomID, member.SessionID}]; ok {
+			if update.status != "" {
+				statuses[member.SessionID] = update.status
+			}
+			member.LastSeen = update.seenAt
+		}
+	}
+	s.mu.Unlock()
+
+	now := s.now()
+	result := make([]*model.MemberPresence, 0, len(members))
+	for _, member := range members {
+		presence := model.PresenceAway
+		if now.Sub(member.LastSeen) <= s.opts.AwayAfter {
+			switch statuses[member.SessionID] {
+			case model.StatusOnline:
+				presence = model.PresenceOnline
+			case model.StatusIdle:
+				presence = model.PresenceIdle
+			}
+		}
+		result = append(result, &model.MemberPresence{RoomMember: member, Presence: presence})
+	}
+	return result, nil
+}
+
+// ExpireOffline 将离线超过 OfflineTimeout 的成员移出房间，返回移出的成员
+//
+// 在线的连接定期心跳，最后在线时间不会超时；房主不会被自动移出。
+func (s *PresenceService) ExpireOffline() ([]*model.RoomMember, error) {
+	// 先写入最近的心跳，避免刚断线重连的成员被误判
+	if err := s.Flush(); err != nil {
+		return nil, err
+	}
+
+	stale, err := s.memberRepo.FindStale(s.now().Add(-s.opts.OfflineTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	expired := make([]*model.RoomMember, 0, len(stale))
+	for _, member := range stale {
+		if err := s.roomRepo.LeaveRoom(member.RoomID, member.SessionID); err != nil {
+			log.Printf("presence: remove offline member %s from room %s: %v", member.SessionID, member.RoomID, err)
+			continue
+		}
+		expired = append(expired, member)
+		if s.onChange != nil {
+			s.onChange(MemberEventLeft, member)
+		}
+	}
+	return expired, nil
+}
+
+// Start 在后台按间隔写入在线状态并移出离线超时的成员
+func (s *PresenceService) Start() {
+	go func() {
+		flush := time.NewTicker(s.opts.FlushInterval)
+		defer flush.Stop()
+		sweep := time.NewTicker(presenceSweepInterval)
+		defer sweep.Stop()
+		for {
+			select {
+			case <-flush.C:
+				if err := s.Flush(); err != nil {
+					log.Printf("presence: flush: %v", err)
+				}
+			case <-sweep.C:
+				if _, err := s.ExpireOffline(); err != nil {
+					log.Printf("presence: expire offline members: %v", err)
+				}
+			case <-s.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台任务并写入剩余的在线状态，未调用 Start 时同样会写入
+func (s *PresenceService) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.quit)
+	})
+	if err := s.Flush(); err != nil {
+		log.Printf("presence: flush: %v", err)
+	}
+}
@@ -1,8 +1,11 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/password"
 	"xiaowo/backend/internal/repository"
 	"time"
 )
@@ -18,22 +21,78 @@ type CreateRoomRequest struct {
 	MediaType     string                 `json:"media_type"`
 	MediaTitle    string                 `json:"media_title"`
 	MediaDuration int                    `json:"media_duration"`
-	Settings      map[string]interface{} `json:"settings"`
+	Settings      *model.RoomSettingsPatch `json:"settings,omitempty"`
 }
 
 // UpdateRoomRequest 更新房间请求
 type UpdateRoomRequest struct {
 	Name       *string                 `json:"name,omitempty"`
 	IsPrivate  *bool                   `json:"is_private,omitempty"`
-	Password   *string                 `json:"password,omitempty"`
+	Password   *string                 `json:"password,omitempty"` // 为空字符串时清除密码，房间改为公开
 	MaxUsers   *int                    `json:"max_users,omitempty"`
-	Settings   map[string]interface{}  `json:"settings"`
+	Settings   *model.RoomSettingsPatch `json:"settings,omitempty"`
+}
+
+// 播放控制动作
+const (
+	PlaybackActionPlay      = "play"
+	PlaybackActionPause     = "pause"
+	PlaybackActionSeek      = "seek"
+	PlaybackActionRate      = "rate"
+	PlaybackActionCountdown = "countdown" // 倒计时结束后开始播放
+	PlaybackActionChangeMedia = "change_media" // 切换播放列表条目（见 PlaylistService）
+)
+
+// MaxCountdown 倒计时开播允许的最长倒计时
+const MaxCountdown = 60 * time.Second
+
+// PlaybackCommand 播放控制命令
+type PlaybackCommand struct {
+	Action      string   // 控制动作
+	Position    *float64 // 目标位置（秒），play/pause 为空时沿用推算位置，seek 必填
+	Rate        float64  // 播放倍速，仅 rate 使用
+	BaseVersion *int     // 客户端所基于的版本号，为空时以当前版本为准
+	ExecuteAt   int64    // 生效的服务器时间（毫秒），晚于当前时间时状态从该时刻起推算，为 0 表示立即生效
+}
+
+// RoomAction 需要权限校验的房间操作
+type RoomAction string
+
+const (
+	ActionControlPlayback RoomAction = "control_playback" // 播放、暂停、倍速
+	ActionSeek            RoomAction = "seek"             // 拖动进度
+	ActionChat            RoomAction = "chat"             // 发送聊天消息
+	ActionEditPlaylist    RoomAction = "edit_playlist"    // 编辑播放列表、切换条目
+	ActionJoinCall        RoomAction = "join_call"        // 加入语音/视频通话、发送通话信令
+	ActionManageRoom      RoomAction = "manage_room"      // 修改房间、管理成员（仅房主）
+)
+
+// LeadTimeFunc 返回房间内播放指令需要提前下发的时间，保证所有成员在生效前收到
+type LeadTimeFunc func(roomID string) time.Duration
+
+// PlaybackListener 播放状态变更回调
+type PlaybackListener func(room *model.Room, action string)
+
+// SettingsListener 房间设置变更回调
+type SettingsListener func(roomID string, settings model.RoomSettings)
+
+// RoomLimits 房间限制
+type RoomLimits struct {
+	DefaultMaxUsers int // 未指定人数上限时的默认值
+	MaxUsers        int // 允许设置的最大人数上限
 }
 
 // RoomService 房间业务逻辑服务
 type RoomService struct {
 	roomRepo   repository.RoomRepository
 	memberRepo repository.RoomMemberRepository
+	onPlayback PlaybackListener
+	onSettings SettingsListener
+	onClosed   RoomClosedListener
+	leadTime   LeadTimeFunc
+	prober     MediaProber
+	passwords  *password.Guard
+	limits     RoomLimits
 }
 
 // NewRoomService 创建房间服务
@@ -41,7 +100,27 @@ func NewRoomService(roomRepo repository.RoomRepository, memberRepo repository.Ro
 	return &RoomService{
 		roomRepo:   roomRepo,
 		memberRepo: memberRepo,
+		passwords:  password.NewGuard(password.DefaultMaxAttempts, password.DefaultWindow, password.DefaultLockout),
+		limits:     RoomLimits{DefaultMaxUsers: 10, MaxUsers: 1000},
+	}
+}
+
+// SetLimits 设置房间限制，需在启动阶段设置
+func (s *RoomService) SetLimits(limits RoomLimits) {
+	s.limits = limits
+}
+
+// ProbeWith 注册媒体地址探测器，创建房间时补全媒体类型、时长和标题，需在启动阶段设置
+func (s *RoomService) ProbeWith(prober MediaProber) {
+	s.prober = prober
+}
+
+// checkMaxUsers 校验人数上限是否在允许范围内
+func (s *RoomService) checkMaxUsers(maxUsers int) error {
+	if maxUsers < 1 || maxUsers > s.limits.MaxUsers {
+		return fmt.Errorf("%w: %d (1-%d)", model.ErrInvalidMaxUsers, maxUsers, s.limits.MaxUsers)
 	}
+	return nil
 }
 
 // CreateRoom 创建房间
@@ -57,12 +136,43 @@ func (s *RoomService) CreateRoom(req *CreateRoomRequest, creatorSessionID string
 		isPrivate = *req.IsPrivate
 	}
 	
-	maxUsers := 10
+	maxUsers := s.limits.DefaultMaxUsers
 	if req.MaxUsers != nil {
 		maxUsers = *req.MaxUsers
 	}
+	if err := s.checkMaxUsers(maxUsers); err != nil {
+		return nil, err
+	}
 	
-	mediaDuration := float64(req.MediaDuration)
+	// 探测媒体地址，补全类型、时长和标题
+	mediaInfo := mediaFields{
+		URL:      req.MediaURL,
+		Type:     req.MediaType,
+		Title:    req.MediaTitle,
+		Duration: float64(req.MediaDuration),
+	}
+	if err := probeMedia(s.prober, &mediaInfo); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, syncplayPassword := "", ""
+	if req.Password != "" {
+		hashed, syncplayHashed, err := hashRoomPassword(req.Password)
+		if err != nil {
+			return nil, err
+		}
+		hashedPassword, syncplayPassword = hashed, syncplayHashed
+	}
+
+	// 未提供的设置项使用默认值
+	settings := model.DefaultRoomSettings().Merge(req.Settings)
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+	settingsJSON, err := settings.ToJSON()
+	if err != nil {
+		return nil, err
+	}
 
 	room := &model.Room{
 		ID:               s.roomRepo.GenerateRoomID(),
@@ -70,17 +180,20 @@ func (s *RoomService) CreateRoom(req *CreateRoomRequest, creatorSessionID string
 		Description:      req.Description,
 		CreatorSessionID: creatorSessionID,
 		IsPrivate:        isPrivate,
-		Password:         req.Password,
+		Password:         hashedPassword,
+		SyncplayPassword: syncplayPassword,
 		MaxUsers:         maxUsers,
 		Status:           model.RoomStatusActive,
 		MediaURL:         req.MediaURL,
-		MediaType:        req.MediaType,
-		MediaTitle:       req.MediaTitle,
-		MediaDuration:    mediaDuration,
-		PlaybackState:    "paused",
+		MediaType:        mediaInfo.Type,
+		MediaTitle:       mediaInfo.Title,
+		MediaDuration:    mediaInfo.Duration,
+		MediaInfo:        mediaInfo.Info,
+		PlaybackState:    model.PlaybackPaused,
 		CurrentTime:      0,
 		PlaybackRate:     1.0,
-		Settings:         s.convertSettings(req.Settings),
+		PlaybackUpdatedAt: time.Now().UnixMilli(),
+		Settings:         settingsJSON,
 		Version:          0,
 		CreatedAt:        time.Now(),
 		UpdatedAt:        time.Now(),
@@ -106,17 +219,23 @@ func (s *RoomService) UpdateRoom(roomID string, req *UpdateRoomRequest) (*model.
 	if req.Name != nil {
 		updates["name"] = *req.Name
 	}
-	if req.IsPrivate != nil {
-		updates["is_private"] = *req.IsPrivate
-	}
-	if req.Password != nil {
-		updates["password"] = *req.Password
+	if req.IsPrivate != nil || req.Password != nil {
+		if err := s.updatePrivacy(roomID, req, updates); err != nil {
+			return nil, err
+		}
 	}
 	if req.MaxUsers != nil {
+		if err := s.checkMaxUsers(*req.MaxUsers); err != nil {
+			return nil, err
+		}
 		updates["max_users"] = *req.MaxUsers
 	}
 	if req.Settings != nil {
-		updates["settings"] = s.convertSettings(req.Settings)
+		settingsJSON, err := s.mergeSettings(roomID, req.Settings)
+		if err != nil {
+			return nil, err
+		}
+		updates["settings"] = settingsJSON
 	}
 
 	updatedRoom, err := s.roomRepo.Update(roomID, updates)
@@ -124,9 +243,133 @@ func (s *RoomService) UpdateRoom(roomID string, req *UpdateRoomRequest) (*model.
 		return nil, err
 	}
 
+	if req.Settings != nil {
+		s.notifySettings(updatedRoom)
+	}
+
 	return updatedRoom, nil
 }
 
+// updatePrivacy 计算私密设置和密码的更新
+//
+// 清除密码的私密房间改为公开，改为公开的房间同时清除密码；私密房间必须有密码。
+func (s *RoomService) updatePrivacy(roomID string, req *UpdateRoomRequest, updates map[string]interface{}) error {
+	room, err := s.roomRepo.GetByID(roomID)
+	if err != nil {
+		return err
+	}
+
+	isPrivate, hasPassword := room.IsPrivate, room.Password != ""
+	if req.Password != nil {
+		hasPassword = *req.Password != ""
+		if !hasPassword && req.IsPrivate == nil {
+			isPrivate = false
+		}
+	}
+	if req.IsPrivate != nil {
+		isPrivate = *req.IsPrivate
+	}
+
+	switch {
+	case !isPrivate:
+		updates["room_password"] = ""
+		updates["syncplay_password"] = ""
+	case !hasPassword:
+		return model.ErrRoomPasswordRequired
+	case req.Password != nil:
+		hashed, syncplayHashed, err := hashRoomPassword(*req.Password)
+		if err != nil {
+			return err
+		}
+		updates["room_password"] = hashed
+		updates["syncplay_password"] = syncplayHashed
+	}
+	updates["is_private"] = isPrivate
+	return nil
+}
+
+// VerifyPassword 校验私密房间密码，同一来源IP连续输错后临时锁定
+//
+// 锁定期间返回 password.ErrLocked 及剩余锁定时长。
+func (s *RoomService) VerifyPassword(room *model.Room, plain, clientIP string) (time.Duration, error) {
+	return s.verifyPassword(room, room.Password, plain, clientIP)
+}
+
+// VerifySyncplayPassword 校验 Syncplay 客户端发送的房间密码 MD5，错误次数与 VerifyPassword 合并计算
+//
+// 设置该校验值之前创建的房间需要房主重新设置一次密码，Syncplay 客户端才能以密码登录。
+func (s *RoomService) VerifySyncplayPassword(room *model.Room, digest, clientIP string) (time.Duration, error) {
+	return s.verifyPassword(room, room.SyncplayPassword, strings.ToLower(digest), clientIP)
+}
+
+func (s *RoomService) verifyPassword(room *model.Room, hashed, plain, clientIP string) (time.Duration, error) {
+	if !room.IsPrivate || room.Password == "" {
+		return 0, nil
+	}
+	if remaining := s.passwords.Check(room.ID, clientIP); remaining > 0 {
+		return remaining, password.ErrLocked
+	}
+
+	if hashed == "" || !password.Verify(hashed, plain) {
+		if locked := s.passwords.Fail(room.ID, clientIP); locked > 0 {
+			return locked, password.ErrLocked
+		}
+		return 0, model.ErrRoomPasswordInvalid
+	}
+
+	s.passwords.Reset(room.ID, clientIP)
+	return 0, nil
+}
+
+// hashRoomPassword 生成房间密码的哈希，以及供 Syncplay 登录校验的密码 MD5 的哈希
+func hashRoomPassword(plain string) (string, string, error) {
+	hashed, err := password.Hash(plain)
+	if err != nil {
+		return "", "", err
+	}
+	syncplayHashed, err := password.Hash(password.SyncplayDigest(plain))
+	if err != nil {
+		return "", "", err
+	}
+	return hashed, syncplayHashed, nil
+}
+
+// PrunePasswordAttempts 清理已过期的密码错误记录，返回清理数量
+func (s *RoomService) PrunePasswordAttempts() int {
+	return s.passwords.Prune()
+}
+
+// UpdateSettings 部分更新房间设置，未提供的字段保持不变
+func (s *RoomService) UpdateSettings(roomID string, patch *model.RoomSettingsPatch) (*model.Room, error) {
+	return s.UpdateRoom(roomID, &UpdateRoomRequest{Settings: patch})
+}
+
+// OnSettingsChange 注册房间设置变更回调（用于向实时连接推送），需在启动阶段设置
+func (s *RoomService) OnSettingsChange(listener SettingsListener) {
+	s.onSettings = listener
+}
+
+// mergeSettings 将部分设置合并到当前设置并校验
+func (s *RoomService) mergeSettings(roomID string, patch *model.RoomSettingsPatch) (model.JSON, error) {
+	room, err := s.GetRoom(roomID)
+	if err != nil {
+		return "", err
+	}
+
+	merged := room.GetSettings().Merge(patch)
+	if err := merged.Validate(); err != nil {
+		return "", err
+	}
+	return merged.ToJSON()
+}
+
+// notifySettings 触发房间设置变更回调
+func (s *RoomService) notifySettings(room *model.Room) {
+	if s.onSettings != nil {
+		s.onSettings(room.ID, room.GetSettings())
+	}
+}
+
 // ListRooms 获取房间列表
 func (s *RoomService) ListRooms(page, size int) ([]*model.Room, int64, error) {
 	return s.roomRepo.GetActiveRooms(page, size)
@@ -158,75 +401,246 @@ func (s *RoomService) DeleteRoom(roomID, sessionID string) error {
 		"updated_at": time.Now(),
 	}
 
-	_, err = s.roomRepo.Update(roomID, updates)
-	return err
+	if _, err := s.roomRepo.Update(roomID, updates); err != nil {
+		return err
+	}
+	s.notifyClosed(roomID)
+	return nil
 }
 
-// CloseRoom 关闭房间
+// CloseRoom 关闭房间，房间内的实时连接随之断开，记录由后台清理任务在保留期后删除
 func (s *RoomService) CloseRoom(roomID string) error {
 	updates := map[string]interface{}{
 		"status":     model.RoomStatusDeleted,
 		"updated_at": time.Now(),
 	}
 
-	_, err := s.roomRepo.Update(roomID, updates)
-	return err
+	if _, err := s.roomRepo.Update(roomID, updates); err != nil {
+		return err
+	}
+	s.notifyClosed(roomID)
+	return nil
 }
 
-// PlayVideo 播放视频
-func (s *RoomService) PlayVideo(roomID string) error {
-	// 获取当前房间
+// GetJoinableRoom 获取可以加入的房间，已关闭的房间视为不存在
+func (s *RoomService) GetJoinableRoom(roomID string) (*model.Room, error) {
 	room, err := s.GetRoom(roomID)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	if room.Status == model.RoomStatusDeleted {
+		return nil, fmt.Errorf("%w: %s", model.ErrRoomNotFound, roomID)
+	}
+	return room, nil
+}
 
-	// 更新播放状态
-	updates := map[string]interface{}{
-		"playback_state": "playing",
-		"version":        room.Version + 1,
-		"updated_at":     time.Now(),
+// OnRoomClosed 注册房间关闭回调（用于断开实时连接并清理缓存），需在启动阶段设置
+func (s *RoomService) OnRoomClosed(listener RoomClosedListener) {
+	s.onClosed = listener
+}
+
+// notifyClosed 触发房间关闭回调
+func (s *RoomService) notifyClosed(roomID string) {
+	if s.onClosed != nil {
+		s.onClosed(roomID)
 	}
+}
 
-	err = s.roomRepo.UpdatePlaybackState(roomID, updates)
-	return err
+// OnPlaybackChange 注册播放状态变更回调（用于向实时连接广播），需在启动阶段设置
+func (s *RoomService) OnPlaybackChange(listener PlaybackListener) {
+	s.onPlayback = listener
 }
 
-// PauseVideo 暂停视频
-func (s *RoomService) PauseVideo(roomID string) error {
-	// 获取当前房间
+// ScheduleWith 注册播放指令提前量的计算函数（由实时连接层按往返时延估算），需在启动阶段设置
+//
+// 未设置时播放、跳转、倍速指令立即生效。
+func (s *RoomService) ScheduleWith(leadTime LeadTimeFunc) {
+	s.leadTime = leadTime
+}
+
+// ApplyPlayback 应用播放控制命令
+//
+// 播放进度先按旧状态结算到命令生效时刻，再应用命令，通过版本号乐观锁写入，
+// REST 与 WebSocket 的播放控制都经过这里，保证两边看到同一份状态。
+// 播放、跳转、倍速命令未指定生效时间时，预约在所有成员都能收到之后的同一时刻生效。
+func (s *RoomService) ApplyPlayback(roomID string, cmd PlaybackCommand) (*model.Room, error) {
 	room, err := s.GetRoom(roomID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	expectedVersion := room.Version
+	if cmd.BaseVersion != nil {
+		if *cmd.BaseVersion != room.Version {
+			return room, model.ErrVersionConflict
+		}
+		expectedVersion = *cmd.BaseVersion
+	}
+
+	now := time.Now().UnixMilli()
+	executeAt := cmd.ExecuteAt
+	switch cmd.Action {
+	case PlaybackActionPlay, PlaybackActionSeek, PlaybackActionRate:
+		if executeAt == 0 && s.leadTime != nil {
+			executeAt = now + s.leadTime(roomID).Milliseconds()
+		}
+	case PlaybackActionCountdown:
+		if executeAt <= now || executeAt > now+MaxCountdown.Milliseconds() {
+			return nil, fmt.Errorf("%w: countdown must end within %s", model.ErrInvalidPlaybackState, MaxCountdown)
+		}
+	}
+
+	// 预约生效时，位置在生效时刻之前按旧状态推算
+	updatedAt := now
+	if executeAt > now {
+		updatedAt = executeAt
+	}
+
+	position := room.PlaybackPositionAt(updatedAt)
+	state := room.PlaybackState
+	rate := room.PlaybackRate
+
+	switch cmd.Action {
+	case PlaybackActionPlay, PlaybackActionCountdown:
+		state = model.PlaybackPlaying
+		if cmd.Position != nil {
+			position = *cmd.Position
+		}
+	case PlaybackActionPause:
+		state = model.PlaybackPaused
+		if cmd.Position != nil {
+			position = *cmd.Position
+		}
+	case PlaybackActionSeek:
+		if cmd.Position == nil {
+			return nil, fmt.Errorf("%w: seek position is required", model.ErrInvalidPlaybackState)
+		}
+		position = *cmd.Position
+	case PlaybackActionRate:
+		if cmd.Rate < 0.25 || cmd.Rate > 4.0 {
+			return nil, fmt.Errorf("%w: playback rate out of range", model.ErrInvalidPlaybackState)
+		}
+		rate = cmd.Rate
+	default:
+		return nil, fmt.Errorf("%w: unknown action %q", model.ErrInvalidPlaybackState, cmd.Action)
+	}
+
+	if position < 0 {
+		position = 0
+	}
+	if room.MediaDuration > 0 && position > room.MediaDuration {
+		position = room.MediaDuration
 	}
 
-	// 更新播放状态
 	updates := map[string]interface{}{
-		"playback_state": "paused",
-		"version":        room.Version + 1,
-		"updated_at":     time.Now(),
+		"playback_state":      state,
+		"current_time":        position,
+		"playback_rate":       rate,
+		"playback_updated_at": updatedAt,
+	}
+	if err := s.roomRepo.UpdateWithVersion(roomID, updates, expectedVersion); err != nil {
+		if errors.Is(err, model.ErrVersionConflict) {
+			if latest, getErr := s.GetRoom(roomID); getErr == nil {
+				return latest, err
+			}
+		}
+		return nil, err
 	}
 
-	err = s.roomRepo.UpdatePlaybackState(roomID, updates)
-	return err
+	updated, err := s.GetRoom(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.onPlayback != nil {
+		s.onPlayback(updated, cmd.Action)
+	}
+
+	return updated, nil
 }
 
-// SeekVideo 视频跳转
-func (s *RoomService) SeekVideo(roomID string, currentTime float64) error {
-	// 获取当前房间
+// Authorize 校验会话是否可以在房间内执行指定操作
+//
+// 房主拥有全部权限；普通成员受房间权限设置约束，被禁言的成员不能发送聊天消息。
+func (s *RoomService) Authorize(roomID, sessionID string, action RoomAction) error {
+	member, err := s.memberRepo.FindBySessionAndRoom(sessionID, roomID)
+	if err != nil {
+		return model.ErrNotRoomMember
+	}
+	if member.Role == model.RoleHost {
+		return nil
+	}
+
 	room, err := s.GetRoom(roomID)
 	if err != nil {
 		return err
 	}
+	return Permit(member, room.GetPermissions(), action)
+}
 
-	// 更新播放状态
-	updates := map[string]interface{}{
-		"current_time": currentTime,
-		"version":      room.Version + 1,
-		"updated_at":   time.Now(),
+// Permit 按成员身份和房间权限设置判断能否执行操作，不访问存储（供已缓存成员信息的实时连接使用）
+func Permit(member *model.RoomMember, permissions model.RoomPermissions, action RoomAction) error {
+	if member == nil {
+		return model.ErrNotRoomMember
+	}
+	if member.Role == model.RoleHost {
+		return nil
 	}
 
-	err = s.roomRepo.UpdatePlaybackState(roomID, updates)
+	switch action {
+	case ActionControlPlayback, ActionEditPlaylist:
+		if permissions.HostOnlyControl {
+			return fmt.Errorf("%w: only the host controls playback", model.ErrPermissionDenied)
+		}
+	case ActionSeek:
+		if permissions.HostOnlyControl || !permissions.AllowSeek {
+			return fmt.Errorf("%w: seeking is disabled for members", model.ErrPermissionDenied)
+		}
+	case ActionChat:
+		if !permissions.ChatEnabled {
+			return fmt.Errorf("%w: chat is disabled", model.ErrPermissionDenied)
+		}
+		if member.IsMuted {
+			return model.ErrMemberMuted
+		}
+	case ActionJoinCall:
+		if !permissions.CallEnabled {
+			return fmt.Errorf("%w: calls are disabled for members", model.ErrPermissionDenied)
+		}
+		if member.IsMuted {
+			return model.ErrMemberMuted
+		}
+	default:
+		return fmt.Errorf("%w: host only", model.ErrPermissionDenied)
+	}
+
+	return nil
+}
+
+// PlayVideo 播放视频
+func (s *RoomService) PlayVideo(roomID string) error {
+	_, err := s.ApplyPlayback(roomID, PlaybackCommand{Action: PlaybackActionPlay})
+	return err
+}
+
+// StartCountdown 倒计时开播：seconds 秒后所有成员同时从 position（为空时沿用当前位置）开始播放
+func (s *RoomService) StartCountdown(roomID string, seconds int, position *float64) (*model.Room, error) {
+	return s.ApplyPlayback(roomID, PlaybackCommand{
+		Action:    PlaybackActionCountdown,
+		Position:  position,
+		ExecuteAt: time.Now().Add(time.Duration(seconds) * time.Second).UnixMilli(),
+	})
+}
+
+// PauseVideo 暂停视频
+func (s *RoomService) PauseVideo(roomID string) error {
+	_, err := s.ApplyPlayback(roomID, PlaybackCommand{Action: PlaybackActionPause})
+	return err
+}
+
+// SeekVideo 视频跳转
+func (s *RoomService) SeekVideo(roomID string, currentTime float64) error {
+	_, err := s.ApplyPlayback(roomID, PlaybackCommand{Action: PlaybackActionSeek, Position: &currentTime})
 	return err
 }
 
@@ -240,19 +654,6 @@ func (s *RoomService) GetPlaybackStatus(roomID string) (map[string]interface{},
 	return room.GetPlaybackState(), nil
 }
 
-// convertSettings 转换设置格式
-func (s *RoomService) convertSettings(settings interface{}) model.JSON {
-	// 使用默认设置
-	return model.JSON(`{
-		"auto_play":      true,
-		"allow_seek":     true,
-		"default_volume": 1.0,
-		"quality":        "auto",
-		"subtitle_on":    false,
-		"subtitle_lang":  "zh"
-	}`)
-}
-
 // getDefaultPlaybackState 获取默认播放状态
 func (s *RoomService) getDefaultPlaybackState() model.JSON {
 	jsonStr := `{
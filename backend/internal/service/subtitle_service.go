@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/repository"
+	"xiaowo/backend/internal/subtitle"
+)
+
+// 字幕限制
+const (
+	DefaultSubtitleMaxSize = 2 << 20
+	MaxSubtitleOffset      = 3600 // 时间偏移的最大绝对值（秒）
+	maxSubtitleLabelLen    = 64
+	maxSubtitleLangLen     = 35 // BCP 47 语言标签的常见上限
+)
+
+// SubtitleListener 字幕轨道变更回调
+type SubtitleListener func(tracks *model.SubtitleTracks)
+
+// SubtitleOptions 字幕服务配置
+type SubtitleOptions struct {
+	Client  *http.Client // 按地址下载字幕的客户端（为空时使用默认客户端），应拒绝内网地址
+	MaxSize int64        // 单个字幕文件的最大字节数（为空时使用 DefaultSubtitleMaxSize）
+}
+
+// AddSubtitleRequest 添加字幕请求，URL 和 Data 二选一
+type AddSubtitleRequest struct {
+	URL      string  // 字幕地址
+	Filename string  // 上传的文件名，用于判断格式和默认名称
+	Data     []byte  // 上传的字幕内容
+	Label    string  // 显示名称，为空时取文件名
+	Lang     string  // 语言标签
+	Offset   float64 // 时间偏移（秒）
+}
+
+// SubtitleService 房间字幕业务逻辑服务
+//
+// 字幕在添加时统一转换为 WebVTT 保存，时间偏移在读取时应用，调整偏移不需要重新解析原文件。
+// 房间同一时间只显示一条字幕（房间的 subtitle_id），添加第一条字幕时自动选中。
+type SubtitleService struct {
+	subtitleRepo repository.SubtitleRepository
+	roomRepo     repository.RoomRepository
+	client       *http.Client
+	maxSize      int64
+	onChange     SubtitleListener
+}
+
+// NewSubtitleService 创建字幕服务
+func NewSubtitleService(subtitleRepo repository.SubtitleRepository, roomRepo repository.RoomRepository, opts SubtitleOptions) *SubtitleService {
+	s := &SubtitleService{
+		subtitleRepo: subtitleRepo,
+		roomRepo:     roomRepo,
+		client:       opts.Client,
+		maxSize:      opts.MaxSize,
+	}
+	if s.client == nil {
+		s.client = http.DefaultClient
+	}
+	if s.maxSize <= 0 {
+		s.maxSize = DefaultSubtitleMaxSize
+	}
+	return s
+}
+
+// MaxSize 单个字幕文件的最大字节数
+func (s *SubtitleService) MaxSize() int64 {
+	return s.maxSize
+}
+
+// OnSubtitleChange 注册字幕轨道变更回调（用于向实时连接广播），需在启动阶段设置
+func (s *SubtitleService) OnSubtitleChange(listener SubtitleListener) {
+	s.onChange = listener
+}
+
+// GetTracks 获取房间的字幕轨道和当前选中的轨道
+func (s *SubtitleService) GetTracks(roomID string) (*model.SubtitleTracks, error) {
+	room, err := s.roomRepo.GetByID(roomID)
+	if err != nil {
+		return nil, err
+	}
+	tracks, err := s.subtitleRepo.ListByRoom(roomID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.SubtitleTracks{RoomID: roomID, Tracks: tracks, CurrentID: room.SubtitleID}, nil
+}
+
+// AddSubtitle 添加字幕轨道，按地址下载或使用上传的内容，转换为 WebVTT 保存
+func (s *SubtitleService) AddSubtitle(ctx context.Context, roomID, sessionID string, req *AddSubtitleRequest) (*model.Subtitle, error) {
+	if err := checkSubtitleOffset(req.Offset); err != nil {
+		return nil, err
+	}
+	lang := strings.TrimSpace(req.Lang)
+	if len(lang) > maxSubtitleLangLen {
+		return nil, fmt.Errorf("%w: lang too long", model.ErrInvalidSubtitle)
+	}
+
+	data, filename := req.Data, req.Filename
+	if req.URL != "" {
+		if err := s.roomRepo.ValidateMediaURL(req.URL); err != nil {
+			return nil, fmt.Errorf("%w: url must be http or https", model.ErrInvalidSubtitle)
+		}
+		var err error
+		if data, err = s.fetch(ctx, req.URL); err != nil {
+			return nil, err
+		}
+		if u, err := url.Parse(req.URL); err == nil {
+			filename = path.Base(u.Path)
+		}
+	}
+	if int64(len(data)) > s.maxSize {
+		return nil, fmt.Errorf("%w: max %d bytes", model.ErrSubtitleTooLarge, s.maxSize)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: empty file", model.ErrInvalidSubtitle)
+	}
+
+	format := subtitle.DetectFormat(filename, data)
+	cues, err := subtitle.Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", model.ErrInvalidSubtitle, err)
+	}
+
+	label := strings.TrimSpace(req.Label)
+	if label == "" {
+		label = strings.TrimSuffix(filename, path.Ext(filename))
+	}
+	if label == "" || label == "." || label == "/" {
+		label = "字幕"
+	}
+	if utf8.RuneCountInString(label) > maxSubtitleLabelLen {
+		label = string([]rune(label)[:maxSubtitleLabelLen])
+	}
+
+	track := &model.Subtitle{
+		RoomID:    roomID,
+		Label:     label,
+		Lang:      lang,
+		Format:    format,
+		SourceURL: req.URL,
+		Offset:    req.Offset,
+		Content:   string(subtitle.WriteVTT(cues, 0)),
+		AddedBy:   sessionID,
+	}
+	if err := s.subtitleRepo.Add(track); err != nil {
+		return nil, err
+	}
+
+	room, err := s.roomRepo.GetByID(roomID)
+	if err != nil {
+		return nil, err
+	}
+	if room.SubtitleID == "" {
+		if err := s.subtitleRepo.Select(roomID, track.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	track.Content = ""
+	return track, s.changed(roomID)
+}
+
+// VTT 返回应用了时间偏移的 WebVTT 内容
+func (s *SubtitleService) VTT(roomID, subtitleID string) ([]byte, error) {
+	track, err := s.subtitleRepo.GetByID(roomID, subtitleID)
+	if err != nil {
+		return nil, err
+	}
+	if track.Offset == 0 {
+		return []byte(track.Content), nil
+	}
+	cues, err := subtitle.Parse([]byte(track.Content), subtitle.FormatVTT)
+	if err != nil {
+		return nil, fmt.Errorf("stored subtitle %s is corrupt: %w", subtitleID, err)
+	}
+	return subtitle.WriteVTT(cues, time.Duration(track.Offset*float64(time.Second))), nil
+}
+
+// SetOffset 调整字幕轨道的时间偏移
+func (s *SubtitleService) SetOffset(roomID, subtitleID string, offset float64) error {
+	if err := checkSubtitleOffset(offset); err != nil {
+		return err
+	}
+	if err := s.subtitleRepo.UpdateOffset(roomID, subtitleID, offset); err != nil {
+		return err
+	}
+	return s.changed(roomID)
+}
+
+// RemoveSubtitle 删除字幕轨道，删除的是当前轨道时关闭字幕
+func (s *SubtitleService) RemoveSubtitle(roomID, subtitleID string) error {
+	if err := s.subtitleRepo.Remove(roomID, subtitleID); err != nil {
+		return err
+	}
+	return s.changed(roomID)
+}
+
+// SelectSubtitle 切换房间显示的字幕轨道，为空时关闭字幕
+func (s *SubtitleService) SelectSubtitle(roomID, subtitleID string) error {
+	if err := s.subtitleRepo.Select(roomID, subtitleID); err != nil {
+		return err
+	}
+	return s.changed(roomID)
+}
+
+// fetch 下载字幕文件
+func (s *SubtitleService) fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", model.ErrInvalidSubtitle, err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", model.ErrSubtitleFetch, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: HTTP %d", model.ErrSubtitleFetch, resp.StatusCode)
+	}
+	if resp.ContentLength > s.maxSize {
+		return nil, fmt.Errorf("%w: max %d bytes", model.ErrSubtitleTooLarge, s.maxSize)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, s.maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", model.ErrSubtitleFetch, err)
+	}
+	return data, nil
+}
+
+// changed 重新加载字幕轨道并通知回调
+func (s *SubtitleService) changed(roomID string) error {
+	if s.onChange == nil {
+		return nil
+	}
+	tracks, err := s.GetTracks(roomID)
+	if err != nil {
+		return err
+	}
+	s.onChange(tracks)
+	return nil
+}
+
+// checkSubtitleOffset 校验时间偏移
+func checkSubtitleOffset(offset float64) error {
+	if math.IsNaN(offset) || math.Abs(offset) > MaxSubtitleOffset {
+		return fmt.Errorf("%w: offset must be within ±%d seconds", model.ErrInvalidSubtitle, MaxSubtitleOffset)
+	}
+	return nil
+}
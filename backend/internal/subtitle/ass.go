@@ -0,0 +1,82 @@
+package subtitle
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// assOverride 匹配 ASS 的样式覆盖代码 {\b1}、{\pos(10,20)} 等
+var assOverride = regexp.MustCompile(`\{[^}]*\}`)
+
+// parseASS 解析 ASS/SSA 的 [Events] 段中的 Dialogue 行
+//
+// 字段顺序由 Format 行给出，Text 是最后一个字段，本身可以包含逗号。样式和特效被忽略。
+func parseASS(text string) ([]Cue, error) {
+	var cues []Cue
+	var format []string
+	inEvents := false
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inEvents = strings.EqualFold(line, "[Events]")
+			continue
+		}
+		if !inEvents {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "Format":
+			format = nil
+			for _, field := range strings.Split(value, ",") {
+				format = append(format, strings.ToLower(strings.TrimSpace(field)))
+			}
+		case "Dialogue":
+			if format == nil {
+				return nil, fmt.Errorf("%w: Dialogue before Format in [Events]", ErrInvalidSubtitle)
+			}
+			if cue, ok := assDialogue(format, value); ok {
+				cues = append(cues, cue)
+			}
+		}
+	}
+	return cues, nil
+}
+
+// assDialogue 按 Format 字段解析一行 Dialogue
+func assDialogue(format []string, value string) (Cue, bool) {
+	fields := strings.SplitN(strings.TrimSpace(value), ",", len(format))
+	if len(fields) != len(format) {
+		return Cue{}, false
+	}
+
+	var cue Cue
+	var okStart, okEnd bool
+	for i, name := range format {
+		switch name {
+		case "start":
+			cue.Start, okStart = parseTimestamp(strings.TrimSpace(fields[i]))
+		case "end":
+			cue.End, okEnd = parseTimestamp(strings.TrimSpace(fields[i]))
+		case "text":
+			cue.Text = assText(fields[i])
+		}
+	}
+	if !okStart || !okEnd || cue.End <= cue.Start || cue.Text == "" {
+		return Cue{}, false
+	}
+	return cue, true
+}
+
+// assText 去掉样式覆盖代码，\N、\n 换行，\h 为不换行空格
+func assText(text string) string {
+	text = assOverride.ReplaceAllString(text, "")
+	text = strings.NewReplacer(`\N`, "\n", `\n`, "\n", `\h`, " ").Replace(text)
+	return strings.TrimSpace(escape(text))
+}
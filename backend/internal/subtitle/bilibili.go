@@ -0,0 +1,37 @@
+package subtitle
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// bilibiliSubtitle Bilibili 的 JSON 字幕
+type bilibiliSubtitle struct {
+	Body []struct {
+		From    float64 `json:"from"`
+		To      float64 `json:"to"`
+		Content string  `json:"content"`
+	} `json:"body"`
+}
+
+// parseBilibili 解析 Bilibili 的 JSON 字幕，时间为秒
+func parseBilibili(data []byte) ([]Cue, error) {
+	var subtitle bilibiliSubtitle
+	if err := json.Unmarshal(data, &subtitle); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSubtitle, err)
+	}
+
+	cues := make([]Cue, 0, len(subtitle.Body))
+	for _, line := range subtitle.Body {
+		start := time.Duration(line.From * float64(time.Second)).Round(time.Millisecond)
+		end := time.Duration(line.To * float64(time.Second)).Round(time.Millisecond)
+		text := strings.TrimSpace(line.Content)
+		if start < 0 || end <= start || text == "" {
+			continue
+		}
+		cues = append(cues, Cue{Start: start, End: end, Text: escape(text)})
+	}
+	return cues, nil
+}
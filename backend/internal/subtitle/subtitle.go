@@ -0,0 +1,243 @@
+package subtitle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ErrInvalidSubtitle 字幕内容无法解析
+var ErrInvalidSubtitle = errors.New("invalid subtitle")
+
+// 支持的字幕格式
+const (
+	FormatSRT      = "srt"
+	FormatVTT      = "vtt"
+	FormatASS      = "ass"      // 同时支持 SSA
+	FormatBilibili = "bilibili" // Bilibili 的 JSON 字幕（body[].from/to/content）
+)
+
+// Cue 一条字幕
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string // WebVTT 文本，可包含 <i>、<b>、<u> 标签
+}
+
+// DetectFormat 按文件扩展名判断格式，无法判断时按内容识别
+func DetectFormat(filename string, data []byte) string {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".srt":
+		return FormatSRT
+	case ".vtt":
+		return FormatVTT
+	case ".ass", ".ssa":
+		return FormatASS
+	}
+
+	head := strings.TrimSpace(string(stripBOM(data[:min(len(data), 512)])))
+	switch {
+	case strings.HasPrefix(head, "WEBVTT"):
+		return FormatVTT
+	case strings.HasPrefix(head, "[Script Info]"):
+		return FormatASS
+	case strings.HasPrefix(head, "{"):
+		return FormatBilibili
+	}
+	return FormatSRT
+}
+
+// Parse 解析字幕，返回按开始时间排列的字幕条目
+func Parse(data []byte, format string) ([]Cue, error) {
+	text, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var cues []Cue
+	switch format {
+	case FormatSRT:
+		cues = parseBlocks(text, false)
+	case FormatVTT:
+		if !strings.HasPrefix(text, "WEBVTT") {
+			return nil, fmt.Errorf("%w: missing WEBVTT header", ErrInvalidSubtitle)
+		}
+		cues = parseBlocks(text, true)
+	case FormatASS:
+		cues, err = parseASS(text)
+	case FormatBilibili:
+		cues, err = parseBilibili([]byte(text))
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidSubtitle, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(cues) == 0 {
+		return nil, fmt.Errorf("%w: no cues found", ErrInvalidSubtitle)
+	}
+
+	sortCues(cues)
+	return cues, nil
+}
+
+// WriteVTT 输出 WebVTT，offset 为正时字幕延后显示；平移后完全落在 0 之前的字幕被丢弃
+func WriteVTT(cues []Cue, offset time.Duration) []byte {
+	var out bytes.Buffer
+	out.WriteString("WEBVTT\n\n")
+	for _, cue := range cues {
+		start, end := cue.Start+offset, cue.End+offset
+		if end <= 0 {
+			continue
+		}
+		if start < 0 {
+			start = 0
+		}
+		fmt.Fprintf(&out, "%s --> %s\n%s\n\n", formatTimestamp(start), formatTimestamp(end), cue.Text)
+	}
+	return out.Bytes()
+}
+
+// decode 转为 UTF-8 文本并统一换行符，支持带 BOM 的 UTF-16
+func decode(data []byte) (string, error) {
+	if len(data) >= 2 && ((data[0] == 0xFF && data[1] == 0xFE) || (data[0] == 0xFE && data[1] == 0xFF)) {
+		littleEndian := data[0] == 0xFF
+		data = data[2:]
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			if littleEndian {
+				units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+			} else {
+				units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+			}
+		}
+		data = []byte(string(utf16.Decode(units)))
+	}
+
+	data = stripBOM(data)
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("%w: must be UTF-8 or UTF-16 encoded", ErrInvalidSubtitle)
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n"), nil
+}
+
+func stripBOM(data []byte) []byte {
+	return bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+}
+
+// blankLine 匹配只有空白字符的行，视为块之间的空行
+var blankLine = regexp.MustCompile(`(?m)^[ \t]+$`)
+
+// parseBlocks 解析 SRT 和 WebVTT：以空行分隔的块，含 "-->" 的行为时间轴，其后为文本
+//
+// WebVTT 的 NOTE、STYLE、REGION 块和时间轴后的设置被忽略。
+func parseBlocks(text string, vtt bool) []Cue {
+	var cues []Cue
+	text = blankLine.ReplaceAllString(text, "")
+	for _, block := range strings.Split(text, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		timing := -1
+		for i, line := range lines {
+			if strings.Contains(line, "-->") {
+				timing = i
+				break
+			}
+		}
+		if timing < 0 || timing > 1 {
+			continue
+		}
+
+		fields := strings.Fields(strings.Replace(lines[timing], "-->", " --> ", 1))
+		if len(fields) < 3 || fields[1] != "-->" {
+			continue
+		}
+		start, ok1 := parseTimestamp(fields[0])
+		end, ok2 := parseTimestamp(fields[2])
+		if !ok1 || !ok2 || end <= start {
+			continue
+		}
+
+		body := strings.TrimSpace(strings.Join(lines[timing+1:], "\n"))
+		if body == "" {
+			continue
+		}
+		if vtt {
+			body = strings.ReplaceAll(body, "-->", "->")
+		} else {
+			body = srtText(body)
+		}
+		cues = append(cues, Cue{Start: start, End: end, Text: body})
+	}
+	return cues
+}
+
+// parseTimestamp 解析 HH:MM:SS,mmm、HH:MM:SS.mmm 和 MM:SS.mmm
+func parseTimestamp(s string) (time.Duration, bool) {
+	parts := strings.Split(strings.Replace(s, ",", ".", 1), ":")
+	if len(parts) == 2 {
+		parts = append([]string{"0"}, parts...)
+	}
+	if len(parts) != 3 {
+		return 0, false
+	}
+
+	hours, err1 := strconv.Atoi(parts[0])
+	minutes, err2 := strconv.Atoi(parts[1])
+	seconds, err3 := strconv.ParseFloat(parts[2], 64)
+	if err1 != nil || err2 != nil || err3 != nil || hours < 0 || minutes < 0 || minutes >= 60 || seconds < 0 || seconds >= 60 {
+		return 0, false
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute +
+		time.Duration(seconds*float64(time.Second)).Round(time.Millisecond), true
+}
+
+// formatTimestamp 输出 WebVTT 时间戳 HH:MM:SS.mmm
+func formatTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// srtTag 匹配 SRT 中的 HTML 风格标签
+var srtTag = regexp.MustCompile(`</?([a-zA-Z]+)[^>]*>`)
+
+// srtText 保留 WebVTT 支持的 <i>、<b>、<u>，去掉 <font> 等其他标签并转义其余的 & 和 <
+func srtText(text string) string {
+	var out strings.Builder
+	last := 0
+	for _, loc := range srtTag.FindAllStringSubmatchIndex(text, -1) {
+		out.WriteString(escape(text[last:loc[0]]))
+		switch strings.ToLower(text[loc[2]:loc[3]]) {
+		case "i", "b", "u":
+			tag := strings.ToLower(text[loc[0]:loc[1]])
+			if strings.HasPrefix(tag, "</") {
+				out.WriteString("</" + tag[2:3] + ">")
+			} else {
+				out.WriteString("<" + tag[1:2] + ">")
+			}
+		}
+		last = loc[1]
+	}
+	out.WriteString(escape(text[last:]))
+	return out.String()
+}
+
+// escape 转义 WebVTT 文本中的特殊字符
+func escape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// sortCues 按开始时间稳定排序
+func sortCues(cues []Cue) {
+	sort.SliceStable(cues, func(i, j int) bool {
+		return cues[i].Start < cues[j].Start
+	})
+}
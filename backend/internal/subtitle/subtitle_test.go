@@ -0,0 +1,124 @@
+package subtitle
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// convert 解析字幕并输出 WebVTT
+func convert(t *testing.T, filename, data string, offset time.Duration) string {
+	t.Helper()
+	cues, err := Parse([]byte(data), DetectFormat(filename, []byte(data)))
+	if err != nil {
+		t.Fatalf("解析 %s 失败: %v", filename, err)
+	}
+	return string(WriteVTT(cues, offset))
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		filename string
+		data     string
+		want     string
+	}{
+		{"a.SRT", "", FormatSRT},
+		{"a.ssa", "", FormatASS},
+		{"", "\xEF\xBB\xBFWEBVTT\n", FormatVTT},
+		{"download", "[Script Info]\n", FormatASS},
+		{"download", `{"body":[]}`, FormatBilibili},
+		{"download", "1\n00:00:01,000 --> 00:00:02,000\nhi\n", FormatSRT},
+	}
+	for _, tt := range tests {
+		if got := DetectFormat(tt.filename, []byte(tt.data)); got != tt.want {
+			t.Errorf("DetectFormat(%q, %q) = %s, want %s", tt.filename, tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		data     string
+		want     string
+	}{
+		{
+			name:     "SRT",
+			filename: "a.srt",
+			data:     "2\r\n00:00:03,000 --> 00:00:04,000\r\nB & <b>C</b>\r\n\r\n1\r\n00:00:01,500 --> 00:00:02,000\r\n<font color=\"red\">A</font>\r\n  \r\n",
+			want:     "WEBVTT\n\n00:00:01.500 --> 00:00:02.000\nA\n\n00:00:03.000 --> 00:00:04.000\nB &amp; <b>C</b>\n\n",
+		},
+		{
+			name:     "VTT",
+			filename: "a.vtt",
+			data:     "WEBVTT\n\nNOTE comment\n\nintro\n01:02.000 --> 01:03.000 align:start\nLine\nTwo\n",
+			want:     "WEBVTT\n\n00:01:02.000 --> 00:01:03.000\nLine\nTwo\n\n",
+		},
+		{
+			name:     "ASS",
+			filename: "a.ass",
+			data:     "[Script Info]\nTitle: x\n\n[Events]\nFormat: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\nComment: 0,0:00:00.00,0:00:01.00,Default,,0,0,0,,skip\nDialogue: 0,0:00:01.50,0:00:02.00,Default,,0,0,0,,{\\i1}a, b{\\i0}\\Nc\n",
+			want:     "WEBVTT\n\n00:00:01.500 --> 00:00:02.000\na, b\nc\n\n",
+		},
+		{
+			name:     "Bilibili",
+			filename: "a.json",
+			data:     `{"body":[{"from":1.25,"to":2,"content":"x<y"},{"from":3,"to":3,"content":"empty"}]}`,
+			want:     "WEBVTT\n\n00:00:01.250 --> 00:00:02.000\nx&lt;y\n\n",
+		},
+		{
+			name:     "UTF-16",
+			filename: "a.srt",
+			data:     "\xFF\xFE1\x00\n\x000\x000\x00:\x000\x000\x00:\x000\x001\x00,\x000\x000\x000\x00 \x00-\x00-\x00>\x00 \x000\x000\x00:\x000\x000\x00:\x000\x002\x00,\x000\x000\x000\x00\n\x00`O}Y\n\x00",
+			want:     "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\n你好\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := convert(t, tt.filename, tt.data, 0); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteVTTOffset(t *testing.T) {
+	cues := []Cue{
+		{Start: time.Second, End: 2 * time.Second, Text: "a"},
+		{Start: 3 * time.Second, End: 4 * time.Second, Text: "b"},
+	}
+
+	got := string(WriteVTT(cues, -2500*time.Millisecond))
+	want := "WEBVTT\n\n00:00:00.500 --> 00:00:01.500\nb\n\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	got = string(WriteVTT(cues, time.Hour))
+	if !strings.Contains(got, "01:00:01.000 --> 01:00:02.000\na") {
+		t.Errorf("延后一小时结果不符:\n%s", got)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format string
+	}{
+		{"NoCues", "hello world", FormatSRT},
+		{"MissingHeader", "00:01.000 --> 00:02.000\nx\n", FormatVTT},
+		{"BadJSON", "{", FormatBilibili},
+		{"NotUTF8", "1\n00:00:01,000 --> 00:00:02,000\n\xC4\xE3\n", FormatSRT},
+		{"Unknown", "x", "sub"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.data), tt.format); !errors.Is(err, ErrInvalidSubtitle) {
+				t.Errorf("期望 ErrInvalidSubtitle，实际: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,866 @@
+package syncplay
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/password"
+	"xiaowo/backend/internal/service"
+	"xiaowo/backend/internal/websocket"
+)
+
+const (
+	writeTimeout = 10 * time.Second
+	inboxSize    = 64
+	maxFileInfo  = 2 << 10 // Set file 中文件信息的最大字节数
+)
+
+// emptyFile 未打开文件的用户在 List 中的文件信息
+var emptyFile = json.RawMessage(`{}`)
+
+// conn 一个 Syncplay 客户端连接
+//
+// 登录前在连接协程中同步处理（TLS 协商需要在读取下一行之前升级连接），登录后由 run 的主循环
+// 串行处理客户端消息、房间消息和周期状态，房间状态和协议状态只在该协程中读写，写出也只在该协程中进行。
+// 其他连接只通过 deliver 向 inbox 投递消息，并在 mu 保护下读取会话、房间和文件信息。
+type conn struct {
+	server *Server
+	nc     net.Conn // TLS 协商后替换为加密连接，由 mu 保护
+	reader *bufio.Scanner
+	ip     string
+
+	inbox    chan []byte   // 其他连接转发的消息
+	done     chan struct{} // 连接断开时关闭
+	dropOnce sync.Once
+
+	// 登录信息
+	username string
+	password string
+	client   *websocket.Client
+	release  func() // 离开房间时调用，见 Authenticator
+
+	mu        sync.Mutex
+	roomID    string
+	sessionID string
+	file      json.RawMessage
+	features  map[string]interface{}
+
+	// 房间状态（仅在主循环中读写）
+	state    websocket.PlaybackState
+	playlist *model.Playlist
+	files    []string          // 最近下发的共享播放列表
+	index    int               // 最近下发的播放列表位置
+	names    map[string]string // 会话ID → 昵称
+	start    *time.Timer       // 预约开播生效时下发状态
+	pending  string            // 本连接发出、尚未收到广播的播放控制类型
+	selected bool              // 本连接切换了播放列表条目，尚未收到广播
+
+	// 协议状态（仅在主循环中读写）
+	ping            pingService
+	serverIgnoring  int
+	clientIgnoring  int
+	clientLatency   float64
+	clientLatencyAt time.Time
+}
+
+// hubMessage 房间下发的消息中 Syncplay 需要的字段
+type hubMessage struct {
+	Type         string                   `json:"type"`
+	State        *websocket.PlaybackState `json:"state"`    // room_state
+	Playlist     *model.Playlist          `json:"playlist"` // room_state、playlist
+	CurrentTime  float64                  `json:"current_time"`
+	IsPlaying    bool                     `json:"is_playing"`
+	PlaybackRate float64                  `json:"playback_rate"`
+	LastUpdated  int64                    `json:"last_updated"`
+	VideoURL     string                   `json:"video_url"`   // change_media
+	VideoTitle   string                   `json:"video_title"` // change_media
+	Duration     float64                  `json:"duration"`    // change_media
+	SessionID    string                   `json:"session_id"`
+	DisplayName  string                   `json:"display_name"` // chat
+	Message      string                   `json:"message"`      // chat、error
+	Code         string                   `json:"code"`         // error
+}
+
+// newConn 创建连接
+func newConn(s *Server, nc net.Conn) *conn {
+	ip, _, err := net.SplitHostPort(nc.RemoteAddr().String())
+	if err != nil {
+		ip = nc.RemoteAddr().String()
+	}
+	return &conn{
+		server: s,
+		nc:     nc,
+		reader: newScanner(nc),
+		ip:     ip,
+		inbox:  make(chan []byte, inboxSize),
+		done:   make(chan struct{}),
+		index:  -1,
+	}
+}
+
+// newScanner 按行读取，兼容 \r\n 和 \n 分隔
+func newScanner(nc net.Conn) *bufio.Scanner {
+	scanner := bufio.NewScanner(nc)
+	scanner.Buffer(make([]byte, 4096), maxLineSize)
+	return scanner
+}
+
+// serve 连接协程：登录后进入主循环，退出时离开房间并断开
+func (c *conn) serve() {
+	defer c.server.untrack(c)
+	defer c.drop()
+
+	if !c.login() {
+		return
+	}
+	defer c.leaveRoom()
+	c.run()
+}
+
+// drop 断开连接，可在任意协程中重复调用
+func (c *conn) drop() {
+	c.dropOnce.Do(func() {
+		close(c.done)
+		c.mu.Lock()
+		c.nc.Close()
+		c.mu.Unlock()
+	})
+}
+
+// fail 回复错误并断开（客户端收到 Error 后会自行断开）
+func (c *conn) fail(message string) {
+	log.Printf("syncplay: drop %s: %s", c.ip, message)
+	c.send("Error", map[string]string{"message": message})
+	c.drop()
+}
+
+// login 处理登录前的 TLS 协商和 Hello，成功进入房间后返回 true
+func (c *conn) login() bool {
+	for {
+		line, ok := c.readLine()
+		if !ok {
+			return false
+		}
+
+		var msg map[string]json.RawMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			c.fail("无法解析的消息")
+			return false
+		}
+		switch {
+		case msg["TLS"] != nil:
+			if !c.handleTLS(msg["TLS"]) {
+				return false
+			}
+		case msg["Hello"] != nil:
+			return c.handleHello(msg["Hello"])
+		default:
+			c.fail("请先发送 Hello 登录")
+			return false
+		}
+	}
+}
+
+// handleTLS 处理 TLS 协商：配置了证书时回复 true 并升级为加密连接，否则回复 false 继续明文通信
+func (c *conn) handleTLS(raw json.RawMessage) bool {
+	var req struct {
+		StartTLS string `json:"startTLS"`
+	}
+	if err := json.Unmarshal(raw, &req); err != nil || req.StartTLS != "send" {
+		return true
+	}
+
+	config := c.server.opts.TLS
+	if config == nil {
+		return c.send("TLS", map[string]string{"startTLS": "false"})
+	}
+	if !c.send("TLS", map[string]string{"startTLS": "true"}) {
+		return false
+	}
+
+	// 客户端收到回复后才开始握手，此时读缓冲中没有未处理的明文
+	tc := tls.Server(c.nc, config)
+	tc.SetDeadline(time.Now().Add(protocolTimeout))
+	if err := tc.Handshake(); err != nil {
+		log.Printf("syncplay: TLS handshake with %s: %v", c.ip, err)
+		return false
+	}
+	tc.SetDeadline(time.Time{})
+
+	c.mu.Lock()
+	c.nc = tc
+	c.mu.Unlock()
+	c.reader = newScanner(tc)
+	return true
+}
+
+// handleHello 校验凭据并进入房间，回复 Hello
+func (c *conn) handleHello(raw json.RawMessage) bool {
+	var hello helloMessage
+	if err := json.Unmarshal(raw, &hello); err != nil {
+		c.fail("Hello 消息格式错误")
+		return false
+	}
+	roomID, roomToken := "", ""
+	if hello.Room != nil {
+		roomID, roomToken = splitRoomName(hello.Room.Name)
+	}
+	version := hello.RealVersion
+	if version == "" {
+		version = hello.Version
+	}
+	c.username = truncate(strings.TrimSpace(hello.Username), maxUsernameLength)
+	if c.username == "" || roomID == "" || version == "" {
+		c.fail("Hello 消息缺少用户名、房间或版本")
+		return false
+	}
+	c.password = hello.Password
+	c.features = hello.Features
+
+	client, release, err := c.authenticate(roomID, roomToken)
+	if err != nil {
+		c.fail(joinError(err))
+		return false
+	}
+	// 以令牌登录时沿用成员在房间内的昵称
+	if member, err := c.server.opts.Members.GetMember(roomID, client.SessionID()); err == nil && member.Nickname != "" {
+		c.username = member.Nickname
+	}
+	c.enterRoom(client, release)
+
+	if !c.send("Hello", helloMessage{
+		Username:    c.username,
+		Room:        &roomName{Name: roomID},
+		Version:     hello.Version,
+		RealVersion: ProtocolVersion,
+		Features:    serverFeatures(),
+		MOTD:        c.server.opts.MOTD,
+	}) {
+		c.leaveRoom()
+		return false
+	}
+	return true
+}
+
+// splitRoomName 拆分 Syncplay 房间名为房间ID和可选的房间访问令牌（"房间ID:令牌"）
+//
+// Syncplay 客户端会对密码做 MD5，令牌只能放在原样发送的房间名中。
+func splitRoomName(name string) (roomID, roomToken string) {
+	roomID, roomToken, _ = strings.Cut(strings.TrimSpace(name), ":")
+	return strings.TrimSpace(roomID), strings.TrimSpace(roomToken)
+}
+
+// authenticate 校验凭据并接入房间，返回离开房间时需要调用的 release（可为空）
+func (c *conn) authenticate(roomID, roomToken string) (*websocket.Client, func(), error) {
+	if c.server.opts.Authenticator == nil {
+		return nil, nil, errors.New("syncplay: no authenticator configured")
+	}
+	sessionID, release, err := c.server.opts.Authenticator(Credentials{
+		RoomID:   roomID,
+		Token:    roomToken,
+		Password: c.password,
+		Username: c.username,
+		ClientIP: c.ip,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := c.server.opts.Hub.Attach(roomID, sessionID)
+	if err != nil {
+		if release != nil {
+			release()
+		}
+		return nil, nil, err
+	}
+	return client, release, nil
+}
+
+// enterRoom 使用已接入的房间连接，重置房间状态（房间随后会下发 room_state）
+func (c *conn) enterRoom(client *websocket.Client, release func()) {
+	c.client = client
+	c.release = release
+	c.mu.Lock()
+	c.roomID = client.RoomID()
+	c.sessionID = client.SessionID()
+	c.mu.Unlock()
+	c.server.join(c, client.RoomID())
+
+	c.state = websocket.NewPlaybackState()
+	c.playlist = nil
+	c.files, c.index = nil, -1
+	c.names = make(map[string]string)
+	c.pending, c.selected = "", false
+	c.stopStart()
+}
+
+// leaveRoom 离开当前房间，登录时新建的成员随之移出房间
+func (c *conn) leaveRoom() {
+	c.stopStart()
+	c.server.leave(c, c.client.RoomID())
+	c.client.Close()
+	if c.release != nil {
+		c.release()
+		c.release = nil
+	}
+}
+
+// run 登录后的主循环
+func (c *conn) run() {
+	lines := make(chan []byte)
+	go c.readLoop(lines)
+
+	ticker := time.NewTicker(stateInterval)
+	defer ticker.Stop()
+
+	for {
+		var started <-chan time.Time
+		if c.start != nil {
+			started = c.start.C
+		}
+
+		select {
+		case line, ok := <-lines:
+			if !ok || !c.handleLine(line) {
+				return
+			}
+		case message, ok := <-c.client.Messages():
+			if !ok {
+				c.fail("已断开与房间的连接")
+				return
+			}
+			if !c.handleEvent(message) {
+				return
+			}
+		case message := <-c.inbox:
+			c.write(message)
+		case <-ticker.C:
+			c.sendState(false, false, "")
+		case <-started:
+			c.start = nil
+			c.sendState(true, false, serverName)
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// readLoop 读协程：逐行读取客户端消息，超时或出错时关闭 lines
+func (c *conn) readLoop(lines chan<- []byte) {
+	defer close(lines)
+	for {
+		line, ok := c.readLine()
+		if !ok {
+			return
+		}
+		select {
+		case lines <- line:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// readLine 读取下一行非空消息，超过协议超时时间没有消息时返回 false
+func (c *conn) readLine() ([]byte, bool) {
+	for {
+		c.nc.SetReadDeadline(time.Now().Add(protocolTimeout))
+		if !c.reader.Scan() {
+			return nil, false
+		}
+		if line := c.reader.Bytes(); len(strings.TrimSpace(string(line))) > 0 {
+			return append([]byte(nil), line...), true
+		}
+	}
+}
+
+// handleLine 处理登录后的客户端消息，需要断开时返回 false
+func (c *conn) handleLine(line []byte) bool {
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		c.fail("无法解析的消息")
+		return false
+	}
+
+	if raw, ok := msg["Error"]; ok {
+		log.Printf("syncplay: client %s reported error: %s", c.ip, raw)
+		return false
+	}
+	if raw, ok := msg["Set"]; ok && !c.handleSet(raw) {
+		return false
+	}
+	if raw, ok := msg["State"]; ok {
+		c.handleState(raw)
+	}
+	if _, ok := msg["List"]; ok {
+		c.sendList()
+	}
+	if raw, ok := msg["Chat"]; ok {
+		c.handleChat(raw)
+	}
+	return true
+}
+
+// handleSet 处理 Set：切换房间、报告文件、切换播放列表条目
+//
+// 共享播放列表由房间管理，客户端修改列表时重新下发房间的列表；准备状态和受控房间不支持，忽略。
+func (c *conn) handleSet(raw json.RawMessage) bool {
+	var set setMessage
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return true
+	}
+
+	if set.Room != nil {
+		if roomID, roomToken := splitRoomName(set.Room.Name); roomID != "" && roomID != c.client.RoomID() {
+			client, release, err := c.authenticate(roomID, roomToken)
+			if err != nil {
+				c.fail(joinError(err))
+				return false
+			}
+			c.leaveRoom()
+			c.enterRoom(client, release)
+		}
+	}
+	if set.File != nil && len(set.File) <= maxFileInfo {
+		c.setFile(set.File)
+	}
+	if set.Features != nil {
+		c.mu.Lock()
+		c.features = set.Features
+		c.mu.Unlock()
+	}
+	if set.PlaylistIndex != nil && set.PlaylistIndex.Index != nil {
+		c.selectItem(*set.PlaylistIndex.Index)
+	}
+	if set.PlaylistChange != nil {
+		c.files = nil
+		c.syncPlaylist()
+	}
+	return true
+}
+
+// setFile 记录客户端打开的文件并告知同房间的其他 Syncplay 连接
+func (c *conn) setFile(file json.RawMessage) {
+	c.mu.Lock()
+	c.file = file
+	roomID := c.roomID
+	c.mu.Unlock()
+
+	message := encode("Set", map[string]interface{}{
+		"user": map[string]interface{}{
+			c.username: map[string]interface{}{
+				"room": roomName{Name: roomID},
+				"file": file,
+			},
+		},
+	})
+	for _, peer := range c.server.peers(roomID) {
+		if peer != c {
+			peer.deliver(message)
+		}
+	}
+}
+
+// selectItem 切换到共享播放列表中的第 index 个条目
+func (c *conn) selectItem(index int) {
+	if c.playlist == nil || index < 0 || index >= len(c.playlist.Items) || index == c.index {
+		return
+	}
+	c.selected = true
+	c.hubSend(map[string]interface{}{
+		"type":    websocket.MsgTypePlaylistSet,
+		"item_id": c.playlist.Items[index].ID,
+	})
+}
+
+// handleState 处理客户端的播放状态和时延测量（与 Syncplay 服务器的 handleState 相同）
+func (c *conn) handleState(raw json.RawMessage) {
+	var msg stateMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return
+	}
+	now := time.Now()
+
+	if ignore := msg.IgnoringOnTheFly; ignore != nil {
+		if ignore.Server != 0 && ignore.Server == c.serverIgnoring {
+			c.serverIgnoring = 0
+		}
+		if ignore.Client != 0 {
+			c.clientIgnoring = ignore.Client
+		}
+	}
+	if ping := msg.Ping; ping != nil {
+		c.clientLatency, c.clientLatencyAt = ping.ClientLatencyCalculation, now
+		c.ping.receive(ping.LatencyCalculation, ping.ClientRTT, now)
+		if c.ping.rtt > 0 {
+			c.client.ReportRTT(time.Duration(c.ping.rtt * float64(time.Second)))
+		}
+	}
+	// 等待客户端确认强制状态期间，客户端上报的是旧状态
+	if c.serverIgnoring == 0 && msg.Playstate != nil {
+		c.applyPlaystate(msg.Playstate)
+	}
+}
+
+// applyPlaystate 客户端暂停、继续或拖动时转为房间的播放控制消息
+//
+// 位置按单程时延补偿到服务器收到消息的时刻，成功后由房间广播回来再下发强制状态。
+func (c *conn) applyPlaystate(ps *playstate) {
+	// 客户端还没有打开文件
+	if ps.Paused == nil {
+		return
+	}
+	paused := *ps.Paused
+	pauseChanged := paused != c.paused(time.Now())
+
+	position := ps.Position
+	if !paused {
+		position += c.ping.fd
+	}
+
+	switch {
+	case pauseChanged && paused:
+		c.control(websocket.MsgTypePause, map[string]interface{}{"type": websocket.MsgTypePause, "pause_time": position})
+	case pauseChanged:
+		c.control(websocket.MsgTypePlay, map[string]interface{}{"type": websocket.MsgTypePlay, "start_time": position})
+	case ps.DoSeek:
+		c.control(websocket.MsgTypeSeek, map[string]interface{}{"type": websocket.MsgTypeSeek, "target_time": position})
+	}
+}
+
+// control 发送播放控制消息，并记录等待广播的类型以便识别回显和被拒绝
+func (c *conn) control(action string, msg map[string]interface{}) {
+	c.pending = action
+	c.hubSend(msg)
+}
+
+// handleChat 转发聊天消息
+func (c *conn) handleChat(raw json.RawMessage) {
+	var message string
+	if err := json.Unmarshal(raw, &message); err != nil {
+		return
+	}
+	c.hubSend(map[string]interface{}{
+		"type":    websocket.MsgTypeChat,
+		"message": truncate(message, maxChatMessageLength),
+	})
+}
+
+// handleEvent 处理房间下发的消息，需要断开时返回 false
+func (c *conn) handleEvent(message []byte) bool {
+	var msg hubMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return true
+	}
+
+	switch msg.Type {
+	case "room_state":
+		if msg.State != nil {
+			c.state = *msg.State
+		}
+		c.playlist = msg.Playlist
+		c.armStart()
+		c.sendState(true, true, serverName)
+		c.syncPlaylist()
+	case websocket.MsgTypePlay, websocket.MsgTypePause, websocket.MsgTypeSeek, websocket.MsgTypeRate,
+		websocket.MsgTypeCountdown, "change_media":
+		c.applyPlayback(msg)
+	case "playlist":
+		c.playlist = msg.Playlist
+		c.selected = false
+		c.syncPlaylist()
+	case websocket.MsgTypeChat:
+		c.send("Chat", map[string]string{"username": msg.DisplayName, "message": msg.Message})
+	case "member_join", "member_leave":
+		c.announce(msg.SessionID, msg.Type == "member_join")
+	case service.MemberEventKicked:
+		if msg.SessionID == c.client.SessionID() {
+			c.fail("你已被房主移出房间")
+			return false
+		}
+	case "room_closed":
+		c.fail("房间已关闭")
+		return false
+	case websocket.MsgTypeError:
+		c.rejected(msg.Code, msg.Message)
+	}
+	return true
+}
+
+// applyPlayback 更新播放状态缓存并下发强制状态
+func (c *conn) applyPlayback(msg hubMessage) {
+	c.state.CurrentTime = msg.CurrentTime
+	c.state.IsPlaying = msg.IsPlaying
+	c.state.PlaybackRate = msg.PlaybackRate
+	c.state.LastUpdated = msg.LastUpdated
+	if msg.Type == "change_media" {
+		c.state.VideoURL = msg.VideoURL
+		c.state.VideoTitle = msg.VideoTitle
+		c.state.Duration = msg.Duration
+	}
+
+	// 本连接发起的变更以自己的名义下发，客户端不会重复跳转
+	setBy := serverName
+	if c.pending == msg.Type {
+		setBy, c.pending = c.username, ""
+	}
+	c.armStart()
+	c.sendState(true, msg.Type == websocket.MsgTypeSeek || msg.Type == "change_media", setBy)
+	if msg.Type == "change_media" {
+		c.syncPlaylist()
+	}
+}
+
+// rejected 房间拒绝了本连接的操作：恢复客户端的播放状态或播放列表位置，并以聊天消息显示原因
+func (c *conn) rejected(code, message string) {
+	if c.pending != "" && (code == "permission_denied" || code == c.pending+"_failed") {
+		c.pending = ""
+		c.sendState(true, true, serverName)
+	}
+	if c.selected && (code == "permission_denied" || code == "playlist_failed") {
+		c.selected = false
+		c.files = nil
+		c.syncPlaylist()
+	}
+	c.send("Chat", map[string]string{"username": serverName, "message": message})
+}
+
+// announce 成员上线或下线时通知客户端
+func (c *conn) announce(sessionID string, joined bool) {
+	if sessionID == "" || sessionID == c.client.SessionID() {
+		return
+	}
+	event := "left"
+	if joined {
+		event = "joined"
+	}
+	c.send("Set", map[string]interface{}{
+		"user": map[string]interface{}{
+			c.nickname(sessionID): map[string]interface{}{
+				"room":  roomName{Name: c.client.RoomID()},
+				"event": map[string]bool{event: true},
+			},
+		},
+	})
+}
+
+// sendList 回复房间内在线成员：Syncplay 成员带上各自打开的文件，浏览器成员显示房间当前的媒体
+func (c *conn) sendList() {
+	roomID := c.client.RoomID()
+	peers := make(map[string]*conn)
+	for _, peer := range c.server.peers(roomID) {
+		peer.mu.Lock()
+		peers[peer.sessionID] = peer
+		peer.mu.Unlock()
+	}
+
+	position := c.state.Position()
+	users := make(map[string]listEntry)
+	for _, sessionID := range c.server.opts.Hub.OnlineSessions(roomID) {
+		entry := listEntry{Position: position, File: c.roomFile(), IsReady: true, Features: map[string]interface{}{}}
+		if peer, ok := peers[sessionID]; ok {
+			peer.mu.Lock()
+			entry.File, entry.Features = peer.file, peer.features
+			peer.mu.Unlock()
+			if entry.File == nil {
+				entry.File = emptyFile
+			}
+		}
+		users[c.nickname(sessionID)] = entry
+	}
+	c.send("List", map[string]interface{}{roomID: users})
+}
+
+// roomFile 房间当前媒体的文件信息
+func (c *conn) roomFile() json.RawMessage {
+	if c.state.VideoURL == "" {
+		return emptyFile
+	}
+	name := c.state.VideoTitle
+	if name == "" {
+		name = path.Base(c.state.VideoURL)
+	}
+	file, _ := json.Marshal(map[string]interface{}{
+		"name":     truncate(name, maxFilenameLength),
+		"duration": c.state.Duration,
+		"size":     0,
+	})
+	return file
+}
+
+// nickname 成员在房间内的昵称，查询失败时使用会话ID前缀
+func (c *conn) nickname(sessionID string) string {
+	if name, ok := c.names[sessionID]; ok {
+		return name
+	}
+	name := sessionID
+	if len(name) > 8 {
+		name = name[:8]
+	}
+	if member, err := c.server.opts.Members.GetMember(c.client.RoomID(), sessionID); err == nil && member.Nickname != "" {
+		name = member.Nickname
+	}
+	c.names[sessionID] = name
+	return name
+}
+
+// syncPlaylist 以房间的播放列表（或当前媒体）作为共享播放列表下发，内容没有变化时不重复发送
+func (c *conn) syncPlaylist() {
+	var files []string
+	index := -1
+	if c.playlist != nil && len(c.playlist.Items) > 0 {
+		for _, item := range c.playlist.Items {
+			files = append(files, item.MediaURL)
+		}
+		index = c.playlist.IndexOf(c.playlist.CurrentID)
+	} else if c.state.VideoURL != "" {
+		files, index = []string{c.state.VideoURL}, 0
+	}
+
+	changed := c.files == nil || !slices.Equal(files, c.files)
+	if changed {
+		c.send("Set", map[string]interface{}{
+			"playlistChange": map[string]interface{}{"user": serverName, "files": append([]string{}, files...)},
+		})
+	}
+	if index >= 0 && (changed || index != c.index) {
+		c.send("Set", map[string]interface{}{
+			"playlistIndex": map[string]interface{}{"user": serverName, "index": index},
+		})
+	}
+	c.files, c.index = append([]string{}, files...), index
+}
+
+// sendState 下发播放状态（与 Syncplay 服务器的 sendState 相同）
+//
+// forced 为 true 表示状态发生了变化，客户端需要照此调整播放器；等待客户端确认期间不再下发周期状态。
+// 房间以非 1 倍速播放时 Syncplay 客户端仍按原速播放，会由客户端的追赶逻辑持续纠正。
+func (c *conn) sendState(forced, doSeek bool, setBy string) {
+	now := time.Now()
+	paused := c.paused(now)
+	msg := stateMessage{
+		Playstate: &playstate{
+			Position: c.state.PositionAt(now.UnixMilli()),
+			Paused:   &paused,
+			DoSeek:   doSeek,
+			SetBy:    setBy,
+		},
+		Ping: &pingInfo{
+			LatencyCalculation: unixSeconds(now),
+			ServerRTT:          c.ping.rtt,
+		},
+	}
+	if c.clientLatency != 0 {
+		msg.Ping.ClientLatencyCalculation = c.clientLatency + now.Sub(c.clientLatencyAt).Seconds()
+		c.clientLatency = 0
+	}
+
+	if forced {
+		c.serverIgnoring++
+	}
+	if c.serverIgnoring != 0 && !forced {
+		return
+	}
+	if c.serverIgnoring != 0 || c.clientIgnoring != 0 {
+		msg.IgnoringOnTheFly = &ignoring{Server: c.serverIgnoring, Client: c.clientIgnoring}
+		c.clientIgnoring = 0
+	}
+	c.send("State", msg)
+}
+
+// paused 客户端应处于暂停状态：房间已暂停，或预约的开播时间还没到
+func (c *conn) paused(now time.Time) bool {
+	return !c.state.IsPlaying || c.state.LastUpdated > now.UnixMilli()
+}
+
+// armStart 预约开播时设置定时器，生效时再下发开始播放的状态
+func (c *conn) armStart() {
+	c.stopStart()
+	if !c.state.IsPlaying {
+		return
+	}
+	if delay := time.Until(time.UnixMilli(c.state.LastUpdated)); delay > 0 {
+		c.start = time.NewTimer(delay)
+	}
+}
+
+// stopStart 取消预约开播定时器
+func (c *conn) stopStart() {
+	if c.start != nil {
+		c.start.Stop()
+		c.start = nil
+	}
+}
+
+// hubSend 向房间提交一条客户端消息
+func (c *conn) hubSend(msg map[string]interface{}) {
+	message, _ := json.Marshal(msg)
+	c.client.Send(message)
+}
+
+// send 编码并写出一条协议消息
+func (c *conn) send(command string, payload interface{}) bool {
+	return c.write(encode(command, payload))
+}
+
+// write 写出已编码的消息，失败时断开
+func (c *conn) write(message []byte) bool {
+	c.nc.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := c.nc.Write(message); err != nil {
+		c.drop()
+		return false
+	}
+	return true
+}
+
+// deliver 由其他连接非阻塞地投递消息，队列已满时丢弃
+func (c *conn) deliver(message []byte) {
+	select {
+	case c.inbox <- message:
+	default:
+	}
+}
+
+// encode 编码协议消息，每条消息占一行
+func encode(command string, payload interface{}) []byte {
+	data, _ := json.Marshal(map[string]interface{}{command: payload})
+	return append(data, '\r', '\n')
+}
+
+// truncate 按字符截断
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// joinError 登录或切换房间失败时显示给用户的原因
+func joinError(err error) string {
+	switch {
+	case errors.Is(err, model.ErrRoomNotFound):
+		return "房间不存在"
+	case errors.Is(err, model.ErrRoomPasswordInvalid):
+		return "房间密码错误"
+	case errors.Is(err, password.ErrLocked):
+		return "密码错误次数过多，请稍后再试"
+	case errors.Is(err, model.ErrGuestJoinDisabled):
+		return "房间已关闭新成员加入"
+	case errors.Is(err, model.ErrRoomFull):
+		return "房间已满"
+	case errors.Is(err, websocket.ErrHubClosed):
+		return "服务器正在关闭"
+	default:
+		return "加入房间失败"
+	}
+}
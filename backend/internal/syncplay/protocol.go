@@ -0,0 +1,145 @@
+package syncplay
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 协议常量，取值与 Syncplay 1.7 服务器一致
+const (
+	// ProtocolVersion 回复给客户端的服务器版本
+	ProtocolVersion = "1.7.5"
+	// protocolTimeout 超过该时间没有收到客户端消息时断开
+	protocolTimeout = 12500 * time.Millisecond
+	// stateInterval 周期下发播放状态的间隔
+	stateInterval = time.Second
+	// pingMovingAverageWeight 往返时延滑动平均的旧值权重
+	pingMovingAverageWeight = 0.85
+
+	maxLineSize          = 256 << 10 // 单行消息的最大字节数（共享播放列表可能很长）
+	maxUsernameLength    = 32
+	maxRoomNameLength    = 64
+	maxFilenameLength    = 250
+	maxChatMessageLength = 500
+)
+
+// serverName 服务器发起的变更、提示消息和播放列表使用的用户名
+const serverName = "xiaowo"
+
+// helloMessage Hello 消息，客户端登录和服务器回复共用
+type helloMessage struct {
+	Username    string                 `json:"username"`
+	Password    string                 `json:"password,omitempty"`
+	Room        *roomName              `json:"room,omitempty"`
+	Version     string                 `json:"version"`
+	RealVersion string                 `json:"realversion,omitempty"`
+	Features    map[string]interface{} `json:"features,omitempty"`
+	MOTD        string                 `json:"motd,omitempty"`
+}
+
+// roomName 房间名称（即 xiaowo 的房间ID）
+type roomName struct {
+	Name string `json:"name"`
+}
+
+// stateMessage State 消息
+type stateMessage struct {
+	Playstate        *playstate `json:"playstate,omitempty"`
+	Ping             *pingInfo  `json:"ping,omitempty"`
+	IgnoringOnTheFly *ignoring  `json:"ignoringOnTheFly,omitempty"`
+}
+
+// playstate 播放状态，客户端未加载文件时 paused 可能为 null
+type playstate struct {
+	Position float64 `json:"position"`
+	Paused   *bool   `json:"paused"`
+	DoSeek   bool    `json:"doSeek"`
+	SetBy    string  `json:"setBy,omitempty"`
+}
+
+// pingInfo 时延测量，时间戳均为 Unix 秒
+type pingInfo struct {
+	LatencyCalculation       float64 `json:"latencyCalculation,omitempty"`
+	ClientLatencyCalculation float64 `json:"clientLatencyCalculation,omitempty"`
+	ClientRTT                float64 `json:"clientRtt,omitempty"`
+	ServerRTT                float64 `json:"serverRtt"`
+}
+
+// ignoring 正在等待对方确认的强制状态计数
+type ignoring struct {
+	Server int `json:"server,omitempty"`
+	Client int `json:"client,omitempty"`
+}
+
+// setMessage 客户端的 Set 消息，各字段按需出现
+type setMessage struct {
+	Room          *roomName       `json:"room"`
+	File          json.RawMessage `json:"file"`
+	PlaylistIndex *struct {
+		Index *int `json:"index"`
+	} `json:"playlistIndex"`
+	PlaylistChange *struct {
+		Files []string `json:"files"`
+	} `json:"playlistChange"`
+	Features map[string]interface{} `json:"features"`
+}
+
+// listEntry List 回复中的用户信息
+type listEntry struct {
+	Position   float64                `json:"position"`
+	File       json.RawMessage        `json:"file"`
+	Controller bool                   `json:"controller"`
+	IsReady    bool                   `json:"isReady"`
+	Features   map[string]interface{} `json:"features"`
+}
+
+// serverFeatures Hello 回复中声明的服务器功能
+//
+// 房间由 xiaowo 管理：不支持 Syncplay 的受控房间、准备状态和持久房间，共享播放列表只读。
+func serverFeatures() map[string]interface{} {
+	return map[string]interface{}{
+		"isolateRooms":         true,
+		"readiness":            false,
+		"managedRooms":         false,
+		"persistentRooms":      false,
+		"chat":                 true,
+		"sharedPlaylists":      true,
+		"setOthersReadiness":   false,
+		"maxChatMessageLength": maxChatMessageLength,
+		"maxUsernameLength":    maxUsernameLength,
+		"maxRoomNameLength":    maxRoomNameLength,
+		"maxFilenameLength":    maxFilenameLength,
+	}
+}
+
+// pingService 往返时延和单程时延估算（与 Syncplay 的 PingService 相同）
+type pingService struct {
+	rtt    float64 // 最近一次往返时延（秒）
+	avgRTT float64 // 往返时延滑动平均（秒）
+	fd     float64 // 客户端到服务器的单程时延估算（秒）
+}
+
+// receive 处理对方回传的时间戳，senderRTT 为对方测得的往返时延
+func (p *pingService) receive(timestamp, senderRTT float64, now time.Time) {
+	if timestamp == 0 {
+		return
+	}
+	p.rtt = unixSeconds(now) - timestamp
+	if p.rtt < 0 || senderRTT < 0 {
+		return
+	}
+	if p.avgRTT == 0 {
+		p.avgRTT = p.rtt
+	}
+	p.avgRTT = p.avgRTT*pingMovingAverageWeight + p.rtt*(1-pingMovingAverageWeight)
+	if senderRTT < p.rtt {
+		p.fd = p.avgRTT/2 + (p.rtt - senderRTT)
+	} else {
+		p.fd = p.avgRTT / 2
+	}
+}
+
+// unixSeconds 协议使用的浮点 Unix 秒
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
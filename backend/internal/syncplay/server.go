@@ -0,0 +1,205 @@
+// Package syncplay 实现 Syncplay 协议服务，让 mpv、VLC 等桌面播放器通过 Syncplay 客户端加入房间
+//
+// Syncplay 使用 TCP 上逐行的 JSON 消息（以 \r\n 分隔）。每个连接登录后作为一个普通房间连接
+// 接入 websocket.WebSocketHub（见 websocket.Client），与浏览器成员共享播放状态、聊天和成员列表：
+// Syncplay 的房间名即 xiaowo 的房间ID，已有成员可以在房间名后以 ":" 附上房间访问令牌；
+// 密码为房间密码（Syncplay 客户端发送的是密码的 MD5）。
+package syncplay
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/websocket"
+)
+
+// ErrServerClosed 服务已关闭
+var ErrServerClosed = errors.New("syncplay: server closed")
+
+// Credentials 登录凭据
+type Credentials struct {
+	RoomID   string // 房间ID
+	Token    string // 房间访问令牌（房间名中 ":" 之后的部分），为空时按房间密码校验
+	Password string // 房间密码的 MD5（十六进制），Syncplay 客户端发送前会对密码做 MD5
+	Username string // 需要新建会话时作为昵称
+	ClientIP string // 来源IP
+}
+
+// Authenticator 登录鉴权函数，校验凭据能否进入房间并返回会话ID
+//
+// release 在连接离开该房间时调用（可为空），用于移除为这次登录新建的成员。
+type Authenticator func(creds Credentials) (sessionID string, release func(), err error)
+
+// Hub 房间实时连接中心（由 websocket.WebSocketHub 实现）
+type Hub interface {
+	Attach(roomID, sessionID string) (*websocket.Client, error)
+	OnlineSessions(roomID string) []string
+}
+
+// Members 房间成员查询（由 service.MemberService 实现）
+type Members interface {
+	GetMember(roomID, sessionID string) (*model.RoomMember, error)
+}
+
+// Options 服务依赖项
+type Options struct {
+	Hub           Hub           // 房间实时连接中心
+	Members       Members       // 成员查询，用于显示昵称
+	Authenticator Authenticator // 登录鉴权
+	TLS           *tls.Config   // 为空时拒绝客户端的 TLS 协商，以明文通信
+	MOTD          string        // 登录后显示的欢迎消息
+}
+
+// Server Syncplay 协议服务
+type Server struct {
+	opts Options
+
+	mu        sync.Mutex
+	closed    bool
+	listeners map[net.Listener]struct{}
+	conns     map[*conn]struct{}
+	rooms     map[string]map[*conn]struct{} // 已登录的连接，按房间索引
+	wg        sync.WaitGroup
+}
+
+// New 创建 Syncplay 服务
+func New(opts Options) *Server {
+	return &Server{
+		opts:      opts,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[*conn]struct{}),
+		rooms:     make(map[string]map[*conn]struct{}),
+	}
+}
+
+// ListenAndServe 监听 TCP 地址并处理连接，Close 之后返回 ErrServerClosed
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve 在监听器上接受连接，Close 之后返回 ErrServerClosed
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	var delay time.Duration
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			// 与 net/http 相同，临时错误（如文件描述符耗尽）时退避重试
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				delay = min(max(2*delay, 5*time.Millisecond), time.Second)
+				time.Sleep(delay)
+				continue
+			}
+			return err
+		}
+		delay = 0
+
+		c := newConn(s, nc)
+		if !s.track(c) {
+			nc.Close()
+			return ErrServerClosed
+		}
+		go c.serve()
+	}
+}
+
+// Close 停止监听并断开所有连接，等待连接处理协程退出
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+		delete(s.listeners, l)
+	}
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		c.drop()
+	}
+	s.wg.Wait()
+	return nil
+}
+
+// isClosed 服务是否已关闭
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// track 记录新连接，服务已关闭时返回 false
+func (s *Server) track(c *conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[c] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+// untrack 移除已断开的连接
+func (s *Server) untrack(c *conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.conns[c]; ok {
+		delete(s.conns, c)
+		s.wg.Done()
+	}
+}
+
+// join 将已登录的连接加入房间索引
+func (s *Server) join(c *conn, roomID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rooms[roomID] == nil {
+		s.rooms[roomID] = make(map[*conn]struct{})
+	}
+	s.rooms[roomID][c] = struct{}{}
+}
+
+// leave 将连接移出房间索引
+func (s *Server) leave(c *conn, roomID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.rooms[roomID], c)
+	if len(s.rooms[roomID]) == 0 {
+		delete(s.rooms, roomID)
+	}
+}
+
+// peers 返回房间内的 Syncplay 连接
+func (s *Server) peers(roomID string) []*conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	peers := make([]*conn, 0, len(s.rooms[roomID]))
+	for c := range s.rooms[roomID] {
+		peers = append(peers, c)
+	}
+	return peers
+}
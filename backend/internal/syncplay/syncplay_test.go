@@ -0,0 +1,227 @@
+package syncplay
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/websocket"
+)
+
+// stubHub 不接入任何房间的测试用 Hub
+type stubHub struct{}
+
+func (stubHub) Attach(roomID, sessionID string) (*websocket.Client, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (stubHub) OnlineSessions(roomID string) []string { return nil }
+
+// startServer 启动测试服务，返回监听地址
+func startServer(t *testing.T, opts Options) (*Server, string) {
+	t.Helper()
+
+	server := New(opts)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(l) }()
+	t.Cleanup(func() {
+		server.Close()
+		if err := <-served; !errors.Is(err, ErrServerClosed) {
+			t.Errorf("关闭后 Serve 应返回 ErrServerClosed，实际: %v", err)
+		}
+	})
+	return server, l.Addr().String()
+}
+
+// exchange 发送一行消息并读取服务器的回复
+func exchange(t *testing.T, conn net.Conn, reader *bufio.Reader, line string) map[string]json.RawMessage {
+	t.Helper()
+
+	if _, err := conn.Write([]byte(line + "\r\n")); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	reply, err := reader.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("读取回复失败: %v", err)
+	}
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(reply, &msg); err != nil {
+		t.Fatalf("回复不是 JSON: %q", reply)
+	}
+	return msg
+}
+
+func TestServer_Login(t *testing.T) {
+	var got Credentials
+	_, addr := startServer(t, Options{
+		Hub: stubHub{},
+		Authenticator: func(creds Credentials) (string, func(), error) {
+			got = creds
+			return "", nil, model.ErrRoomPasswordInvalid
+		},
+	})
+
+	dial := func(t *testing.T) (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("连接失败: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn, bufio.NewReader(conn)
+	}
+
+	t.Run("TestRequireHello", func(t *testing.T) {
+		conn, reader := dial(t)
+		msg := exchange(t, conn, reader, `{"Chat":"hi"}`)
+		if msg["Error"] == nil {
+			t.Errorf("未登录时发送其他消息应返回错误: %s", msg)
+		}
+	})
+
+	t.Run("TestRefuseTLS", func(t *testing.T) {
+		conn, reader := dial(t)
+		msg := exchange(t, conn, reader, `{"TLS":{"startTLS":"send"}}`)
+		if string(msg["TLS"]) != `{"startTLS":"false"}` {
+			t.Errorf("未配置证书时应拒绝 TLS: %s", msg)
+		}
+	})
+
+	t.Run("TestMissingRoom", func(t *testing.T) {
+		conn, reader := dial(t)
+		msg := exchange(t, conn, reader, `{"Hello":{"username":"alice","version":"1.2.255"}}`)
+		if msg["Error"] == nil {
+			t.Errorf("缺少房间时应返回错误: %s", msg)
+		}
+	})
+
+	t.Run("TestAuthenticationError", func(t *testing.T) {
+		conn, reader := dial(t)
+		msg := exchange(t, conn, reader, `{"Hello":{"username":"  alice  ","password":"4142047431f5f974ef182c6f3a4982f6","room":{"name":"ROOM01"},"version":"1.2.255"}}`)
+		if string(msg["Error"]) != `{"message":"房间密码错误"}` {
+			t.Errorf("鉴权失败应返回原因: %s", msg)
+		}
+		// 客户端发送的是密码 "guess" 的 MD5，原样交给鉴权
+		if got.RoomID != "ROOM01" || got.Username != "alice" || got.Password != "4142047431f5f974ef182c6f3a4982f6" || got.Token != "" {
+			t.Errorf("鉴权参数不正确: %+v", got)
+		}
+
+		// 服务器发送错误后断开连接
+		conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		if _, err := reader.ReadBytes('\n'); err == nil {
+			t.Error("登录失败后连接应被关闭")
+		}
+	})
+
+	t.Run("TestRoomToken", func(t *testing.T) {
+		// 房间访问令牌写在房间名中，不经过客户端的 MD5
+		conn, reader := dial(t)
+		exchange(t, conn, reader, `{"Hello":{"username":"alice","room":{"name":" ROOM01:eyJ.token.sig "},"version":"1.2.255"}}`)
+		if got.RoomID != "ROOM01" || got.Token != "eyJ.token.sig" || got.Password != "" {
+			t.Errorf("房间名中的令牌应单独传给鉴权: %+v", got)
+		}
+	})
+}
+
+// 测试鉴权通过但接入房间失败时立即调用 release
+func TestServer_ReleaseOnAttachFailure(t *testing.T) {
+	released := make(chan struct{})
+	_, addr := startServer(t, Options{
+		Hub: stubHub{},
+		Authenticator: func(creds Credentials) (string, func(), error) {
+			return "s1", func() { close(released) }, nil
+		},
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer conn.Close()
+	exchange(t, conn, bufio.NewReader(conn), `{"Hello":{"username":"alice","room":{"name":"ROOM01"},"version":"1.2.255"}}`)
+
+	select {
+	case <-released:
+	case <-time.After(3 * time.Second):
+		t.Error("接入房间失败时应释放鉴权时新建的成员")
+	}
+}
+
+func TestServer_CloseDropsConnections(t *testing.T) {
+	server, addr := startServer(t, Options{Hub: stubHub{}})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer conn.Close()
+
+	// 等待服务器接受连接
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		server.mu.Lock()
+		n := len(server.conns)
+		server.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("服务器未接受连接")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	server.Close()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("关闭服务后连接应被断开")
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	if err := server.Serve(l); !errors.Is(err, ErrServerClosed) {
+		t.Errorf("关闭后再次 Serve 应返回 ErrServerClosed: %v", err)
+	}
+}
+
+func TestPingService(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	var ping pingService
+
+	// 时间戳为空时忽略
+	ping.receive(0, 0, now)
+	if ping.rtt != 0 || ping.avgRTT != 0 {
+		t.Fatalf("空时间戳不应更新时延: %+v", ping)
+	}
+
+	ping.receive(unixSeconds(now.Add(-200*time.Millisecond)), 0.2, now)
+	if math.Abs(ping.rtt-0.2) > 1e-6 || math.Abs(ping.avgRTT-0.2) > 1e-6 || math.Abs(ping.fd-0.1) > 1e-6 {
+		t.Errorf("首次测量应以往返时延为平均值: %+v", ping)
+	}
+
+	// 对方测得的时延更小时，多出的部分计入单程时延
+	ping.receive(unixSeconds(now.Add(-400*time.Millisecond)), 0.1, now)
+	wantAvg := 0.2*pingMovingAverageWeight + 0.4*(1-pingMovingAverageWeight)
+	if math.Abs(ping.avgRTT-wantAvg) > 1e-6 || math.Abs(ping.fd-(wantAvg/2+0.3)) > 1e-6 {
+		t.Errorf("滑动平均或单程时延不正确: %+v", ping)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("播放器用户", 3); got != "播放器" {
+		t.Errorf("应按字符截断: %q", got)
+	}
+	if got := truncate("mpv", 32); got != "mpv" {
+		t.Errorf("未超长时不应截断: %q", got)
+	}
+}
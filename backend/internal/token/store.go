@@ -0,0 +1,110 @@
+package token
+
+import (
+	"sync"
+	"time"
+)
+
+// Store 令牌吊销记录存储（由 repository.TokenRepo 实现）
+//
+// 多个实例共用同一存储时，任一实例上的吊销对所有实例生效，服务重启后也不会失效。
+type Store interface {
+	// RevokeToken 吊销单个令牌，expiresAt 之后记录可以删除
+	RevokeToken(id string, expiresAt time.Time) error
+	// IsTokenRevoked 令牌是否已被吊销
+	IsTokenRevoked(id string) (bool, error)
+	// Generation 会话在房间内的当前令牌代数，没有记录时为 0
+	Generation(roomID, sessionID string) (int64, error)
+	// IssueGeneration 返回签发新令牌使用的当前代数，并把代数记录的更新时间记为 now
+	IssueGeneration(roomID, sessionID string, now time.Time) (int64, error)
+	// NextGeneration 递增会话在房间内的令牌代数，旧代数的令牌随即失效
+	NextGeneration(roomID, sessionID string, now time.Time) error
+	// Prune 删除 now 之前过期的令牌吊销记录和 generationsBefore 之前更新的代数记录，返回删除数量
+	//
+	// 代数记录在递增和签发时都会更新，删除时以该代数签发的令牌都已过期。
+	Prune(now, generationsBefore time.Time) (int64, error)
+}
+
+// memoryStore 进程内的吊销记录存储，只适用于单实例部署
+type memoryStore struct {
+	mu          sync.Mutex
+	revoked     map[string]time.Time       // jti -> 令牌过期时间
+	generations map[string]generationEntry // room_id/session_id -> 当前令牌代数
+}
+
+type generationEntry struct {
+	generation int64
+	updatedAt  time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		revoked:     make(map[string]time.Time),
+		generations: make(map[string]generationEntry),
+	}
+}
+
+func (s *memoryStore) RevokeToken(id string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked[id] = expiresAt
+	return nil
+}
+
+func (s *memoryStore) IsTokenRevoked(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.revoked[id]
+	return ok, nil
+}
+
+func (s *memoryStore) Generation(roomID, sessionID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.generations[generationKey(roomID, sessionID)].generation, nil
+}
+
+func (s *memoryStore) IssueGeneration(roomID, sessionID string, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := generationKey(roomID, sessionID)
+	entry, ok := s.generations[key]
+	if !ok {
+		return 0, nil
+	}
+	entry.updatedAt = now
+	s.generations[key] = entry
+	return entry.generation, nil
+}
+
+func (s *memoryStore) NextGeneration(roomID, sessionID string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := generationKey(roomID, sessionID)
+	s.generations[key] = generationEntry{generation: s.generations[key].generation + 1, updatedAt: now}
+	return nil
+}
+
+func (s *memoryStore) Prune(now, generationsBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pruned int64
+	for id, expiresAt := range s.revoked {
+		if now.After(expiresAt) {
+			delete(s.revoked, id)
+			pruned++
+		}
+	}
+	for key, entry := range s.generations {
+		if entry.updatedAt.Before(generationsBefore) {
+			delete(s.generations, key)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+func generationKey(roomID, sessionID string) string {
+	return roomID + "/" + sessionID
+}
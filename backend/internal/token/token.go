@@ -0,0 +1,223 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"xiaowo/backend/internal/model"
+)
+
+// 令牌错误
+var (
+	ErrInvalidToken = errors.New("invalid room token")
+	ErrTokenExpired = errors.New("room token expired")
+	ErrTokenRevoked = errors.New("room token revoked")
+	ErrRoomMismatch = errors.New("room token does not match room")
+)
+
+const issuer = "xiaowo"
+
+// Claims 房间访问令牌载荷
+type Claims struct {
+	RoomID     string         `json:"room_id"`       // 房间ID
+	SessionID  string         `json:"session_id"`    // 会话ID
+	Role       model.RoomRole `json:"role"`          // 成员角色
+	Generation int64          `json:"gen,omitempty"` // 会话令牌代数（整体吊销时递增）
+	jwt.RegisteredClaims
+}
+
+// Manager 房间访问令牌管理器，负责签发、校验、刷新和吊销 HMAC 签名令牌
+//
+// 吊销记录默认保存在内存中，只适用于单实例部署；多实例部署或需要重启后仍然有效时，
+// 通过 PersistWith 保存到共享存储。
+type Manager struct {
+	secret []byte
+	ttl    time.Duration
+	store  Store
+}
+
+// NewManager 创建令牌管理器
+func NewManager(secret string, ttl time.Duration) (*Manager, error) {
+	if len(secret) < 16 {
+		return nil, fmt.Errorf("token secret too short (min 16 characters)")
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("token ttl must be positive")
+	}
+
+	return &Manager{
+		secret: []byte(secret),
+		ttl:    ttl,
+		store:  newMemoryStore(),
+	}, nil
+}
+
+// PersistWith 将吊销记录保存到共享存储，需在启动阶段设置
+func (m *Manager) PersistWith(store Store) {
+	m.store = store
+}
+
+// TTL 返回令牌有效期
+func (m *Manager) TTL() time.Duration {
+	return m.ttl
+}
+
+// Issue 为房间成员签发访问令牌
+func (m *Manager) Issue(roomID, sessionID string, role model.RoomRole) (string, *Claims, error) {
+	if roomID == "" || sessionID == "" {
+		return "", nil, fmt.Errorf("%w: room and session are required", ErrInvalidToken)
+	}
+
+	now := time.Now()
+	generation, err := m.store.IssueGeneration(roomID, sessionID, now)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read token generation: %w", err)
+	}
+
+	claims := &Claims{
+		RoomID:     roomID,
+		SessionID:  sessionID,
+		Role:       role,
+		Generation: generation,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Issuer:    issuer,
+			Subject:   sessionID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.ttl)),
+		},
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return signed, claims, nil
+}
+
+// Parse 校验令牌签名、有效期和吊销状态
+func (m *Manager) Parse(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(
+		tokenString,
+		claims,
+		func(_ *jwt.Token) (any, error) {
+			return m.secret, nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrTokenExpired
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if claims.RoomID == "" || claims.SessionID == "" || claims.ID == "" {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := m.isRevoked(claims)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+// ParseForRoom 校验令牌并要求其属于指定房间
+func (m *Manager) ParseForRoom(tokenString, roomID string) (*Claims, error) {
+	claims, err := m.Parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.RoomID != roomID {
+		return nil, ErrRoomMismatch
+	}
+
+	return claims, nil
+}
+
+// Refresh 用仍然有效的令牌换取新令牌，旧令牌随即吊销
+func (m *Manager) Refresh(tokenString string, role model.RoomRole) (string, *Claims, error) {
+	claims, err := m.Parse(tokenString)
+	if err != nil {
+		return "", nil, err
+	}
+
+	signed, newClaims, err := m.Issue(claims.RoomID, claims.SessionID, role)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := m.Revoke(claims); err != nil {
+		return "", nil, err
+	}
+	return signed, newClaims, nil
+}
+
+// Revoke 吊销单个令牌
+func (m *Manager) Revoke(claims *Claims) error {
+	if claims == nil || claims.ID == "" {
+		return nil
+	}
+
+	expiresAt := time.Now().Add(m.ttl)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	if err := m.store.RevokeToken(claims.ID, expiresAt); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// RevokeSession 吊销会话在房间内已签发的全部令牌（离开、被踢出时使用）
+func (m *Manager) RevokeSession(roomID, sessionID string) error {
+	if err := m.store.NextGeneration(roomID, sessionID, time.Now()); err != nil {
+		return fmt.Errorf("failed to revoke session tokens: %w", err)
+	}
+	return nil
+}
+
+// Prune 清理不再需要的吊销记录，返回清理数量（由后台清理任务定期调用）
+//
+// 令牌过期后其吊销记录不再需要；代数记录在最后一次递增或签发一个有效期之后删除，
+// 此时以该代数及更早代数签发的令牌都已过期，代数从 0 重新开始也不会让旧令牌复活。
+func (m *Manager) Prune() int {
+	now := time.Now()
+	pruned, err := m.store.Prune(now, now.Add(-m.ttl))
+	if err != nil {
+		log.Printf("token: prune revocations: %v", err)
+	}
+	return int(pruned)
+}
+
+// isRevoked 检查令牌是否已被吊销：单独吊销，或签发后会话的令牌被整体吊销
+func (m *Manager) isRevoked(claims *Claims) (bool, error) {
+	revoked, err := m.store.IsTokenRevoked(claims.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	if revoked {
+		return true, nil
+	}
+
+	generation, err := m.store.Generation(claims.RoomID, claims.SessionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to read token generation: %w", err)
+	}
+	return claims.Generation < generation, nil
+}
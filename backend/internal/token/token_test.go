@@ -0,0 +1,203 @@
+package token
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"xiaowo/backend/internal/model"
+)
+
+const testSecret = "test-secret-for-room-tokens"
+
+func newTestManager(t *testing.T, ttl time.Duration) *Manager {
+	t.Helper()
+
+	m, err := NewManager(testSecret, ttl)
+	if err != nil {
+		t.Fatalf("创建令牌管理器失败: %v", err)
+	}
+	return m
+}
+
+// 测试签发与解析
+func TestManager_IssueAndParse(t *testing.T) {
+	m := newTestManager(t, time.Hour)
+
+	signed, issued, err := m.Issue("ROOM01", "session-1", model.RoleHost)
+	if err != nil {
+		t.Fatalf("签发令牌失败: %v", err)
+	}
+
+	claims, err := m.ParseForRoom(signed, "ROOM01")
+	if err != nil {
+		t.Fatalf("解析令牌失败: %v", err)
+	}
+
+	if claims.SessionID != "session-1" || claims.RoomID != "ROOM01" || claims.Role != model.RoleHost {
+		t.Errorf("令牌载荷不正确: %+v", claims)
+	}
+	if claims.ID != issued.ID {
+		t.Errorf("期望令牌ID: %s, 实际: %s", issued.ID, claims.ID)
+	}
+
+	t.Run("TestWrongRoom", func(t *testing.T) {
+		if _, err := m.ParseForRoom(signed, "ROOM02"); !errors.Is(err, ErrRoomMismatch) {
+			t.Errorf("期望错误: %v, 实际: %v", ErrRoomMismatch, err)
+		}
+	})
+
+	t.Run("TestTampered", func(t *testing.T) {
+		parts := strings.Split(signed, ".")
+		forged := parts[0] + "." + parts[1] + "x." + parts[2]
+		if _, err := m.Parse(forged); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("期望错误: %v, 实际: %v", ErrInvalidToken, err)
+		}
+	})
+
+	t.Run("TestOtherSecret", func(t *testing.T) {
+		other, _ := NewManager("another-secret-value-123", time.Hour)
+		if _, err := other.Parse(signed); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("期望错误: %v, 实际: %v", ErrInvalidToken, err)
+		}
+	})
+
+	t.Run("TestLegacyFormat", func(t *testing.T) {
+		if _, err := m.Parse("xiaowo_ROOM01_session-1"); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("旧格式令牌应该被拒绝，实际: %v", err)
+		}
+	})
+}
+
+// 测试过期
+func TestManager_Expired(t *testing.T) {
+	m := newTestManager(t, time.Second)
+
+	signed, _, err := m.Issue("ROOM01", "session-1", model.RoleMember)
+	if err != nil {
+		t.Fatalf("签发令牌失败: %v", err)
+	}
+
+	time.Sleep(2100 * time.Millisecond)
+
+	if _, err := m.Parse(signed); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("期望错误: %v, 实际: %v", ErrTokenExpired, err)
+	}
+}
+
+// 测试刷新与吊销
+func TestManager_RefreshAndRevoke(t *testing.T) {
+	m := newTestManager(t, time.Hour)
+
+	signed, _, _ := m.Issue("ROOM01", "session-1", model.RoleMember)
+
+	refreshed, claims, err := m.Refresh(signed, model.RoleHost)
+	if err != nil {
+		t.Fatalf("刷新令牌失败: %v", err)
+	}
+	if claims.Role != model.RoleHost {
+		t.Errorf("期望角色: %s, 实际: %s", model.RoleHost, claims.Role)
+	}
+
+	// 旧令牌刷新后失效
+	if _, err := m.Parse(signed); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("期望错误: %v, 实际: %v", ErrTokenRevoked, err)
+	}
+	if _, err := m.Parse(refreshed); err != nil {
+		t.Fatalf("新令牌应该有效: %v", err)
+	}
+
+	t.Run("TestRevokeSession", func(t *testing.T) {
+		other, _, _ := m.Issue("ROOM02", "session-1", model.RoleMember)
+
+		m.RevokeSession("ROOM01", "session-1")
+
+		if _, err := m.Parse(refreshed); !errors.Is(err, ErrTokenRevoked) {
+			t.Errorf("期望错误: %v, 实际: %v", ErrTokenRevoked, err)
+		}
+
+		// 其他房间的令牌不受影响
+		if _, err := m.Parse(other); err != nil {
+			t.Errorf("其他房间的令牌应该有效: %v", err)
+		}
+
+		// 吊销后重新签发的令牌有效
+		again, _, _ := m.Issue("ROOM01", "session-1", model.RoleMember)
+		if _, err := m.Parse(again); err != nil {
+			t.Errorf("重新签发的令牌应该有效: %v", err)
+		}
+	})
+}
+
+// 测试共用存储的多个实例之间吊销互相可见，清理吊销记录后未过期的令牌不受影响
+func TestManager_SharedStore(t *testing.T) {
+	store := newMemoryStore()
+	a, b := newTestManager(t, time.Hour), newTestManager(t, time.Hour)
+	a.PersistWith(store)
+	b.PersistWith(store)
+
+	signed, claims, _ := a.Issue("ROOM01", "session-1", model.RoleMember)
+	if err := b.Revoke(claims); err != nil {
+		t.Fatalf("吊销令牌失败: %v", err)
+	}
+	if _, err := a.Parse(signed); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("其他实例吊销的令牌应失效，实际: %v", err)
+	}
+
+	old, _, _ := a.Issue("ROOM01", "session-2", model.RoleMember)
+	if err := b.RevokeSession("ROOM01", "session-2"); err != nil {
+		t.Fatalf("吊销会话令牌失败: %v", err)
+	}
+	if _, err := a.Parse(old); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("其他实例整体吊销的会话令牌应失效，实际: %v", err)
+	}
+	current, _, _ := a.Issue("ROOM01", "session-2", model.RoleMember)
+
+	t.Run("TestPrune", func(t *testing.T) {
+		// 一个有效期之后清理：已吊销的令牌都已过期，之后签发的令牌仍然有效
+		later := time.Now().Add(2 * time.Hour)
+		if pruned, _ := store.Prune(later, later.Add(-time.Hour)); pruned != 2 {
+			t.Errorf("期望清理 2 条记录，实际: %d", pruned)
+		}
+		if _, err := a.Parse(current); err != nil {
+			t.Errorf("清理代数记录后新令牌应该有效: %v", err)
+		}
+		if pruned := a.Prune(); pruned != 0 {
+			t.Errorf("没有可清理的记录，实际清理: %d", pruned)
+		}
+	})
+}
+
+// 测试踢出后重新签发的令牌在清理代数记录后再次踢出时仍然失效
+func TestManager_PruneAfterReissue(t *testing.T) {
+	store := newMemoryStore()
+	m := newTestManager(t, time.Hour)
+	m.PersistWith(store)
+
+	// 50 分钟前被踢出，刚刚重新加入
+	now := time.Now()
+	if err := store.NextGeneration("ROOM01", "session-1", now.Add(-50*time.Minute)); err != nil {
+		t.Fatalf("吊销会话令牌失败: %v", err)
+	}
+	signed, claims, _ := m.Issue("ROOM01", "session-1", model.RoleMember)
+	if claims.Generation != 1 {
+		t.Fatalf("重新签发的令牌代数应为 1，实际: %d", claims.Generation)
+	}
+
+	// 距最后一次踢出已过一个有效期，但重新签发的令牌还没有过期
+	later := now.Add(20 * time.Minute)
+	if _, err := store.Prune(later, later.Add(-time.Hour)); err != nil {
+		t.Fatalf("清理吊销记录失败: %v", err)
+	}
+	if _, err := m.Parse(signed); err != nil {
+		t.Fatalf("清理后重新签发的令牌应该有效: %v", err)
+	}
+
+	if err := m.RevokeSession("ROOM01", "session-1"); err != nil {
+		t.Fatalf("吊销会话令牌失败: %v", err)
+	}
+	if _, err := m.Parse(signed); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("再次踢出后旧令牌应失效，实际: %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package webrtc
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"strconv"
+	"time"
+)
+
+// DefaultTURNTTL 默认 TURN 临时凭据的有效期
+const DefaultTURNTTL = 24 * time.Hour
+
+// ICEServer 下发给浏览器的 ICE 服务器，字段与 RTCPeerConnection 的 iceServers 配置相同
+type ICEServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// Options ICE 服务器配置
+type Options struct {
+	STUNURLs   []string      // STUN 服务器地址，如 stun:stun.example.com:3478
+	TURNURLs   []string      // TURN 服务器地址，如 turn:turn.example.com:3478?transport=udp
+	TURNSecret string        // 与 TURN 服务器共享的密钥（coturn 的 static-auth-secret），为空时不下发 TURN
+	TURNTTL    time.Duration // TURN 临时凭据有效期（为空时使用 DefaultTURNTTL）
+}
+
+// ICE 为每个会话生成 ICE 服务器配置
+//
+// TURN 使用 TURN REST API 约定的临时凭据：用户名为 "过期时间戳:会话ID"，
+// 密码为以共享密钥对用户名做 HMAC-SHA1 后的 Base64，TURN 服务器无需查询本服务即可校验。
+type ICE struct {
+	opts Options
+	now  func() time.Time
+}
+
+// NewICE 创建 ICE 服务器配置生成器
+func NewICE(opts Options) *ICE {
+	if opts.TURNTTL <= 0 {
+		opts.TURNTTL = DefaultTURNTTL
+	}
+	return &ICE{opts: opts, now: time.Now}
+}
+
+// Servers 返回会话可用的 ICE 服务器，未配置任何服务器时返回空列表
+func (i *ICE) Servers(sessionID string) []ICEServer {
+	servers := make([]ICEServer, 0, 2)
+	if len(i.opts.STUNURLs) > 0 {
+		servers = append(servers, ICEServer{URLs: i.opts.STUNURLs})
+	}
+	if len(i.opts.TURNURLs) > 0 && i.opts.TURNSecret != "" {
+		username, credential := TURNCredentials(i.opts.TURNSecret, sessionID, i.now().Add(i.opts.TURNTTL))
+		servers = append(servers, ICEServer{
+			URLs:       i.opts.TURNURLs,
+			Username:   username,
+			Credential: credential,
+		})
+	}
+	return servers
+}
+
+// TURNCredentials 生成在 expiry 之前有效的 TURN 临时凭据
+func TURNCredentials(secret, user string, expiry time.Time) (username, credential string) {
+	username = strconv.FormatInt(expiry.Unix(), 10) + ":" + user
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(username))
+	return username, base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
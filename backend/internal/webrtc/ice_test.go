@@ -0,0 +1,59 @@
+package webrtc
+
+import (
+	"testing"
+	"time"
+)
+
+// 测试 TURN 临时凭据与 TURN REST API 约定一致
+func TestTURNCredentials(t *testing.T) {
+	username, credential := TURNCredentials("north-sea", "session-1", time.Unix(1700000000, 0))
+	if username != "1700000000:session-1" {
+		t.Errorf("用户名不正确: %s", username)
+	}
+	// python3: base64(hmac.new(b"north-sea", b"1700000000:session-1", sha1).digest())
+	if credential != "uU16gd/vsdKm30SU7vapQMEBpG8=" {
+		t.Errorf("密码不正确: %s", credential)
+	}
+}
+
+// 测试按配置生成 ICE 服务器
+func TestICE_Servers(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	t.Run("TestEmpty", func(t *testing.T) {
+		servers := NewICE(Options{}).Servers("session-1")
+		if servers == nil || len(servers) != 0 {
+			t.Errorf("未配置时应返回空列表: %+v", servers)
+		}
+	})
+
+	t.Run("TestTURNRequiresSecret", func(t *testing.T) {
+		servers := NewICE(Options{
+			STUNURLs: []string{"stun:stun.example.com:3478"},
+			TURNURLs: []string{"turn:turn.example.com:3478"},
+		}).Servers("session-1")
+		if len(servers) != 1 || servers[0].Username != "" {
+			t.Errorf("未配置密钥时不应下发 TURN: %+v", servers)
+		}
+	})
+
+	t.Run("TestTURNCredentials", func(t *testing.T) {
+		ice := NewICE(Options{
+			STUNURLs:   []string{"stun:stun.example.com:3478"},
+			TURNURLs:   []string{"turn:turn.example.com:3478", "turns:turn.example.com:5349"},
+			TURNSecret: "north-sea",
+			TURNTTL:    time.Hour,
+		})
+		ice.now = func() time.Time { return now }
+
+		servers := ice.Servers("session-1")
+		if len(servers) != 2 {
+			t.Fatalf("期望 STUN 和 TURN 两项: %+v", servers)
+		}
+		turn := servers[1]
+		if len(turn.URLs) != 2 || turn.Username != "1700003600:session-1" || turn.Credential == "" {
+			t.Errorf("TURN 配置不正确: %+v", turn)
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package websocket
+
+import (
+	"time"
+
+	"xiaowo/backend/internal/service"
+)
+
+// DefaultBufferingTimeout 默认等待缓冲成员的最长时间，超时后房间不再等待仍在缓冲的成员
+const DefaultBufferingTimeout = 15 * time.Second
+
+// stall 成员缓冲引起的自动暂停
+//
+// 由发起暂停的实例持有并负责恢复播放：所有成员（包括其他实例上的）报告就绪后，
+// 在预约的同一时刻恢复；等待超时后不再等待仍在缓冲的成员。
+// 暂停之后有人手动改变播放状态时放弃恢复，以手动操作为准。
+type stall struct {
+	version int64       // 自动暂停写入的版本号，暂停生效前为 0
+	timer   *time.Timer // 等待超时定时器
+}
+
+// handleBuffering 成员播放器开始缓冲，房间正在播放时自动暂停等待
+func (h *WebSocketHub) handleBuffering(conn *WebSocketConnection) {
+	var started *stall
+	changed := conn.room.call(func(r *Room) {
+		if conn.buffering {
+			return
+		}
+		conn.buffering = true
+		r.broadcast(bufferingMessage(MsgTypeBuffering, r.ID, conn.sessionID, r.bufferingCount()))
+
+		if r.stall == nil && r.state.IsPlaying && h.store != nil {
+			r.stall = &stall{}
+			started = r.stall
+		}
+	})
+	if !changed {
+		return
+	}
+	h.publish(eventBuffering, conn.roomID, 0, conn.sessionID, nil)
+
+	if started != nil {
+		h.pauseForBuffering(conn.room, started)
+	}
+}
+
+// handleReady 成员播放器缓冲完成，所有成员就绪后恢复自动暂停前的播放
+func (h *WebSocketHub) handleReady(conn *WebSocketConnection) {
+	var changed bool
+	conn.room.call(func(r *Room) {
+		if !conn.buffering {
+			return
+		}
+		conn.buffering = false
+		changed = true
+		r.broadcast(bufferingMessage(MsgTypeReady, r.ID, conn.sessionID, r.bufferingCount()))
+		h.checkStall(r)
+	})
+	if changed {
+		h.publish(eventReady, conn.roomID, 0, conn.sessionID, nil)
+	}
+}
+
+// pauseForBuffering 通过存储暂停房间，记录暂停版本并开始计时
+func (h *WebSocketHub) pauseForBuffering(room *Room, s *stall) {
+	stored, err := h.store.ApplyPlayback(room.ID, service.PlaybackCommand{Action: service.PlaybackActionPause})
+	room.post(func(r *Room) {
+		if r.stall != s {
+			return
+		}
+		if err != nil {
+			r.stall = nil
+			return
+		}
+		s.version = int64(stored.Version)
+		s.timer = time.AfterFunc(h.bufferingTimeout, func() {
+			h.resumeStall(r, s, true)
+		})
+		// 暂停生效前缓冲的成员可能已经就绪
+		h.checkStall(r)
+	})
+}
+
+// checkStall 没有成员仍在缓冲时恢复播放（仅在房间协程中调用）
+func (h *WebSocketHub) checkStall(r *Room) {
+	if s := r.stall; s != nil && s.version > 0 && r.bufferingCount() == 0 {
+		go h.resumeStall(r, s, false)
+	}
+}
+
+// resumeStall 结束自动暂停，在预约的时刻恢复播放，timedOut 表示等待超时
+func (h *WebSocketHub) resumeStall(room *Room, s *stall, timedOut bool) {
+	var lead time.Duration
+	var current bool
+	room.call(func(r *Room) {
+		if r.stall != s {
+			return
+		}
+		r.endStall()
+		current = true
+		lead = r.scheduleLead()
+
+		if timedOut {
+			r.broadcast(map[string]interface{}{
+				"type":      "buffering_timeout",
+				"room_id":   r.ID,
+				"waiting":   r.bufferingSessions(),
+				"timestamp": time.Now().Unix(),
+			})
+		}
+	})
+	if !current {
+		return
+	}
+
+	// 基于自动暂停的版本恢复，期间有其他变更时版本冲突，不覆盖
+	base := int(s.version)
+	h.store.ApplyPlayback(room.ID, service.PlaybackCommand{
+		Action:      service.PlaybackActionPlay,
+		BaseVersion: &base,
+		ExecuteAt:   time.Now().Add(lead).UnixMilli(),
+	})
+}
+
+// endStall 放弃等待（仅在房间协程中调用）
+func (r *Room) endStall() {
+	if r.stall == nil {
+		return
+	}
+	if r.stall.timer != nil {
+		r.stall.timer.Stop()
+	}
+	r.stall = nil
+}
+
+// bufferingCount 所有实例上正在缓冲的会话数（仅在房间协程中调用）
+func (r *Room) bufferingCount() int {
+	count := len(r.remoteBuffering)
+	for _, conn := range r.clients {
+		if conn.buffering {
+			count++
+		}
+	}
+	return count
+}
+
+// bufferingSessions 所有实例上正在缓冲的会话ID（仅在房间协程中调用）
+func (r *Room) bufferingSessions() []string {
+	sessions := make([]string, 0, len(r.remoteBuffering))
+	for sessionID := range r.remoteBuffering {
+		sessions = append(sessions, sessionID)
+	}
+	for sessionID, conn := range r.clients {
+		if conn.buffering {
+			sessions = append(sessions, sessionID)
+		}
+	}
+	return sessions
+}
+
+// bufferingMessage 成员缓冲/就绪通知，waiting 为仍在缓冲的会话数
+func bufferingMessage(msgType, roomID, sessionID string, waiting int) map[string]interface{} {
+	return map[string]interface{}{
+		"type":       msgType,
+		"room_id":    roomID,
+		"session_id": sessionID,
+		"waiting":    waiting,
+		"timestamp":  time.Now().Unix(),
+	}
+}
+
+// remoteBufferingOp 记录其他实例上会话的缓冲状态并通知本实例的连接
+func (h *WebSocketHub) remoteBufferingOp(sessionID string, buffering bool) roomOp {
+	return func(r *Room) {
+		if r.remoteBuffering[sessionID] == buffering {
+			return
+		}
+		msgType := MsgTypeReady
+		if buffering {
+			r.remoteBuffering[sessionID] = true
+			msgType = MsgTypeBuffering
+		} else {
+			delete(r.remoteBuffering, sessionID)
+		}
+		r.broadcast(bufferingMessage(msgType, r.ID, sessionID, r.bufferingCount()))
+		h.checkStall(r)
+	}
+}
@@ -0,0 +1,146 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"xiaowo/backend/internal/model"
+)
+
+// stored 读取存储中的房间记录
+func (db *fakeDB) stored(roomID string) model.Room {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.rooms[roomID]
+}
+
+// newPlayingRoom 创建两个成员都已加入且正在播放的房间
+func newPlayingRoom(t *testing.T, timeout time.Duration) (*WebSocketHub, *fakeDB, *testClient, *testClient) {
+	t.Helper()
+
+	db := newFakeDB()
+	hub := newInstance(t, db, HubOptions{BufferingTimeout: timeout})
+	alice := newTestClient("ROOM01", "alice", 64, true)
+	bob := newTestClient("ROOM01", "bob", 64, true)
+	for _, client := range []*testClient{alice, bob} {
+		if err := hub.RegisterClient(client.conn); err != nil {
+			t.Fatalf("注册连接失败: %v", err)
+		}
+	}
+
+	hub.HandleMessage(alice.conn, []byte(`{"type":"play","start_time":10}`))
+	waitFor(t, "成员收到播放指令", func() bool { return bob.has("play") })
+	return hub, db, alice, bob
+}
+
+// scheduledPlay 是否收到过预约生效的播放指令
+func scheduledPlay(c *testClient) bool {
+	return c.find("play", func(msg map[string]interface{}) bool {
+		_, ok := msg["execute_at"]
+		return ok
+	})
+}
+
+// 测试成员缓冲时房间自动暂停，所有成员就绪后在预约时刻恢复播放
+func TestHub_BufferingPausesRoom(t *testing.T) {
+	hub, db, alice, bob := newPlayingRoom(t, time.Minute)
+
+	t.Run("TestAutoPause", func(t *testing.T) {
+		hub.HandleMessage(bob.conn, []byte(`{"type":"buffering"}`))
+		waitFor(t, "其他成员收到缓冲通知和暂停指令", func() bool {
+			return alice.has(MsgTypeBuffering) && alice.has("pause")
+		})
+		if room := db.stored("ROOM01"); room.IsPlaying() || room.Version != 2 {
+			t.Errorf("房间应被自动暂停: state=%s version=%d", room.PlaybackState, room.Version)
+		}
+	})
+
+	t.Run("TestSyncSkippedWhileBuffering", func(t *testing.T) {
+		hub.HandleMessage(bob.conn, []byte(`{"type":"sync","data":{"current_time":0}}`))
+		if bob.has("seek") {
+			t.Error("缓冲中的成员不应收到同步跳转")
+		}
+	})
+
+	t.Run("TestResumeWhenReady", func(t *testing.T) {
+		before := time.Now().UnixMilli()
+		hub.HandleMessage(bob.conn, []byte(`{"type":"ready"}`))
+		waitFor(t, "成员收到预约的播放指令", func() bool { return scheduledPlay(alice) && scheduledPlay(bob) })
+
+		room := db.stored("ROOM01")
+		if !room.IsPlaying() || room.Version != 3 {
+			t.Errorf("所有成员就绪后应恢复播放: state=%s version=%d", room.PlaybackState, room.Version)
+		}
+		if room.PlaybackUpdatedAt < before+scheduleMargin.Milliseconds() {
+			t.Errorf("恢复时刻应至少预留 %s: %d", scheduleMargin, room.PlaybackUpdatedAt-before)
+		}
+	})
+}
+
+// 测试没有播放控制权限的成员缓冲时不会暂停房间
+func TestHub_BufferingRequiresControl(t *testing.T) {
+	db := newFakeDB()
+	hub := newInstance(t, db, HubOptions{Permissions: &fakeMembers{}, BufferingTimeout: time.Minute})
+	alice := newTestClient("ROOM01", "alice", 64, true)
+	bob := newTestClient("ROOM01", "bob", 64, true)
+	for _, client := range []*testClient{alice, bob} {
+		if err := hub.RegisterClient(client.conn); err != nil {
+			t.Fatalf("注册连接失败: %v", err)
+		}
+	}
+	hub.HandleMessage(alice.conn, []byte(`{"type":"play","start_time":10}`))
+	waitFor(t, "成员收到播放指令", func() bool { return bob.has("play") })
+
+	settings := model.DefaultRoomSettings()
+	settings.HostOnlyControl = true
+	hub.PublishSettings("ROOM01", settings)
+	hub.HandleMessage(bob.conn, []byte(`{"type":"buffering"}`))
+	waitFor(t, "缓冲被拒绝", func() bool {
+		return bob.find(MsgTypeError, func(msg map[string]interface{}) bool { return msg["code"] == "permission_denied" })
+	})
+	hub.HandleMessage(bob.conn, []byte(`{"type":"ready"}`))
+
+	if alice.has(MsgTypeBuffering) || alice.has(MsgTypeReady) || alice.has("pause") {
+		t.Error("没有权限的成员缓冲不应通知其他成员或暂停房间")
+	}
+	if room := db.stored("ROOM01"); !room.IsPlaying() {
+		t.Errorf("房间应继续播放: state=%s", room.PlaybackState)
+	}
+}
+
+// 测试等待超时后不再等待仍在缓冲的成员
+func TestHub_BufferingTimeout(t *testing.T) {
+	hub, db, alice, bob := newPlayingRoom(t, 50*time.Millisecond)
+
+	hub.HandleMessage(bob.conn, []byte(`{"type":"buffering"}`))
+	waitFor(t, "等待超时通知", func() bool {
+		return alice.find("buffering_timeout", func(msg map[string]interface{}) bool {
+			waiting, _ := msg["waiting"].([]interface{})
+			return len(waiting) == 1 && waiting[0] == "bob"
+		})
+	})
+	waitFor(t, "超时后恢复播放", func() bool { return scheduledPlay(alice) })
+
+	// 超时后就绪不再触发恢复
+	hub.HandleMessage(bob.conn, []byte(`{"type":"ready"}`))
+	waitFor(t, "其他成员收到就绪通知", func() bool { return alice.has(MsgTypeReady) })
+	if room := db.stored("ROOM01"); !room.IsPlaying() || room.Version != 3 {
+		t.Errorf("超时恢复后状态不正确: state=%s version=%d", room.PlaybackState, room.Version)
+	}
+}
+
+// 测试自动暂停期间有人手动控制播放时，以手动操作为准
+func TestHub_BufferingManualOverride(t *testing.T) {
+	hub, db, alice, bob := newPlayingRoom(t, time.Minute)
+
+	hub.HandleMessage(bob.conn, []byte(`{"type":"buffering"}`))
+	waitFor(t, "房间被自动暂停", func() bool { return db.stored("ROOM01").Version == 2 && alice.has("pause") })
+
+	hub.HandleMessage(alice.conn, []byte(`{"type":"pause","pause_time":30}`))
+	hub.HandleMessage(bob.conn, []byte(`{"type":"ready"}`))
+
+	waitFor(t, "其他成员收到就绪通知", func() bool { return alice.has(MsgTypeReady) })
+	if room := db.stored("ROOM01"); room.IsPlaying() || room.Version != 3 {
+		t.Errorf("手动暂停后不应自动恢复: state=%s version=%d", room.PlaybackState, room.Version)
+	}
+}
@@ -0,0 +1,187 @@
+package websocket
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"xiaowo/backend/internal/webrtc"
+)
+
+// ICEServerFunc 返回会话加入通话时使用的 ICE 服务器（STUN/TURN）
+type ICEServerFunc func(sessionID string) []webrtc.ICEServer
+
+// SignalMessage 通话信令（offer、answer 和 ICE 候选），只转发给目标会话
+//
+// 客户端发送时填写 target_session_id 以及 sdp 或 candidate，内容原样转发，
+// session_id 由服务器根据已认证的连接填写。发送方和接收方都需要已加入通话。
+type SignalMessage struct {
+	Type            string          `json:"type"`                // "webrtc_offer" | "webrtc_answer" | "webrtc_ice_candidate"
+	RoomID          string          `json:"room_id"`             // 房间ID
+	SessionID       string          `json:"session_id"`          // 发送方会话ID
+	TargetSessionID string          `json:"target_session_id"`   // 接收方会话ID
+	SDP             json.RawMessage `json:"sdp,omitempty"`       // 会话描述（RTCSessionDescriptionInit）
+	Candidate       json.RawMessage `json:"candidate,omitempty"` // ICE 候选（RTCIceCandidateInit），null 表示收集结束
+}
+
+// handleCallJoin 加入房间通话
+//
+// 加入者收到 webrtc_joined（通话中的其他会话和 ICE 服务器），之后向每个已在通话中的会话发起 offer；
+// 其他连接收到 webrtc_join。重复加入只重新下发 webrtc_joined。
+func (h *WebSocketHub) handleCallJoin(conn *WebSocketConnection) {
+	var servers []webrtc.ICEServer
+	if h.iceServers != nil {
+		servers = h.iceServers(conn.sessionID)
+	}
+	if servers == nil {
+		servers = []webrtc.ICEServer{}
+	}
+
+	var joined bool
+	conn.room.call(func(r *Room) {
+		if !conn.inCall {
+			conn.inCall = true
+			joined = true
+			r.broadcastOthers(callMessage(MsgTypeWebRTCJoin, r.ID, conn.sessionID, r.callParticipants()), conn)
+		}
+
+		participants := r.callParticipants()
+		others := make([]string, 0, len(participants))
+		for _, sessionID := range participants {
+			if sessionID != conn.sessionID {
+				others = append(others, sessionID)
+			}
+		}
+		conn.sendJSON("webrtc_joined", map[string]interface{}{
+			"type":         "webrtc_joined",
+			"room_id":      r.ID,
+			"session_id":   conn.sessionID,
+			"participants": others,
+			"ice_servers":  servers,
+			"timestamp":    time.Now().Unix(),
+		})
+	})
+	if joined {
+		h.publish(eventCallJoin, conn.roomID, 0, conn.sessionID, nil)
+	}
+}
+
+// leaveCall 退出房间通话，未加入时忽略
+func (h *WebSocketHub) leaveCall(conn *WebSocketConnection) {
+	var left bool
+	conn.room.call(func(r *Room) {
+		left = r.dropFromCall(conn)
+	})
+	if left {
+		h.publish(eventCallLeave, conn.roomID, 0, conn.sessionID, nil)
+	}
+}
+
+// handleSignal 将 offer、answer 或 ICE 候选转发给目标会话，目标在其他实例上时经 Broker 转发
+func (h *WebSocketHub) handleSignal(conn *WebSocketConnection, msgType string, message []byte) {
+	var msg SignalMessage
+	if err := json.Unmarshal(message, &msg); err != nil || msg.TargetSessionID == "" {
+		h.sendError(conn, "webrtc_failed", "信令消息格式错误")
+		return
+	}
+	if (msgType == MsgTypeWebRTCICECandidate && len(msg.Candidate) == 0) ||
+		(msgType != MsgTypeWebRTCICECandidate && len(msg.SDP) == 0) {
+		h.sendError(conn, "webrtc_failed", "信令消息缺少内容")
+		return
+	}
+	if msg.TargetSessionID == conn.sessionID {
+		h.sendError(conn, "webrtc_failed", "不能向自己发送信令")
+		return
+	}
+	msg.Type = msgType
+	msg.RoomID = conn.roomID
+	msg.SessionID = conn.sessionID
+
+	var inCall, delivered, remote bool
+	conn.room.call(func(r *Room) {
+		if inCall = conn.inCall; !inCall {
+			return
+		}
+		if delivered = r.deliverSignal(msg); !delivered {
+			remote = r.remoteCall[msg.TargetSessionID]
+		}
+	})
+	switch {
+	case !inCall:
+		h.sendError(conn, "webrtc_failed", "尚未加入通话")
+	case remote:
+		h.publish(eventSignal, conn.roomID, 0, conn.sessionID, msg)
+	case !delivered:
+		h.sendError(conn, "webrtc_failed", "对方不在通话中")
+	}
+}
+
+// signalOp 转发其他实例发来的信令
+func signalOp(msg SignalMessage) roomOp {
+	return func(r *Room) {
+		r.deliverSignal(msg)
+	}
+}
+
+// remoteCallOp 记录其他实例上会话的通话状态并通知本实例的连接
+func remoteCallOp(sessionID string, joined bool) roomOp {
+	return func(r *Room) {
+		if r.remoteCall[sessionID] == joined {
+			return
+		}
+		msgType := MsgTypeWebRTCLeave
+		if joined {
+			r.remoteCall[sessionID] = true
+			msgType = MsgTypeWebRTCJoin
+		} else {
+			delete(r.remoteCall, sessionID)
+		}
+		r.broadcast(callMessage(msgType, r.ID, sessionID, r.callParticipants()))
+	}
+}
+
+// deliverSignal 将信令发给本实例上已加入通话的目标连接（仅在房间协程中调用）
+func (r *Room) deliverSignal(msg SignalMessage) bool {
+	target, ok := r.clients[msg.TargetSessionID]
+	if !ok || !target.inCall {
+		return false
+	}
+	target.sendJSON(msg.Type, msg)
+	return true
+}
+
+// dropFromCall 连接退出通话并通知房间（仅在房间协程中调用）
+func (r *Room) dropFromCall(conn *WebSocketConnection) bool {
+	if !conn.inCall {
+		return false
+	}
+	conn.inCall = false
+	r.broadcast(callMessage(MsgTypeWebRTCLeave, r.ID, conn.sessionID, r.callParticipants()))
+	return true
+}
+
+// callParticipants 所有实例上已加入通话的会话ID，按字典序排列（仅在房间协程中调用）
+func (r *Room) callParticipants() []string {
+	participants := make([]string, 0, len(r.remoteCall))
+	for sessionID := range r.remoteCall {
+		participants = append(participants, sessionID)
+	}
+	for sessionID, conn := range r.clients {
+		if conn.inCall {
+			participants = append(participants, sessionID)
+		}
+	}
+	sort.Strings(participants)
+	return participants
+}
+
+// callMessage 会话加入/退出通话通知，participants 为通话中的全部会话
+func callMessage(msgType, roomID, sessionID string, participants []string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":         msgType,
+		"room_id":      roomID,
+		"session_id":   sessionID,
+		"participants": participants,
+		"timestamp":    time.Now().Unix(),
+	}
+}
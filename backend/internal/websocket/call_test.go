@@ -0,0 +1,181 @@
+package websocket
+
+import (
+	"sync"
+	"testing"
+
+	"xiaowo/backend/internal/broker"
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/service"
+	"xiaowo/backend/internal/webrtc"
+)
+
+// fakeMembers 所有会话都是普通成员，记录读取次数
+type fakeMembers struct {
+	mu    sync.Mutex
+	reads int
+}
+
+func (m *fakeMembers) GetMember(roomID, sessionID string) (*model.RoomMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reads++
+	return &model.RoomMember{RoomID: roomID, SessionID: sessionID, Role: model.RoleMember}, nil
+}
+
+func (m *fakeMembers) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reads
+}
+
+// inCall 通知中的通话成员是否恰好为 sessions
+func inCall(msg map[string]interface{}, sessions ...string) bool {
+	participants, _ := msg["participants"].([]interface{})
+	if len(participants) != len(sessions) {
+		return false
+	}
+	for i, sessionID := range sessions {
+		if participants[i] != sessionID {
+			return false
+		}
+	}
+	return true
+}
+
+// 测试加入、定向转发信令和退出通话
+func TestHub_Call(t *testing.T) {
+	hub := newInstance(t, newFakeDB(), HubOptions{
+		Permissions: &fakeMembers{},
+		ICEServers: func(sessionID string) []webrtc.ICEServer {
+			return []webrtc.ICEServer{{URLs: []string{"turn:turn.example.com"}, Username: "1:" + sessionID, Credential: "c"}}
+		},
+	})
+	alice := newTestClient("ROOM01", "alice", 64, true)
+	bob := newTestClient("ROOM01", "bob", 64, true)
+	carol := newTestClient("ROOM01", "carol", 64, true)
+	for _, client := range []*testClient{alice, bob, carol} {
+		if err := hub.RegisterClient(client.conn); err != nil {
+			t.Fatalf("注册连接失败: %v", err)
+		}
+	}
+
+	t.Run("TestJoin", func(t *testing.T) {
+		hub.HandleMessage(alice.conn, []byte(`{"type":"webrtc_join"}`))
+		hub.HandleMessage(bob.conn, []byte(`{"type":"webrtc_join"}`))
+		waitFor(t, "加入者收到通话成员和 ICE 服务器", func() bool {
+			return bob.find("webrtc_joined", func(msg map[string]interface{}) bool {
+				servers, _ := msg["ice_servers"].([]interface{})
+				if len(servers) != 1 {
+					return false
+				}
+				server, _ := servers[0].(map[string]interface{})
+				return inCall(msg, "alice") && server["username"] == "1:bob"
+			})
+		})
+		waitFor(t, "其他连接收到加入通知", func() bool {
+			return carol.find(MsgTypeWebRTCJoin, func(msg map[string]interface{}) bool {
+				return msg["session_id"] == "bob" && inCall(msg, "alice", "bob")
+			})
+		})
+		if bob.find(MsgTypeWebRTCJoin, func(msg map[string]interface{}) bool { return msg["session_id"] == "bob" }) {
+			t.Error("加入者不应收到自己的加入通知")
+		}
+	})
+
+	t.Run("TestSignalTargeted", func(t *testing.T) {
+		hub.HandleMessage(bob.conn, []byte(`{"type":"webrtc_offer","target_session_id":"alice","session_id":"carol","sdp":{"type":"offer","sdp":"v=0"}}`))
+		waitFor(t, "目标收到 offer", func() bool {
+			return alice.find(MsgTypeWebRTCOffer, func(msg map[string]interface{}) bool {
+				sdp, _ := msg["sdp"].(map[string]interface{})
+				return msg["session_id"] == "bob" && sdp["sdp"] == "v=0"
+			})
+		})
+		if carol.has(MsgTypeWebRTCOffer) || bob.has(MsgTypeWebRTCOffer) {
+			t.Error("信令只应发给目标会话")
+		}
+	})
+
+	t.Run("TestSignalRejected", func(t *testing.T) {
+		hub.HandleMessage(carol.conn, []byte(`{"type":"webrtc_offer","target_session_id":"alice","sdp":{}}`))
+		hub.HandleMessage(bob.conn, []byte(`{"type":"webrtc_ice_candidate","target_session_id":"carol","candidate":null}`))
+		hub.HandleMessage(bob.conn, []byte(`{"type":"webrtc_answer","target_session_id":"alice"}`))
+		waitFor(t, "未加入通话的发送方收到错误", func() bool { return carol.has(MsgTypeError) })
+		waitFor(t, "目标不在通话中和缺少内容时收到错误", func() bool {
+			return bob.find(MsgTypeError, func(msg map[string]interface{}) bool { return msg["message"] == "对方不在通话中" }) &&
+				bob.find(MsgTypeError, func(msg map[string]interface{}) bool { return msg["message"] == "信令消息缺少内容" })
+		})
+	})
+
+	t.Run("TestPermissionRevoked", func(t *testing.T) {
+		// 禁言事件刷新连接缓存的成员信息
+		hub.PublishMemberEvent(service.MemberEventMuted, &model.RoomMember{RoomID: "ROOM01", SessionID: "bob", Role: model.RoleMember, IsMuted: true})
+		hub.HandleMessage(bob.conn, []byte(`{"type":"webrtc_ice_candidate","target_session_id":"alice","candidate":null}`))
+		waitFor(t, "权限被收回的成员退出通话", func() bool {
+			return alice.find(MsgTypeWebRTCLeave, func(msg map[string]interface{}) bool {
+				return msg["session_id"] == "bob" && inCall(msg, "alice")
+			})
+		})
+		if alice.has(MsgTypeWebRTCICECandidate) {
+			t.Error("没有权限时不应转发信令")
+		}
+	})
+
+	t.Run("TestLeaveOnDisconnect", func(t *testing.T) {
+		hub.HandleMessage(carol.conn, []byte(`{"type":"webrtc_join"}`))
+		waitFor(t, "加入通知", func() bool {
+			return alice.find(MsgTypeWebRTCJoin, func(msg map[string]interface{}) bool { return msg["session_id"] == "carol" })
+		})
+		hub.UnregisterClient(carol.conn)
+		waitFor(t, "断开的连接退出通话", func() bool {
+			return alice.find(MsgTypeWebRTCLeave, func(msg map[string]interface{}) bool {
+				return msg["session_id"] == "carol" && inCall(msg, "alice")
+			})
+		})
+	})
+}
+
+// 测试通话成员和信令在实例之间同步
+func TestHub_CallMultiInstance(t *testing.T) {
+	b := broker.NewMemory()
+	t.Cleanup(func() { b.Close() })
+	db := newFakeDB()
+	hubA := newInstance(t, db, HubOptions{Broker: b, InstanceID: "A"})
+	hubB := newInstance(t, db, HubOptions{Broker: b, InstanceID: "B"})
+
+	alice := newTestClient("ROOM01", "alice", 64, true)
+	bob := newTestClient("ROOM01", "bob", 64, true)
+	if err := hubA.RegisterClient(alice.conn); err != nil {
+		t.Fatalf("注册连接失败: %v", err)
+	}
+	if err := hubB.RegisterClient(bob.conn); err != nil {
+		t.Fatalf("注册连接失败: %v", err)
+	}
+	waitFor(t, "收到其他实例的成员加入通知", func() bool { return alice.has("member_join") })
+
+	hubA.HandleMessage(alice.conn, []byte(`{"type":"webrtc_join"}`))
+	waitFor(t, "其他实例的连接收到加入通知", func() bool {
+		return bob.find(MsgTypeWebRTCJoin, func(msg map[string]interface{}) bool { return inCall(msg, "alice") })
+	})
+	hubB.HandleMessage(bob.conn, []byte(`{"type":"webrtc_join"}`))
+	waitFor(t, "加入者看到其他实例上的通话成员", func() bool {
+		return bob.find("webrtc_joined", func(msg map[string]interface{}) bool { return inCall(msg, "alice") })
+	})
+	waitFor(t, "其他实例记录加入", func() bool {
+		return alice.find(MsgTypeWebRTCJoin, func(msg map[string]interface{}) bool { return inCall(msg, "alice", "bob") })
+	})
+
+	hubB.HandleMessage(bob.conn, []byte(`{"type":"webrtc_offer","target_session_id":"alice","sdp":"v=0"}`))
+	waitFor(t, "信令经 Broker 转发给目标", func() bool {
+		return alice.find(MsgTypeWebRTCOffer, func(msg map[string]interface{}) bool {
+			return msg["session_id"] == "bob" && msg["sdp"] == "v=0"
+		})
+	})
+
+	hubB.UnregisterClient(bob.conn)
+	waitFor(t, "其他实例上的会话下线后退出通话", func() bool {
+		return alice.find(MsgTypeWebRTCLeave, func(msg map[string]interface{}) bool {
+			return msg["session_id"] == "bob" && inCall(msg, "alice")
+		})
+	})
+}
@@ -0,0 +1,82 @@
+package websocket
+
+import (
+	"sort"
+	"time"
+)
+
+// Client 不经过 WebSocket 的房间连接，供其他协议的网关（如 Syncplay）接入房间
+//
+// 收发的消息与浏览器连接相同：Messages 返回下发给该会话的消息（每个元素是一条 JSON），
+// Send 提交一条客户端消息。Messages 关闭表示连接已被移出房间（同一会话在别处重新连接、
+// 成员被移出、房间关闭或消费过慢），调用方应随之断开。
+type Client struct {
+	hub  *WebSocketHub
+	conn *WebSocketConnection
+}
+
+// Attach 以会话身份接入房间（调用前需已完成鉴权），同一会话已有的连接会被替换
+func (h *WebSocketHub) Attach(roomID, sessionID string) (*Client, error) {
+	conn := &WebSocketConnection{
+		roomID:    roomID,
+		sessionID: sessionID,
+		send:      make(chan []byte, 256),
+	}
+	if err := h.RegisterClient(conn); err != nil {
+		return nil, err
+	}
+	return &Client{hub: h, conn: conn}, nil
+}
+
+// RoomID 连接所在的房间ID
+func (c *Client) RoomID() string {
+	return c.conn.roomID
+}
+
+// SessionID 连接对应的会话ID
+func (c *Client) SessionID() string {
+	return c.conn.sessionID
+}
+
+// Messages 下发给该连接的消息，连接被移出房间后关闭
+func (c *Client) Messages() <-chan []byte {
+	return c.conn.send
+}
+
+// Send 提交一条客户端消息，处理方式与浏览器连接发送的消息相同
+func (c *Client) Send(message []byte) {
+	c.hub.HandleMessage(c.conn, message)
+}
+
+// ReportRTT 记录网关测得的往返时延，用于估算预约指令的提前量
+func (c *Client) ReportRTT(rtt time.Duration) {
+	c.conn.calculateSmoothedRTT(rtt.Milliseconds())
+}
+
+// Close 离开房间，可重复调用
+func (c *Client) Close() {
+	c.hub.UnregisterClient(c.conn)
+}
+
+// OnlineSessions 返回所有实例上房间内在线的会话ID（按字典序），本实例没有该房间的连接时返回空
+func (h *WebSocketHub) OnlineSessions(roomID string) []string {
+	room := h.lookupRoom(roomID)
+	if room == nil {
+		return nil
+	}
+
+	var sessions []string
+	room.call(func(r *Room) {
+		for sessionID := range r.clients {
+			sessions = append(sessions, sessionID)
+		}
+		for sessionID := range r.remote {
+			sessions = append(sessions, sessionID)
+		}
+		for sessionID := range r.parked {
+			sessions = append(sessions, sessionID)
+		}
+	})
+	sort.Strings(sessions)
+	return sessions
+}
@@ -0,0 +1,101 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/service"
+)
+
+// DanmakuStore 弹幕存储（由 service.DanmakuService 实现）
+type DanmakuStore interface {
+	SendDanmaku(roomID, sessionID string, req *service.DanmakuRequest) (*model.Danmaku, error)
+}
+
+// DanmakuMessage 弹幕（type 为 danmaku）或表情回应（type 为 reaction）消息
+//
+// 客户端发送时只需要 content，可选 time（媒体时间点，为空时取当前播放位置）、mode 和 color，
+// 其余字段由服务器根据已认证的连接和房间当前的媒体填写。
+type DanmakuMessage struct {
+	Type        string   `json:"type"`            // "danmaku" | "reaction"
+	ID          string   `json:"id,omitempty"`    // 弹幕ID
+	RoomID      string   `json:"room_id"`         // 房间ID
+	SessionID   string   `json:"session_id"`      // 发送者会话ID
+	DisplayName string   `json:"display_name"`    // 发送者显示名称
+	MediaURL    string   `json:"media_url"`       // 弹幕所属的媒体地址
+	Content     string   `json:"content"`         // 弹幕内容或表情
+	Time        *float64 `json:"time"`            // 媒体时间点（秒）
+	Mode        int      `json:"mode,omitempty"`  // 显示模式: 1 滚动/4 底部/5 顶部
+	Color       *int     `json:"color,omitempty"` // 颜色 0xRRGGBB
+	Timestamp   int64    `json:"timestamp"`       // 发送时间戳（毫秒）
+}
+
+// newDanmakuMessage 由存储的弹幕构建下发的消息
+func newDanmakuMessage(danmaku *model.Danmaku) DanmakuMessage {
+	msgType := MsgTypeDanmaku
+	if danmaku.Kind == model.DanmakuKindReaction {
+		msgType = MsgTypeReaction
+	}
+	return DanmakuMessage{
+		Type:        msgType,
+		ID:          danmaku.ID,
+		RoomID:      danmaku.RoomID,
+		SessionID:   danmaku.SessionID,
+		DisplayName: danmaku.Nickname,
+		MediaURL:    danmaku.MediaURL,
+		Content:     danmaku.Content,
+		Time:        &danmaku.Time,
+		Mode:        danmaku.Mode,
+		Color:       &danmaku.Color,
+		Timestamp:   danmaku.CreatedAt.UnixMilli(),
+	}
+}
+
+// handleDanmaku 处理弹幕和表情回应消息，保存后广播给所有实例上房间内的连接
+func (h *WebSocketHub) handleDanmaku(conn *WebSocketConnection, msgType string, message []byte) {
+	var msg DanmakuMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		h.sendError(conn, "danmaku_failed", "弹幕消息格式错误")
+		return
+	}
+
+	if h.danmaku == nil {
+		h.sendError(conn, "danmaku_failed", "服务器未配置弹幕存储")
+		return
+	}
+
+	kind := model.DanmakuKindComment
+	if msgType == MsgTypeReaction {
+		kind = model.DanmakuKindReaction
+	}
+	stored, err := h.danmaku.SendDanmaku(conn.roomID, conn.sessionID, &service.DanmakuRequest{
+		Kind:    kind,
+		Content: msg.Content,
+		Time:    msg.Time,
+		Mode:    msg.Mode,
+		Color:   msg.Color,
+	})
+	switch {
+	case errors.Is(err, model.ErrDanmakuRateLimited):
+		h.sendError(conn, "danmaku_rate_limited", "发送太频繁，请稍后再试")
+		return
+	case errors.Is(err, model.ErrInvalidDanmaku):
+		h.sendError(conn, "danmaku_failed", "弹幕内容不合法")
+		return
+	case err != nil:
+		h.sendError(conn, "danmaku_failed", "弹幕发送失败")
+		return
+	}
+
+	danmaku := newDanmakuMessage(stored)
+	conn.room.post(danmakuOp(danmaku))
+	h.publish(eventDanmaku, conn.roomID, 0, conn.sessionID, danmaku)
+}
+
+// danmakuOp 广播弹幕
+func danmakuOp(danmaku DanmakuMessage) roomOp {
+	return func(r *Room) {
+		r.broadcast(danmaku)
+	}
+}
@@ -0,0 +1,95 @@
+package websocket
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/service"
+)
+
+// fakeDanmaku 内存弹幕存储，content 为 err 的键时返回对应错误
+type fakeDanmaku struct {
+	errs map[string]error
+}
+
+func (d *fakeDanmaku) SendDanmaku(roomID, sessionID string, req *service.DanmakuRequest) (*model.Danmaku, error) {
+	if err := d.errs[req.Content]; err != nil {
+		return nil, err
+	}
+	at := 0.0
+	if req.Time != nil {
+		at = *req.Time
+	}
+	return &model.Danmaku{
+		ID:        sessionID + "-" + req.Content,
+		RoomID:    roomID,
+		MediaURL:  "https://example.com/video.mp4",
+		Time:      at,
+		Kind:      req.Kind,
+		Content:   req.Content,
+		Mode:      req.Mode,
+		SessionID: sessionID,
+		Nickname:  "昵称-" + sessionID,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// 测试弹幕和表情回应保存后广播给房间内所有连接，存储错误映射为对应的错误码
+func TestHub_Danmaku(t *testing.T) {
+	hub := newInstance(t, newFakeDB(), HubOptions{Danmaku: &fakeDanmaku{errs: map[string]error{
+		"limited": model.ErrDanmakuRateLimited,
+		"invalid": model.ErrInvalidDanmaku,
+		"broken":  errors.New("database is locked"),
+	}}})
+	alice := newTestClient("ROOM01", "alice", 64, true)
+	bob := newTestClient("ROOM01", "bob", 64, true)
+	for _, client := range []*testClient{alice, bob} {
+		if err := hub.RegisterClient(client.conn); err != nil {
+			t.Fatalf("注册连接失败: %v", err)
+		}
+	}
+
+	t.Run("TestBroadcast", func(t *testing.T) {
+		hub.HandleMessage(alice.conn, []byte(`{"type":"danmaku","content":"前方高能","time":12.5,"mode":5,"session_id":"forged"}`))
+		hub.HandleMessage(bob.conn, []byte(`{"type":"reaction","content":"🔥"}`))
+
+		for _, client := range []*testClient{alice, bob} {
+			waitFor(t, "收到弹幕", func() bool {
+				return client.find(MsgTypeDanmaku, func(msg map[string]interface{}) bool {
+					return msg["content"] == "前方高能" && msg["time"] == 12.5 && msg["mode"] == 5.0 &&
+						msg["session_id"] == "alice" && msg["display_name"] == "昵称-alice" && msg["media_url"] != ""
+				})
+			})
+			waitFor(t, "收到表情回应", func() bool {
+				return client.find(MsgTypeReaction, func(msg map[string]interface{}) bool {
+					return msg["content"] == "🔥" && msg["session_id"] == "bob"
+				})
+			})
+		}
+	})
+
+	t.Run("TestErrors", func(t *testing.T) {
+		tests := []struct {
+			content string
+			code    string
+			message string
+		}{
+			{"limited", "danmaku_rate_limited", "发送太频繁，请稍后再试"},
+			{"invalid", "danmaku_failed", "弹幕内容不合法"},
+			{"broken", "danmaku_failed", "弹幕发送失败"},
+		}
+		for _, tt := range tests {
+			hub.HandleMessage(bob.conn, []byte(`{"type":"danmaku","content":"`+tt.content+`"}`))
+			waitFor(t, tt.content+" 返回错误", func() bool {
+				return bob.find(MsgTypeError, func(msg map[string]interface{}) bool {
+					return msg["code"] == tt.code && msg["message"] == tt.message
+				})
+			})
+		}
+		if alice.find(MsgTypeDanmaku, func(msg map[string]interface{}) bool { return msg["session_id"] == "bob" }) {
+			t.Error("发送失败的弹幕不应广播")
+		}
+	})
+}
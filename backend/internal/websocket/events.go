@@ -0,0 +1,325 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"xiaowo/backend/internal/broker"
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/service"
+)
+
+// publishTimeout 向其他实例发布房间事件的超时时间
+const publishTimeout = 3 * time.Second
+
+// 跨实例房间事件类型
+//
+// 本实例的变更先在本地房间生效，再经 Broker 发给其他实例；收到自己发布的事件时直接忽略。
+const (
+	eventPlayback  = "playback"   // 播放状态变更，Version 为存储分配的版本号
+	eventChat      = "chat"       // 聊天消息
+	eventSettings  = "settings"   // 房间设置变更
+	eventMember    = "member"     // 成员状态变更（禁言、移出、转让房主等）
+	eventJoin      = "join"       // 会话在发布实例上线
+	eventLeave     = "leave"      // 会话从发布实例下线
+	eventClose     = "close"      // 房间被停用或删除
+	eventBuffering = "buffering"  // 会话的播放器开始缓冲
+	eventReady     = "ready"      // 会话的播放器缓冲完成
+	eventPlaylist  = "playlist"   // 播放列表变更，Version 为列表版本号
+	eventSubtitle  = "subtitle"   // 字幕轨道变更
+	eventDanmaku   = "danmaku"    // 弹幕或表情回应
+	eventCallJoin  = "call_join"  // 会话加入通话
+	eventCallLeave = "call_leave" // 会话退出通话
+	eventSignal    = "signal"     // 发给本实例上会话的通话信令
+	eventPresence  = "presence"   // 会话报告空闲或恢复活跃
+
+	eventRosterRequest = "roster_request" // 发布实例新打开房间，请求其他实例报告在线的会话
+	eventRoster        = "roster"         // 回复 roster_request，Data 为发布实例上在线的会话及其状态
+)
+
+// playbackEvent 播放状态变更事件数据
+type playbackEvent struct {
+	Action string        `json:"action"`
+	State  PlaybackState `json:"state"`
+}
+
+// memberEvent 成员状态变更事件数据
+type memberEvent struct {
+	Event  string            `json:"event"`
+	Member *model.RoomMember `json:"member"`
+}
+
+// publish 将房间事件发给其他实例，失败只记录日志（本实例的连接已在本地处理）
+//
+// 调用方不能持有 h.mu：进程内 Broker 队列满时会等待订阅方回调，而回调可能需要 h.mu。
+func (h *WebSocketHub) publish(eventType, roomID string, version int64, sessionID string, data interface{}) {
+	event := broker.Event{
+		Type:      eventType,
+		RoomID:    roomID,
+		Origin:    h.instance,
+		Version:   version,
+		SessionID: sessionID,
+	}
+	if data != nil {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			log.Printf("broker: encode %s event for room %s: %v", eventType, roomID, err)
+			return
+		}
+		event.Data = payload
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	if err := h.broker.Publish(ctx, event); err != nil {
+		log.Printf("broker: publish %s event for room %s: %v", eventType, roomID, err)
+	}
+}
+
+// receive 处理其他实例发布的房间事件（在 Broker 的回调协程中执行）
+func (h *WebSocketHub) receive(room *Room, event broker.Event) {
+	if event.Origin == h.instance {
+		return
+	}
+
+	switch event.Type {
+	case eventPlayback:
+		var data playbackEvent
+		if err := json.Unmarshal(event.Data, &data); err == nil {
+			room.post(playbackOp(data.Action, data.State, event.Version))
+		}
+	case eventChat:
+		var chat ChatMessage
+		if err := json.Unmarshal(event.Data, &chat); err == nil {
+			room.post(chatOp(chat))
+		}
+	case eventDanmaku:
+		var danmaku DanmakuMessage
+		if err := json.Unmarshal(event.Data, &danmaku); err == nil {
+			room.post(danmakuOp(danmaku))
+		}
+	case eventPlaylist:
+		var playlist model.Playlist
+		if err := json.Unmarshal(event.Data, &playlist); err == nil {
+			room.post(playlistOp(&playlist))
+		}
+	case eventSubtitle:
+		var tracks model.SubtitleTracks
+		if err := json.Unmarshal(event.Data, &tracks); err == nil {
+			room.post(subtitleOp(&tracks))
+		}
+	case eventSettings:
+		var settings model.RoomSettings
+		if err := json.Unmarshal(event.Data, &settings); err == nil {
+			room.post(settingsOp(settings))
+		}
+	case eventMember:
+		var data memberEvent
+		if err := json.Unmarshal(event.Data, &data); err == nil && data.Member != nil {
+			room.post(memberOp(data.Event, data.Member))
+		}
+	case eventJoin:
+		// 同一会话在其他实例上线，断开本实例上的旧连接
+		h.takeover(event.RoomID, event.SessionID)
+		room.post(remoteJoinOp(event.SessionID, event.Origin))
+		room.post(h.remoteBufferingOp(event.SessionID, false))
+		// 新连接需要重新加入通话
+		room.post(remoteCallOp(event.SessionID, false))
+	case eventLeave:
+		room.post(remoteLeaveOp(event.SessionID, event.Origin))
+		room.post(h.checkStall)
+	case eventBuffering:
+		room.post(h.remoteBufferingOp(event.SessionID, true))
+	case eventReady:
+		room.post(h.remoteBufferingOp(event.SessionID, false))
+	case eventPresence:
+		var presence PresenceMessage
+		if err := json.Unmarshal(event.Data, &presence); err == nil {
+			room.post(remotePresenceOp(event.SessionID, event.Origin, presence.Status == string(model.StatusIdle)))
+		}
+	case eventCallJoin:
+		room.post(remoteCallOp(event.SessionID, true))
+	case eventCallLeave:
+		room.post(remoteCallOp(event.SessionID, false))
+	case eventSignal:
+		var signal SignalMessage
+		if err := json.Unmarshal(event.Data, &signal); err == nil {
+			room.post(signalOp(signal))
+		}
+	case eventRosterRequest:
+		room.post(h.replyRosterOp())
+	case eventRoster:
+		var roster rosterEvent
+		if err := json.Unmarshal(event.Data, &roster); err == nil {
+			room.post(h.rosterOp(roster, event.Origin))
+		}
+	case eventClose:
+		h.evictLocal(event.RoomID)
+	}
+}
+
+// takeover 会话已在其他实例上线，静默断开本实例上该会话的连接
+func (h *WebSocketHub) takeover(roomID, sessionID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// 断线等待续接的会话已在其他实例重新连接
+	if p, ok := h.parked[sessionID]; ok && p.room.ID == roomID {
+		h.unpark(sessionID, p, false)
+	}
+
+	conn, ok := h.clients[sessionID]
+	if !ok || conn.roomID != roomID {
+		return
+	}
+	delete(h.clients, sessionID)
+	conn.close()
+	h.detach(conn, false)
+	h.updateGauges()
+}
+
+// playbackOp 应用播放状态并广播，忽略比缓存旧的版本
+func playbackOp(action string, state PlaybackState, version int64) roomOp {
+	return func(r *Room) {
+		if !r.applyState(state, version) {
+			return
+		}
+		// 自动暂停之后有人手动改变了播放状态，以手动操作为准
+		if r.stall != nil && r.stall.version > 0 && version > r.stall.version {
+			r.endStall()
+		}
+
+		msg := map[string]interface{}{
+			"type":          action,
+			"current_time":  state.CurrentTime,
+			"is_playing":    state.IsPlaying,
+			"playback_rate": state.PlaybackRate,
+			"last_updated":  state.LastUpdated,
+			"version":       version,
+		}
+		if action == service.PlaybackActionSeek {
+			msg["target_time"] = state.CurrentTime
+		}
+		// 切换播放列表条目时附带新的媒体信息
+		if action == service.PlaybackActionChangeMedia {
+			msg["video_url"] = state.VideoURL
+			msg["video_title"] = state.VideoTitle
+			msg["duration"] = state.Duration
+		}
+		// 预约生效的指令，客户端按对时结果换算为本地时间后执行
+		if state.Scheduled(time.Now().UnixMilli()) {
+			msg["execute_at"] = state.LastUpdated
+		}
+		r.broadcast(msg)
+	}
+}
+
+// chatOp 广播聊天消息
+func chatOp(chat ChatMessage) roomOp {
+	return func(r *Room) {
+		r.broadcast(chat)
+	}
+}
+
+// settingsOp 更新房间设置并推送
+func settingsOp(settings model.RoomSettings) roomOp {
+	return func(r *Room) {
+		r.settings = settings
+		r.broadcast(map[string]interface{}{
+			"type":     "room_settings",
+			"room_id":  r.ID,
+			"settings": settings,
+		})
+	}
+}
+
+// memberOp 广播成员状态变更并刷新连接缓存的成员信息，被移出的成员收到通知后断开，读协程退出时完成注销
+func memberOp(event string, member *model.RoomMember) roomOp {
+	msg := map[string]interface{}{
+		"type":       event,
+		"room_id":    member.RoomID,
+		"session_id": member.SessionID,
+		"nickname":   member.Nickname,
+		"role":       member.Role,
+		"is_muted":   member.IsMuted,
+		"timestamp":  time.Now().Unix(),
+	}
+	sessionID := member.SessionID
+	removed := event == service.MemberEventKicked || event == service.MemberEventLeft
+	var cached *model.RoomMember
+	if !removed {
+		copied := *member
+		cached = &copied
+	}
+
+	return func(r *Room) {
+		r.broadcast(msg)
+		conn, ok := r.clients[sessionID]
+		if !ok {
+			return
+		}
+		// 刷新连接缓存的成员信息，之后的消息按新的角色和禁言状态校验权限
+		conn.member, conn.memberSet = cached, true
+		if removed {
+			conn.close()
+		}
+	}
+}
+
+// closeOp 通知房间已关闭并断开所有连接，读协程退出时完成注销
+func closeOp() roomOp {
+	return func(r *Room) {
+		r.broadcast(map[string]interface{}{
+			"type":      "room_closed",
+			"room_id":   r.ID,
+			"timestamp": time.Now().Unix(),
+		})
+		for _, conn := range r.clients {
+			conn.close()
+		}
+	}
+}
+
+// presenceMessage 成员上线/下线通知
+func presenceMessage(msgType, roomID, sessionID string, count int) map[string]interface{} {
+	return map[string]interface{}{
+		"type":         msgType,
+		"session_id":   sessionID,
+		"room_id":      roomID,
+		"timestamp":    time.Now().Unix(),
+		"member_count": count,
+	}
+}
+
+// remoteJoinOp 记录在其他实例上线的会话并通知本实例的连接
+//
+// 会话在原实例上断线续接时同样会发布上线事件，此时本实例看来它一直在线，不再通知。
+func remoteJoinOp(sessionID, origin string) roomOp {
+	return func(r *Room) {
+		if r.remote[sessionID] == origin {
+			return
+		}
+		r.remote[sessionID] = origin
+		r.broadcast(presenceMessage("member_join", r.ID, sessionID, r.memberCount()))
+		r.announcePresence(sessionID, model.PresenceOnline)
+	}
+}
+
+// remoteLeaveOp 会话从其他实例下线，会话已在别处重新上线时忽略
+func remoteLeaveOp(sessionID, origin string) roomOp {
+	return func(r *Room) {
+		if r.remote[sessionID] != origin {
+			return
+		}
+		delete(r.remote, sessionID)
+		delete(r.remoteBuffering, sessionID)
+		if r.remoteCall[sessionID] {
+			delete(r.remoteCall, sessionID)
+			r.broadcast(callMessage(MsgTypeWebRTCLeave, r.ID, sessionID, r.callParticipants()))
+		}
+		r.broadcast(presenceMessage("member_leave", r.ID, sessionID, r.memberCount()))
+		r.announcePresence(sessionID, model.PresenceAway)
+	}
+}
@@ -2,42 +2,107 @@ package websocket
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"math"
 	"sort"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+
+	"xiaowo/backend/internal/broker"
+	"xiaowo/backend/internal/metrics"
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/service"
+)
+
+// ErrInvalidToken 访问令牌无效
+var ErrInvalidToken = errors.New("invalid access token")
+
+// ErrHubClosed Hub 已关闭，不再接受新连接
+var ErrHubClosed = errors.New("websocket hub closed")
+
+// errRoomStopped 注册时房间已停止，需要重新创建
+var errRoomStopped = errors.New("room stopped")
+
+// Authenticator 令牌鉴权函数，校验令牌对房间有效并返回对应的会话ID
+type Authenticator func(token, roomID string) (sessionID string, err error)
+
+// PlaybackStore 播放状态存储（由 service.RoomService 实现）
+type PlaybackStore interface {
+	GetRoom(roomID string) (*model.Room, error)
+	ApplyPlayback(roomID string, cmd service.PlaybackCommand) (*model.Room, error)
+}
+
+// ChatStore 聊天消息存储（由 service.MessageService 实现）
+type ChatStore interface {
+	SendChat(roomID, sessionID, content string) (*model.Message, error)
+	GetRecentMessages(roomID string, limit int) ([]*model.Message, error)
+}
+
+// PermissionChecker 房间操作权限校验所需的成员信息（由 service.MemberService 实现）
+//
+// 成员信息在连接注册时读取一次并缓存在连接上，之后随成员变更事件（禁言、转让房主等）刷新；
+// 房间权限设置取自房间缓存的设置，处理消息时不再访问存储。
+type PermissionChecker interface {
+	GetMember(roomID, sessionID string) (*model.RoomMember, error)
+}
+
+// HubOptions Hub 依赖项
+type HubOptions struct {
+	Authenticator Authenticator // 令牌鉴权（为空时拒绝所有 auth 消息）
+	Playback      PlaybackStore // 播放状态存储（为空时拒绝播放控制消息）
+	Chat          ChatStore     // 聊天消息存储（为空时拒绝聊天消息）
+	Permissions   PermissionChecker // 权限校验（为空时不做限制）
+	Broker        broker.Broker     // 跨实例房间事件（为空时使用进程内实现，即单实例部署）
+	InstanceID    string            // 本实例ID（为空时随机生成）
+	BufferingTimeout time.Duration  // 等待缓冲成员的最长时间（为空时使用 DefaultBufferingTimeout）
+	Playlist      PlaylistStore     // 播放列表存储（为空时拒绝播放列表消息，不自动切换条目）
+	Subtitles     SubtitleStore     // 字幕存储（为空时拒绝切换字幕消息）
+	Danmaku       DanmakuStore      // 弹幕存储（为空时拒绝弹幕和表情回应消息）
+	ICEServers    ICEServerFunc     // 通话的 ICE 服务器（为空时不下发，只能在可直连的网络之间通话）
+	ResumeWindow  time.Duration     // 连接意外断开后等待续接的时间（为空时使用 DefaultResumeWindow）
+	Presence      PresenceStore     // 会话在线状态存储（为空时不记录上线、下线和心跳）
+}
+
+// chatBackfillLimit 新连接补发的历史消息条数
+const chatBackfillLimit = 50
+
+// 往返时延探测
+const (
+	pingTimeout = 10 * time.Second // 探测 ping 的写超时，超过后不再等待其 pong
+	maxRTT      = 3 * time.Second  // 单次时延样本上限，避免故意迟回 pong 的客户端无限拉长房间的指令提前量
 )
 
 // WebSocketHub WebSocket连接管理中心
+//
+// h.mu 只保护 clients、rooms 索引和各房间的 members 计数，持有期间只会向房间投递操作，
+// 不会等待房间执行完成；房间内的状态由各房间协程独占（见 Room）。
+// 每个连接的发送队列只由连接自身关闭（见 WebSocketConnection.close）。
+// 多实例部署时，房间事件经 Broker 在实例之间同步（见 events.go）。
 type WebSocketHub struct {
-	rooms     map[string]*Room
-	clients   map[string]*WebSocketConnection
-	broadcast chan []byte
-	register  chan *WebSocketConnection
-	unregister chan *WebSocketConnection
-	mu        sync.RWMutex
-}
-
-// Room 房间连接管理
-type Room struct {
-	ID        string
-	clients   map[string]*WebSocketConnection
-	version   int64 // 乐观锁版本
-	state     PlaybackState
-	mu        sync.RWMutex
-}
-
-// PlaybackState 播放状态
-type PlaybackState struct {
-	CurrentTime  float64 `json:"current_time"`
-	Duration     float64 `json:"duration"`
-	IsPlaying    bool    `json:"is_playing"`
-	PlaybackRate float64 `json:"playback_rate"`
-	VideoURL     string  `json:"video_url"`
-	VideoTitle   string  `json:"video_title"`
-	LastUpdated  int64   `json:"last_updated"`
+	rooms    map[string]*Room
+	clients  map[string]*WebSocketConnection
+	closed   bool
+	quit     chan struct{}
+	stopOnce sync.Once
+	auth     Authenticator
+	store    PlaybackStore
+	chat     ChatStore
+	perms    PermissionChecker
+	broker   broker.Broker
+	instance string
+	bufferingTimeout time.Duration
+	playlist PlaylistStore
+	subtitles SubtitleStore
+	danmaku  DanmakuStore
+	iceServers ICEServerFunc
+	resumeWindow time.Duration
+	parked   map[string]*parkedSession // 断线等待续接的会话（见 resume.go）
+	presence PresenceStore
+	mu       sync.RWMutex
 }
 
 // Message 基础消息类型
@@ -58,8 +123,50 @@ const (
 	MsgTypeRate    = "rate"
 	MsgTypeError   = "error"
 	MsgTypeHeartbeat = "heartbeat"
+	MsgTypeBuffering = "buffering"
+	MsgTypeReady     = "ready"
+	MsgTypeCountdown = "countdown"
+	MsgTypePlaylistAdd    = "playlist_add"
+	MsgTypePlaylistRemove = "playlist_remove"
+	MsgTypePlaylistMove   = "playlist_move"
+	MsgTypePlaylistSet    = "playlist_set"
+	MsgTypePlaylistNext   = "playlist_next"
+	MsgTypePlaylistPrev   = "playlist_prev"
+	MsgTypeSubtitleSet    = "subtitle_set"
+	MsgTypeDanmaku        = "danmaku"
+	MsgTypeReaction       = "reaction"
+	MsgTypeWebRTCJoin         = "webrtc_join"
+	MsgTypeWebRTCLeave        = "webrtc_leave"
+	MsgTypeWebRTCOffer        = "webrtc_offer"
+	MsgTypeWebRTCAnswer       = "webrtc_answer"
+	MsgTypeWebRTCICECandidate = "webrtc_ice_candidate"
+	MsgTypePresence           = "presence"
 )
 
+// messageActions 需要权限校验的消息类型
+var messageActions = map[string]service.RoomAction{
+	MsgTypePlay:  service.ActionControlPlayback,
+	MsgTypePause: service.ActionControlPlayback,
+	MsgTypeRate:  service.ActionControlPlayback,
+	MsgTypeCountdown: service.ActionControlPlayback,
+	MsgTypeBuffering: service.ActionControlPlayback, // 缓冲会自动暂停房间；就绪只清除成员自己的缓冲状态，无需校验
+	MsgTypeSeek:  service.ActionSeek,
+	MsgTypeChat:  service.ActionChat,
+	MsgTypePlaylistAdd:    service.ActionEditPlaylist,
+	MsgTypePlaylistRemove: service.ActionEditPlaylist,
+	MsgTypePlaylistMove:   service.ActionEditPlaylist,
+	MsgTypePlaylistSet:    service.ActionEditPlaylist,
+	MsgTypePlaylistNext:   service.ActionEditPlaylist,
+	MsgTypePlaylistPrev:   service.ActionEditPlaylist,
+	MsgTypeSubtitleSet:    service.ActionControlPlayback,
+	MsgTypeDanmaku:        service.ActionChat,
+	MsgTypeReaction:       service.ActionChat,
+	MsgTypeWebRTCJoin:         service.ActionJoinCall,
+	MsgTypeWebRTCOffer:        service.ActionJoinCall,
+	MsgTypeWebRTCAnswer:       service.ActionJoinCall,
+	MsgTypeWebRTCICECandidate: service.ActionJoinCall,
+}
+
 // PingMessage ping 消息
 type PingMessage struct {
 	Type    string `json:"type"`              // "ping"
@@ -96,16 +203,33 @@ type SyncData struct {
 	IsPlaying    bool    `json:"is_playing"`    // 是否正在播放
 	PlaybackRate float64 `json:"playback_rate"` // 播放倍速
 	BaseVersion  int64   `json:"base_version"`  // 基础版本号（乐观锁）
+	ClientTime   int64   `json:"client_time,omitempty"` // 采样 current_time 时的客户端时间（毫秒）
 }
 
 // ChatMessage 聊天消息
+//
+// 客户端发送时只需要 message 字段，其余字段由服务器根据已认证的连接填写。
 type ChatMessage struct {
 	Type        string    `json:"type"`         // "chat"
+	ID          string    `json:"id,omitempty"` // 消息ID
 	RoomID      string    `json:"room_id"`      // 房间ID
 	SessionID   string    `json:"session_id"`   // 发送者会话ID
 	DisplayName string    `json:"display_name"` // 发送者显示名称
 	Message     string    `json:"message"`      // 消息内容
-	Timestamp   int64     `json:"timestamp"`    // 发送时间戳
+	Timestamp   int64     `json:"timestamp"`    // 发送时间戳（毫秒）
+}
+
+// newChatMessage 由存储的消息构建下发的聊天消息
+func newChatMessage(message *model.Message) ChatMessage {
+	return ChatMessage{
+		Type:        MsgTypeChat,
+		ID:          message.ID,
+		RoomID:      message.RoomID,
+		SessionID:   message.SessionID,
+		DisplayName: message.GetDisplayName(),
+		Message:     message.Content,
+		Timestamp:   message.CreatedAt.UnixMilli(),
+	}
 }
 
 // PlayMessage 播放消息
@@ -113,6 +237,7 @@ type PlayMessage struct {
 	Type    string `json:"type"`    // "play"
 	RoomID  string `json:"room_id"` // 房间ID
 	StartTime float64 `json:"start_time"` // 开始播放时间
+	BaseVersion *int  `json:"base_version,omitempty"` // 基础版本号（乐观锁）
 }
 
 // PauseMessage 暂停消息
@@ -120,6 +245,7 @@ type PauseMessage struct {
 	Type    string `json:"type"`    // "pause"
 	RoomID  string `json:"room_id"` // 房间ID
 	PauseTime float64 `json:"pause_time"` // 暂停时间
+	BaseVersion *int  `json:"base_version,omitempty"` // 基础版本号（乐观锁）
 }
 
 // SeekMessage 跳转消息
@@ -127,6 +253,7 @@ type SeekMessage struct {
 	Type    string `json:"type"`    // "seek"
 	RoomID  string `json:"room_id"` // 房间ID
 	TargetTime float64 `json:"target_time"` // 目标播放时间
+	BaseVersion *int   `json:"base_version,omitempty"` // 基础版本号（乐观锁）
 }
 
 // RateMessage 倍速消息
@@ -134,6 +261,16 @@ type RateMessage struct {
 	Type    string `json:"type"`    // "rate"
 	RoomID  string `json:"room_id"` // 房间ID
 	PlaybackRate   float64 `json:"playback_rate"` // 播放倍速
+	BaseVersion    *int    `json:"base_version,omitempty"` // 基础版本号（乐观锁）
+}
+
+// CountdownMessage 倒计时开播消息
+type CountdownMessage struct {
+	Type        string  `json:"type"`                   // "countdown"
+	RoomID      string  `json:"room_id"`                // 房间ID
+	Seconds     int     `json:"seconds"`                // 倒计时秒数
+	StartTime   float64 `json:"start_time"`             // 开始播放的位置，为 0 时沿用当前位置
+	BaseVersion *int    `json:"base_version,omitempty"` // 基础版本号（乐观锁）
 }
 
 // WebSocketConnection WebSocket连接
@@ -141,46 +278,75 @@ type WebSocketConnection struct {
 	ws        *websocket.Conn
 	roomID    string
 	sessionID string
+	room      *Room // 注册时绑定，读协程启动后不再变化
+	buffering bool  // 播放器是否正在缓冲（仅在房间协程中读写）
+	inCall    bool  // 是否已加入通话（仅在房间协程中读写）
+	member    *model.RoomMember // 权限校验用的成员信息，为空表示不是成员（仅在房间协程中读写）
+	memberSet bool              // member 是否已加载或已被成员变更事件刷新（仅在房间协程中读写）
+	resumeToken string         // 续接令牌，为空表示断开后不等待续接（见 resume.go）
+	resume      *ResumeRequest // 客户端重连时携带的续接信息
 	send      chan []byte
+	sendMu    sync.Mutex // 保护 send 的写入与关闭
+	closed    bool
 	// RTT 相关
 	rtts           []int64 // 最近3次RTT测量
+	rtt            int64   // 平滑后的RTT（毫秒）
+	pingSentAt     time.Time // 尚未收到 pong 的探测 ping 发送时间
+	lastTouch      time.Time // 上次记录心跳的时间（见 touchPresence）
 	lastCalibrate  time.Time
-	timeOffset     int64 // 时钟偏移量
+	timeOffset     int64 // 时钟偏移量（服务器时间 - 客户端时间，毫秒）
 	mu             sync.RWMutex
 }
 
-// Register 注册WebSocket连接
-func (h *WebSocketHub) Register(conn *websocket.Conn, roomID, sessionID string) {
+// Register 注册WebSocket连接，resume 为客户端重连时携带的续接信息（可为空）
+func (h *WebSocketHub) Register(conn *websocket.Conn, roomID, sessionID string, resume *ResumeRequest) {
 	// 创建WebSocket连接对象
 	wsConn := &WebSocketConnection{
 		ws:        conn,
 		roomID:    roomID,
 		sessionID: sessionID,
 		send:      make(chan []byte, 256),
+		resumeToken: uuid.NewString(),
+		resume:      resume,
 	}
 	
 	// 注册连接
-	h.register <- wsConn
+	if err := h.RegisterClient(wsConn); err != nil {
+		conn.Close()
+		return
+	}
 	
 	// 启动读写协程
 	go wsConn.readPump(h)
 	go wsConn.writePump()
+
+	// 连接建立后立即测一次往返时延，首次对时即可使用
+	wsConn.probeRTT()
 }
 
 // readPump 从WebSocket连接读取消息
 func (c *WebSocketConnection) readPump(hub *WebSocketHub) {
 	defer func() {
-		hub.unregister <- c
+		hub.UnregisterClient(c)
 		c.ws.Close()
 	}()
 	
-	c.ws.SetReadLimit(512)
+	c.ws.SetReadLimit(16 << 10) // 通话的 SDP 通常有数 KB，聊天消息最长 2000 字节
 	c.ws.SetReadDeadline(time.Now().Add(60 * time.Second))
-	c.ws.SetPongHandler(func(string) error { c.ws.SetReadDeadline(time.Now().Add(60 * time.Second)); return nil })
+	c.ws.SetPongHandler(func(string) error {
+		c.ws.SetReadDeadline(time.Now().Add(60 * time.Second))
+		c.recordPong(time.Now())
+		hub.touchPresence(c)
+		return nil
+	})
 	
 	for {
 		_, message, err := c.ws.ReadMessage()
 		if err != nil {
+			// 客户端主动关闭时直接离开房间，不等待续接
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				c.close()
+			}
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				// 记录错误
 			}
@@ -227,8 +393,7 @@ func (c *WebSocketConnection) writePump() {
 				return
 			}
 		case <-ticker.C:
-			c.ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := c.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+			if err := c.probeRTT(); err != nil {
 				return
 			}
 		}
@@ -244,127 +409,315 @@ type SyncAction struct {
 }
 
 // NewWebSocketHub 创建WebSocket Hub
-func NewWebSocketHub() *WebSocketHub {
-	return &WebSocketHub{
-		rooms:       make(map[string]*Room),
-		clients:     make(map[string]*WebSocketConnection),
-		broadcast:   make(chan []byte),
-		register:    make(chan *WebSocketConnection),
-		unregister:  make(chan *WebSocketConnection),
+func NewWebSocketHub(opts HubOptions) *WebSocketHub {
+	h := &WebSocketHub{
+		rooms:    make(map[string]*Room),
+		clients:  make(map[string]*WebSocketConnection),
+		quit:     make(chan struct{}),
+		auth:     opts.Authenticator,
+		store:    opts.Playback,
+		chat:     opts.Chat,
+		perms:    opts.Permissions,
+		broker:   opts.Broker,
+		instance: opts.InstanceID,
+		bufferingTimeout: opts.BufferingTimeout,
+		playlist: opts.Playlist,
+		subtitles: opts.Subtitles,
+		danmaku:  opts.Danmaku,
+		iceServers: opts.ICEServers,
+		resumeWindow: opts.ResumeWindow,
+		parked:   make(map[string]*parkedSession),
+		presence: opts.Presence,
+	}
+	if h.broker == nil {
+		h.broker = broker.NewMemory()
+	}
+	if h.instance == "" {
+		h.instance = uuid.NewString()
+	}
+	if h.bufferingTimeout <= 0 {
+		h.bufferingTimeout = DefaultBufferingTimeout
 	}
+	if h.resumeWindow <= 0 {
+		h.resumeWindow = DefaultResumeWindow
+	}
+	return h
 }
 
-// Run 运行WebSocket Hub主循环
-func (h *WebSocketHub) Run() {
-	for {
-		select {
-		case client := <-h.register:
-			h.registerClient(client)
-		case client := <-h.unregister:
-			h.unregisterClient(client)
-		case message := <-h.broadcast:
-			h.broadcastToAll(message)
-		}
+// Shutdown 停止周期任务，关闭所有连接并停止房间协程
+func (h *WebSocketHub) Shutdown() {
+	h.stopOnce.Do(func() {
+		close(h.quit)
+		h.closeAll()
+	})
+}
+
+// closeAll 关闭所有连接（写协程会发送关闭帧后断开）
+func (h *WebSocketHub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sessionID, conn := range h.clients {
+		conn.close()
+		delete(h.clients, sessionID)
+	}
+	for sessionID, p := range h.parked {
+		p.timer.Stop()
+		delete(h.parked, sessionID)
+	}
+	for roomID, room := range h.rooms {
+		room.stop()
+		delete(h.rooms, roomID)
 	}
+	h.updateGauges()
 }
 
-// RegisterClient 注册客户端连接
-func (h *WebSocketHub) RegisterClient(conn *WebSocketConnection) {
-	h.register <- conn
+// updateGauges 刷新连接数和房间数指标（调用方需持有 h.mu）
+func (h *WebSocketHub) updateGauges() {
+	metrics.WebSocketConnections.Set(float64(len(h.clients)))
+	metrics.ActiveRooms.Set(float64(len(h.rooms)))
+}
+
+// RegisterClient 注册客户端连接，Hub 已关闭时返回 ErrHubClosed
+func (h *WebSocketHub) RegisterClient(conn *WebSocketConnection) error {
+	// 历史消息在加锁前读取，避免持有 h.mu 访问存储
+	messages := h.recentChat(conn.roomID)
+
+	for {
+		// 新房间在加锁前完成订阅和状态加载，避免持有 h.mu 访问 Broker 和存储
+		room, opened := h.lookupRoom(conn.roomID), false
+		if room == nil {
+			room, opened = h.openRoom(conn.roomID), true
+		}
+
+		err := h.registerClient(conn, room, opened, messages)
+		if errors.Is(err, errRoomStopped) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		h.loadMember(conn)
+
+		// 通知其他实例该会话已在本实例上线
+		h.setPresence(conn.roomID, conn.sessionID, model.StatusOnline)
+		h.publish(eventJoin, conn.roomID, 0, conn.sessionID, nil)
+		return nil
+	}
 }
 
-// UnregisterClient 取消注册客户端连接
+// UnregisterClient 取消注册客户端连接，可重复调用
 func (h *WebSocketHub) UnregisterClient(conn *WebSocketConnection) {
-	h.unregister <- conn
+	if h.unregisterClient(conn) {
+		h.setPresence(conn.roomID, conn.sessionID, model.StatusOffline)
+		h.publish(eventLeave, conn.roomID, 0, conn.sessionID, nil)
+	}
 }
 
-// registerClient 注册客户端连接（内部方法）
-func (h *WebSocketHub) registerClient(conn *WebSocketConnection) {
+// registerClient 注册客户端连接（内部方法），opened 表示 room 是本次新建的房间
+func (h *WebSocketHub) registerClient(conn *WebSocketConnection, room *Room, opened bool, messages []ChatMessage) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	if h.closed {
+		if opened {
+			room.stop()
+		}
+		conn.close()
+		return ErrHubClosed
+	}
+	old, replaced := h.clients[conn.sessionID]
+	if replaced && old == conn {
+		if opened {
+			room.stop()
+		}
+		return nil
+	}
+
+	// 加锁前查到或新建的房间可能已经过时
+	switch current, ok := h.rooms[conn.roomID]; {
+	case !ok && opened:
+		h.rooms[conn.roomID] = room
+	case !ok:
+		return errRoomStopped
+	case current != room:
+		if opened {
+			room.stop()
+		}
+		room = current
+	}
+
+	// 加入房间（先计入新连接，避免替换旧连接时房间中途归零被停止）
+	conn.room = room
+	room.members++
 	h.clients[conn.sessionID] = conn
 
-	// 加入房间
-	room := h.getOrCreateRoom(conn.roomID)
-	room.mu.Lock()
-	room.clients[conn.sessionID] = conn
-	room.mu.Unlock()
+	// 同一会话重复连接时关闭旧连接，旧连接静默离开；断线等待续接的会话不再等待。
+	// 会话在同一房间内重新连接时，其他成员看来一直在线，不再广播加入通知
+	var rejoin bool
+	var resumeToken string
+	if replaced {
+		old.close()
+		h.detach(old, false)
+		rejoin, resumeToken = old.room == room, old.resumeToken
+	}
+	if p, ok := h.parked[conn.sessionID]; ok {
+		h.unpark(conn.sessionID, p, false)
+		rejoin, resumeToken = p.room == room, p.token
+	}
+	lastSeq := int64(-1)
+	if resume := conn.resume; resume != nil && rejoin && resumeToken != "" && resume.Token == resumeToken {
+		lastSeq = resume.LastSeq
+	}
+	h.updateGauges()
+
+	room.post(func(r *Room) {
+		r.clients[conn.sessionID] = conn
+		delete(r.remote, conn.sessionID)
 
-	// 发送房间当前状态
-	h.sendRoomState(conn.roomID, conn)
-	
-	// 广播成员加入通知给房间内其他成员
-	h.broadcastToRoom(conn.roomID, map[string]interface{}{
-		"type":        "member_join",
-		"session_id":  conn.sessionID,
-		"room_id":     conn.roomID,
-		"timestamp":   time.Now().Unix(),
-		"member_count": len(room.clients),
+		// 续接时只补发断开期间错过的广播，否则发送房间当前状态
+		if conn.resume == nil || !r.replay(conn, lastSeq) {
+			r.sendState(conn, messages)
+		}
+
+		// 广播成员加入通知，重新连接的会话恢复为活跃
+		if !rejoin {
+			r.broadcast(presenceMessage("member_join", r.ID, conn.sessionID, r.memberCount()))
+		}
+		if !rejoin || r.idle[conn.sessionID] {
+			r.announcePresence(conn.sessionID, model.PresenceOnline)
+		}
 	})
+	return nil
 }
 
-// unregisterClient 取消注册客户端连接（内部方法）
-func (h *WebSocketHub) unregisterClient(conn *WebSocketConnection) {
+// unregisterClient 取消注册客户端连接（内部方法），返回会话是否由本次调用下线
+//
+// 意外断开的连接（服务器未主动关闭）保留会话等待续接，此时会话尚未下线。
+func (h *WebSocketHub) unregisterClient(conn *WebSocketConnection) bool {
+	resumable := conn.resumeToken != "" && !conn.isClosed()
+	conn.close()
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if _, ok := h.clients[conn.sessionID]; ok {
-		delete(h.clients, conn.sessionID)
-		close(conn.send)
-
-		// 从房间移除
-		if room, ok := h.rooms[conn.roomID]; ok {
-			room.mu.Lock()
-			memberCount := len(room.clients)
-			delete(room.clients, conn.sessionID)
-			room.mu.Unlock()
-
-			// 广播成员退出通知给房间内其他成员
-			h.broadcastToRoom(conn.roomID, map[string]interface{}{
-				"type":        "member_leave",
-				"session_id":  conn.sessionID,
-				"room_id":     conn.roomID,
-				"timestamp":   time.Now().Unix(),
-				"member_count": memberCount - 1,
-			})
-
-			// 如果房间为空，清理房间
-			if len(room.clients) == 0 {
-				delete(h.rooms, conn.roomID)
-			}
+	if current, ok := h.clients[conn.sessionID]; !ok || current != conn {
+		return false
+	}
+	delete(h.clients, conn.sessionID)
+	defer h.updateGauges()
+	if resumable && !h.closed {
+		h.park(conn)
+		return false
+	}
+	h.detach(conn, true)
+	return true
+}
+
+// detach 将连接移出所在房间，房间没有连接后停止房间协程（调用方需持有 h.mu）
+func (h *WebSocketHub) detach(conn *WebSocketConnection, announce bool) {
+	room := conn.room
+	room.post(func(r *Room) {
+		if r.clients[conn.sessionID] != conn {
+			return
 		}
+		delete(r.clients, conn.sessionID)
+		// 其他实例经会话下线或重新上线事件得知其退出通话
+		r.dropFromCall(conn)
+
+		// 广播成员退出通知给房间内其他成员
+		if announce {
+			r.broadcast(presenceMessage("member_leave", r.ID, conn.sessionID, r.memberCount()))
+			r.announcePresence(conn.sessionID, model.PresenceAway)
+		}
+		// 离开的成员可能是最后一个仍在缓冲的
+		h.checkStall(r)
+	})
+	h.release(room)
+}
+
+// release 释放连接或等待续接的会话占用的计数，房间归零后停止房间协程（调用方需持有 h.mu）
+func (h *WebSocketHub) release(room *Room) {
+	room.members--
+	if room.members == 0 {
+		// 房间可能已被 EvictRoom 移除并被同ID的新房间取代
+		if h.rooms[room.ID] == room {
+			delete(h.rooms, room.ID)
+		}
+		room.stop()
 	}
 }
 
-// getOrCreateRoom 获取或创建房间
-func (h *WebSocketHub) getOrCreateRoom(roomID string) *Room {
-	room, exists := h.rooms[roomID]
-	if !exists {
-		room = &Room{
-			ID:      roomID,
-			clients: make(map[string]*WebSocketConnection),
-			version: 0,
-			state: PlaybackState{
-				CurrentTime:  0,
-				Duration:     0,
-				IsPlaying:    false,
-				PlaybackRate: 1.0,
-				LastUpdated:  time.Now().Unix(),
-			},
+// openRoom 新建房间：先订阅其他实例的房间事件，再从存储加载持久化的状态，最后启动房间协程
+//
+// 加载前其他实例的变更已写入存储，加载后的变更经订阅排在初始状态之后执行，
+// 播放状态再按版本号合并，因此不会丢失或回退。
+// 启动后请求其他实例报告房间内在线的会话，这些会话在本实例打开房间之前上线，没有收到过它们的 join 事件。
+func (h *WebSocketHub) openRoom(roomID string) *Room {
+	room := newRoom(roomID)
+
+	sub, err := h.broker.Subscribe(roomID, func(event broker.Event) {
+		h.receive(room, event)
+	})
+	if err != nil {
+		log.Printf("broker: subscribe room %s: %v", roomID, err)
+	} else {
+		room.onStop = func() { sub.Unsubscribe() }
+	}
+
+	if h.store != nil {
+		if stored, err := h.store.GetRoom(roomID); err == nil {
+			room.state = playbackStateFromRoom(stored)
+			room.version = int64(stored.Version)
+			room.settings = stored.GetSettings()
+		}
+	}
+	if h.playlist != nil {
+		if playlist, err := h.playlist.GetPlaylist(roomID); err == nil {
+			room.playlist = playlist
+		}
+		room.onEnded = func(version int64) {
+			h.advancePlaylist(roomID, version)
+		}
+	}
+	if h.subtitles != nil {
+		if tracks, err := h.subtitles.GetTracks(roomID); err == nil {
+			room.subtitles = tracks
 		}
-		h.rooms[roomID] = room
 	}
+
+	room.start()
+	h.publish(eventRosterRequest, roomID, 0, "", nil)
 	return room
 }
 
+// lookupRoom 获取有在线连接的房间，不存在时返回 nil
+func (h *WebSocketHub) lookupRoom(roomID string) *Room {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.rooms[roomID]
+}
+
 // HandleMessage 处理WebSocket消息
 func (h *WebSocketHub) HandleMessage(conn *WebSocketConnection, message []byte) {
 	var msg Message
 	if err := json.Unmarshal(message, &msg); err != nil {
+		metrics.WebSocketMessages.WithLabelValues("in", "invalid").Inc()
 		h.sendError(conn, "invalid_message", "消息格式错误")
 		return
 	}
+	metrics.WebSocketMessages.WithLabelValues("in", inboundType(msg.Type)).Inc()
+	h.touchPresence(conn)
+
+	if action, ok := messageActions[msg.Type]; ok && !h.authorize(conn, action) {
+		// 通话权限被收回（如房主关闭通话或禁言）后不再留在通话中
+		if action == service.ActionJoinCall {
+			h.leaveCall(conn)
+		}
+		return
+	}
 
 	switch msg.Type {
 	case MsgTypePing:
@@ -385,11 +738,87 @@ func (h *WebSocketHub) HandleMessage(conn *WebSocketConnection, message []byte)
 		h.handleSeek(conn, message)
 	case MsgTypeRate:
 		h.handleRate(conn, message)
+	case MsgTypeBuffering:
+		h.handleBuffering(conn)
+	case MsgTypeReady:
+		h.handleReady(conn)
+	case MsgTypeCountdown:
+		h.handleCountdown(conn, message)
+	case MsgTypePlaylistAdd, MsgTypePlaylistRemove, MsgTypePlaylistMove,
+		MsgTypePlaylistSet, MsgTypePlaylistNext, MsgTypePlaylistPrev:
+		h.handlePlaylist(conn, msg.Type, message)
+	case MsgTypeSubtitleSet:
+		h.handleSubtitleSet(conn, message)
+	case MsgTypeDanmaku, MsgTypeReaction:
+		h.handleDanmaku(conn, msg.Type, message)
+	case MsgTypeWebRTCJoin:
+		h.handleCallJoin(conn)
+	case MsgTypeWebRTCLeave:
+		h.leaveCall(conn)
+	case MsgTypeWebRTCOffer, MsgTypeWebRTCAnswer, MsgTypeWebRTCICECandidate:
+		h.handleSignal(conn, msg.Type, message)
+	case MsgTypePresence:
+		h.handlePresence(conn, message)
 	default:
 		h.sendError(conn, "unknown_message_type", "未知消息类型")
 	}
 }
 
+// inboundType 客户端消息类型的指标标签，未知类型统一归为 unknown 以限制标签基数
+func inboundType(msgType string) string {
+	switch msgType {
+	case MsgTypePing, MsgTypePong, MsgTypeAuth, MsgTypeSync, MsgTypeChat,
+		MsgTypePlay, MsgTypePause, MsgTypeSeek, MsgTypeRate, MsgTypeBuffering, MsgTypeReady, MsgTypeCountdown,
+		MsgTypePlaylistAdd, MsgTypePlaylistRemove, MsgTypePlaylistMove, MsgTypePlaylistSet, MsgTypePlaylistNext, MsgTypePlaylistPrev,
+		MsgTypeSubtitleSet, MsgTypeDanmaku, MsgTypeReaction,
+		MsgTypeWebRTCJoin, MsgTypeWebRTCLeave, MsgTypeWebRTCOffer, MsgTypeWebRTCAnswer, MsgTypeWebRTCICECandidate,
+		MsgTypePresence:
+		return msgType
+	}
+	return "unknown"
+}
+
+// loadMember 读取连接的成员信息供权限校验使用
+//
+// 在连接加入房间之后读取：读取期间到达的成员变更事件已经刷新了缓存，不会被较旧的读取结果覆盖。
+func (h *WebSocketHub) loadMember(conn *WebSocketConnection) {
+	if h.perms == nil {
+		return
+	}
+	member, err := h.perms.GetMember(conn.roomID, conn.sessionID)
+	if err != nil {
+		member = nil
+	}
+	conn.room.post(func(r *Room) {
+		if !conn.memberSet {
+			conn.member, conn.memberSet = member, true
+		}
+	})
+}
+
+// authorize 校验连接对应的成员能否执行操作，拒绝时回复错误帧
+func (h *WebSocketHub) authorize(conn *WebSocketConnection, action service.RoomAction) bool {
+	if h.perms == nil {
+		return true
+	}
+
+	var err error
+	if !conn.room.call(func(r *Room) { err = service.Permit(conn.member, r.settings.Permissions(), action) }) {
+		return false
+	}
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, model.ErrMemberMuted):
+		h.sendError(conn, "muted", "你已被房主禁言")
+	case errors.Is(err, model.ErrNotRoomMember):
+		h.sendError(conn, "permission_denied", "你已不是房间成员")
+	default:
+		h.sendError(conn, "permission_denied", "没有权限执行该操作")
+	}
+	return false
+}
+
 // handlePing 处理ping消息
 func (h *WebSocketHub) handlePing(conn *WebSocketConnection, message []byte) {
 	var pingMsg PingMessage
@@ -408,6 +837,9 @@ func (h *WebSocketHub) handlePing(conn *WebSocketConnection, message []byte) {
 }
 
 // handleCalibration 处理对时消息
+//
+// 往返时延由服务器自己的 ping/pong 测量（见 probeRTT），不采信客户端上报的数值：
+// 客户端时钟与服务器不同步，且上报值可以伪造，会拉长整个房间的指令提前量。
 func (h *WebSocketHub) handleCalibration(conn *WebSocketConnection, pingMsg PingMessage) {
 	serverRecvTime := time.Now().UnixMilli()
 	
@@ -419,21 +851,53 @@ func (h *WebSocketHub) handleCalibration(conn *WebSocketConnection, pingMsg Ping
 		ServerSendTime: time.Now().UnixMilli(),
 	}
 	
-	// 计算RTT
-	rawRTT := pongMsg.ServerSendTime - pingMsg.ClientSendTime
-	
-	// RTT平滑处理 (中位数算法)
-	smoothedRTT := conn.calculateSmoothedRTT(rawRTT)
-	
-	// 计算时钟偏移
-	offset := pongMsg.ServerRecvTime - pingMsg.ClientSendTime - (smoothedRTT / 2)
+	// 计算时钟偏移并记录校准时间，客户端发出 ping 后经过单程时延到达服务器
+	offset := pongMsg.ServerRecvTime - pingMsg.ClientSendTime - (conn.SmoothedRTT() / 2)
 	conn.SetTimeOffset(offset)
-	
-	// 记录校准时间
-	conn.lastCalibrate = time.Now()
+	metrics.ClockOffset.Observe(math.Abs(float64(offset)) / 1000)
 	
 	// 发送pong响应
-	h.sendJSON(conn, pongMsg)
+	conn.sendJSON(MsgTypePong, pongMsg)
+
+	// 客户端对时期间顺带补充时延样本
+	conn.probeRTT()
+}
+
+// probeRTT 发送探测 ping 并记录发送时间，上一个探测尚未收到 pong 时不重复发送
+func (c *WebSocketConnection) probeRTT() error {
+	if c.ws == nil {
+		return nil
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	if !c.pingSentAt.IsZero() && now.Sub(c.pingSentAt) < pingTimeout {
+		c.mu.Unlock()
+		return nil
+	}
+	c.pingSentAt = now
+	c.mu.Unlock()
+
+	// WriteControl 可以与写协程的普通写入并发调用
+	return c.ws.WriteControl(websocket.PingMessage, nil, now.Add(pingTimeout))
+}
+
+// recordPong 收到探测 ping 的 pong，记录往返时延（在读协程中调用）
+func (c *WebSocketConnection) recordPong(now time.Time) {
+	c.mu.Lock()
+	sentAt := c.pingSentAt
+	c.pingSentAt = time.Time{}
+	c.mu.Unlock()
+	if sentAt.IsZero() {
+		return // 不是探测 ping 的响应
+	}
+
+	rtt := now.Sub(sentAt)
+	if rtt > maxRTT {
+		rtt = maxRTT
+	}
+	smoothed := c.calculateSmoothedRTT(rtt.Milliseconds())
+	metrics.CalibrationRTT.Observe(float64(smoothed) / 1000)
 }
 
 // calculateSmoothedRTT 计算平滑RTT
@@ -446,6 +910,7 @@ func (c *WebSocketConnection) calculateSmoothedRTT(newRTT int64) int64 {
 		c.rtts = c.rtts[1:] // 保持最近3次
 	}
 	if len(c.rtts) < 3 {
+		c.rtt = newRTT
 		return newRTT // 不足3次直接返回
 	}
 	
@@ -453,7 +918,8 @@ func (c *WebSocketConnection) calculateSmoothedRTT(newRTT int64) int64 {
 	sortedRTTs := make([]int64, len(c.rtts))
 	copy(sortedRTTs, c.rtts)
 	sort.Slice(sortedRTTs, func(i, j int) bool { return sortedRTTs[i] < sortedRTTs[j] })
-	return sortedRTTs[len(sortedRTTs)/2] // 中位数
+	c.rtt = sortedRTTs[len(sortedRTTs)/2] // 中位数
+	return c.rtt
 }
 
 // SetTimeOffset 设置时钟偏移
@@ -461,13 +927,36 @@ func (c *WebSocketConnection) SetTimeOffset(offset int64) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.timeOffset = offset
+	c.lastCalibrate = time.Now()
 }
 
-// GetAdjustedTime 获取修正时间
+// GetAdjustedTime 获取修正时间（客户端时间换算为服务器时间）
 func (c *WebSocketConnection) GetAdjustedTime(clientTime int64) int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return clientTime + c.timeOffset
 }
 
+// SmoothedRTT 获取平滑后的RTT（毫秒），未校准时为0
+func (c *WebSocketConnection) SmoothedRTT() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.rtt
+}
+
+// sampleTime 估算客户端采样播放位置时对应的服务器时间（毫秒）
+func (c *WebSocketConnection) sampleTime(clientTime int64, now time.Time) int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// 已校准且客户端带了采样时间，直接换算
+	if clientTime > 0 && !c.lastCalibrate.IsZero() {
+		return clientTime + c.timeOffset
+	}
+	// 否则认为消息在单程时延之前采样
+	return now.UnixMilli() - c.rtt/2
+}
+
 // handlePong 处理pong消息
 func (h *WebSocketHub) handlePong(conn *WebSocketConnection, message []byte) {
 	// 这里主要用于心跳响应，不需要特殊处理
@@ -481,16 +970,29 @@ func (h *WebSocketHub) handleAuth(conn *WebSocketConnection, message []byte) {
 		return
 	}
 	
-	// TODO: 验证JWT令牌
-	// 暂时简化实现
+	if authMsg.RoomID != "" && authMsg.RoomID != conn.roomID {
+		h.sendError(conn, "auth_failed", "令牌与当前房间不匹配")
+		return
+	}
+	
+	if h.auth == nil {
+		h.sendError(conn, "auth_failed", "服务器未配置鉴权")
+		return
+	}
+	
+	sessionID, err := h.auth(authMsg.Token, conn.roomID)
+	if err != nil || sessionID != conn.sessionID {
+		h.sendError(conn, "auth_failed", "无效的访问令牌")
+		return
+	}
 	
 	// 认证成功，发送确认
 	successMsg := map[string]interface{}{
 		"type":    "auth_success",
-		"room_id": authMsg.RoomID,
+		"room_id": conn.roomID,
 		"status":  "authenticated",
 	}
-	h.sendJSON(conn, successMsg)
+	conn.sendJSON("auth_success", successMsg)
 }
 
 // handleSync 处理同步消息
@@ -501,50 +1003,69 @@ func (h *WebSocketHub) handleSync(conn *WebSocketConnection, message []byte) {
 		return
 	}
 
-	room := h.getOrCreateRoom(syncMsg.RoomID)
-	room.mu.Lock()
-	defer room.mu.Unlock()
+	var state PlaybackState
+	var buffering bool
+	if !conn.room.call(func(r *Room) { state, buffering = r.state, conn.buffering }) {
+		return
+	}
+	// 缓冲中的播放器进度停滞，等它报告就绪后再纠正，避免反复跳转
+	if buffering {
+		return
+	}
+
+	// 预约的指令生效前客户端仍按旧状态播放，生效后再纠正
+	now := time.Now()
+	if state.LastUpdated > now.UnixMilli() {
+		return
+	}
 
+	// 以客户端采样时刻的服务器推算位置为基准比较
 	currentTime := syncMsg.Data.CurrentTime
-	targetTime := h.calculateTargetTime(room)
-	timeDiff := targetTime - currentTime
+	timeDiff := state.PositionAt(conn.sampleTime(syncMsg.Data.ClientTime, now)) - currentTime
 
-	var action SyncAction
+	// 下发的目标位置需要补偿指令到达客户端的单程时延
+	targetTime := state.PositionAt(now.UnixMilli() + conn.SmoothedRTT()/2)
+
+	metrics.SyncChecks.Inc()
+	action, ok := calculateSyncAction(timeDiff, targetTime)
+	if !ok {
+		metrics.SyncInTolerance.Inc()
+		return
+	}
+	metrics.SyncActions.WithLabelValues(action.Type).Inc()
+
+	// 同步指令只发给偏离的客户端
+	conn.sendJSON(action.Type, action)
+}
+
+// calculateSyncAction 根据误差（服务器位置 - 客户端位置，秒）决定同步动作
+func calculateSyncAction(timeDiff, targetTime float64) (SyncAction, bool) {
 	switch {
-	case timeDiff > 2.0: // Level 2: 误差 > 2s，触发 Seek
-		action = SyncAction{
+	case math.Abs(timeDiff) > 2.0: // Level 2: 误差 > 2s，触发 Seek
+		return SyncAction{
 			Type:       "seek",
 			TargetTime: targetTime,
 			Reason:     "large_difference",
-		}
+		}, true
 	case timeDiff > 0.5: // Level 3: 误差 0.5s - 2s，动态调整倍速
 		speed := 1.0 + math.Min(timeDiff/10.0, 0.5) // 最大1.5倍速
-		action = SyncAction{
+		return SyncAction{
 			Type:          "playback_rate",
 			PlaybackRate:  speed,
 			TargetTime:    targetTime,
 			Reason:        "medium_difference",
-		}
+		}, true
 	case timeDiff < -0.5: // 客户端超前，减速
 		speed := 1.0 + math.Max(timeDiff/10.0, -0.3) // 最小0.7倍速
-		action = SyncAction{
+		return SyncAction{
 			Type:          "playback_rate",
 			PlaybackRate:  speed,
 			TargetTime:    targetTime,
 			Reason:        "client_ahead",
-		}
+		}, true
 	default: // Level 4: 误差 < 0.5s，忽略
-		return
+		return SyncAction{}, false
 	}
-
-	// 广播同步指令给房间内其他用户
-	h.broadcastToRoom(room.ID, action)
-}
-
-// calculateTargetTime 计算目标时间
-func (h *WebSocketHub) calculateTargetTime(room *Room) float64 {
-	// 简化实现：返回房间当前播放时间
-	return room.state.CurrentTime
 }
 
 // handleChat 处理聊天消息
@@ -555,44 +1076,61 @@ func (h *WebSocketHub) handleChat(conn *WebSocketConnection, message []byte) {
 		return
 	}
 
+	if h.chat == nil {
+		h.sendError(conn, "chat_failed", "服务器未配置聊天存储")
+		return
+	}
+
+	// 发送者身份取自已认证的连接，忽略客户端提供的 session_id/display_name
+	stored, err := h.chat.SendChat(conn.roomID, conn.sessionID, chatMsg.Message)
+	switch {
+	case errors.Is(err, model.ErrMessageEmpty):
+		h.sendError(conn, "chat_failed", "消息内容不能为空")
+		return
+	case errors.Is(err, model.ErrMessageTooLong):
+		h.sendError(conn, "chat_failed", "消息内容过长")
+		return
+	case err != nil:
+		h.sendError(conn, "chat_failed", "消息发送失败")
+		return
+	}
+
 	// 广播聊天消息给房间内所有用户
-	h.broadcastToRoom(conn.roomID, chatMsg)
+	chat := newChatMessage(stored)
+	conn.room.post(chatOp(chat))
+	h.publish(eventChat, conn.roomID, 0, conn.sessionID, chat)
 }
 
 // handlePlay 处理播放消息
 func (h *WebSocketHub) handlePlay(conn *WebSocketConnection, message []byte) {
-	room := h.getOrCreateRoom(conn.roomID)
-	room.mu.Lock()
-	defer room.mu.Unlock()
-
-	room.state.IsPlaying = true
-	room.state.LastUpdated = time.Now().Unix()
-	room.version++
-
-	// 广播播放状态给房间内其他用户
-	h.broadcastToRoom(conn.roomID, map[string]interface{}{
-		"type":         "play",
-		"current_time": room.state.CurrentTime,
-		"version":      room.version,
-	})
+	var playMsg PlayMessage
+	if err := json.Unmarshal(message, &playMsg); err != nil {
+		h.sendError(conn, "play_failed", "播放消息格式错误")
+		return
+	}
+
+	cmd := service.PlaybackCommand{Action: service.PlaybackActionPlay, BaseVersion: playMsg.BaseVersion}
+	// start_time 为 0 时沿用服务器推算的位置
+	if playMsg.StartTime > 0 {
+		cmd.Position = &playMsg.StartTime
+	}
+	h.applyPlayback(conn, cmd)
 }
 
 // handlePause 处理暂停消息
 func (h *WebSocketHub) handlePause(conn *WebSocketConnection, message []byte) {
-	room := h.getOrCreateRoom(conn.roomID)
-	room.mu.Lock()
-	defer room.mu.Unlock()
-
-	room.state.IsPlaying = false
-	room.state.LastUpdated = time.Now().Unix()
-	room.version++
-
-	// 广播暂停状态给房间内其他用户
-	h.broadcastToRoom(conn.roomID, map[string]interface{}{
-		"type":         "pause",
-		"current_time": room.state.CurrentTime,
-		"version":      room.version,
-	})
+	var pauseMsg PauseMessage
+	if err := json.Unmarshal(message, &pauseMsg); err != nil {
+		h.sendError(conn, "pause_failed", "暂停消息格式错误")
+		return
+	}
+
+	cmd := service.PlaybackCommand{Action: service.PlaybackActionPause, BaseVersion: pauseMsg.BaseVersion}
+	// pause_time 为 0 时沿用服务器推算的位置
+	if pauseMsg.PauseTime > 0 {
+		cmd.Position = &pauseMsg.PauseTime
+	}
+	h.applyPlayback(conn, cmd)
 }
 
 // handleSeek 处理拖拽消息
@@ -603,19 +1141,10 @@ func (h *WebSocketHub) handleSeek(conn *WebSocketConnection, message []byte) {
 		return
 	}
 
-	room := h.getOrCreateRoom(conn.roomID)
-	room.mu.Lock()
-	defer room.mu.Unlock()
-
-	room.state.CurrentTime = seekMsg.TargetTime
-	room.state.LastUpdated = time.Now().Unix()
-	room.version++
-
-	// 广播拖拽状态给房间内其他用户
-	h.broadcastToRoom(conn.roomID, map[string]interface{}{
-		"type":         "seek",
-		"target_time":  seekMsg.TargetTime,
-		"version":      room.version,
+	h.applyPlayback(conn, service.PlaybackCommand{
+		Action:      service.PlaybackActionSeek,
+		Position:    &seekMsg.TargetTime,
+		BaseVersion: seekMsg.BaseVersion,
 	})
 }
 
@@ -627,78 +1156,216 @@ func (h *WebSocketHub) handleRate(conn *WebSocketConnection, message []byte) {
 		return
 	}
 
-	room := h.getOrCreateRoom(conn.roomID)
-	room.mu.Lock()
-	defer room.mu.Unlock()
+	h.applyPlayback(conn, service.PlaybackCommand{
+		Action:      service.PlaybackActionRate,
+		Rate:        rateMsg.PlaybackRate,
+		BaseVersion: rateMsg.BaseVersion,
+	})
+}
 
-	room.state.PlaybackRate = rateMsg.PlaybackRate
-	room.state.LastUpdated = time.Now().Unix()
-	room.version++
+// handleCountdown 处理倒计时开播消息
+func (h *WebSocketHub) handleCountdown(conn *WebSocketConnection, message []byte) {
+	var countdownMsg CountdownMessage
+	if err := json.Unmarshal(message, &countdownMsg); err != nil {
+		h.sendError(conn, "countdown_failed", "倒计时消息格式错误")
+		return
+	}
 
-	// 广播倍速状态给房间内其他用户
-	h.broadcastToRoom(conn.roomID, map[string]interface{}{
-		"type":          "rate",
-		"playback_rate": rateMsg.PlaybackRate,
-		"version":       room.version,
-	})
+	cmd := service.PlaybackCommand{
+		Action:      service.PlaybackActionCountdown,
+		BaseVersion: countdownMsg.BaseVersion,
+		ExecuteAt:   time.Now().Add(time.Duration(countdownMsg.Seconds) * time.Second).UnixMilli(),
+	}
+	// start_time 为 0 时沿用服务器推算的位置
+	if countdownMsg.StartTime > 0 {
+		cmd.Position = &countdownMsg.StartTime
+	}
+	h.applyPlayback(conn, cmd)
 }
 
-// sendRoomState 发送房间状态
-func (h *WebSocketHub) sendRoomState(roomID string, conn *WebSocketConnection) {
-	room := h.getOrCreateRoom(roomID)
-	room.mu.RLock()
-	defer room.mu.RUnlock()
+// applyPlayback 通过存储应用播放控制，成功后由 PublishPlayback 广播
+func (h *WebSocketHub) applyPlayback(conn *WebSocketConnection, cmd service.PlaybackCommand) {
+	if h.store == nil {
+		h.sendError(conn, cmd.Action+"_failed", "服务器未配置播放状态存储")
+		return
+	}
 
-	stateMsg := map[string]interface{}{
-		"type":    "room_state",
-		"state":   room.state,
-		"version": room.version,
-		"members": len(room.clients),
+	latest, err := h.store.ApplyPlayback(conn.roomID, cmd)
+	switch {
+	case errors.Is(err, model.ErrVersionConflict):
+		// 版本冲突时把最新状态发给发起方，由客户端重新基于新版本操作
+		h.sendError(conn, "version_conflict", "播放状态已被其他成员修改")
+		messages := h.recentChat(conn.roomID)
+		conn.room.post(func(r *Room) {
+			if latest != nil {
+				r.applyState(playbackStateFromRoom(latest), int64(latest.Version))
+			}
+			r.sendState(conn, messages)
+		})
+	case errors.Is(err, model.ErrInvalidPlaybackState):
+		h.sendError(conn, cmd.Action+"_failed", "无效的播放控制参数")
+	case err != nil:
+		h.sendError(conn, cmd.Action+"_failed", "播放状态更新失败")
 	}
-	h.sendJSON(conn, stateMsg)
 }
 
-// broadcastToRoom 向房间内广播消息
-func (h *WebSocketHub) broadcastToRoom(roomID string, data interface{}) {
-	room := h.getOrCreateRoom(roomID)
-	room.mu.RLock()
-	defer room.mu.RUnlock()
+// PublishPlayback 播放状态变更回调，更新缓存并广播给所有实例上房间内的连接
+//
+// 注册到 service.RoomService.OnPlaybackChange，REST 与 WebSocket 的变更都经由这里下发。
+func (h *WebSocketHub) PublishPlayback(stored *model.Room, action string) {
+	state, version := playbackStateFromRoom(stored), int64(stored.Version)
 
-	message, _ := json.Marshal(data)
-	for _, conn := range room.clients {
-		select {
-		case conn.send <- message:
-		default:
-			close(conn.send)
-			h.unregister <- conn
-		}
+	// 本实例没有在线连接时，下次连接会从存储加载
+	if room := h.lookupRoom(stored.ID); room != nil {
+		room.post(playbackOp(action, state, version))
 	}
+	h.publish(eventPlayback, stored.ID, version, "", playbackEvent{Action: action, State: state})
 }
 
-// broadcastToAll 向所有连接广播消息
-func (h *WebSocketHub) broadcastToAll(message []byte) {
+// ScheduleLead 房间内播放指令需要提前下发的时间，按本实例上最慢连接的往返时延估算
+//
+// 注册到 service.RoomService.ScheduleWith。本实例没有该房间的连接时只返回安全余量。
+func (h *WebSocketHub) ScheduleLead(roomID string) time.Duration {
+	lead := scheduleMargin
+	if room := h.lookupRoom(roomID); room != nil {
+		room.call(func(r *Room) { lead = r.scheduleLead() })
+	}
+	return lead
+}
+
+// PublishMemberEvent 成员状态变更回调，广播给所有实例上房间内的连接，被移出的成员随后断开
+//
+// 注册到 service.MemberService.OnMemberChange。
+func (h *WebSocketHub) PublishMemberEvent(event string, member *model.RoomMember) {
+	if room := h.lookupRoom(member.RoomID); room != nil {
+		room.post(memberOp(event, member))
+	}
+	h.publish(eventMember, member.RoomID, 0, member.SessionID, memberEvent{Event: event, Member: member})
+}
+
+// ActiveRoomIDs 返回当前有在线连接的房间ID
+func (h *WebSocketHub) ActiveRoomIDs() []string {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	for _, conn := range h.clients {
-		select {
-		case conn.send <- message:
-		default:
-			close(conn.send)
-			h.unregister <- conn
+	roomIDs := make([]string, 0, len(h.rooms))
+	for roomID, room := range h.rooms {
+		if room.members > 0 {
+			roomIDs = append(roomIDs, roomID)
 		}
 	}
+	return roomIDs
+}
+
+// EvictRoom 房间被停用、关闭或清理后通知所有实例移除缓存，并断开仍在房间内的连接
+//
+// 注册到 service.Janitor.OnRoomClosed 和 service.RoomService.OnRoomClosed。
+func (h *WebSocketHub) EvictRoom(roomID string) {
+	h.evictLocal(roomID)
+	h.publish(eventClose, roomID, 0, "", nil)
+}
+
+// evictLocal 移除本实例的房间缓存并断开房间内的连接
+func (h *WebSocketHub) evictLocal(roomID string) {
+	h.mu.Lock()
+	room, ok := h.rooms[roomID]
+	delete(h.rooms, roomID)
+	if ok {
+		h.unparkRoom(room)
+	}
+	h.updateGauges()
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	room.post(closeOp())
+	room.stop()
+}
+
+// PublishSettings 房间设置变更回调，更新缓存并推送给所有实例上房间内的连接
+//
+// 注册到 service.RoomService.OnSettingsChange。
+func (h *WebSocketHub) PublishSettings(roomID string, settings model.RoomSettings) {
+	if room := h.lookupRoom(roomID); room != nil {
+		room.post(settingsOp(settings))
+	}
+	h.publish(eventSettings, roomID, 0, "", settings)
+}
+
+// recentChat 获取房间最近的聊天记录，失败时返回空列表
+func (h *WebSocketHub) recentChat(roomID string) []ChatMessage {
+	messages := []ChatMessage{}
+	if h.chat == nil {
+		return messages
+	}
+
+	stored, err := h.chat.GetRecentMessages(roomID, chatBackfillLimit)
+	if err != nil {
+		return messages
+	}
+	for _, message := range stored {
+		messages = append(messages, newChatMessage(message))
+	}
+	return messages
 }
 
-// sendJSON 发送JSON消息
-func (h *WebSocketHub) sendJSON(conn *WebSocketConnection, data interface{}) {
+// sendJSON 发送JSON消息，msgType 为消息的 type 字段（用于指标标签）
+func (c *WebSocketConnection) sendJSON(msgType string, data interface{}) {
 	message, _ := json.Marshal(data)
+	c.deliver(message, msgType)
+}
+
+// deliver 将消息非阻塞地放入发送队列
+//
+// 队列已满说明客户端消费过慢，直接关闭发送队列：写协程发送关闭帧后断开，
+// 读协程随之退出并注销连接，投递方（房间协程或周期任务）不会被阻塞。
+func (c *WebSocketConnection) deliver(message []byte, msgType string) bool {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if c.closed {
+		return false
+	}
 	select {
-	case conn.send <- message:
+	case c.send <- message:
+		metrics.WebSocketMessages.WithLabelValues("out", msgType).Inc()
+		return true
 	default:
-		close(conn.send)
-		h.unregister <- conn
+		metrics.WebSocketSendDropped.Inc()
+		c.closed = true
+		close(c.send)
+		return false
+	}
+}
+
+// isClosed 发送队列是否已关闭（服务器主动断开或消费过慢）
+func (c *WebSocketConnection) isClosed() bool {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	return c.closed
+}
+
+// close 关闭发送队列，可重复调用
+func (c *WebSocketConnection) close() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+	}
+}
+
+// messageType 读取消息的 type 字段（用于指标标签）
+func messageType(message []byte) string {
+	var msg struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(message, &msg); err != nil || msg.Type == "" {
+		return "unknown"
 	}
+	return msg.Type
 }
 
 // sendPong 发送pong响应
@@ -709,7 +1376,7 @@ func (h *WebSocketHub) sendPong(conn *WebSocketConnection, clientSendTime int64)
 		ServerRecvTime: time.Now().UnixMilli(),
 		ServerSendTime: time.Now().UnixMilli(),
 	}
-	h.sendJSON(conn, pongMsg)
+	conn.sendJSON(MsgTypePong, pongMsg)
 }
 
 // sendError 发送错误消息
@@ -719,7 +1386,7 @@ func (h *WebSocketHub) sendError(conn *WebSocketConnection, code, message string
 		"code":    code,
 		"message": message,
 	}
-	h.sendJSON(conn, errorMsg)
+	conn.sendJSON(MsgTypeError, errorMsg)
 }
 
 // StartPeriodicTasks 启动周期性任务
@@ -727,16 +1394,28 @@ func (h *WebSocketHub) StartPeriodicTasks() {
 	// 心跳任务 - 每30秒
 	go func() {
 		ticker := time.NewTicker(30 * time.Second)
-		for range ticker.C {
-			h.broadcastHeartbeat()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				h.broadcastHeartbeat()
+			case <-h.quit:
+				return
+			}
 		}
 	}()
 
 	// 对时任务 - 每5分钟
 	go func() {
 		ticker := time.NewTicker(5 * time.Minute)
-		for range ticker.C {
-			h.triggerCalibration()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				h.triggerCalibration()
+			case <-h.quit:
+				return
+			}
 		}
 	}()
 }
@@ -751,13 +1430,9 @@ func (h *WebSocketHub) broadcastHeartbeat() {
 	defer h.mu.RUnlock()
 	
 	message, _ := json.Marshal(heartbeatMsg)
+	msgType := MsgTypeHeartbeat
 	for _, conn := range h.clients {
-		select {
-		case conn.send <- message:
-		default:
-			close(conn.send)
-			h.unregister <- conn
-		}
+		conn.deliver(message, msgType)
 	}
 }
 
@@ -772,12 +1447,8 @@ func (h *WebSocketHub) triggerCalibration() {
 	}
 	
 	message, _ := json.Marshal(calibrationMsg)
+	msgType := MsgTypePing
 	for _, conn := range h.clients {
-		select {
-		case conn.send <- message:
-		default:
-			close(conn.send)
-			h.unregister <- conn
-		}
+		conn.deliver(message, msgType)
 	}
 }
\ No newline at end of file
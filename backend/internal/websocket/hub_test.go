@@ -0,0 +1,588 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"xiaowo/backend/internal/broker"
+	"xiaowo/backend/internal/model"
+	"xiaowo/backend/internal/service"
+)
+
+// fakeDB 多个 Hub 实例共用的内存房间存储
+type fakeDB struct {
+	mu    sync.Mutex
+	rooms map[string]model.Room
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{rooms: make(map[string]model.Room)}
+}
+
+// fakeStore 内存播放状态与聊天存储，变更后像 service.RoomService 一样同步回调所属的 Hub
+type fakeStore struct {
+	hub *WebSocketHub
+	db  *fakeDB
+}
+
+func (s *fakeStore) GetRoom(roomID string) (*model.Room, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	room, ok := s.db.rooms[roomID]
+	if !ok {
+		return nil, model.ErrRoomNotFound
+	}
+	return &room, nil
+}
+
+func (s *fakeStore) ApplyPlayback(roomID string, cmd service.PlaybackCommand) (*model.Room, error) {
+	s.db.mu.Lock()
+	room := s.db.rooms[roomID]
+	if cmd.BaseVersion != nil && *cmd.BaseVersion != room.Version {
+		s.db.mu.Unlock()
+		return &room, model.ErrVersionConflict
+	}
+	room.ID = roomID
+	room.Version++
+	room.PlaybackRate = 1
+	room.PlaybackUpdatedAt = time.Now().UnixMilli()
+	if cmd.ExecuteAt > room.PlaybackUpdatedAt {
+		room.PlaybackUpdatedAt = cmd.ExecuteAt
+	}
+	if cmd.Action == service.PlaybackActionPlay || cmd.Action == service.PlaybackActionCountdown {
+		room.PlaybackState = model.PlaybackPlaying
+	} else {
+		room.PlaybackState = model.PlaybackPaused
+	}
+	if cmd.Position != nil {
+		room.CurrentTime = *cmd.Position
+	}
+	s.db.rooms[roomID] = room
+	s.db.mu.Unlock()
+
+	s.hub.PublishPlayback(&room, cmd.Action)
+	return &room, nil
+}
+
+func (s *fakeStore) SendChat(roomID, sessionID, content string) (*model.Message, error) {
+	return &model.Message{ID: fmt.Sprintf("%s-%d", sessionID, time.Now().UnixNano()), RoomID: roomID, SessionID: sessionID, Content: content, CreatedAt: time.Now()}, nil
+}
+
+func (s *fakeStore) GetRecentMessages(roomID string, limit int) ([]*model.Message, error) {
+	return nil, nil
+}
+
+// newTestHub 创建使用内存存储的 Hub
+func newTestHub(t *testing.T) *WebSocketHub {
+	t.Helper()
+	return newInstance(t, newFakeDB(), HubOptions{})
+}
+
+// newInstance 创建使用共用存储的 Hub 实例，opts 中的存储由 db 提供
+func newInstance(t *testing.T, db *fakeDB, opts HubOptions) *WebSocketHub {
+	t.Helper()
+
+	store := &fakeStore{db: db}
+	opts.Playback, opts.Chat = store, store
+	hub := NewWebSocketHub(opts)
+	store.hub = hub
+	t.Cleanup(hub.Shutdown)
+	return hub
+}
+
+// testClient 不经过真实网络的模拟客户端，收到的消息写入 received
+type testClient struct {
+	conn     *WebSocketConnection
+	mu       sync.Mutex
+	received [][]byte
+	done     chan struct{}
+}
+
+// newTestClient 创建模拟客户端，drain 为 false 时不读取发送队列（模拟消费过慢的客户端）
+func newTestClient(roomID, sessionID string, queue int, drain bool) *testClient {
+	c := &testClient{
+		conn: &WebSocketConnection{roomID: roomID, sessionID: sessionID, send: make(chan []byte, queue)},
+		done: make(chan struct{}),
+	}
+	if !drain {
+		return c
+	}
+	go func() {
+		defer close(c.done)
+		for message := range c.conn.send {
+			c.mu.Lock()
+			c.received = append(c.received, message)
+			c.mu.Unlock()
+		}
+	}()
+	return c
+}
+
+// has 是否收到过指定类型的消息
+func (c *testClient) has(msgType string) bool {
+	return c.find(msgType, func(map[string]interface{}) bool { return true })
+}
+
+// find 是否收到过指定类型且满足条件的消息
+func (c *testClient) find(msgType string, match func(msg map[string]interface{}) bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, received := range c.received {
+		if messageType(received) != msgType {
+			continue
+		}
+		var msg map[string]interface{}
+		if json.Unmarshal(received, &msg) == nil && match(msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// closed 发送队列是否已关闭
+func (c *testClient) closed() bool {
+	c.conn.sendMu.Lock()
+	defer c.conn.sendMu.Unlock()
+	return c.conn.closed
+}
+
+// waitClosed 等待发送队列被关闭
+func (c *testClient) waitClosed(t *testing.T) {
+	t.Helper()
+	select {
+	case <-c.done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("连接 %s 的发送队列未被关闭", c.conn.sessionID)
+	}
+}
+
+// waitFor 在超时前等待条件成立
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待超时: %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// 测试数百个客户端并发收发、设置推送和周期任务时 Hub 不死锁、不泄漏
+func TestHub_ConcurrentClients(t *testing.T) {
+	const (
+		rooms          = 10
+		clientsPerRoom = 30
+		messages       = 20
+	)
+	hub := newTestHub(t)
+
+	frames := [][]byte{
+		[]byte(`{"type":"play","start_time":1}`),
+		[]byte(`{"type":"sync","data":{"current_time":1}}`),
+		[]byte(`{"type":"chat","message":"hello"}`),
+		[]byte(`{"type":"seek","target_time":30}`),
+		[]byte(`{"type":"ping","client_send_time":1}`),
+		[]byte(`{"type":"pause"}`),
+		[]byte(`not json`),
+	}
+
+	stop := make(chan struct{})
+	var background sync.WaitGroup
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			hub.PublishSettings(fmt.Sprintf("ROOM%02d", i%rooms), model.DefaultRoomSettings())
+			hub.ActiveRoomIDs()
+			hub.broadcastHeartbeat()
+			hub.triggerCalibration()
+			// 周期任务不能无间隔地刷屏，否则客户端会因发送队列溢出被当作慢客户端断开
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	clients := make([]*testClient, 0, rooms*clientsPerRoom)
+	var wg sync.WaitGroup
+	for r := 0; r < rooms; r++ {
+		for i := 0; i < clientsPerRoom; i++ {
+			client := newTestClient(fmt.Sprintf("ROOM%02d", r), fmt.Sprintf("s%02d-%02d", r, i), 256, true)
+			clients = append(clients, client)
+
+			wg.Add(1)
+			go func(client *testClient, seed int) {
+				defer wg.Done()
+				if err := hub.RegisterClient(client.conn); err != nil {
+					t.Errorf("注册连接失败: %v", err)
+					return
+				}
+				for n := 0; n < messages; n++ {
+					hub.HandleMessage(client.conn, frames[(seed+n)%len(frames)])
+				}
+				hub.UnregisterClient(client.conn)
+			}(client, r*clientsPerRoom+i)
+		}
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(20 * time.Second):
+		t.Fatal("客户端未能在限定时间内完成，Hub 可能死锁")
+	}
+	close(stop)
+	background.Wait()
+
+	t.Run("TestAllConnectionsClosed", func(t *testing.T) {
+		for _, client := range clients {
+			client.waitClosed(t)
+			if !client.has("room_state") {
+				t.Errorf("连接 %s 未收到房间状态", client.conn.sessionID)
+			}
+		}
+	})
+
+	t.Run("TestRoomsReleased", func(t *testing.T) {
+		if ids := hub.ActiveRoomIDs(); len(ids) != 0 {
+			t.Errorf("所有连接断开后不应有活跃房间，实际: %v", ids)
+		}
+		hub.mu.RLock()
+		defer hub.mu.RUnlock()
+		if len(hub.clients) != 0 || len(hub.rooms) != 0 {
+			t.Errorf("索引未清空: clients=%d rooms=%d", len(hub.clients), len(hub.rooms))
+		}
+	})
+}
+
+// 测试消费过慢的客户端被断开，且不阻塞房间内的其他客户端
+func TestHub_SlowConsumerEvicted(t *testing.T) {
+	hub := newTestHub(t)
+	fast := newTestClient("ROOM01", "fast", 256, true)
+	slow := newTestClient("ROOM01", "slow", 1, false)
+
+	for _, client := range []*testClient{fast, slow} {
+		if err := hub.RegisterClient(client.conn); err != nil {
+			t.Fatalf("注册连接失败: %v", err)
+		}
+	}
+
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for i := 0; i < 50; i++ {
+			hub.HandleMessage(fast.conn, []byte(`{"type":"chat","message":"spam"}`))
+		}
+	}()
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("慢客户端阻塞了广播")
+	}
+
+	t.Run("TestSlowQueueClosed", func(t *testing.T) {
+		waitFor(t, "慢客户端发送队列关闭", slow.closed)
+	})
+
+	t.Run("TestLeaveAnnounced", func(t *testing.T) {
+		// 写协程关闭后读协程退出并注销连接
+		hub.UnregisterClient(slow.conn)
+		waitFor(t, "快客户端收到离开通知", func() bool { return fast.has("member_leave") })
+		if fast.closed() {
+			t.Error("快客户端不应被断开")
+		}
+	})
+}
+
+// 测试同一会话重复连接时替换旧连接，旧连接延迟注销不影响新连接
+func TestHub_ReplaceSameSession(t *testing.T) {
+	hub := newTestHub(t)
+	first := newTestClient("ROOM01", "s1", 16, true)
+	second := newTestClient("ROOM01", "s1", 16, true)
+
+	hub.RegisterClient(first.conn)
+	hub.RegisterClient(second.conn)
+	first.waitClosed(t)
+
+	hub.UnregisterClient(first.conn)
+	if ids := hub.ActiveRoomIDs(); len(ids) != 1 {
+		t.Fatalf("旧连接注销后房间应保持活跃，实际: %v", ids)
+	}
+
+	var members int
+	hub.lookupRoom("ROOM01").call(func(r *Room) { members = len(r.clients) })
+	if members != 1 {
+		t.Errorf("期望房间连接数: 1, 实际: %d", members)
+	}
+
+	hub.UnregisterClient(second.conn)
+	second.waitClosed(t)
+	if ids := hub.ActiveRoomIDs(); len(ids) != 0 {
+		t.Errorf("最后一个连接注销后房间应被释放，实际: %v", ids)
+	}
+}
+
+// 测试房间被清理后连接收到通知并断开，Hub 关闭后拒绝新连接
+func TestHub_EvictAndShutdown(t *testing.T) {
+	hub := newTestHub(t)
+	client := newTestClient("ROOM01", "s1", 16, true)
+	hub.RegisterClient(client.conn)
+
+	hub.EvictRoom("ROOM01")
+	client.waitClosed(t)
+	if !client.has("room_closed") {
+		t.Error("连接断开前应收到 room_closed 通知")
+	}
+	if ids := hub.ActiveRoomIDs(); len(ids) != 0 {
+		t.Errorf("被清理的房间不应再活跃，实际: %v", ids)
+	}
+
+	// 读协程退出时注销，不应影响已移除的房间
+	hub.UnregisterClient(client.conn)
+
+	hub.Shutdown()
+	late := newTestClient("ROOM01", "s2", 16, true)
+	if err := hub.RegisterClient(late.conn); err != ErrHubClosed {
+		t.Errorf("期望错误: %v, 实际: %v", ErrHubClosed, err)
+	}
+	late.waitClosed(t)
+}
+
+// 测试同步上报读取的是房间协程中的最新状态
+func TestHub_SyncReadsRoomState(t *testing.T) {
+	hub := newTestHub(t)
+	client := newTestClient("ROOM01", "s1", 16, true)
+	hub.RegisterClient(client.conn)
+
+	position := 120.0
+	if _, err := hub.store.ApplyPlayback("ROOM01", service.PlaybackCommand{Action: service.PlaybackActionPause, Position: &position}); err != nil {
+		t.Fatalf("应用播放控制失败: %v", err)
+	}
+
+	msg, _ := json.Marshal(SyncMessage{Type: MsgTypeSync, Data: SyncData{CurrentTime: 0}})
+	hub.HandleMessage(client.conn, msg)
+	waitFor(t, "落后客户端收到跳转指令", func() bool { return client.has("seek") })
+}
+
+// 测试聊天消息的发送者取自已认证的连接，忽略客户端填写的身份
+func TestHub_ChatIdentity(t *testing.T) {
+	hub := newTestHub(t)
+	alice := newTestClient("ROOM01", "alice", 16, true)
+	bob := newTestClient("ROOM01", "bob", 16, true)
+	hub.RegisterClient(alice.conn)
+	hub.RegisterClient(bob.conn)
+
+	hub.HandleMessage(alice.conn, []byte(`{"type":"chat","session_id":"bob","display_name":"冒充者","message":"大家好"}`))
+	waitFor(t, "收到聊天消息", func() bool {
+		return bob.find(MsgTypeChat, func(msg map[string]interface{}) bool {
+			return msg["message"] == "大家好" && msg["session_id"] == "alice" && msg["display_name"] != "冒充者"
+		})
+	})
+}
+
+// 测试两个实例经 Broker 同步房间事件：播放状态按版本合并、成员数包含其他实例、同一会话只保留最新连接
+func TestHub_MultiInstance(t *testing.T) {
+	brokers := map[string]func(t *testing.T) (broker.Broker, broker.Broker){
+		"TestMemoryBroker": func(t *testing.T) (broker.Broker, broker.Broker) {
+			b := broker.NewMemory()
+			t.Cleanup(func() { b.Close() })
+			return b, b
+		},
+		"TestRedisBroker": func(t *testing.T) (broker.Broker, broker.Broker) {
+			server := miniredis.RunT(t)
+			newRedis := func() broker.Broker {
+				client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+				b := broker.NewRedis(client, "test:room:")
+				t.Cleanup(func() {
+					b.Close()
+					client.Close()
+				})
+				return b
+			}
+			return newRedis(), newRedis()
+		},
+	}
+
+	for name, newBrokers := range brokers {
+		t.Run(name, func(t *testing.T) {
+			brokerA, brokerB := newBrokers(t)
+			db := newFakeDB()
+			hubA := newInstance(t, db, HubOptions{Broker: brokerA, InstanceID: "A"})
+			hubB := newInstance(t, db, HubOptions{Broker: brokerB, InstanceID: "B"})
+
+			alice := newTestClient("ROOM01", "alice", 64, true)
+			bob := newTestClient("ROOM01", "bob", 64, true)
+			if err := hubA.RegisterClient(alice.conn); err != nil {
+				t.Fatalf("注册连接失败: %v", err)
+			}
+			if err := hubB.RegisterClient(bob.conn); err != nil {
+				t.Fatalf("注册连接失败: %v", err)
+			}
+
+			t.Run("TestRemoteMemberCounted", func(t *testing.T) {
+				waitFor(t, "收到其他实例的成员加入通知", func() bool {
+					return alice.find("member_join", func(msg map[string]interface{}) bool {
+						return msg["session_id"] == "bob" && msg["member_count"] == float64(2)
+					})
+				})
+			})
+
+			t.Run("TestPlaybackFannedOut", func(t *testing.T) {
+				hubA.HandleMessage(alice.conn, []byte(`{"type":"play","start_time":42}`))
+				waitFor(t, "其他实例的连接收到播放指令", func() bool {
+					return bob.find("play", func(msg map[string]interface{}) bool {
+						return msg["version"] == float64(1) && msg["current_time"] == float64(42)
+					})
+				})
+			})
+
+			t.Run("TestStaleVersionIgnored", func(t *testing.T) {
+				stale, _ := json.Marshal(playbackEvent{Action: service.PlaybackActionPause, State: NewPlaybackState()})
+				brokerA.Publish(context.Background(), broker.Event{Type: eventPlayback, RoomID: "ROOM01", Origin: "C", Version: 1, Data: stale})
+
+				// 同一发布方的事件按顺序到达，收到后续聊天时过期事件已处理
+				hubA.HandleMessage(alice.conn, []byte(`{"type":"chat","message":"hello"}`))
+				waitFor(t, "其他实例的连接收到聊天消息", func() bool { return bob.has("chat") })
+				if bob.has(service.PlaybackActionPause) {
+					t.Error("不应应用比缓存旧的播放状态")
+				}
+
+				var state PlaybackState
+				hubB.lookupRoom("ROOM01").call(func(r *Room) { state = r.state })
+				if !state.IsPlaying || state.CurrentTime != 42 {
+					t.Errorf("播放状态被过期事件覆盖: %+v", state)
+				}
+			})
+
+			t.Run("TestRemoteBufferingPausesRoom", func(t *testing.T) {
+				hubB.HandleMessage(bob.conn, []byte(`{"type":"buffering"}`))
+				waitFor(t, "其他实例的连接收到缓冲通知和暂停指令", func() bool {
+					return alice.has(MsgTypeBuffering) && alice.has("pause")
+				})
+				hubB.HandleMessage(bob.conn, []byte(`{"type":"ready"}`))
+				waitFor(t, "其他实例的连接收到预约的播放指令", func() bool { return scheduledPlay(alice) })
+				if room := db.stored("ROOM01"); !room.IsPlaying() || room.Version != 3 {
+					t.Errorf("所有成员就绪后应恢复播放: state=%s version=%d", room.PlaybackState, room.Version)
+				}
+			})
+
+			t.Run("TestSettingsFannedOut", func(t *testing.T) {
+				hubB.PublishSettings("ROOM01", model.DefaultRoomSettings())
+				waitFor(t, "其他实例的连接收到设置变更", func() bool { return alice.has("room_settings") })
+			})
+
+			t.Run("TestSessionTakeover", func(t *testing.T) {
+				moved := newTestClient("ROOM01", "bob", 64, true)
+				if err := hubA.RegisterClient(moved.conn); err != nil {
+					t.Fatalf("注册连接失败: %v", err)
+				}
+				bob.waitClosed(t)
+				hubB.UnregisterClient(bob.conn)
+
+				if ids := hubB.ActiveRoomIDs(); len(ids) != 0 {
+					t.Errorf("会话转移后原实例不应再有活跃房间，实际: %v", ids)
+				}
+				var members int
+				hubA.lookupRoom("ROOM01").call(func(r *Room) { members = r.memberCount() })
+				if members != 2 {
+					t.Errorf("期望房间成员数: 2, 实际: %d", members)
+				}
+			})
+
+			t.Run("TestEvictFannedOut", func(t *testing.T) {
+				// 原实例上的房间已释放，重新加入一个连接后从另一实例清理
+				carol := newTestClient("ROOM01", "carol", 64, true)
+				if err := hubB.RegisterClient(carol.conn); err != nil {
+					t.Fatalf("注册连接失败: %v", err)
+				}
+				waitFor(t, "新房间加载存储中的播放状态", func() bool {
+					return carol.find("room_state", func(msg map[string]interface{}) bool {
+						return msg["version"] == float64(3)
+					})
+				})
+
+				hubB.EvictRoom("ROOM01")
+				alice.waitClosed(t)
+				carol.waitClosed(t)
+				if !alice.has("room_closed") {
+					t.Error("其他实例的连接断开前应收到 room_closed 通知")
+				}
+				if ids := hubA.ActiveRoomIDs(); len(ids) != 0 {
+					t.Errorf("被清理的房间不应再活跃，实际: %v", ids)
+				}
+			})
+		})
+	}
+}
+
+// 测试权限校验使用连接缓存的成员信息，成员变更和设置变更事件刷新缓存
+func TestHub_CachedPermissions(t *testing.T) {
+	members := &fakeMembers{}
+	hub := newInstance(t, newFakeDB(), HubOptions{Permissions: members})
+	alice := newTestClient("ROOM01", "alice", 64, true)
+	bob := newTestClient("ROOM01", "bob", 64, true)
+	for _, client := range []*testClient{alice, bob} {
+		if err := hub.RegisterClient(client.conn); err != nil {
+			t.Fatalf("注册连接失败: %v", err)
+		}
+	}
+	rejected := func(client *testClient, code string) func() bool {
+		return func() bool {
+			return client.find(MsgTypeError, func(msg map[string]interface{}) bool { return msg["code"] == code })
+		}
+	}
+
+	t.Run("TestMemberReadOnce", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			hub.HandleMessage(bob.conn, []byte(`{"type":"chat","content":"hi"}`))
+		}
+		waitFor(t, "聊天消息广播", func() bool { return alice.count(MsgTypeChat, "bob") == 3 })
+		if reads := members.count(); reads != 2 {
+			t.Errorf("成员信息只应在注册时读取，期望: 2, 实际: %d", reads)
+		}
+	})
+
+	t.Run("TestMutedEvent", func(t *testing.T) {
+		hub.PublishMemberEvent(service.MemberEventMuted, &model.RoomMember{RoomID: "ROOM01", SessionID: "bob", Role: model.RoleMember, IsMuted: true})
+		hub.HandleMessage(bob.conn, []byte(`{"type":"chat","content":"hi"}`))
+		waitFor(t, "禁言后聊天被拒绝", rejected(bob, "muted"))
+	})
+
+	t.Run("TestSettingsEvent", func(t *testing.T) {
+		settings := model.DefaultRoomSettings()
+		settings.HostOnlyControl = true
+		hub.PublishSettings("ROOM01", settings)
+		hub.HandleMessage(alice.conn, []byte(`{"type":"pause"}`))
+		waitFor(t, "仅房主控制时普通成员被拒绝", rejected(alice, "permission_denied"))
+	})
+
+	t.Run("TestRoleChangedEvent", func(t *testing.T) {
+		hub.PublishMemberEvent(service.MemberEventRoleChanged, &model.RoomMember{RoomID: "ROOM01", SessionID: "bob", Role: model.RoleHost})
+		hub.HandleMessage(bob.conn, []byte(`{"type":"chat","content":"host"}`))
+		waitFor(t, "成为房主后可以聊天", func() bool { return alice.count(MsgTypeChat, "bob") == 4 })
+	})
+
+	t.Run("TestLeftEvent", func(t *testing.T) {
+		hub.PublishMemberEvent(service.MemberEventLeft, &model.RoomMember{RoomID: "ROOM01", SessionID: "alice", Role: model.RoleMember})
+		alice.waitClosed(t)
+		if reads := members.count(); reads != 2 {
+			t.Errorf("成员变更不应重新读取成员信息，实际读取: %d", reads)
+		}
+	})
+}
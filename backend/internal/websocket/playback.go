@@ -0,0 +1,96 @@
+package websocket
+
+import (
+	"time"
+
+	"xiaowo/backend/internal/model"
+)
+
+// PlaybackState 播放状态
+//
+// CurrentTime 只是 LastUpdated 时刻的播放位置，播放中的实际位置需要用 Position 推算，
+// 不要直接读取 CurrentTime 作为当前进度。
+type PlaybackState struct {
+	CurrentTime  float64 `json:"current_time"`
+	Duration     float64 `json:"duration"`
+	IsPlaying    bool    `json:"is_playing"`
+	PlaybackRate float64 `json:"playback_rate"`
+	VideoURL     string  `json:"video_url"`
+	VideoTitle   string  `json:"video_title"`
+	LastUpdated  int64   `json:"last_updated"` // 服务器时间，毫秒时间戳
+}
+
+// NewPlaybackState 创建初始播放状态
+func NewPlaybackState() PlaybackState {
+	return PlaybackState{
+		PlaybackRate: 1.0,
+		LastUpdated:  time.Now().UnixMilli(),
+	}
+}
+
+// PositionAt 推算服务器时间 at（毫秒）时的播放位置
+func (s PlaybackState) PositionAt(at int64) float64 {
+	position := s.CurrentTime
+	if s.IsPlaying && at > s.LastUpdated {
+		position += float64(at-s.LastUpdated) / 1000 * s.rate()
+	}
+
+	if position < 0 {
+		return 0
+	}
+	if s.Duration > 0 && position > s.Duration {
+		return s.Duration
+	}
+	return position
+}
+
+// Position 推算当前播放位置
+func (s PlaybackState) Position() float64 {
+	return s.PositionAt(time.Now().UnixMilli())
+}
+
+// Snapshot 返回以当前时刻为基准的状态副本，用于下发给客户端
+//
+// 预约生效（LastUpdated 晚于当前时刻）的状态原样返回，客户端据此在生效时刻开始播放。
+func (s PlaybackState) Snapshot() PlaybackState {
+	if now := time.Now().UnixMilli(); !s.Scheduled(now) {
+		s.advance(now)
+	}
+	return s
+}
+
+// Scheduled 状态是否预约在服务器时间 now（毫秒）之后生效
+func (s PlaybackState) Scheduled(now int64) bool {
+	return s.LastUpdated > now
+}
+
+// playbackStateFromRoom 由持久化的房间记录构建播放状态
+func playbackStateFromRoom(room *model.Room) PlaybackState {
+	state := PlaybackState{
+		CurrentTime:  room.CurrentTime,
+		Duration:     room.MediaDuration,
+		IsPlaying:    room.IsPlaying(),
+		PlaybackRate: room.PlaybackRate,
+		VideoURL:     room.MediaURL,
+		VideoTitle:   room.MediaTitle,
+		LastUpdated:  room.PlaybackUpdatedAt,
+	}
+	if state.LastUpdated == 0 {
+		state.LastUpdated = room.UpdatedAt.UnixMilli()
+	}
+	return state
+}
+
+// advance 将推算位置结算到 CurrentTime，并更新基准时间
+func (s *PlaybackState) advance(now int64) {
+	s.CurrentTime = s.PositionAt(now)
+	s.LastUpdated = now
+}
+
+// rate 返回有效倍速（未设置时按 1 倍速）
+func (s PlaybackState) rate() float64 {
+	if s.PlaybackRate <= 0 {
+		return 1.0
+	}
+	return s.PlaybackRate
+}
@@ -0,0 +1,237 @@
+package websocket
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"xiaowo/backend/internal/model"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 0.05
+}
+
+// 测试播放位置推算
+func TestPlaybackState_Position(t *testing.T) {
+	now := time.Now().UnixMilli()
+
+	t.Run("TestPlayingExtrapolates", func(t *testing.T) {
+		state := PlaybackState{CurrentTime: 10, IsPlaying: true, PlaybackRate: 1.5, LastUpdated: now - 4000}
+		if got := state.PositionAt(now); !almostEqual(got, 16) {
+			t.Errorf("期望位置: 16, 实际: %.3f", got)
+		}
+	})
+
+	t.Run("TestPausedStays", func(t *testing.T) {
+		state := PlaybackState{CurrentTime: 10, IsPlaying: false, PlaybackRate: 1, LastUpdated: now - 4000}
+		if got := state.PositionAt(now); got != 10 {
+			t.Errorf("暂停时位置不应变化，实际: %.3f", got)
+		}
+	})
+
+	t.Run("TestClampToDuration", func(t *testing.T) {
+		state := PlaybackState{CurrentTime: 95, Duration: 100, IsPlaying: true, PlaybackRate: 1, LastUpdated: now - 10000}
+		if got := state.PositionAt(now); got != 100 {
+			t.Errorf("期望位置: 100, 实际: %.3f", got)
+		}
+	})
+
+	t.Run("TestFromStoredRoom", func(t *testing.T) {
+		room := &model.Room{
+			PlaybackState:     model.PlaybackPlaying,
+			CurrentTime:       30,
+			PlaybackRate:      2,
+			PlaybackUpdatedAt: now - 1500,
+		}
+		state := playbackStateFromRoom(room)
+		if got := state.PositionAt(now); !almostEqual(got, room.PlaybackPositionAt(now)) || !almostEqual(got, 33) {
+			t.Errorf("缓存状态与存储推算位置不一致: %.3f / %.3f", got, room.PlaybackPositionAt(now))
+		}
+	})
+}
+
+// 测试同步动作分级
+func TestCalculateSyncAction(t *testing.T) {
+	cases := []struct {
+		name     string
+		diff     float64
+		wantOK   bool
+		wantType string
+	}{
+		{"TestIgnoreSmallDiff", 0.3, false, ""},
+		{"TestSpeedUp", 1.0, true, "playback_rate"},
+		{"TestSlowDown", -1.0, true, "playback_rate"},
+		{"TestSeekBehind", 3.0, true, "seek"},
+		{"TestSeekAhead", -3.0, true, "seek"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			action, ok := calculateSyncAction(tc.diff, 42)
+			if ok != tc.wantOK || action.Type != tc.wantType {
+				t.Errorf("误差 %.1f 期望动作: %q, 实际: %q (ok=%v)", tc.diff, tc.wantType, action.Type, ok)
+			}
+		})
+	}
+}
+
+// 测试持续播放的客户端不会被误判为需要跳转
+func TestHandleSync_NoSpuriousSeek(t *testing.T) {
+	hub := NewWebSocketHub(HubOptions{})
+	defer hub.Shutdown()
+	conn := &WebSocketConnection{roomID: "ROOM01", sessionID: "s1", send: make(chan []byte, 8)}
+	if err := hub.RegisterClient(conn); err != nil {
+		t.Fatalf("注册连接失败: %v", err)
+	}
+
+	conn.room.call(func(r *Room) {
+		r.state = PlaybackState{CurrentTime: 0, IsPlaying: true, PlaybackRate: 1, LastUpdated: time.Now().UnixMilli() - 10000}
+	})
+	// 丢弃注册时下发的房间状态和加入通知
+	for len(conn.send) > 0 {
+		<-conn.send
+	}
+
+	// 客户端时钟比服务器慢 5 秒，已完成对时
+	conn.calculateSmoothedRTT(40)
+	conn.SetTimeOffset(5000)
+	clientNow := time.Now().UnixMilli() - 5000
+
+	sync := func(position float64) {
+		msg, _ := json.Marshal(SyncMessage{
+			Type:   MsgTypeSync,
+			RoomID: "ROOM01",
+			Data:   SyncData{CurrentTime: position, IsPlaying: true, PlaybackRate: 1, ClientTime: clientNow},
+		})
+		hub.handleSync(conn, msg)
+	}
+
+	sync(10)
+	select {
+	case msg := <-conn.send:
+		t.Fatalf("位置一致时不应该下发同步指令: %s", msg)
+	default:
+	}
+
+	sync(5)
+	select {
+	case msg := <-conn.send:
+		var action SyncAction
+		json.Unmarshal(msg, &action)
+		if action.Type != "seek" || !almostEqual(action.TargetTime, 10.02) {
+			t.Errorf("期望跳转到约 10s，实际: %+v", action)
+		}
+	default:
+		t.Fatal("落后 5 秒应该下发跳转指令")
+	}
+}
+
+// 测试携带过期版本号的播放控制被拒绝，不改变房间状态
+func TestHub_StaleVersionRejected(t *testing.T) {
+	db := newFakeDB()
+	hub := newInstance(t, db, HubOptions{})
+	client := newTestClient("ROOM01", "s1", 16, true)
+	hub.RegisterClient(client.conn)
+
+	hub.HandleMessage(client.conn, []byte(`{"type":"play","start_time":12.5,"base_version":0}`))
+	waitFor(t, "收到播放指令", func() bool { return client.has("play") })
+
+	hub.HandleMessage(client.conn, []byte(`{"type":"pause","base_version":0}`))
+	waitFor(t, "收到版本冲突", func() bool {
+		return client.find(MsgTypeError, func(msg map[string]interface{}) bool { return msg["code"] == "version_conflict" })
+	})
+	if room := db.stored("ROOM01"); room.Version != 1 || room.PlaybackState != model.PlaybackPlaying {
+		t.Errorf("版本冲突不应改变房间状态: version=%d state=%s", room.Version, room.PlaybackState)
+	}
+}
+
+// 测试倒计时开播下发预约时间，生效前不纠正客户端进度
+func TestHub_CountdownScheduled(t *testing.T) {
+	hub := newTestHub(t)
+	client := newTestClient("ROOM01", "s1", 16, true)
+	hub.RegisterClient(client.conn)
+
+	before := time.Now().UnixMilli()
+	hub.HandleMessage(client.conn, []byte(`{"type":"countdown","seconds":3,"start_time":42}`))
+	waitFor(t, "收到倒计时指令", func() bool {
+		return client.find(MsgTypeCountdown, func(msg map[string]interface{}) bool {
+			executeAt, _ := msg["execute_at"].(float64)
+			return int64(executeAt) >= before+3000 && msg["current_time"] == float64(42)
+		})
+	})
+
+	hub.HandleMessage(client.conn, []byte(`{"type":"sync","data":{"current_time":0}}`))
+	if client.has("seek") {
+		t.Error("倒计时结束前不应下发同步跳转")
+	}
+
+	// 倒计时期间加入的成员同样收到预约时间，不会提前开始播放
+	late := newTestClient("ROOM01", "s2", 16, true)
+	hub.RegisterClient(late.conn)
+	waitFor(t, "后加入的成员收到预约时间", func() bool {
+		return late.find("room_state", func(msg map[string]interface{}) bool {
+			executeAt, _ := msg["execute_at"].(float64)
+			state, _ := msg["state"].(map[string]interface{})
+			return int64(executeAt) >= before+3000 && state["last_updated"] == executeAt && state["current_time"] == float64(42)
+		})
+	})
+}
+
+// 测试往返时延由服务器的探测 ping/pong 测量，对时不采信客户端上报的时延
+func TestCalibration_ServerMeasuredRTT(t *testing.T) {
+	hub := NewWebSocketHub(HubOptions{})
+	defer hub.Shutdown()
+	conn := &WebSocketConnection{roomID: "ROOM01", sessionID: "s1", send: make(chan []byte, 8)}
+
+	t.Run("TestUnsolicitedPongIgnored", func(t *testing.T) {
+		conn.recordPong(time.Now())
+		if rtt := conn.SmoothedRTT(); rtt != 0 {
+			t.Errorf("没有探测中的 ping 时不应记录时延，实际: %d", rtt)
+		}
+	})
+
+	t.Run("TestPongMeasuresRTT", func(t *testing.T) {
+		now := time.Now()
+		conn.pingSentAt = now.Add(-80 * time.Millisecond)
+		conn.recordPong(now)
+		if rtt := conn.SmoothedRTT(); rtt != 80 {
+			t.Errorf("期望时延: 80, 实际: %d", rtt)
+		}
+		if !conn.pingSentAt.IsZero() {
+			t.Error("收到 pong 后应清除探测记录")
+		}
+	})
+
+	t.Run("TestSampleCapped", func(t *testing.T) {
+		capped := &WebSocketConnection{}
+		now := time.Now()
+		capped.pingSentAt = now.Add(-time.Minute)
+		capped.recordPong(now)
+		if rtt := capped.SmoothedRTT(); rtt != maxRTT.Milliseconds() {
+			t.Errorf("期望时延上限: %d, 实际: %d", maxRTT.Milliseconds(), rtt)
+		}
+	})
+
+	t.Run("TestClientRTTIgnored", func(t *testing.T) {
+		clientNow := time.Now().UnixMilli() - 5000
+		msg, _ := json.Marshal(map[string]interface{}{
+			"type":             MsgTypePing,
+			"purpose":          "calibration",
+			"client_send_time": clientNow,
+			"rtt":              5000,
+		})
+		hub.handlePing(conn, msg)
+
+		if rtt := conn.SmoothedRTT(); rtt != 80 {
+			t.Errorf("客户端上报的时延不应生效，期望: 80, 实际: %d", rtt)
+		}
+		if offset := conn.GetAdjustedTime(clientNow) - time.Now().UnixMilli(); offset < -100 || offset > 100 {
+			t.Errorf("时钟偏移应按服务器测得的时延计算，误差: %dms", offset)
+		}
+		if len(conn.send) != 1 {
+			t.Errorf("对时应回复 pong，实际消息数: %d", len(conn.send))
+		}
+	})
+}
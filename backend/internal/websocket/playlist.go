@@ -0,0 +1,176 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"xiaowo/backend/internal/model"
+)
+
+// PlaylistStore 播放列表存储（由 service.PlaylistService 实现）
+type PlaylistStore interface {
+	GetPlaylist(roomID string) (*model.Playlist, error)
+	AddItem(roomID, sessionID string, item *model.PlaylistItem, baseVersion *int) (*model.Playlist, error)
+	RemoveItem(roomID, itemID string, baseVersion *int) (*model.Playlist, error)
+	MoveItem(roomID, itemID string, position int, baseVersion *int) (*model.Playlist, error)
+	SetCurrent(roomID, itemID string, baseVersion *int) (*model.Playlist, error)
+	Next(roomID string, baseVersion *int) (*model.Playlist, error)
+	Previous(roomID string, baseVersion *int) (*model.Playlist, error)
+	Advance(roomID string, playbackVersion int) error
+}
+
+// PlaylistMessage 播放列表操作消息
+//
+// playlist_add 使用 media_* 字段；playlist_remove、playlist_set 使用 item_id；
+// playlist_move 使用 item_id 和 position；playlist_next、playlist_prev 只需要 base_version。
+type PlaylistMessage struct {
+	Type          string  `json:"type"`                     // 操作类型
+	RoomID        string  `json:"room_id"`                  // 房间ID
+	ItemID        string  `json:"item_id,omitempty"`        // 条目ID
+	Position      int     `json:"position,omitempty"`       // 目标位置（从0开始）
+	MediaURL      string  `json:"media_url,omitempty"`      // 媒体资源URL
+	MediaType     string  `json:"media_type,omitempty"`     // 媒体类型
+	MediaTitle    string  `json:"media_title,omitempty"`    // 媒体标题
+	MediaDuration float64 `json:"media_duration,omitempty"` // 媒体总时长（秒）
+	BaseVersion   *int    `json:"base_version,omitempty"`   // 基础列表版本号（乐观锁）
+}
+
+// handlePlaylist 处理播放列表操作消息，成功后由 PublishPlaylist 广播
+func (h *WebSocketHub) handlePlaylist(conn *WebSocketConnection, msgType string, message []byte) {
+	var msg PlaylistMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		h.sendError(conn, "playlist_failed", "播放列表消息格式错误")
+		return
+	}
+
+	if h.playlist == nil {
+		h.sendError(conn, "playlist_failed", "服务器未配置播放列表")
+		return
+	}
+
+	var latest *model.Playlist
+	var err error
+	switch msgType {
+	case MsgTypePlaylistAdd:
+		latest, err = h.playlist.AddItem(conn.roomID, conn.sessionID, &model.PlaylistItem{
+			MediaURL:      msg.MediaURL,
+			MediaType:     msg.MediaType,
+			MediaTitle:    msg.MediaTitle,
+			MediaDuration: msg.MediaDuration,
+		}, msg.BaseVersion)
+	case MsgTypePlaylistRemove:
+		latest, err = h.playlist.RemoveItem(conn.roomID, msg.ItemID, msg.BaseVersion)
+	case MsgTypePlaylistMove:
+		latest, err = h.playlist.MoveItem(conn.roomID, msg.ItemID, msg.Position, msg.BaseVersion)
+	case MsgTypePlaylistSet:
+		latest, err = h.playlist.SetCurrent(conn.roomID, msg.ItemID, msg.BaseVersion)
+	case MsgTypePlaylistNext:
+		latest, err = h.playlist.Next(conn.roomID, msg.BaseVersion)
+	case MsgTypePlaylistPrev:
+		latest, err = h.playlist.Previous(conn.roomID, msg.BaseVersion)
+	}
+
+	switch {
+	case errors.Is(err, model.ErrVersionConflict):
+		// 版本冲突时把最新列表发给发起方，由客户端重新基于新版本操作
+		h.sendError(conn, "version_conflict", "播放列表已被其他成员修改")
+		conn.room.post(func(r *Room) {
+			if latest != nil {
+				r.applyPlaylist(latest)
+			}
+			if r.playlist != nil {
+				conn.sendJSON("playlist", playlistMessage(r.playlist))
+			}
+		})
+	case errors.Is(err, model.ErrPlaylistItemNotFound):
+		h.sendError(conn, "playlist_failed", "播放列表条目不存在")
+	case errors.Is(err, model.ErrPlaylistEnd):
+		h.sendError(conn, "playlist_failed", "没有可切换的条目")
+	case errors.Is(err, model.ErrPlaylistFull):
+		h.sendError(conn, "playlist_failed", "播放列表已满")
+	case errors.Is(err, model.ErrInvalidMediaURL):
+		h.sendError(conn, "playlist_failed", "无效的媒体地址")
+	case err != nil:
+		h.sendError(conn, "playlist_failed", "播放列表更新失败")
+	}
+}
+
+// PublishPlaylist 播放列表变更回调，更新缓存并广播给所有实例上房间内的连接
+//
+// 注册到 service.PlaylistService.OnPlaylistChange。
+func (h *WebSocketHub) PublishPlaylist(playlist *model.Playlist) {
+	if room := h.lookupRoom(playlist.RoomID); room != nil {
+		room.post(playlistOp(playlist))
+	}
+	h.publish(eventPlaylist, playlist.RoomID, int64(playlist.Version), "", playlist)
+}
+
+// advancePlaylist 房间当前条目播放结束，通过存储切换到下一条目（在定时器协程中执行）
+//
+// 每个有连接的实例都会触发，存储按播放版本号只接受其中一次；期间播放状态已变化时同样被拒绝。
+func (h *WebSocketHub) advancePlaylist(roomID string, version int64) {
+	err := h.playlist.Advance(roomID, int(version))
+	if err != nil && !errors.Is(err, model.ErrVersionConflict) && !errors.Is(err, model.ErrInvalidPlaybackState) {
+		log.Printf("playlist: advance room %s: %v", roomID, err)
+	}
+}
+
+// playlistOp 更新播放列表缓存并广播，忽略比缓存旧的版本
+func playlistOp(playlist *model.Playlist) roomOp {
+	return func(r *Room) {
+		if r.applyPlaylist(playlist) {
+			r.broadcast(playlistMessage(playlist))
+		}
+	}
+}
+
+// playlistMessage 播放列表推送消息
+func playlistMessage(playlist *model.Playlist) map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "playlist",
+		"room_id":  playlist.RoomID,
+		"playlist": playlist,
+	}
+}
+
+// applyPlaylist 刷新播放列表缓存，忽略不比缓存新的版本（仅在房间协程中调用）
+func (r *Room) applyPlaylist(playlist *model.Playlist) bool {
+	if r.playlist != nil && playlist.Version <= r.playlist.Version {
+		return false
+	}
+	r.playlist = playlist
+	return true
+}
+
+// scheduleEnd 按当前播放状态重设播完定时器（仅在房间协程中调用）
+//
+// 正在播放且时长已知时，在推算位置到达结尾的时刻回调 onEnded，并带上当时的版本号。
+func (r *Room) scheduleEnd() {
+	r.stopEnd()
+	state := r.state
+	if r.onEnded == nil || !state.IsPlaying || state.Duration <= 0 || state.rate() <= 0 {
+		return
+	}
+
+	remaining := state.Duration - state.CurrentTime
+	if remaining < 0 {
+		remaining = 0
+	}
+	endAt := state.LastUpdated + int64(remaining/state.rate()*1000)
+	delay := time.Duration(endAt-time.Now().UnixMilli()) * time.Millisecond
+
+	version, onEnded := r.version, r.onEnded
+	r.endTimer = time.AfterFunc(delay, func() {
+		onEnded(version)
+	})
+}
+
+// stopEnd 停止播完定时器（仅在房间协程中调用）
+func (r *Room) stopEnd() {
+	if r.endTimer != nil {
+		r.endTimer.Stop()
+		r.endTimer = nil
+	}
+}
@@ -0,0 +1,81 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"xiaowo/backend/internal/model"
+)
+
+// 测试播完定时器按推算的结尾时刻触发，并随播放状态重设
+func TestRoom_EndTimer(t *testing.T) {
+	newEndRoom := func(t *testing.T) (*Room, chan int64) {
+		ended := make(chan int64, 4)
+		room := newRoom("ROOM01")
+		room.onEnded = func(version int64) { ended <- version }
+		room.start()
+		t.Cleanup(room.stop)
+		return room, ended
+	}
+	playing := func(position, duration, rate float64) PlaybackState {
+		return PlaybackState{CurrentTime: position, Duration: duration, IsPlaying: true, PlaybackRate: rate, LastUpdated: time.Now().UnixMilli()}
+	}
+
+	t.Run("TestFiresAtEnd", func(t *testing.T) {
+		room, ended := newEndRoom(t)
+		start := time.Now()
+		room.call(func(r *Room) { r.applyState(playing(9.7, 10, 1.5), 3) })
+
+		select {
+		case version := <-ended:
+			if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+				t.Errorf("定时器触发过早: %s", elapsed)
+			}
+			if version != 3 {
+				t.Errorf("期望版本: 3, 实际: %d", version)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("播放到结尾后应触发回调")
+		}
+	})
+
+	t.Run("TestResetByNewState", func(t *testing.T) {
+		room, ended := newEndRoom(t)
+		room.call(func(r *Room) { r.applyState(playing(9.8, 10, 1), 1) })
+		paused := playing(9.8, 10, 1)
+		paused.IsPlaying = false
+		room.call(func(r *Room) { r.applyState(paused, 2) })
+
+		select {
+		case version := <-ended:
+			t.Errorf("暂停后不应触发回调，收到版本: %d", version)
+		case <-time.After(400 * time.Millisecond):
+		}
+	})
+
+	t.Run("TestUnknownDurationIgnored", func(t *testing.T) {
+		room, ended := newEndRoom(t)
+		room.call(func(r *Room) { r.applyState(playing(0, 0, 1), 1) })
+		room.stop()
+
+		select {
+		case <-ended:
+			t.Error("时长未知时不应触发回调")
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("TestNewerPlaylistOnly", func(t *testing.T) {
+		room, _ := newEndRoom(t)
+		var applied []bool
+		room.call(func(r *Room) {
+			applied = append(applied,
+				r.applyPlaylist(&model.Playlist{Version: 2}),
+				r.applyPlaylist(&model.Playlist{Version: 1}),
+				r.applyPlaylist(&model.Playlist{Version: 3}))
+		})
+		if !applied[0] || applied[1] || !applied[2] {
+			t.Errorf("播放列表应按版本号合并: %v", applied)
+		}
+	})
+}
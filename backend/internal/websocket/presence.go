@@ -0,0 +1,190 @@
+package websocket
+
+import (
+	"encoding/json"
+	"time"
+
+	"xiaowo/backend/internal/model"
+)
+
+// presenceTouchInterval 同一连接记录心跳的最短间隔，远小于离线判定时间
+const presenceTouchInterval = 5 * time.Second
+
+// PresenceStore 会话在线状态存储（由 service.PresenceService 实现，合并后批量写入）
+type PresenceStore interface {
+	SetStatus(roomID, sessionID string, status model.UserSessionStatus)
+	Touch(roomID, sessionID string)
+}
+
+// PresenceMessage 客户端报告自己空闲（如页面切到后台）或恢复活跃
+type PresenceMessage struct {
+	Type   string `json:"type"`   // 消息类型
+	Status string `json:"status"` // "idle" | "online"
+}
+
+// handlePresence 连接报告空闲或恢复活跃，状态变化时通知所有实例上的连接
+func (h *WebSocketHub) handlePresence(conn *WebSocketConnection, message []byte) {
+	var msg PresenceMessage
+	if err := json.Unmarshal(message, &msg); err != nil ||
+		(msg.Status != string(model.StatusOnline) && msg.Status != string(model.StatusIdle)) {
+		h.sendError(conn, "presence_failed", "在线状态格式错误")
+		return
+	}
+	idle := msg.Status == string(model.StatusIdle)
+
+	var changed bool
+	conn.room.call(func(r *Room) {
+		if r.clients[conn.sessionID] != conn || r.idle[conn.sessionID] == idle {
+			return
+		}
+		changed = true
+		r.announcePresence(conn.sessionID, presenceState(idle))
+	})
+	if changed {
+		h.setPresence(conn.roomID, conn.sessionID, model.UserSessionStatus(msg.Status))
+		h.publish(eventPresence, conn.roomID, 0, conn.sessionID, PresenceMessage{Type: MsgTypePresence, Status: msg.Status})
+	}
+}
+
+// setPresence 记录会话状态，未配置在线状态存储时忽略
+func (h *WebSocketHub) setPresence(roomID, sessionID string, status model.UserSessionStatus) {
+	if h.presence != nil {
+		h.presence.SetStatus(roomID, sessionID, status)
+	}
+}
+
+// touchPresence 记录连接的心跳（任意客户端消息或 pong 帧），未配置在线状态存储时忽略
+//
+// 同一连接在 presenceTouchInterval 内只记录一次，频繁发送消息（如同步、弹幕）的连接不会逐条写入。
+func (h *WebSocketHub) touchPresence(conn *WebSocketConnection) {
+	if h.presence == nil {
+		return
+	}
+	now := time.Now()
+	conn.mu.Lock()
+	due := now.Sub(conn.lastTouch) >= presenceTouchInterval
+	if due {
+		conn.lastTouch = now
+	}
+	conn.mu.Unlock()
+	if due {
+		h.presence.Touch(conn.roomID, conn.sessionID)
+	}
+}
+
+// remotePresenceOp 其他实例上的会话报告空闲或恢复活跃
+func remotePresenceOp(sessionID, origin string, idle bool) roomOp {
+	return func(r *Room) {
+		if r.remote[sessionID] != origin || r.idle[sessionID] == idle {
+			return
+		}
+		r.announcePresence(sessionID, presenceState(idle))
+	}
+}
+
+// announcePresence 更新会话的空闲标记并广播 member_presence（仅在房间协程中调用）
+func (r *Room) announcePresence(sessionID string, state model.PresenceState) {
+	if state == model.PresenceIdle {
+		r.idle[sessionID] = true
+	} else {
+		delete(r.idle, sessionID)
+	}
+	r.broadcast(map[string]interface{}{
+		"type":       "member_presence",
+		"room_id":    r.ID,
+		"session_id": sessionID,
+		"status":     state,
+		"timestamp":  time.Now().Unix(),
+	})
+}
+
+// presence 所有实例上在线会话的状态，包括等待续接的会话（仅在房间协程中调用）
+func (r *Room) presence() map[string]model.PresenceState {
+	presence := make(map[string]model.PresenceState, r.memberCount())
+	add := func(sessionID string) {
+		presence[sessionID] = presenceState(r.idle[sessionID])
+	}
+	for sessionID := range r.clients {
+		add(sessionID)
+	}
+	for sessionID := range r.remote {
+		add(sessionID)
+	}
+	for sessionID := range r.parked {
+		add(sessionID)
+	}
+	return presence
+}
+
+// presenceState 在线会话的状态
+func presenceState(idle bool) model.PresenceState {
+	if idle {
+		return model.PresenceIdle
+	}
+	return model.PresenceOnline
+}
+
+// rosterEvent 一个实例上房间内在线的会话及其状态，供新打开房间的实例同步
+type rosterEvent struct {
+	Sessions  []string `json:"sessions"`            // 在线的会话，包括等待续接的会话
+	Idle      []string `json:"idle,omitempty"`      // 其中报告空闲的会话
+	Buffering []string `json:"buffering,omitempty"` // 其中正在缓冲的会话
+	Call      []string `json:"call,omitempty"`      // 其中已加入通话的会话
+}
+
+// replyRosterOp 回复其他实例的 roster_request，报告本实例上房间内在线的会话
+func (h *WebSocketHub) replyRosterOp() roomOp {
+	return func(r *Room) {
+		var roster rosterEvent
+		add := func(sessionID string) {
+			roster.Sessions = append(roster.Sessions, sessionID)
+			if r.idle[sessionID] {
+				roster.Idle = append(roster.Idle, sessionID)
+			}
+		}
+		for sessionID, conn := range r.clients {
+			add(sessionID)
+			if conn.buffering {
+				roster.Buffering = append(roster.Buffering, sessionID)
+			}
+			if conn.inCall {
+				roster.Call = append(roster.Call, sessionID)
+			}
+		}
+		for sessionID := range r.parked {
+			add(sessionID)
+		}
+		if len(roster.Sessions) > 0 {
+			// 房间协程不能等待 Broker
+			go h.publish(eventRoster, r.ID, 0, "", roster)
+		}
+	}
+}
+
+// rosterOp 记录其他实例报告的在线会话，同一会话已在本实例上线时以本实例为准
+func (h *WebSocketHub) rosterOp(roster rosterEvent, origin string) roomOp {
+	return func(r *Room) {
+		local := func(sessionID string) bool {
+			_, ok := r.clients[sessionID]
+			return ok || r.parked[sessionID]
+		}
+		for _, sessionID := range roster.Sessions {
+			if !local(sessionID) {
+				remoteJoinOp(sessionID, origin)(r)
+			}
+		}
+		for _, sessionID := range roster.Idle {
+			remotePresenceOp(sessionID, origin, true)(r)
+		}
+		for _, sessionID := range roster.Buffering {
+			if r.remote[sessionID] == origin {
+				h.remoteBufferingOp(sessionID, true)(r)
+			}
+		}
+		for _, sessionID := range roster.Call {
+			if r.remote[sessionID] == origin {
+				remoteCallOp(sessionID, true)(r)
+			}
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package websocket
+
+import (
+	"sync"
+	"testing"
+
+	"xiaowo/backend/internal/broker"
+	"xiaowo/backend/internal/model"
+)
+
+// fakePresence 记录写入的会话状态和心跳次数
+type fakePresence struct {
+	mu      sync.Mutex
+	status  map[string]model.UserSessionStatus
+	touches map[string]int
+}
+
+func newFakePresence() *fakePresence {
+	return &fakePresence{status: make(map[string]model.UserSessionStatus), touches: make(map[string]int)}
+}
+
+func (p *fakePresence) SetStatus(roomID, sessionID string, status model.UserSessionStatus) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status[sessionID] = status
+}
+
+func (p *fakePresence) Touch(roomID, sessionID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.touches[sessionID]++
+}
+
+func (p *fakePresence) get(sessionID string) (model.UserSessionStatus, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status[sessionID], p.touches[sessionID]
+}
+
+// presenceOf member_presence 通知是否为 sessionID 的 status
+func presenceOf(sessionID string, status model.PresenceState) func(msg map[string]interface{}) bool {
+	return func(msg map[string]interface{}) bool {
+		return msg["session_id"] == sessionID && msg["status"] == string(status)
+	}
+}
+
+// 测试连接上线、空闲、心跳和下线时写入会话状态并广播 member_presence
+func TestHub_Presence(t *testing.T) {
+	store := newFakePresence()
+	hub := newInstance(t, newFakeDB(), HubOptions{Presence: store})
+	alice := newTestClient("ROOM01", "alice", 64, true)
+	bob := newTestClient("ROOM01", "bob", 64, true)
+	for _, client := range []*testClient{alice, bob} {
+		if err := hub.RegisterClient(client.conn); err != nil {
+			t.Fatalf("注册连接失败: %v", err)
+		}
+	}
+	waitFor(t, "广播成员上线", func() bool {
+		return alice.find("member_presence", presenceOf("bob", model.PresenceOnline))
+	})
+	if status, _ := store.get("bob"); status != model.StatusOnline {
+		t.Errorf("上线后会话应为 online，实际: %q", status)
+	}
+
+	t.Run("TestIdle", func(t *testing.T) {
+		hub.HandleMessage(bob.conn, []byte(`{"type":"presence","status":"idle"}`))
+		waitFor(t, "广播成员空闲", func() bool {
+			return alice.find("member_presence", presenceOf("bob", model.PresenceIdle))
+		})
+		if status, touches := store.get("bob"); status != model.StatusIdle || touches == 0 {
+			t.Errorf("空闲后会话应为 idle 且记录心跳，实际: %q, %d", status, touches)
+		}
+
+		// 重复报告不再广播
+		hub.HandleMessage(bob.conn, []byte(`{"type":"presence","status":"idle"}`))
+		hub.HandleMessage(bob.conn, []byte(`{"type":"presence","status":"online"}`))
+		waitFor(t, "广播成员恢复活跃", func() bool {
+			return alice.count("member_presence", "bob") == 3
+		})
+	})
+
+	t.Run("TestInvalidStatus", func(t *testing.T) {
+		hub.HandleMessage(bob.conn, []byte(`{"type":"presence","status":"offline"}`))
+		waitFor(t, "收到错误", func() bool {
+			return bob.find("error", func(msg map[string]interface{}) bool { return msg["code"] == "presence_failed" })
+		})
+	})
+
+	t.Run("TestTouchThrottled", func(t *testing.T) {
+		_, before := store.get("bob")
+		for i := 0; i < 5; i++ {
+			hub.HandleMessage(bob.conn, []byte(`{"type":"ping","client_send_time":1}`))
+		}
+		if _, after := store.get("bob"); after != before {
+			t.Errorf("间隔内的消息不应重复记录心跳，期望: %d, 实际: %d", before, after)
+		}
+	})
+
+	t.Run("TestRoomState", func(t *testing.T) {
+		hub.HandleMessage(alice.conn, []byte(`{"type":"presence","status":"idle"}`))
+		carol := newTestClient("ROOM01", "carol", 64, true)
+		if err := hub.RegisterClient(carol.conn); err != nil {
+			t.Fatalf("注册连接失败: %v", err)
+		}
+		waitFor(t, "房间状态包含各成员的在线状态", func() bool {
+			return carol.find("room_state", func(msg map[string]interface{}) bool {
+				presence, _ := msg["presence"].(map[string]interface{})
+				return presence["alice"] == "idle" && presence["bob"] == "online" && presence["carol"] == "online"
+			})
+		})
+	})
+
+	t.Run("TestOffline", func(t *testing.T) {
+		hub.UnregisterClient(bob.conn)
+		waitFor(t, "广播成员离开", func() bool {
+			return alice.find("member_presence", presenceOf("bob", model.PresenceAway))
+		})
+		if status, _ := store.get("bob"); status != model.StatusOffline {
+			t.Errorf("下线后会话应为 offline，实际: %q", status)
+		}
+	})
+}
+
+// 测试空闲状态在实例之间同步
+func TestHub_PresenceMultiInstance(t *testing.T) {
+	b := broker.NewMemory()
+	t.Cleanup(func() { b.Close() })
+	db := newFakeDB()
+	hubA := newInstance(t, db, HubOptions{Broker: b, InstanceID: "A"})
+	hubB := newInstance(t, db, HubOptions{Broker: b, InstanceID: "B"})
+
+	alice := newTestClient("ROOM01", "alice", 64, true)
+	bob := newTestClient("ROOM01", "bob", 64, true)
+	if err := hubA.RegisterClient(alice.conn); err != nil {
+		t.Fatalf("注册连接失败: %v", err)
+	}
+	if err := hubB.RegisterClient(bob.conn); err != nil {
+		t.Fatalf("注册连接失败: %v", err)
+	}
+	waitFor(t, "收到其他实例的成员上线通知", func() bool {
+		return alice.find("member_presence", presenceOf("bob", model.PresenceOnline))
+	})
+
+	hubB.HandleMessage(bob.conn, []byte(`{"type":"presence","status":"idle"}`))
+	waitFor(t, "其他实例的连接收到空闲通知", func() bool {
+		return alice.find("member_presence", presenceOf("bob", model.PresenceIdle))
+	})
+
+	carol := newTestClient("ROOM01", "carol", 64, true)
+	if err := hubA.RegisterClient(carol.conn); err != nil {
+		t.Fatalf("注册连接失败: %v", err)
+	}
+	waitFor(t, "房间状态包含其他实例上会话的空闲状态", func() bool {
+		return carol.find("room_state", func(msg map[string]interface{}) bool {
+			presence, _ := msg["presence"].(map[string]interface{})
+			return presence["bob"] == "idle"
+		})
+	})
+
+	hubB.UnregisterClient(bob.conn)
+	waitFor(t, "其他实例上的会话下线后广播离开", func() bool {
+		return alice.find("member_presence", presenceOf("bob", model.PresenceAway))
+	})
+}
+
+// 测试实例新打开房间时同步其他实例上已经在线的会话
+func TestHub_PresenceRosterOnOpen(t *testing.T) {
+	b := broker.NewMemory()
+	t.Cleanup(func() { b.Close() })
+	db := newFakeDB()
+	hubA := newInstance(t, db, HubOptions{Broker: b, InstanceID: "A"})
+	hubB := newInstance(t, db, HubOptions{Broker: b, InstanceID: "B"})
+
+	// alice 在实例 B 打开房间之前上线，B 没有收到过她的 join 事件
+	alice := newTestClient("ROOM01", "alice", 64, true)
+	if err := hubA.RegisterClient(alice.conn); err != nil {
+		t.Fatalf("注册连接失败: %v", err)
+	}
+	hubA.HandleMessage(alice.conn, []byte(`{"type":"presence","status":"idle"}`))
+
+	bob := newTestClient("ROOM01", "bob", 64, true)
+	if err := hubB.RegisterClient(bob.conn); err != nil {
+		t.Fatalf("注册连接失败: %v", err)
+	}
+	waitFor(t, "新打开房间的实例收到已在线会话的空闲状态", func() bool {
+		return bob.find("member_presence", presenceOf("alice", model.PresenceIdle))
+	})
+	if online := hubB.OnlineSessions("ROOM01"); len(online) != 2 {
+		t.Errorf("在线会话应包含其他实例上的会话: %v", online)
+	}
+	waitFor(t, "已有实例收到新会话上线", func() bool { return alice.count("member_join", "bob") == 1 })
+}
@@ -0,0 +1,154 @@
+package websocket
+
+import (
+	"strconv"
+	"time"
+
+	"xiaowo/backend/internal/metrics"
+	"xiaowo/backend/internal/model"
+)
+
+// DefaultResumeWindow 默认断线后等待客户端续接的时间，超时后才通知其他成员该会话下线
+const DefaultResumeWindow = 30 * time.Second
+
+// resumeHistorySize 每个房间保留的最近广播条数，续接时缺口超出该范围则改发完整状态
+const resumeHistorySize = 256
+
+// ResumeRequest 客户端断线重连时携带的续接信息
+type ResumeRequest struct {
+	Token   string // 上一个连接收到的 resume_token
+	LastSeq int64  // 上一个连接收到的最后一条房间广播的 seq
+}
+
+// parkedSession 连接意外断开、等待续接的会话
+//
+// 等待期间会话仍占用房间的连接计数（房间不会停止），其他成员看来该会话一直在线；
+// 超时后才广播 member_leave 并通知其他实例。
+type parkedSession struct {
+	room  *Room
+	token string // 断开的连接的续接令牌
+	timer *time.Timer
+}
+
+// historyEntry 一条已广播的消息
+type historyEntry struct {
+	message []byte
+	msgType string
+	except  string // 广播时跳过的会话，补发时同样跳过
+}
+
+// park 连接意外断开后保留会话等待续接，不广播 member_leave（调用方需持有 h.mu）
+func (h *WebSocketHub) park(conn *WebSocketConnection) {
+	p := &parkedSession{room: conn.room, token: conn.resumeToken}
+	p.timer = time.AfterFunc(h.resumeWindow, func() {
+		h.expireParked(conn.sessionID, p)
+	})
+	h.parked[conn.sessionID] = p
+
+	conn.room.post(func(r *Room) {
+		if r.clients[conn.sessionID] != conn {
+			return
+		}
+		delete(r.clients, conn.sessionID)
+		r.parked[conn.sessionID] = true
+		// 通话连接已经中断，续接后需要重新加入
+		r.dropFromCall(conn)
+		// 断开的成员可能是最后一个仍在缓冲的
+		h.checkStall(r)
+	})
+}
+
+// expireParked 等待续接超时，会话下线
+func (h *WebSocketHub) expireParked(sessionID string, p *parkedSession) {
+	h.mu.Lock()
+	if h.parked[sessionID] != p {
+		h.mu.Unlock()
+		return
+	}
+	h.unpark(sessionID, p, true)
+	h.mu.Unlock()
+
+	h.setPresence(p.room.ID, sessionID, model.StatusOffline)
+	h.publish(eventLeave, p.room.ID, 0, sessionID, nil)
+}
+
+// unpark 结束等待续接并释放会话占用的连接计数，announce 表示广播 member_leave（调用方需持有 h.mu）
+func (h *WebSocketHub) unpark(sessionID string, p *parkedSession, announce bool) {
+	p.timer.Stop()
+	delete(h.parked, sessionID)
+
+	p.room.post(func(r *Room) {
+		if !r.parked[sessionID] {
+			return
+		}
+		delete(r.parked, sessionID)
+		if announce {
+			r.broadcast(presenceMessage("member_leave", r.ID, sessionID, r.memberCount()))
+			r.announcePresence(sessionID, model.PresenceAway)
+		}
+	})
+	h.release(p.room)
+}
+
+// unparkRoom 结束房间内所有会话的等待（房间被清理时调用，调用方需持有 h.mu）
+func (h *WebSocketHub) unparkRoom(room *Room) {
+	for sessionID, p := range h.parked {
+		if p.room == room {
+			p.timer.Stop()
+			delete(h.parked, sessionID)
+		}
+	}
+}
+
+// record 为广播分配房间序号并记入最近广播，返回带 seq 字段的消息（仅在房间协程中调用）
+func (r *Room) record(message []byte, msgType string, except *WebSocketConnection) []byte {
+	r.seq++
+	message = withSeq(message, r.seq)
+
+	entry := historyEntry{message: message, msgType: msgType}
+	if except != nil {
+		entry.except = except.sessionID
+	}
+	r.history[r.seq%int64(len(r.history))] = entry
+	return message
+}
+
+// replay 向续接的连接补发 lastSeq 之后的广播，缺口超出最近广播的范围时返回 false（仅在房间协程中调用）
+func (r *Room) replay(conn *WebSocketConnection, lastSeq int64) bool {
+	if lastSeq < 0 || lastSeq > r.seq || r.seq-lastSeq > int64(len(r.history)) {
+		metrics.WebSocketResumes.WithLabelValues("snapshot").Inc()
+		return false
+	}
+	metrics.WebSocketResumes.WithLabelValues("replayed").Inc()
+
+	conn.sendJSON("resumed", map[string]interface{}{
+		"type":         "resumed",
+		"room_id":      r.ID,
+		"last_seq":     lastSeq,
+		"seq":          r.seq,
+		"missed":       r.seq - lastSeq,
+		"resume_token": conn.resumeToken,
+		"members":      r.memberCount(),
+	})
+	for seq := lastSeq + 1; seq <= r.seq; seq++ {
+		entry := r.history[seq%int64(len(r.history))]
+		if entry.except != conn.sessionID {
+			conn.deliver(entry.message, entry.msgType)
+		}
+	}
+	return true
+}
+
+// withSeq 在 JSON 对象消息的开头加入 seq 字段
+func withSeq(message []byte, seq int64) []byte {
+	if len(message) < 2 || message[0] != '{' {
+		return message
+	}
+	out := make([]byte, 0, len(message)+24)
+	out = append(out, `{"seq":`...)
+	out = strconv.AppendInt(out, seq, 10)
+	if len(message) > 2 {
+		out = append(out, ',')
+	}
+	return append(out, message[1:]...)
+}
@@ -0,0 +1,173 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// newResumableClient 创建像浏览器连接一样断开后等待续接的模拟客户端
+func newResumableClient(sessionID, token string, resume *ResumeRequest) *testClient {
+	c := newTestClient("ROOM01", sessionID, 512, true)
+	c.conn.resumeToken = token
+	c.conn.resume = resume
+	return c
+}
+
+// lastSeq 收到的最后一条广播的 seq
+func (c *testClient) lastSeq() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var seq int64
+	for _, received := range c.received {
+		var msg struct {
+			Seq int64 `json:"seq"`
+		}
+		if json.Unmarshal(received, &msg) == nil && msg.Seq > seq {
+			seq = msg.Seq
+		}
+	}
+	return seq
+}
+
+// count 收到的指定类型且属于 sessionID 的消息条数
+func (c *testClient) count(msgType, sessionID string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var n int
+	for _, received := range c.received {
+		var msg struct {
+			SessionID string `json:"session_id"`
+		}
+		if messageType(received) == msgType && json.Unmarshal(received, &msg) == nil && msg.SessionID == sessionID {
+			n++
+		}
+	}
+	return n
+}
+
+// 测试断线后在等待时间内续接只补发错过的广播，其他成员看不到下线和上线
+func TestHub_Resume(t *testing.T) {
+	hub := newInstance(t, newFakeDB(), HubOptions{ResumeWindow: time.Minute})
+	alice := newTestClient("ROOM01", "alice", 512, true)
+	if err := hub.RegisterClient(alice.conn); err != nil {
+		t.Fatalf("注册连接失败: %v", err)
+	}
+	bob := newResumableClient("bob", "token-1", nil)
+	if err := hub.RegisterClient(bob.conn); err != nil {
+		t.Fatalf("注册连接失败: %v", err)
+	}
+
+	hub.HandleMessage(alice.conn, []byte(`{"type":"chat","message":"first"}`))
+	waitFor(t, "收到聊天消息", func() bool { return bob.has(MsgTypeChat) })
+	seen := bob.lastSeq()
+	if seen == 0 {
+		t.Fatal("广播应带有 seq")
+	}
+
+	// 网络中断：读协程退出，服务器并未主动关闭连接
+	hub.UnregisterClient(bob.conn)
+	hub.HandleMessage(alice.conn, []byte(`{"type":"chat","message":"missed"}`))
+	hub.HandleMessage(alice.conn, []byte(`{"type":"play","start_time":5}`))
+	waitFor(t, "其他成员收到播放指令", func() bool { return alice.has("play") })
+
+	t.Run("TestReplayMissed", func(t *testing.T) {
+		back := newResumableClient("bob", "token-2", &ResumeRequest{Token: "token-1", LastSeq: seen})
+		if err := hub.RegisterClient(back.conn); err != nil {
+			t.Fatalf("注册连接失败: %v", err)
+		}
+		waitFor(t, "续接的连接收到错过的广播", func() bool {
+			return back.has("resumed") && back.has("play") &&
+				back.find(MsgTypeChat, func(msg map[string]interface{}) bool { return msg["message"] == "missed" })
+		})
+		if back.has("room_state") || back.find(MsgTypeChat, func(msg map[string]interface{}) bool { return msg["message"] == "first" }) {
+			t.Error("续接时不应重发已收到的广播或完整状态")
+		}
+		if back.find("resumed", func(msg map[string]interface{}) bool {
+			return msg["resume_token"] != "token-2" || msg["missed"] != float64(2)
+		}) {
+			t.Error("续接通知应带上新连接的续接令牌和补发条数")
+		}
+		if alice.count("member_leave", "bob") != 0 || alice.count("member_join", "bob") != 1 {
+			t.Error("续接的会话不应产生下线和上线通知")
+		}
+		hub.UnregisterClient(back.conn)
+	})
+
+	t.Run("TestGapTooLarge", func(t *testing.T) {
+		for i := 0; i < resumeHistorySize+1; i++ {
+			hub.HandleMessage(alice.conn, []byte(`{"type":"chat","message":"flood"}`))
+		}
+		back := newResumableClient("bob", "token-3", &ResumeRequest{Token: "token-2", LastSeq: seen})
+		if err := hub.RegisterClient(back.conn); err != nil {
+			t.Fatalf("注册连接失败: %v", err)
+		}
+		waitFor(t, "缺口过大时收到完整状态", func() bool { return back.has("room_state") })
+		if back.has("resumed") {
+			t.Error("缺口超出最近广播时不应补发")
+		}
+		hub.UnregisterClient(back.conn)
+	})
+
+	t.Run("TestWrongToken", func(t *testing.T) {
+		back := newResumableClient("bob", "token-4", &ResumeRequest{Token: "token-1", LastSeq: seen})
+		if err := hub.RegisterClient(back.conn); err != nil {
+			t.Fatalf("注册连接失败: %v", err)
+		}
+		waitFor(t, "令牌不匹配时收到完整状态", func() bool { return back.has("room_state") })
+		if back.has("resumed") {
+			t.Error("令牌不匹配时不应补发")
+		}
+	})
+}
+
+// 测试等待续接超时后会话下线，服务器主动断开的连接不等待续接
+func TestHub_ResumeExpires(t *testing.T) {
+	hub := newInstance(t, newFakeDB(), HubOptions{ResumeWindow: 50 * time.Millisecond})
+	alice := newTestClient("ROOM01", "alice", 64, true)
+	bob := newResumableClient("bob", "token-1", nil)
+	for _, client := range []*testClient{alice, bob} {
+		if err := hub.RegisterClient(client.conn); err != nil {
+			t.Fatalf("注册连接失败: %v", err)
+		}
+	}
+
+	hub.UnregisterClient(bob.conn)
+	var members int
+	hub.lookupRoom("ROOM01").call(func(r *Room) { members = r.memberCount() })
+	if members != 2 {
+		t.Errorf("等待续接的会话应计入成员数，实际: %d", members)
+	}
+	waitFor(t, "超时后广播下线", func() bool {
+		return alice.find("member_leave", func(msg map[string]interface{}) bool {
+			return msg["session_id"] == "bob" && msg["member_count"] == float64(1)
+		})
+	})
+
+	carol := newResumableClient("carol", "token-2", nil)
+	if err := hub.RegisterClient(carol.conn); err != nil {
+		t.Fatalf("注册连接失败: %v", err)
+	}
+	carol.conn.close()
+	hub.UnregisterClient(carol.conn)
+	waitFor(t, "主动断开的连接立即下线", func() bool {
+		return alice.find("member_leave", func(msg map[string]interface{}) bool { return msg["session_id"] == "carol" })
+	})
+
+	hub.UnregisterClient(alice.conn)
+	if ids := hub.ActiveRoomIDs(); len(ids) != 0 {
+		t.Errorf("所有会话下线后房间应被释放，实际: %v", ids)
+	}
+}
+
+// 测试 seq 字段加在消息开头
+func TestWithSeq(t *testing.T) {
+	if got := string(withSeq([]byte(`{"type":"chat"}`), 7)); got != `{"seq":7,"type":"chat"}` {
+		t.Errorf("加入 seq 后的消息不正确: %s", got)
+	}
+	if got := string(withSeq([]byte(`{}`), 1)); got != `{"seq":1}` {
+		t.Errorf("空对象加入 seq 后的消息不正确: %s", got)
+	}
+}
@@ -0,0 +1,235 @@
+package websocket
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"xiaowo/backend/internal/model"
+)
+
+// roomQueueSize 房间待处理操作队列长度，队列满时投递方阻塞等待
+const roomQueueSize = 256
+
+// scheduleMargin 预约指令在最大往返时延之外额外留出的时间
+const scheduleMargin = 300 * time.Millisecond
+
+// roomOp 在房间协程中执行的操作
+type roomOp func(r *Room)
+
+// Room 房间连接管理
+//
+// 每个房间由独立协程串行执行操作，clients、version、state、settings 只在该协程中读写，
+// 外部通过 post（异步）或 call（同步）投递操作。房间操作只向连接的发送队列非阻塞投递，
+// 不会等待 Hub 或其他房间，因此不会形成锁环。
+type Room struct {
+	ID        string
+	clients   map[string]*WebSocketConnection
+	version   int64 // 乐观锁版本
+	state     PlaybackState
+	settings  model.RoomSettings
+	remote    map[string]string     // 连接在其他实例上的会话 → 实例ID
+	playlist  *model.Playlist       // 播放列表，未配置播放列表存储时为空
+	subtitles *model.SubtitleTracks // 字幕轨道，未配置字幕存储时为空
+
+	// 缓冲等待（见 buffering.go）
+	stall           *stall          // 本实例因成员缓冲发起的自动暂停，为空表示未在等待
+	remoteBuffering map[string]bool // 其他实例上正在缓冲的会话
+
+	// 通话（见 call.go）
+	remoteCall map[string]bool // 其他实例上已加入通话的会话
+
+	// 断线续接（见 resume.go）
+	seq     int64           // 最后一条广播的序号
+	history []historyEntry  // 最近的广播，按 seq 取模存放
+	parked  map[string]bool // 本实例上断线等待续接的会话
+
+	// 在线状态（见 presence.go）
+	idle map[string]bool // 所有实例上报告空闲的会话
+
+	// 播完自动切换（见 playlist.go）
+	endTimer *time.Timer         // 当前条目播完定时器
+	onEnded  func(version int64) // 播完时回调（在定时器协程中执行），需在 start 之前设置
+
+	// members 本实例上房间内的连接数，由 Hub 持有 h.mu 时维护，归零时房间停止
+	members int
+	// onStop 房间协程退出时调用（取消跨实例订阅），需在 start 之前设置
+	onStop func()
+
+	ops      chan roomOp
+	quit     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// newRoom 创建房间，调用 start 之前投递的操作会在启动后按顺序执行
+func newRoom(id string) *Room {
+	return &Room{
+		ID:       id,
+		clients:  make(map[string]*WebSocketConnection),
+		state:    NewPlaybackState(),
+		settings: model.DefaultRoomSettings(),
+		remote:   make(map[string]string),
+
+		remoteBuffering: make(map[string]bool),
+		remoteCall:      make(map[string]bool),
+		history:         make([]historyEntry, resumeHistorySize),
+		parked:          make(map[string]bool),
+		idle:            make(map[string]bool),
+		ops:             make(chan roomOp, roomQueueSize),
+		quit:            make(chan struct{}),
+		stopped:         make(chan struct{}),
+	}
+}
+
+// start 启动房间协程
+func (r *Room) start() {
+	go r.run()
+}
+
+// run 房间协程主循环，停止前执行完已投递的操作
+func (r *Room) run() {
+	defer close(r.stopped)
+	if r.onStop != nil {
+		defer r.onStop()
+	}
+	defer r.stopEnd()
+
+	r.scheduleEnd()
+	for {
+		select {
+		case op := <-r.ops:
+			op(r)
+		case <-r.quit:
+			for {
+				select {
+				case op := <-r.ops:
+					op(r)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// stop 停止房间协程，可重复调用
+func (r *Room) stop() {
+	r.stopOnce.Do(func() {
+		close(r.quit)
+	})
+}
+
+// post 异步投递操作，房间已停止时返回 false
+func (r *Room) post(op roomOp) bool {
+	select {
+	case <-r.quit:
+		return false
+	default:
+	}
+
+	select {
+	case r.ops <- op:
+		return true
+	case <-r.quit:
+		return false
+	}
+}
+
+// call 同步执行操作并等待完成，房间已停止且操作未执行时返回 false
+func (r *Room) call(op roomOp) bool {
+	done := make(chan struct{})
+	if !r.post(func(r *Room) {
+		op(r)
+		close(done)
+	}) {
+		return false
+	}
+
+	select {
+	case <-done:
+		return true
+	case <-r.stopped:
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// broadcast 向房间内所有连接广播消息（仅在房间协程中调用）
+func (r *Room) broadcast(data interface{}) {
+	r.broadcastOthers(data, nil)
+}
+
+// broadcastOthers 向房间内除 except 之外的连接广播消息（仅在房间协程中调用）
+//
+// 每条广播带有递增的 seq 并记入最近广播，断线续接的连接据此补发错过的消息。
+func (r *Room) broadcastOthers(data interface{}, except *WebSocketConnection) {
+	message, _ := json.Marshal(data)
+	msgType := messageType(message)
+	message = r.record(message, msgType, except)
+	for _, conn := range r.clients {
+		if conn != except {
+			conn.deliver(message, msgType)
+		}
+	}
+}
+
+// applyState 刷新播放状态缓存，忽略不比缓存新的版本（仅在房间协程中调用）
+//
+// 本实例和其他实例的变更到达顺序不确定，以存储分配的版本号为准。
+func (r *Room) applyState(state PlaybackState, version int64) bool {
+	if version <= r.version {
+		return false
+	}
+	r.state = state
+	r.version = version
+	r.scheduleEnd()
+	return true
+}
+
+// memberCount 所有实例上的在线会话数，包括等待续接的会话（仅在房间协程中调用）
+func (r *Room) memberCount() int {
+	return len(r.clients) + len(r.remote) + len(r.parked)
+}
+
+// scheduleLead 预约指令的提前量：房间内最慢连接的往返时延加安全余量（仅在房间协程中调用）
+//
+// 指令需在所有客户端收到之后才生效，按往返时延而不是单程时延估算，留出抖动的余地。
+func (r *Room) scheduleLead() time.Duration {
+	var maxRTT int64
+	for _, conn := range r.clients {
+		if rtt := conn.SmoothedRTT(); rtt > maxRTT {
+			maxRTT = rtt
+		}
+	}
+	return time.Duration(maxRTT)*time.Millisecond + scheduleMargin
+}
+
+// sendState 发送房间状态，附带最近的聊天记录和续接信息（仅在房间协程中调用）
+//
+// 倒计时等预约指令生效前加入的连接同样收到 execute_at，与其他成员在同一时刻开始播放。
+func (r *Room) sendState(conn *WebSocketConnection, messages []ChatMessage) {
+	state := r.state.Snapshot()
+	msg := map[string]interface{}{
+		"type":         "room_state",
+		"seq":          r.seq,
+		"resume_token": conn.resumeToken,
+		"state":        state,
+		"version":      r.version,
+		"settings":     r.settings,
+		"playlist":     r.playlist,
+		"subtitles":    r.subtitles,
+		"members":      r.memberCount(),
+		"presence":     r.presence(),
+		"call":         r.callParticipants(),
+		"messages":     messages,
+	}
+	if state.Scheduled(time.Now().UnixMilli()) {
+		msg["execute_at"] = state.LastUpdated
+	}
+	conn.sendJSON("room_state", msg)
+}
@@ -0,0 +1,65 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+
+	"xiaowo/backend/internal/model"
+)
+
+// SubtitleStore 字幕存储（由 service.SubtitleService 实现）
+type SubtitleStore interface {
+	GetTracks(roomID string) (*model.SubtitleTracks, error)
+	SelectSubtitle(roomID, subtitleID string) error
+}
+
+// SubtitleMessage 切换字幕消息，subtitle_id 为空时关闭字幕
+type SubtitleMessage struct {
+	Type       string `json:"type"`        // 消息类型
+	RoomID     string `json:"room_id"`     // 房间ID
+	SubtitleID string `json:"subtitle_id"` // 字幕ID
+}
+
+// handleSubtitleSet 处理切换字幕消息，成功后由 PublishSubtitles 广播
+func (h *WebSocketHub) handleSubtitleSet(conn *WebSocketConnection, message []byte) {
+	var msg SubtitleMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		h.sendError(conn, "subtitle_failed", "字幕消息格式错误")
+		return
+	}
+
+	if h.subtitles == nil {
+		h.sendError(conn, "subtitle_failed", "服务器未配置字幕")
+		return
+	}
+
+	err := h.subtitles.SelectSubtitle(conn.roomID, msg.SubtitleID)
+	switch {
+	case errors.Is(err, model.ErrSubtitleNotFound):
+		h.sendError(conn, "subtitle_failed", "字幕不存在")
+	case err != nil:
+		h.sendError(conn, "subtitle_failed", "切换字幕失败")
+	}
+}
+
+// PublishSubtitles 字幕轨道变更回调，更新缓存并广播给所有实例上房间内的连接
+//
+// 注册到 service.SubtitleService.OnSubtitleChange。
+func (h *WebSocketHub) PublishSubtitles(tracks *model.SubtitleTracks) {
+	if room := h.lookupRoom(tracks.RoomID); room != nil {
+		room.post(subtitleOp(tracks))
+	}
+	h.publish(eventSubtitle, tracks.RoomID, 0, "", tracks)
+}
+
+// subtitleOp 更新字幕缓存并广播
+func subtitleOp(tracks *model.SubtitleTracks) roomOp {
+	return func(r *Room) {
+		r.subtitles = tracks
+		r.broadcast(map[string]interface{}{
+			"type":      "subtitle_change",
+			"room_id":   tracks.RoomID,
+			"subtitles": tracks,
+		})
+	}
+}
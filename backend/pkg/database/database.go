@@ -13,8 +13,18 @@ import (
 
 var DB *gorm.DB
 
+// Options configures the database file and connection pool
+type Options struct {
+	Path            string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
 // Init initializes the SQLite database with performance optimizations
-func Init(dbPath string) error {
+func Init(opts Options) error {
+	dbPath := opts.Path
+
 	// Ensure directory exists
 	dir := filepath.Dir(dbPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -61,16 +71,16 @@ func Init(dbPath string) error {
 		return err
 	}
 
-	// SetMaxOpenConns(1) is recommended for SQLite to avoid "database is locked" errors
+	// MaxOpenConns of 1 is recommended for SQLite to avoid "database is locked" errors
 	// during concurrent write operations. WAL mode allows non-blocking reads even with this setting.
-	sqlDB.SetMaxOpenConns(1)
+	sqlDB.SetMaxOpenConns(opts.MaxOpenConns)
 	
-	// SetMaxIdleConns should match MaxOpenConns or be slightly higher to keep the connection open
-	sqlDB.SetMaxIdleConns(1)
+	// MaxIdleConns should match MaxOpenConns to keep the connection open
+	sqlDB.SetMaxIdleConns(opts.MaxIdleConns)
 	
-	// SetConnMaxLifetime sets the maximum amount of time a connection may be reused
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	// ConnMaxLifetime sets the maximum amount of time a connection may be reused
+	sqlDB.SetConnMaxLifetime(opts.ConnMaxLifetime)
 
-	log.Println("Database initialized successfully with WAL mode and single-writer configuration")
+	log.Printf("Database initialized successfully with WAL mode (max_open_conns=%d)", opts.MaxOpenConns)
 	return nil
 }